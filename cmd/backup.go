@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/backup"
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/volume"
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [project]",
+	Short: "Back up OtterStack state and named volumes",
+	Long: `Back up project registrations, deployment history and env files into a
+single zstd-compressed tar archive with a manifest.
+
+The state database is copied with the SQLite online backup API, so backups
+can be taken while deployments run. With a project name, the archive
+restores only that project. Use --volumes to also archive the project's
+named Docker volumes; each volume is read by a throwaway helper container.
+
+The archive contains environment variables in plain text: store it securely.
+Requires the zstd command.
+
+Examples:
+  otterstack backup
+  otterstack backup myapp --volumes
+  otterstack backup -f /mnt/backups/otterstack.tar.zst`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runBackup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "Restore OtterStack state and named volumes from a backup",
+	Long: `Restore an archive written by "otterstack backup".
+
+An archive of every project replaces the state database; the current one is
+kept next to it as otterstack.db.pre-restore-<time>. An archive of a single
+project adds that project to the current database. Either way, existing
+projects are only replaced with --force.
+
+Volumes are restored only with --volumes. A volume that already exists is
+overwritten only with --force, and never while a running container uses it.
+
+Repositories of remote projects are cloned again if they are missing.
+Containers are not started: deploy each project to bring it back up.
+
+Examples:
+  otterstack restore ~/.otterstack/backups/otterstack-20261018-120000.tar.zst
+  otterstack restore myapp-backup.tar.zst --volumes`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestore,
+}
+
+var (
+	backupFileFlag     string
+	backupVolumesFlag  bool
+	restoreVolumesFlag bool
+	restoreForceFlag   bool
+)
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+
+	backupCmd.Flags().StringVarP(&backupFileFlag, "file", "f", "", "archive path (default <data-dir>/backups/otterstack-[project-]<time>.tar.zst)")
+	backupCmd.Flags().BoolVar(&backupVolumesFlag, "volumes", false, "also back up the named volumes of the projects")
+
+	restoreCmd.Flags().BoolVar(&restoreVolumesFlag, "volumes", false, "also restore the named volumes in the archive")
+	restoreCmd.Flags().BoolVar(&restoreForceFlag, "force", false, "replace existing projects and volumes")
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var projects []*state.Project
+	var projectName string
+	if len(args) == 1 {
+		projectName = args[0]
+		project, err := store.GetProject(ctx, projectName)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrProjectNotFound) {
+				return projectNotFound(projectName)
+			}
+			return err
+		}
+		projects = []*state.Project{project}
+	} else {
+		projects, err = store.ListProjects(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list projects: %w", err)
+		}
+	}
+
+	dest := backupFileFlag
+	if dest == "" {
+		name := "otterstack-"
+		if projectName != "" {
+			name += projectName + "-"
+		}
+		dest = filepath.Join(dataDir, "backups", name+time.Now().UTC().Format("20060102-150405")+".tar.zst")
+	}
+
+	// Stage next to the archives rather than in /tmp, which is often too small for volumes
+	backupsDir := filepath.Join(dataDir, "backups")
+	if err := os.MkdirAll(backupsDir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(backupsDir, ".staging-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	schemaVersion, err := store.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	manifest := &backup.Manifest{
+		FormatVersion:     backup.FormatVersion,
+		CreatedAt:         time.Now().UTC(),
+		OtterStackVersion: Version,
+		SchemaVersion:     schemaVersion,
+		Project:           projectName,
+	}
+
+	// State database
+	printVerbose("Copying state database...")
+	if err := store.Backup(ctx, filepath.Join(staging, state.DatabaseFile)); err != nil {
+		return err
+	}
+	manifest.Entries = append(manifest.Entries, backup.Entry{Path: state.DatabaseFile, Kind: backup.KindDatabase})
+
+	// Env files and volumes
+	for _, p := range projects {
+		envPath := filepath.Join(dataDir, "envfiles", p.Name+".env")
+		if _, err := os.Stat(envPath); err == nil {
+			entryPath := "envfiles/" + p.Name + ".env"
+			if err := copyFile(envPath, filepath.Join(staging, filepath.FromSlash(entryPath))); err != nil {
+				return err
+			}
+			manifest.Entries = append(manifest.Entries, backup.Entry{Path: entryPath, Kind: backup.KindEnvFile, Project: p.Name})
+		}
+
+		if !backupVolumesFlag {
+			continue
+		}
+		volumes, err := volume.ProjectVolumes(ctx, p.Name)
+		if err != nil {
+			return err
+		}
+		for _, v := range volumes {
+			fmt.Printf("Backing up volume %s...\n", v.Name)
+			entryPath := "volumes/" + v.Name + ".tar"
+			if err := exportVolume(ctx, v.Name, filepath.Join(staging, filepath.FromSlash(entryPath))); err != nil {
+				return err
+			}
+			manifest.Entries = append(manifest.Entries, backup.Entry{Path: entryPath, Kind: backup.KindVolume, Project: p.Name, Volume: &v})
+		}
+	}
+
+	printVerbose("Writing archive...")
+	if err := backup.Write(ctx, dest, manifest, staging); err != nil {
+		return err
+	}
+
+	size := int64(0)
+	if info, err := os.Stat(dest); err == nil {
+		size = info.Size()
+	}
+	fmt.Printf("✓ Backup written to %s (%s)\n", dest, formatBytes(size))
+	fmt.Printf("  Projects:  %d\n", len(projects))
+	fmt.Printf("  Env files: %d\n", len(manifest.EntriesOf(backup.KindEnvFile)))
+	fmt.Printf("  Volumes:   %d\n", len(manifest.EntriesOf(backup.KindVolume)))
+
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	archive := args[0]
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0750); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(dataDir, ".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	printVerbose("Extracting %s...", archive)
+	manifest, err := backup.Extract(ctx, archive, staging)
+	if err != nil {
+		return err
+	}
+
+	scope := "all projects"
+	if manifest.Project != "" {
+		scope = "project " + manifest.Project
+	}
+	fmt.Printf("Restoring backup of %s from %s (otterstack %s)\n",
+		scope, manifest.CreatedAt.Local().Format("2006-01-02 15:04"), manifest.OtterStackVersion)
+
+	// Bring the archived database up to this version's schema; a newer schema is refused
+	archived, err := state.New(staging)
+	if err != nil {
+		return fmt.Errorf("cannot restore archived database: %w", err)
+	}
+	var projects []*state.Project
+	if manifest.Project != "" {
+		var project *state.Project
+		project, err = archived.GetProject(ctx, manifest.Project)
+		projects = []*state.Project{project}
+	} else {
+		projects, err = archived.ListProjects(ctx)
+	}
+	archived.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read archived projects: %w", err)
+	}
+	archivedDB := filepath.Join(staging, state.DatabaseFile)
+
+	// Check for conflicts before changing anything
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	existing, err := store.ListProjects(ctx)
+	store.Close()
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+	if !restoreForceFlag {
+		for _, p := range projects {
+			for _, e := range existing {
+				if e.Name == p.Name {
+					return apperrors.WithMessage(apperrors.ErrProjectExists, fmt.Sprintf("project %q already exists (use --force to replace it)", p.Name))
+				}
+			}
+		}
+		if manifest.Project == "" && len(existing) > 0 {
+			return fmt.Errorf("data directory already has %d project(s) (use --force to replace the database)", len(existing))
+		}
+	}
+
+	volumes := manifest.EntriesOf(backup.KindVolume)
+	if restoreVolumesFlag {
+		if err := checkVolumeConflicts(ctx, volumes, restoreForceFlag); err != nil {
+			return err
+		}
+	}
+
+	// State database
+	if manifest.Project == "" {
+		previous, err := state.ReplaceDatabase(dataDir, archivedDB, "pre-restore-"+time.Now().UTC().Format("20060102-150405"))
+		if err != nil {
+			return err
+		}
+		if previous != "" {
+			fmt.Printf("Previous database saved to %s\n", previous)
+		}
+	} else {
+		store, err := initStore()
+		if err != nil {
+			return err
+		}
+		err = store.ImportProject(ctx, archivedDB, manifest.Project, restoreForceFlag)
+		store.Close()
+		if err != nil {
+			return fmt.Errorf("failed to restore project %s: %w", manifest.Project, err)
+		}
+	}
+	fmt.Printf("✓ Restored %d project(s)\n", len(projects))
+
+	// Env files
+	envFiles := manifest.EntriesOf(backup.KindEnvFile)
+	for _, e := range envFiles {
+		dest := filepath.Join(dataDir, "envfiles", filepath.Base(e.Path))
+		if err := copyFile(filepath.Join(staging, filepath.FromSlash(e.Path)), dest); err != nil {
+			return err
+		}
+	}
+	if len(envFiles) > 0 {
+		fmt.Printf("✓ Restored %d env file(s)\n", len(envFiles))
+	}
+
+	// Volumes
+	if restoreVolumesFlag {
+		for _, e := range volumes {
+			fmt.Printf("Restoring volume %s...\n", e.Volume.Name)
+			if err := importVolume(ctx, *e.Volume, filepath.Join(staging, filepath.FromSlash(e.Path))); err != nil {
+				return err
+			}
+		}
+		if len(volumes) > 0 {
+			fmt.Printf("✓ Restored %d volume(s)\n", len(volumes))
+		}
+	} else if len(volumes) > 0 {
+		fmt.Printf("Archive contains %d volume(s); rerun with --volumes to restore them.\n", len(volumes))
+	}
+
+	// Repositories
+	store, err = initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	for _, p := range projects {
+		if err := restoreRepository(ctx, store, dataDir, p); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", p.Name, err)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Restore complete. Start each project with: otterstack deploy <project>")
+	return nil
+}
+
+// checkVolumeConflicts refuses to overwrite volumes that exist unless force
+// is set, and volumes that running containers use in any case.
+func checkVolumeConflicts(ctx context.Context, entries []backup.Entry, force bool) error {
+	for _, e := range entries {
+		exists, err := volume.Exists(ctx, e.Volume.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if !force {
+			return fmt.Errorf("volume %s already exists (use --force to overwrite it)", e.Volume.Name)
+		}
+		inUse, err := volume.InUse(ctx, e.Volume.Name)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return fmt.Errorf("volume %s is used by a running container; stop the project before restoring it", e.Volume.Name)
+		}
+	}
+	return nil
+}
+
+// restoreRepository points a restored remote project at this data directory
+// and clones its repository if it is missing. Local repositories cannot be
+// recreated, so a missing one is reported.
+func restoreRepository(ctx context.Context, store *state.Store, dataDir string, p *state.Project) error {
+	if p.RepoType != "remote" {
+		if _, err := os.Stat(p.RepoPath); err != nil {
+			return fmt.Errorf("local repository %s is missing; restore it before deploying", p.RepoPath)
+		}
+		return nil
+	}
+
+	repoPath := filepath.Join(dataDir, "repos", p.Name)
+	if repoPath != p.RepoPath {
+		if err := store.UpdateProjectRepoPath(ctx, p.Name, repoPath); err != nil {
+			return err
+		}
+	}
+
+	gitMgr := git.NewManager(repoPath)
+	if gitMgr.IsGitRepo(ctx) {
+		return nil
+	}
+	fmt.Printf("Cloning repository %s for %s...\n", p.RepoURL, p.Name)
+	if err := gitMgr.Clone(ctx, p.RepoURL); err != nil {
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+	return nil
+}
+
+// exportVolume writes the contents of a volume to a tar file.
+func exportVolume(ctx context.Context, name, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	defer f.Close()
+
+	if err := volume.Export(ctx, name, f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// importVolume replaces the contents of a volume with a tar file.
+func importVolume(ctx context.Context, v volume.Volume, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	defer f.Close()
+
+	return volume.Import(ctx, v, f)
+}
+
+// copyFile copies src to dest with owner-only permissions, creating parent directories.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// formatBytes formats a size with a binary unit, e.g. "12.3 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
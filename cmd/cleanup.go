@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -10,6 +11,9 @@ import (
 	"github.com/jayteealao/otterstack/internal/compose"
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/oplog"
+	"github.com/jayteealao/otterstack/internal/target"
 	"github.com/spf13/cobra"
 )
 
@@ -23,8 +27,12 @@ This command:
 2. Removes orphaned worktrees not referenced by any deployment
 3. Stops containers from failed deployments
 4. Prunes git worktree references
-5. Removes orphaned repositories (cloned but not tracked)`,
-	RunE: runCleanup,
+5. Removes orphaned repositories (cloned but not tracked)
+
+With --output json or yaml, progress goes to stderr and what was found (or,
+with --dry-run, would be cleaned) is written to stdout as a CleanupReport.`,
+	RunE:        runCleanup,
+	Annotations: structuredOutput,
 }
 
 var (
@@ -37,7 +45,7 @@ func init() {
 	cleanupCmd.Flags().BoolVar(&cleanupDryRunFlag, "dry-run", false, "show what would be cleaned without making changes")
 }
 
-func runCleanup(cmd *cobra.Command, args []string) error {
+func runCleanup(cmd *cobra.Command, args []string) (retErr error) {
 	ctx := cmd.Context()
 
 	store, err := initStore()
@@ -51,36 +59,65 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Println("Starting cleanup...")
+	projects, err := store.ListProjects(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	// Record the full output of the cleanup against every project it touches
+	rec, err := oplog.Create(store, oplog.Path(dataDir, "", oplog.OperationCleanup, time.Now()), oplog.OperationCleanup)
+	if err != nil {
+		printVerbose("Warning: cleanup output will not be recorded: %v", err)
+	}
+	for _, project := range projects {
+		if err := rec.Track(ctx, project.ID); err != nil {
+			printVerbose("Warning: failed to record cleanup for %s: %v", project.Name, err)
+		}
+	}
+	defer func() {
+		if err := rec.Finish(context.WithoutCancel(ctx), retErr); err != nil {
+			printVerbose("Warning: failed to record cleanup outcome: %v", err)
+		}
+	}()
+	out := operationOutput{rec: rec, log: logging.FromContext(ctx)}
+	report := cleanupReport{
+		DryRun:                  cleanupDryRunFlag,
+		InterruptedDeployments:  []historyEntry{},
+		OrphanedProjectDirs:     []string{},
+		OrphanedComposeProjects: []orphanedComposeProject{},
+		OrphanedRepositories:    []orphanedRepository{},
+	}
+	if isStructuredOutput() {
+		out.stdout = os.Stderr
+	}
+
+	out.Printf("Starting cleanup...")
 	if cleanupDryRunFlag {
-		fmt.Println("(dry run mode - no changes will be made)")
+		out.Printf("(dry run mode - no changes will be made)")
 	}
-	fmt.Println()
+	out.Printf("")
 
 	// 1. Mark interrupted deployments as failed
-	fmt.Println("Checking for interrupted deployments...")
+	out.Printf("Checking for interrupted deployments...")
 	interrupted, err := store.GetInterruptedDeployments(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get interrupted deployments: %w", err)
 	}
 
 	for _, d := range interrupted {
-		fmt.Printf("  Found interrupted deployment: %s (status: %s)\n", git.ShortSHA(d.GitSHA), d.Status)
+		out.Printf("  Found interrupted deployment: %s (status: %s)", git.ShortSHA(d.GitSHA), d.Status)
+		report.InterruptedDeployments = append(report.InterruptedDeployments, newHistoryEntry(d))
 		if !cleanupDryRunFlag {
 			errMsg := "marked as interrupted during cleanup"
 			if err := store.UpdateDeploymentStatus(ctx, d.ID, "interrupted", &errMsg); err != nil {
-				fmt.Fprintf(os.Stderr, "    Warning: failed to update status: %v\n", err)
+				out.Warnf("    Warning: failed to update status: %v", err)
 			}
 		}
 	}
-	fmt.Println()
+	out.Printf("")
 
 	// 2. Get all projects and clean up orphaned worktrees
-	fmt.Println("Checking for orphaned worktrees...")
-	projects, err := store.ListProjects(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list projects: %w", err)
-	}
+	out.Printf("Checking for orphaned worktrees...")
 
 	worktreesDir := filepath.Join(dataDir, "worktrees")
 	if _, err := os.Stat(worktreesDir); err == nil {
@@ -100,59 +137,79 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 			// Check if project exists in database
 			_, err := store.GetProject(ctx, projectName)
 			if errors.Is(err, apperrors.ErrProjectNotFound) {
-				fmt.Printf("  Found orphaned project directory: %s\n", projectName)
+				out.Printf("  Found orphaned project directory: %s", projectName)
+				report.OrphanedProjectDirs = append(report.OrphanedProjectDirs, filepath.Join(worktreesDir, projectName))
 				if !cleanupDryRunFlag {
 					orphanDir := filepath.Join(worktreesDir, projectName)
 					if err := os.RemoveAll(orphanDir); err != nil {
-						fmt.Fprintf(os.Stderr, "    Warning: failed to remove: %v\n", err)
+						out.Warnf("    Warning: failed to remove: %v", err)
 					}
 				}
 				continue
 			}
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "  Warning: failed to check project %s: %v\n", projectName, err)
+				out.Warnf("  Warning: failed to check project %s: %v", projectName, err)
 				continue
 			}
 
 			// TODO: Per-project worktree cleanup can be added here as a future enhancement
 		}
 	}
-	fmt.Println()
+	out.Printf("")
 
 	// 3. Stop containers from failed deployments
-	fmt.Println("Checking for orphaned containers...")
+	out.Printf("Checking for orphaned containers...")
 	for _, project := range projects {
-		// Find running compose projects that match our naming pattern
-		runningProjects, err := compose.FindRunningProjects(ctx, project.Name+"-")
+		targets, err := projectTargets(ctx, store, project, nil)
 		if err != nil {
-			printVerbose("  Warning: failed to find running projects for %s: %v", project.Name, err)
+			out.Verbosef("  Warning: failed to get hosts for %s: %v", project.Name, err)
 			continue
 		}
 
-		// Get active deployment
-		activeDeployment, err := store.GetActiveDeployment(ctx, project.ID)
-		activeProjectName := ""
-		if err == nil && activeDeployment != nil {
-			activeProjectName = compose.GenerateProjectName(project.Name, git.ShortSHA(activeDeployment.GitSHA))
+		// Get the active deployment on each host
+		active, err := store.ListActiveDeployments(ctx, project.ID)
+		if err != nil {
+			out.Verbosef("  Warning: failed to get active deployments for %s: %v", project.Name, err)
+			continue
+		}
+		activeProjectNames := make(map[string]string, len(active))
+		for _, d := range active {
+			activeProjectNames[d.Host] = compose.GenerateProjectName(project.Name, git.ShortSHA(d.GitSHA))
 		}
 
-		for _, runningProject := range runningProjects {
-			if runningProject == activeProjectName {
-				continue // Skip active deployment
+		for _, t := range targets {
+			hostCtx := target.With(ctx, t)
+			where := ""
+			if !t.IsLocal() {
+				where = " on " + t.String()
 			}
 
-			fmt.Printf("  Found orphaned compose project: %s\n", runningProject)
-			if !cleanupDryRunFlag {
-				if err := compose.StopProjectByName(ctx, runningProject, 30*time.Second); err != nil {
-					fmt.Fprintf(os.Stderr, "    Warning: failed to stop: %v\n", err)
+			// Find running compose projects that match our naming pattern
+			runningProjects, err := compose.FindRunningProjects(hostCtx, project.Name+"-")
+			if err != nil {
+				out.Verbosef("  Warning: failed to find running projects for %s%s: %v", project.Name, where, err)
+				continue
+			}
+
+			for _, runningProject := range runningProjects {
+				if runningProject == activeProjectNames[t.Name] {
+					continue // Skip active deployment
+				}
+
+				out.Printf("  Found orphaned compose project%s: %s", where, runningProject)
+				report.OrphanedComposeProjects = append(report.OrphanedComposeProjects, orphanedComposeProject{Project: project.Name, Host: t.Name, Name: runningProject})
+				if !cleanupDryRunFlag {
+					if err := compose.StopProjectByName(hostCtx, runningProject, 30*time.Second); err != nil {
+						out.Warnf("    Warning: failed to stop: %v", err)
+					}
 				}
 			}
 		}
 	}
-	fmt.Println()
+	out.Printf("")
 
 	// 4. Prune git worktree references
-	fmt.Println("Pruning git worktree references...")
+	out.Printf("Pruning git worktree references...")
 	for _, project := range projects {
 		gitMgr := git.NewManager(project.RepoPath)
 		if !gitMgr.IsGitRepo(ctx) {
@@ -161,24 +218,25 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 
 		if !cleanupDryRunFlag {
 			if err := gitMgr.PruneWorktrees(ctx); err != nil {
-				fmt.Fprintf(os.Stderr, "  Warning: failed to prune worktrees for %s: %v\n", project.Name, err)
+				out.Warnf("  Warning: failed to prune worktrees for %s: %v", project.Name, err)
 			}
 		}
 	}
 
 	// Phase 5: Remove orphaned repositories
-	fmt.Println("\nChecking for orphaned repositories...")
+	out.Printf("")
+	out.Printf("Checking for orphaned repositories...")
 
 	reposDir := filepath.Join(dataDir, "repos")
 
 	if _, err := os.Stat(reposDir); os.IsNotExist(err) {
 		// No repos directory, nothing to clean
 	} else if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to access repos directory: %v\n", err)
+		out.Warnf("Warning: failed to access repos directory: %v", err)
 	} else {
 		entries, err := os.ReadDir(reposDir)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to read repos directory: %v\n", err)
+			out.Warnf("Warning: failed to read repos directory: %v", err)
 		} else {
 			// Build tracked repos map
 			trackedRepos := make(map[string]bool)
@@ -198,28 +256,53 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 			}
 
 			if len(orphaned) > 0 {
-				fmt.Printf("Found %d orphaned repository/repositories:\n", len(orphaned))
+				out.Printf("Found %d orphaned repository/repositories:", len(orphaned))
 				for _, name := range orphaned {
 					path := filepath.Join(reposDir, name)
-					fmt.Printf("  - %s (%s)\n", name, path)
+					out.Printf("  - %s (%s)", name, path)
+					report.OrphanedRepositories = append(report.OrphanedRepositories, orphanedRepository{Name: name, Path: path})
 
 					if !cleanupDryRunFlag {
 						if err := os.RemoveAll(path); err != nil {
-							fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", path, err)
+							out.Warnf("Warning: failed to remove %s: %v", path, err)
 						} else {
-							fmt.Printf("    Removed %s\n", name)
+							out.Printf("    Removed %s", name)
 						}
 					}
 				}
 			} else {
-				fmt.Println("No orphaned repositories found.")
+				out.Printf("No orphaned repositories found.")
 			}
 		}
 	}
 
-	fmt.Println()
-	fmt.Println("Cleanup complete.")
+	out.Printf("")
+	out.Printf("Cleanup complete.")
 
+	if isStructuredOutput() {
+		return writeOutput(os.Stdout, kindCleanupReport, report)
+	}
 	return nil
 }
 
+// cleanupReport is the data of a CleanupReport document: what cleanup found
+// and, unless DryRun is set, cleaned up.
+type cleanupReport struct {
+	DryRun                  bool                     `json:"dry_run"`
+	InterruptedDeployments  []historyEntry           `json:"interrupted_deployments"`
+	OrphanedProjectDirs     []string                 `json:"orphaned_project_dirs"`
+	OrphanedComposeProjects []orphanedComposeProject `json:"orphaned_compose_projects"`
+	OrphanedRepositories    []orphanedRepository     `json:"orphaned_repositories"`
+}
+
+type orphanedComposeProject struct {
+	Project string `json:"project"`
+	Host    string `json:"host,omitempty"`
+	Name    string `json:"name"`
+}
+
+type orphanedRepository struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
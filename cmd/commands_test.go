@@ -3,15 +3,35 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/jayteealao/otterstack/internal/compose"
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/metrics"
+	"github.com/jayteealao/otterstack/internal/notify"
+	"github.com/jayteealao/otterstack/internal/orchestrator"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/validate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -40,6 +60,7 @@ type mockStore struct {
 
 	// Return values
 	activeDeployment   *state.Deployment
+	activeDeployments  []*state.Deployment // one per host, overrides activeDeployment
 	previousDeployment *state.Deployment
 	deploymentBySHA    *state.Deployment
 
@@ -47,6 +68,7 @@ type mockStore struct {
 	createdProjects  []*state.Project
 	deletedProjects  []string
 	createdDeployments []*state.Deployment
+	operationLogs      []*state.OperationLog
 }
 
 func newMockStore(dataDir string) *mockStore {
@@ -149,6 +171,24 @@ func (m *mockStore) GetActiveDeployment(ctx context.Context, projectID string) (
 	return nil, apperrors.ErrNoActiveDeployment
 }
 
+func (m *mockStore) GetHost(ctx context.Context, name string) (*state.Host, error) {
+	return nil, apperrors.ErrHostNotFound
+}
+
+func (m *mockStore) ListActiveDeployments(ctx context.Context, projectID string) ([]*state.Deployment, error) {
+	if m.activeDeployments != nil {
+		return m.activeDeployments, nil
+	}
+	active, err := m.GetActiveDeployment(ctx, projectID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNoActiveDeployment) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []*state.Deployment{active}, nil
+}
+
 func (m *mockStore) ListDeployments(ctx context.Context, projectID string, limit int) ([]*state.Deployment, error) {
 	if m.listDeploymentsErr != nil {
 		return nil, m.listDeploymentsErr
@@ -218,6 +258,120 @@ func (m *mockStore) GetInterruptedDeployments(ctx context.Context) ([]*state.Dep
 	return result, nil
 }
 
+func (m *mockStore) SetEnvVars(ctx context.Context, projectID string, vars map[string]string) error {
+	return nil
+}
+
+func (m *mockStore) GetEnvVars(ctx context.Context, projectID string) (map[string]string, error) {
+	return make(map[string]string), nil
+}
+
+func (m *mockStore) DeleteEnvVar(ctx context.Context, projectID, key string) error {
+	return nil
+}
+
+func (m *mockStore) UpdateDeploymentPhases(ctx context.Context, id string, phases []state.DeploymentPhase) error {
+	if d, ok := m.deployments[id]; ok {
+		d.Phases = phases
+	}
+	return nil
+}
+
+func (m *mockStore) GetDeploymentByIDPrefix(ctx context.Context, prefix string) (*state.Deployment, error) {
+	var found *state.Deployment
+	for id, d := range m.deployments {
+		if strings.HasPrefix(id, prefix) {
+			if found != nil {
+				return nil, errors.New("ambiguous deployment ID prefix")
+			}
+			found = d
+		}
+	}
+	if found == nil {
+		return nil, apperrors.ErrDeploymentNotFound
+	}
+	return found, nil
+}
+
+func (m *mockStore) CreateOperationLog(ctx context.Context, l *state.OperationLog) error {
+	if l.ID == "" {
+		l.ID = fmt.Sprintf("op-%d", len(m.operationLogs)+1)
+	}
+	if l.Status == "" {
+		l.Status = "running"
+	}
+	m.operationLogs = append(m.operationLogs, l)
+	return nil
+}
+
+func (m *mockStore) SetOperationLogDeployment(ctx context.Context, id, deploymentID string) error {
+	for _, l := range m.operationLogs {
+		if l.ID == id {
+			l.DeploymentID = deploymentID
+		}
+	}
+	return nil
+}
+
+func (m *mockStore) FinishOperationLog(ctx context.Context, id, status string) error {
+	for _, l := range m.operationLogs {
+		if l.ID == id {
+			now := time.Now()
+			l.Status = status
+			l.FinishedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockStore) GetOperationLog(ctx context.Context, id string) (*state.OperationLog, error) {
+	for _, l := range m.operationLogs {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+	return nil, apperrors.ErrOperationLogNotFound
+}
+
+func (m *mockStore) ListDeploymentOperationLogs(ctx context.Context, deploymentID string) ([]*state.OperationLog, error) {
+	var result []*state.OperationLog
+	for _, l := range m.operationLogs {
+		if l.DeploymentID == deploymentID {
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockStore) ListOperationLogs(ctx context.Context, projectID string, limit int) ([]*state.OperationLog, error) {
+	var result []*state.OperationLog
+	for i := len(m.operationLogs) - 1; i >= 0 && len(result) < limit; i-- {
+		if m.operationLogs[i].ProjectID == projectID {
+			result = append(result, m.operationLogs[i])
+		}
+	}
+	return result, nil
+}
+
+func (m *mockStore) CreateVolumeSnapshot(ctx context.Context, v *state.VolumeSnapshot) error {
+	return nil
+}
+
+func (m *mockStore) ListVolumeSnapshots(ctx context.Context, deploymentID string) ([]*state.VolumeSnapshot, error) {
+	return nil, nil
+}
+
+func (m *mockStore) GetRollbackSnapshots(ctx context.Context, projectID, gitSHA string) ([]*state.VolumeSnapshot, error) {
+	return nil, apperrors.ErrNoVolumeSnapshot
+}
+
+func (m *mockStore) DeleteVolumeSnapshots(ctx context.Context, deploymentID string) error {
+	return nil
+}
+
+// Ensure mockStore implements state.StateStore
+var _ state.StateStore = (*mockStore)(nil)
+
 // --- Test Helpers ---
 
 func createTestProject(id, name, repoType string) *state.Project {
@@ -302,6 +456,48 @@ func TestRootCmd(t *testing.T) {
 		assert.Equal(t, "v", verboseFlag.Shorthand)
 	})
 
+	t.Run("root command has an output flag", func(t *testing.T) {
+		outputFlag := rootCmd.PersistentFlags().Lookup("output")
+		require.NotNil(t, outputFlag)
+		assert.Equal(t, outputTable, outputFlag.DefValue)
+	})
+
+	t.Run("root command has structured logging flags", func(t *testing.T) {
+		require.NotNil(t, rootCmd.PersistentFlags().Lookup("log-format"))
+		require.NotNil(t, rootCmd.PersistentFlags().Lookup("log-file"))
+	})
+
+	t.Run("initLogging writes JSON logs to the log file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "otterstack.log")
+		viper.Set("log-file", path)
+		t.Cleanup(func() {
+			closeLogFile()
+			viper.Set("log-file", "")
+			cmdLogger, closeLogFile = logging.NewNoOpLogger(), func() error { return nil }
+		})
+
+		require.NoError(t, initLogging())
+		printVerbose("not logged below debug level")
+		cmdLogger.Info("deployment succeeded", logging.String("project", "myapp"))
+		require.NoError(t, closeLogFile())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &rec))
+		assert.Equal(t, "deployment succeeded", rec["msg"])
+		assert.Equal(t, "myapp", rec["project"])
+	})
+
+	t.Run("initLogging rejects unknown formats", func(t *testing.T) {
+		viper.Set("log-format", "xml")
+		t.Cleanup(func() { viper.Set("log-format", "") })
+
+		err := initLogging()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid log format")
+	})
+
 	t.Run("checkContext returns nil for active context", func(t *testing.T) {
 		ctx := context.Background()
 		err := checkContext(ctx)
@@ -336,6 +532,13 @@ func TestProjectCmd(t *testing.T) {
 		assert.Contains(t, subcommandNames, "remove")
 	})
 
+	t.Run("project config show command validates arguments", func(t *testing.T) {
+		assert.Equal(t, "config", projectConfigCmd.Use)
+		assert.Equal(t, "show <name>", projectConfigShowCmd.Use)
+		assert.Error(t, projectConfigShowCmd.Args(projectConfigShowCmd, []string{}))
+		assert.NoError(t, projectConfigShowCmd.Args(projectConfigShowCmd, []string{"myapp"}))
+	})
+
 	t.Run("project add command validates arguments", func(t *testing.T) {
 		assert.Equal(t, "add <name> <repo-path-or-url>", projectAddCmd.Use)
 		assert.NotNil(t, projectAddCmd.Args)
@@ -419,6 +622,18 @@ func TestDeployCmd(t *testing.T) {
 		skipPullFlag := deployCmd.Flags().Lookup("skip-pull")
 		require.NotNil(t, skipPullFlag)
 		assert.Equal(t, "false", skipPullFlag.DefValue)
+
+		rebuildFlag := deployCmd.Flags().Lookup("rebuild")
+		require.NotNil(t, rebuildFlag)
+		assert.Equal(t, "false", rebuildFlag.DefValue)
+
+		triggerSourceFlag := deployCmd.Flags().Lookup("trigger-source")
+		require.NotNil(t, triggerSourceFlag)
+		assert.Equal(t, "cli", triggerSourceFlag.DefValue)
+
+		triggeredByFlag := deployCmd.Flags().Lookup("triggered-by")
+		require.NotNil(t, triggeredByFlag)
+		assert.Equal(t, "", triggeredByFlag.DefValue)
 	})
 }
 
@@ -446,6 +661,12 @@ func TestRollbackCmd(t *testing.T) {
 		require.NotNil(t, toFlag)
 		assert.Empty(t, toFlag.DefValue)
 	})
+
+	t.Run("rollback command has --restore-volumes flag", func(t *testing.T) {
+		restoreFlag := rollbackCmd.Flags().Lookup("restore-volumes")
+		require.NotNil(t, restoreFlag)
+		assert.Equal(t, "false", restoreFlag.DefValue)
+	})
 }
 
 // --- Status Command Tests ---
@@ -522,6 +743,226 @@ func TestHistoryCmd(t *testing.T) {
 		jsonFlag := historyCmd.Flags().Lookup("json")
 		require.NotNil(t, jsonFlag)
 		assert.Equal(t, "false", jsonFlag.DefValue)
+
+		timingFlag := historyCmd.Flags().Lookup("timing")
+		require.NotNil(t, timingFlag)
+		assert.Equal(t, "false", timingFlag.DefValue)
+
+		graphFlag := historyCmd.Flags().Lookup("graph")
+		require.NotNil(t, graphFlag)
+		assert.Equal(t, "false", graphFlag.DefValue)
+	})
+}
+
+func TestHistoryFormatting(t *testing.T) {
+	t.Run("formatDuration", func(t *testing.T) {
+		assert.Equal(t, "0s", formatDuration(200*time.Millisecond))
+		assert.Equal(t, "45s", formatDuration(45*time.Second))
+		assert.Equal(t, "1.5m", formatDuration(90*time.Second))
+	})
+
+	t.Run("formatPhases", func(t *testing.T) {
+		phases := []state.DeploymentPhase{
+			{Name: "pull", Duration: 12 * time.Second},
+			{Name: "build", Duration: 3 * time.Minute},
+		}
+		assert.Equal(t, "pull 12s, build 3.0m", formatPhases(phases))
+		assert.Equal(t, "", formatPhases(nil))
+	})
+
+	t.Run("formatTrigger", func(t *testing.T) {
+		assert.Equal(t, "alice (cli)", formatTrigger(&state.Deployment{TriggerSource: "cli", TriggeredBy: "alice"}))
+		assert.Equal(t, "rollback", formatTrigger(&state.Deployment{TriggerSource: "rollback"}))
+		assert.Equal(t, "-", formatTrigger(&state.Deployment{}))
+	})
+
+	t.Run("lineageNote", func(t *testing.T) {
+		assert.Equal(t, "restores aaaaaaaa, reverts bbbbbbbb", lineageNote(&state.Deployment{
+			Kind: state.KindRollback, ParentDeploymentID: "aaaaaaaa-1", RevertsDeploymentID: "bbbbbbbb-2",
+		}))
+		assert.Equal(t, "redeploy of aaaaaaaa", lineageNote(&state.Deployment{
+			Kind: state.KindRedeploy, ParentDeploymentID: "aaaaaaaa-1",
+		}))
+		assert.Equal(t, "", lineageNote(&state.Deployment{Kind: state.KindDeploy}))
+	})
+}
+
+func TestLineageGraph(t *testing.T) {
+	t.Run("plain deploys have no arcs", func(t *testing.T) {
+		graph := lineageGraph([]*state.Deployment{
+			{ID: "b", Status: "active"},
+			{ID: "a", Status: "inactive"},
+		})
+		assert.Equal(t, []string{"●", "●"}, graph)
+	})
+
+	t.Run("rollbacks arc to the deployment they restored", func(t *testing.T) {
+		// Newest first: r2 restores a, r1 restores b, c was rolled back by r1
+		graph := lineageGraph([]*state.Deployment{
+			{ID: "r2", Status: "active", Kind: state.KindRollback, ParentDeploymentID: "a"},
+			{ID: "r1", Status: "rolled_back", Kind: state.KindRollback, ParentDeploymentID: "b"},
+			{ID: "c", Status: "rolled_back"},
+			{ID: "b", Status: "inactive"},
+			{ID: "a", Status: "inactive"},
+		})
+		assert.Equal(t, []string{
+			"●─╮  ",
+			"✗─┼─╮",
+			"✗ │ │",
+			"●─┼─╯",
+			"●─╯  ",
+		}, graph)
+	})
+
+	t.Run("parents outside the listed range are not drawn", func(t *testing.T) {
+		graph := lineageGraph([]*state.Deployment{
+			{ID: "r1", Status: "active", Kind: state.KindRollback, ParentDeploymentID: "old"},
+		})
+		assert.Equal(t, []string{"●"}, graph)
+	})
+}
+
+// --- DB Command Tests ---
+
+func TestDBCmd(t *testing.T) {
+	t.Run("db command has status and migrate subcommands", func(t *testing.T) {
+		var names []string
+		for _, c := range dbCmd.Commands() {
+			names = append(names, c.Name())
+		}
+		assert.ElementsMatch(t, []string{"status", "migrate"}, names)
+	})
+
+	t.Run("migrate command has dry-run flag", func(t *testing.T) {
+		dryRunFlag := dbMigrateCmd.Flags().Lookup("dry-run")
+		require.NotNil(t, dryRunFlag)
+		assert.Equal(t, "false", dryRunFlag.DefValue)
+	})
+
+	t.Run("migrationState", func(t *testing.T) {
+		assert.Equal(t, "pending", migrationState(state.MigrationStatus{}))
+		assert.Equal(t, "applied", migrationState(state.MigrationStatus{Applied: true}))
+		assert.Equal(t, "applied, modified since", migrationState(state.MigrationStatus{Applied: true, Modified: true}))
+		assert.Equal(t, "applied by newer otterstack", migrationState(state.MigrationStatus{Applied: true, Unknown: true}))
+	})
+}
+
+// --- Logs Command Tests ---
+
+func TestLogsCmd(t *testing.T) {
+	t.Run("logs command exists and has correct use", func(t *testing.T) {
+		assert.Equal(t, "logs <project|deployment> [service]", logsCmd.Use)
+		assert.NotEmpty(t, logsCmd.Short)
+	})
+
+	t.Run("logs command accepts a target and an optional service", func(t *testing.T) {
+		assert.Error(t, logsCmd.Args(logsCmd, []string{}))
+		assert.NoError(t, logsCmd.Args(logsCmd, []string{"3f2a9c1e"}))
+		assert.NoError(t, logsCmd.Args(logsCmd, []string{"myapp", "web"}))
+		assert.Error(t, logsCmd.Args(logsCmd, []string{"a", "b", "c"}))
+	})
+
+	t.Run("logs command has expected flags", func(t *testing.T) {
+		followFlag := logsCmd.Flags().Lookup("follow")
+		require.NotNil(t, followFlag)
+		assert.Equal(t, "f", followFlag.Shorthand)
+		assert.Equal(t, "false", followFlag.DefValue)
+
+		exportFlag := logsCmd.Flags().Lookup("export")
+		require.NotNil(t, exportFlag)
+		assert.Equal(t, "", exportFlag.DefValue)
+
+		for _, name := range containerLogFlags {
+			assert.NotNil(t, logsCmd.Flags().Lookup(name), "flag %s should exist", name)
+		}
+	})
+}
+
+func TestGrepWriter(t *testing.T) {
+	var out bytes.Buffer
+	g := &grepWriter{w: &out, re: regexp.MustCompile(`(?i)error`)}
+
+	// Lines split across writes are matched once complete
+	_, err := g.Write([]byte("web-1  | starting\nweb-1  | ERR"))
+	require.NoError(t, err)
+	assert.Empty(t, out.String())
+
+	_, err = g.Write([]byte("OR: boom\nweb-1  | ok\nweb-1  | last error"))
+	require.NoError(t, err)
+	assert.Equal(t, "web-1  | ERROR: boom\n", out.String())
+
+	require.NoError(t, g.Flush())
+	assert.Equal(t, "web-1  | ERROR: boom\nweb-1  | last error\n", out.String())
+}
+
+func TestResolveDeploymentRef(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore(t.TempDir())
+	store.projects["myapp"] = createTestProject("proj-1", "myapp", "local")
+	store.deployments["3f2a9c1e-1111"] = createTestDeployment("3f2a9c1e-1111", "proj-1", "abc1234", "active")
+	store.deploymentBySHA = store.deployments["3f2a9c1e-1111"]
+
+	t.Run("by ID prefix", func(t *testing.T) {
+		d, err := resolveDeploymentRef(ctx, store, "3f2a")
+		require.NoError(t, err)
+		assert.Equal(t, "3f2a9c1e-1111", d.ID)
+	})
+
+	t.Run("by project and SHA", func(t *testing.T) {
+		d, err := resolveDeploymentRef(ctx, store, "myapp@abc1234")
+		require.NoError(t, err)
+		assert.Equal(t, "3f2a9c1e-1111", d.ID)
+	})
+
+	t.Run("unknown ID", func(t *testing.T) {
+		_, err := resolveDeploymentRef(ctx, store, "ffff")
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		_, err := resolveDeploymentRef(ctx, store, "other@abc1234")
+		assert.ErrorContains(t, err, `project "other" not found`)
+	})
+
+	t.Run("invalid project name", func(t *testing.T) {
+		_, err := resolveDeploymentRef(ctx, store, "../x@abc1234")
+		assert.Error(t, err)
+	})
+}
+
+func TestWriteOperationLogs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newMockStore(dir)
+
+	deployPath := dir + "/deploy.log"
+	require.NoError(t, os.WriteFile(deployPath, []byte("Deploying myapp\n"), 0600))
+
+	logs := []*state.OperationLog{
+		{ID: "op-1", Operation: "deploy", Status: "failed", LogPath: deployPath},
+		{ID: "op-2", Operation: "rollback", Status: "failed", LogPath: dir + "/missing.log"},
+	}
+
+	t.Run("single log is copied verbatim", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeOperationLogs(ctx, store, &out, logs[:1], false))
+		assert.Equal(t, "Deploying myapp\n", out.String())
+	})
+
+	t.Run("multiple logs get headers", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeOperationLogs(ctx, store, &out, logs, false))
+		assert.Contains(t, out.String(), "==> deploy started")
+		assert.Contains(t, out.String(), "Deploying myapp")
+		assert.Contains(t, out.String(), "no longer exists")
+	})
+
+	t.Run("follow stops once the operation has finished", func(t *testing.T) {
+		store.operationLogs = []*state.OperationLog{logs[0]}
+
+		var out bytes.Buffer
+		require.NoError(t, writeOperationLogs(ctx, store, &out, logs[:1], true))
+		assert.Equal(t, "Deploying myapp\n", out.String())
 	})
 }
 
@@ -541,6 +982,56 @@ func TestMonitorCmd(t *testing.T) {
 	})
 }
 
+func TestMonitorActions(t *testing.T) {
+	ctx := context.Background()
+	oldDataDir := dataDir
+	defer func() { dataDir = oldDataDir }()
+	dataDir = t.TempDir()
+
+	repo := filepath.Join(t.TempDir(), "repo")
+	for _, args := range [][]string{
+		{"init", "-b", "main", repo},
+		{"-C", repo, "-c", "user.email=test@test.com", "-c", "user.name=Test", "commit", "--allow-empty", "-m", "initial"},
+		{"-C", repo, "branch", "feature"},
+		{"-C", repo, "tag", "v1.2.0"},
+		{"-C", repo, "tag", "v1.10.0"},
+	} {
+		output, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+
+	store, err := state.New(dataDir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	project := &state.Project{Name: "dashboard", RepoType: "local", RepoPath: repo, ComposeFile: "compose.yaml", Status: "unconfigured"}
+	require.NoError(t, store.CreateProject(ctx, project))
+
+	actions := &monitorActions{store: store, dataDir: dataDir}
+
+	t.Run("refs list the default branch first, then tags by version", func(t *testing.T) {
+		refs, err := actions.Refs(ctx, project)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"main", "feature", "v1.10.0", "v1.2.0"}, refs)
+	})
+
+	t.Run("deploy rejects invalid refs", func(t *testing.T) {
+		err := actions.Deploy(ctx, project, "main;rm -rf /", io.Discard)
+		assert.ErrorContains(t, err, "invalid git ref")
+	})
+
+	t.Run("deploy requires a ready project", func(t *testing.T) {
+		err := actions.Deploy(ctx, project, "main", io.Discard)
+		assert.ErrorContains(t, err, "not ready")
+	})
+
+	t.Run("rollback needs an active deployment", func(t *testing.T) {
+		var out bytes.Buffer
+		err := actions.Rollback(ctx, project, &state.Deployment{GitSHA: "abc1234"}, &out)
+		assert.ErrorContains(t, err, "no active deployment")
+	})
+}
+
 // --- Watch Command Tests ---
 
 func TestWatchCmd(t *testing.T) {
@@ -569,6 +1060,18 @@ func TestWatchCmd(t *testing.T) {
 		slackChannelFlag := watchCmd.Flags().Lookup("slack-channel")
 		require.NotNil(t, slackChannelFlag)
 		assert.Empty(t, slackChannelFlag.DefValue)
+
+		memThresholdFlag := watchCmd.Flags().Lookup("memory-threshold")
+		require.NotNil(t, memThresholdFlag)
+		assert.Equal(t, "90", memThresholdFlag.DefValue)
+
+		sustainFlag := watchCmd.Flags().Lookup("sustain")
+		require.NotNil(t, sustainFlag)
+		assert.Equal(t, "3", sustainFlag.DefValue)
+
+		metricsAddrFlag := watchCmd.Flags().Lookup("metrics-addr")
+		require.NotNil(t, metricsAddrFlag)
+		assert.Empty(t, metricsAddrFlag.DefValue, "metrics are off by default")
 	})
 }
 
@@ -926,6 +1429,100 @@ func TestDetectEvent(t *testing.T) {
 	}
 }
 
+func TestDetectUsageEvents(t *testing.T) {
+	// check runs one health check with the given stats and returns the alerts
+	check := func(prev ServiceState, stats *compose.ContainerStats) (ServiceState, []*notify.Event) {
+		current := ServiceState{Status: "running"}
+		events := detectUsageEvents("myapp", "web", prev, &current, stats, 90, 3)
+		return current, events
+	}
+
+	t.Run("sustained high memory alerts once", func(t *testing.T) {
+		high := &compose.ContainerStats{MemPercent: 95, MemLimit: 128 << 20}
+		var s ServiceState
+		var alerts []*notify.Event
+		for range 5 {
+			var events []*notify.Event
+			s, events = check(s, high)
+			alerts = append(alerts, events...)
+		}
+		require.Len(t, alerts, 1)
+		assert.Equal(t, notify.EventServiceHighMemory, alerts[0].Type)
+		assert.Equal(t, "memory at 95.0% of 128.0MiB for 3 checks", alerts[0].Message)
+
+		s, _ = check(s, &compose.ContainerStats{MemPercent: 50})
+		assert.Zero(t, s.HighMemory, "dropping below the threshold resets the count")
+	})
+
+	t.Run("brief spikes do not alert", func(t *testing.T) {
+		s, events := check(ServiceState{}, &compose.ContainerStats{MemPercent: 95})
+		assert.Empty(t, events)
+		s, events = check(s, &compose.ContainerStats{MemPercent: 95})
+		assert.Empty(t, events)
+		_, events = check(s, &compose.ContainerStats{MemPercent: 10})
+		assert.Empty(t, events)
+	})
+
+	t.Run("restart count increase alerts", func(t *testing.T) {
+		s, events := check(ServiceState{}, &compose.ContainerStats{RestartCount: 2})
+		assert.Empty(t, events, "the first sample is the baseline")
+
+		s, _ = check(s, nil)
+		assert.Equal(t, 2, s.RestartCount, "usage carries over when stats are missing")
+
+		_, events = check(s, &compose.ContainerStats{RestartCount: 3})
+		require.Len(t, events, 1)
+		assert.Equal(t, notify.EventServiceRestarted, events[0].Type)
+		assert.Equal(t, "restart count 2 -> 3", events[0].Message)
+	})
+}
+
+func TestDeploymentMetrics(t *testing.T) {
+	ctx := context.Background()
+	store, err := state.New(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	project := &state.Project{Name: "myapp", RepoType: "local", RepoPath: "/tmp/repo", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, project))
+	for _, d := range []struct{ sha, host, status string }{
+		{"aaaaaaa1111111", "", "failed"},
+		{"bbbbbbb2222222", "", "active"},
+		{"bbbbbbb2222222", "edge", "active"},
+		{"ccccccc3333333", "", "deploying"},
+	} {
+		dep := &state.Deployment{ProjectID: project.ID, GitSHA: d.sha, GitRef: "main", Host: d.host, Status: "deploying"}
+		require.NoError(t, store.CreateDeployment(ctx, dep))
+		if d.status != "deploying" {
+			require.NoError(t, store.UpdateDeploymentStatus(ctx, dep.ID, d.status, nil))
+		}
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(deploymentCollector{store: store}))
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	text := rec.Body.String()
+
+	assert.Contains(t, text, `otterstack_deployments{project="myapp",status="active"} 2`)
+	assert.Contains(t, text, `otterstack_deployments{project="myapp",status="deploying"} 1`)
+	assert.Contains(t, text, `otterstack_deployments{project="myapp",status="failed"} 1`)
+	assert.NotContains(t, text, "otterstack_deployments_total", "finished deployments are counted by the deployer")
+	assert.Contains(t, text, `otterstack_active_deployment_info{host="edge",project="myapp",ref="main",sha="bbbbbbb2222222"} 1`)
+	assert.Contains(t, text, `otterstack_active_deployment_info{host="local",project="myapp",ref="main",sha="bbbbbbb2222222"} 1`)
+	assert.Regexp(t, `otterstack_seconds_since_last_successful_deploy\{project="myapp"\} \d`, text)
+}
+
+func TestSetServiceMetrics(t *testing.T) {
+	setServiceMetrics("metricsapp", "local", "metricsapp-abc1234-web-1", ServiceState{Service: "web", Status: "Up 2 minutes", Health: "unhealthy"})
+	setServiceMetrics("metricsapp", "local", "metricsapp-abc1234-db-1", ServiceState{Service: "db", Status: "Up 2 minutes"})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ServiceUp.WithLabelValues("metricsapp", "local", "web", "metricsapp-abc1234-web-1")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.ServiceHealthy.WithLabelValues("metricsapp", "local", "web", "metricsapp-abc1234-web-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ServiceHealthy.WithLabelValues("metricsapp", "local", "db", "metricsapp-abc1234-db-1")), "no health check counts as healthy")
+}
+
 // --- Command Help Text Tests ---
 
 func TestCommandHelpText(t *testing.T) {
@@ -981,8 +1578,10 @@ func TestSubcommandRegistration(t *testing.T) {
 			"status",
 			"cleanup",
 			"history",
+			"logs",
 			"monitor",
 			"watch",
+			"queue",
 		}
 
 		for _, expected := range expectedCommands {
@@ -990,3 +1589,674 @@ func TestSubcommandRegistration(t *testing.T) {
 		}
 	})
 }
+
+// --- Backup and Restore Command Tests ---
+
+func TestBackupCmd(t *testing.T) {
+	t.Run("backup command has file and volumes flags", func(t *testing.T) {
+		assert.Equal(t, "backup [project]", backupCmd.Use)
+
+		fileFlag := backupCmd.Flags().Lookup("file")
+		require.NotNil(t, fileFlag)
+		assert.Equal(t, "f", fileFlag.Shorthand)
+
+		// --output is the global result format
+		assert.Nil(t, backupCmd.LocalNonPersistentFlags().Lookup("output"))
+
+		volumesFlag := backupCmd.Flags().Lookup("volumes")
+		require.NotNil(t, volumesFlag)
+		assert.Equal(t, "false", volumesFlag.DefValue)
+	})
+
+	t.Run("restore command has volumes and force flags", func(t *testing.T) {
+		assert.Equal(t, "restore <archive>", restoreCmd.Use)
+		assert.NotNil(t, restoreCmd.Flags().Lookup("volumes"))
+		assert.NotNil(t, restoreCmd.Flags().Lookup("force"))
+	})
+
+	t.Run("formatBytes", func(t *testing.T) {
+		assert.Equal(t, "512 B", formatBytes(512))
+		assert.Equal(t, "1.5 KiB", formatBytes(1536))
+		assert.Equal(t, "3.0 MiB", formatBytes(3<<20))
+	})
+}
+
+func TestProjectExportCmd(t *testing.T) {
+	t.Run("export command has file and encryption flags", func(t *testing.T) {
+		assert.Equal(t, "export <name>", projectExportCmd.Use)
+
+		fileFlag := projectExportCmd.Flags().Lookup("file")
+		require.NotNil(t, fileFlag)
+		assert.Equal(t, "f", fileFlag.Shorthand)
+
+		// --output is the global result format
+		assert.Nil(t, projectExportCmd.LocalNonPersistentFlags().Lookup("output"))
+
+		encryptFlag := projectExportCmd.Flags().Lookup("encrypt")
+		require.NotNil(t, encryptFlag)
+		assert.Equal(t, "false", encryptFlag.DefValue)
+
+		assert.NotNil(t, projectExportCmd.Flags().Lookup("passphrase-file"))
+	})
+
+	t.Run("import command has name and passphrase flags", func(t *testing.T) {
+		assert.Equal(t, "import <file>", projectImportCmd.Use)
+		assert.NotNil(t, projectImportCmd.Flags().Lookup("name"))
+		assert.NotNil(t, projectImportCmd.Flags().Lookup("passphrase-file"))
+	})
+
+	t.Run("readPassphrase trims the trailing newline", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "pass")
+		require.NoError(t, os.WriteFile(path, []byte("correct horse\n"), 0600))
+
+		got, err := readPassphrase(path, true)
+		require.NoError(t, err)
+		assert.Equal(t, "correct horse", got)
+	})
+
+	t.Run("readPassphrase rejects short new passphrases", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "pass")
+		require.NoError(t, os.WriteFile(path, []byte("short\n"), 0600))
+
+		_, err := readPassphrase(path, true)
+		assert.Error(t, err)
+
+		got, err := readPassphrase(path, false)
+		require.NoError(t, err)
+		assert.Equal(t, "short", got)
+	})
+}
+
+func TestHostCmd(t *testing.T) {
+	t.Run("host subcommands are registered", func(t *testing.T) {
+		names := make(map[string]bool)
+		for _, c := range hostCmd.Commands() {
+			names[c.Name()] = true
+		}
+		for _, want := range []string{"add", "list", "remove", "check"} {
+			assert.True(t, names[want], "missing host %s", want)
+		}
+	})
+
+	t.Run("add takes an ssh address or a docker context", func(t *testing.T) {
+		assert.NotNil(t, hostAddCmd.Flags().Lookup("ssh"))
+		assert.NotNil(t, hostAddCmd.Flags().Lookup("context"))
+		assert.NotNil(t, hostAddCmd.Flags().Lookup("no-check"))
+	})
+
+	t.Run("deploy, logs and project add accept hosts", func(t *testing.T) {
+		assert.NotNil(t, deployCmd.Flags().Lookup("host"))
+		assert.NotNil(t, logsCmd.Flags().Lookup("host"))
+		assert.NotNil(t, projectAddCmd.Flags().Lookup("host"))
+		assert.NotNil(t, projectHostsCmd.Flags().Lookup("local"))
+	})
+
+	t.Run("rolloutSummary splits updated and pending hosts", func(t *testing.T) {
+		targets := []target.Target{{Name: "web1"}, {Name: "web2"}, {Name: "web3"}}
+		assert.Equal(t, "Updated: web1\nNot updated: web2, web3", rolloutSummary(targets, 1))
+	})
+}
+
+func TestActiveDeploymentOn(t *testing.T) {
+	ctx := context.Background()
+	project := createTestProject("proj-1", "test-app", "local")
+	local := &state.Deployment{ID: "d-local", ProjectID: project.ID}
+	remote := &state.Deployment{ID: "d-web1", ProjectID: project.ID, Host: "web1"}
+
+	t.Run("single deployment needs no host", func(t *testing.T) {
+		store := newMockStore("/data")
+		store.activeDeployments = []*state.Deployment{remote}
+
+		got, err := activeDeploymentOn(ctx, store, project, "")
+		require.NoError(t, err)
+		assert.Equal(t, remote.ID, got.ID)
+	})
+
+	t.Run("several hosts need --host", func(t *testing.T) {
+		store := newMockStore("/data")
+		store.activeDeployments = []*state.Deployment{local, remote}
+
+		_, err := activeDeploymentOn(ctx, store, project, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "local, web1")
+
+		got, err := activeDeploymentOn(ctx, store, project, "local")
+		require.NoError(t, err)
+		assert.Equal(t, local.ID, got.ID)
+
+		got, err = activeDeploymentOn(ctx, store, project, "web1")
+		require.NoError(t, err)
+		assert.Equal(t, remote.ID, got.ID)
+	})
+
+	t.Run("unknown host has no active deployment", func(t *testing.T) {
+		store := newMockStore("/data")
+		store.activeDeployments = []*state.Deployment{local}
+
+		_, err := activeDeploymentOn(ctx, store, project, "web2")
+		assert.ErrorIs(t, err, apperrors.ErrNoActiveDeployment)
+	})
+}
+
+func TestScheduleCmd(t *testing.T) {
+	t.Run("schedule subcommands are registered", func(t *testing.T) {
+		names := make(map[string]bool)
+		for _, c := range scheduleCmd.Commands() {
+			names[c.Name()] = true
+		}
+		for _, want := range []string{"set", "list", "remove"} {
+			assert.True(t, names[want], "missing schedule %s", want)
+		}
+	})
+
+	t.Run("set flags", func(t *testing.T) {
+		every := scheduleSetCmd.Flags().Lookup("every")
+		require.NotNil(t, every)
+		assert.Equal(t, "5m0s", every.DefValue)
+		assert.NotNil(t, scheduleSetCmd.Flags().Lookup("branch"))
+		assert.NotNil(t, scheduleSetCmd.Flags().Lookup("tag"))
+		assert.NotNil(t, scheduleSetCmd.Flags().Lookup("window"))
+		assert.NotNil(t, scheduleSetCmd.Flags().Lookup("timezone"))
+	})
+
+	t.Run("watch can skip schedules", func(t *testing.T) {
+		flag := watchCmd.Flags().Lookup("no-schedule")
+		require.NotNil(t, flag)
+		assert.Equal(t, "false", flag.DefValue)
+	})
+
+	t.Run("outdatedTargets", func(t *testing.T) {
+		web1 := target.Target{Name: "web1", DockerHost: "ssh://web1"}
+		web2 := target.Target{Name: "web2", DockerHost: "ssh://web2"}
+		active := []*state.Deployment{
+			{Host: "web1", GitSHA: "new"},
+			{Host: "web2", GitSHA: "old"},
+		}
+
+		assert.Equal(t, []target.Target{web2}, outdatedTargets([]target.Target{web1, web2}, active, "new"))
+		assert.Equal(t, []target.Target{target.Local}, outdatedTargets([]target.Target{target.Local}, nil, "new"))
+		assert.Empty(t, outdatedTargets([]target.Target{web1}, active, "new"))
+	})
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	// A local repository whose main branch is already deployed
+	repo := filepath.Join(t.TempDir(), "repo")
+	for _, args := range [][]string{
+		{"init", "-b", "main", repo},
+		{"-C", repo, "-c", "user.email=test@test.com", "-c", "user.name=Test", "commit", "--allow-empty", "-m", "initial"},
+	} {
+		output, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	head, err := exec.Command("git", "-C", repo, "rev-parse", "HEAD").Output()
+	require.NoError(t, err)
+	headSHA := strings.TrimSpace(string(head))
+
+	store, err := state.New(dataDir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	project := &state.Project{Name: "scheduled", RepoType: "local", RepoPath: repo, ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, project))
+	require.NoError(t, store.CreateDeployment(ctx, &state.Deployment{ProjectID: project.ID, GitSHA: headSHA, Status: "active"}))
+	require.NoError(t, store.SetSchedule(ctx, &state.Schedule{ProjectID: project.ID, Branch: "main", Interval: time.Hour}))
+
+	notifyMgr := notify.NewManager()
+	defer notifyMgr.Close()
+
+	t.Run("running commit is recorded without deploying", func(t *testing.T) {
+		runDueSchedules(ctx, store, notifyMgr, dataDir, "", time.Now())
+
+		sc, err := store.GetSchedule(ctx, project.ID)
+		require.NoError(t, err)
+		require.NotNil(t, sc.LastCheckedAt)
+		assert.Equal(t, headSHA, sc.LastSHA)
+		assert.Empty(t, sc.LastError)
+
+		deployments, err := store.ListDeployments(ctx, project.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, deployments, 1, "no deployment should be started")
+	})
+
+	t.Run("not due again before the interval", func(t *testing.T) {
+		require.NoError(t, store.RecordScheduleCheck(ctx, project.ID, "", "marker"))
+		runDueSchedules(ctx, store, notifyMgr, dataDir, "", time.Now())
+
+		sc, err := store.GetSchedule(ctx, project.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "marker", sc.LastError, "schedule should not have been checked")
+	})
+
+	t.Run("resolve errors are recorded", func(t *testing.T) {
+		require.NoError(t, store.SetSchedule(ctx, &state.Schedule{ProjectID: project.ID, TagPattern: "v*", Interval: time.Hour}))
+		runDueSchedules(ctx, store, notifyMgr, dataDir, "", time.Now())
+
+		sc, err := store.GetSchedule(ctx, project.ID)
+		require.NoError(t, err)
+		assert.Contains(t, sc.LastError, apperrors.ErrNoMatchingTag.Error())
+	})
+}
+
+func TestDeployLimits(t *testing.T) {
+	set := func(t *testing.T, values map[string]string) {
+		for k, v := range values {
+			viper.Set(k, v)
+		}
+		t.Cleanup(func() {
+			for k := range values {
+				viper.Set(k, "")
+			}
+		})
+	}
+
+	t.Run("no limits by default", func(t *testing.T) {
+		maxConcurrent, resources, err := deployLimits()
+		require.NoError(t, err)
+		assert.Zero(t, maxConcurrent)
+		assert.Equal(t, orchestrator.ResourceCheck{}, resources)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		set(t, map[string]string{
+			"max_concurrent_deploys": "2",
+			"min_free_disk":          "10GB",
+			"min_free_disk_path":     "/var/lib/docker",
+			"min_free_memory":        "512MiB",
+			"resource_wait":          "5m",
+		})
+
+		maxConcurrent, resources, err := deployLimits()
+		require.NoError(t, err)
+		assert.Equal(t, 2, maxConcurrent)
+		assert.Equal(t, orchestrator.ResourceCheck{
+			MinFreeDisk:   10e9,
+			DiskPath:      "/var/lib/docker",
+			MinFreeMemory: 512 << 20,
+			Wait:          5 * time.Minute,
+		}, resources)
+	})
+
+	for key, value := range map[string]string{
+		"max_concurrent_deploys": "-1",
+		"min_free_disk":          "lots",
+		"min_free_memory":        "1PB",
+		"resource_wait":          "forever",
+	} {
+		t.Run("invalid "+key, func(t *testing.T) {
+			set(t, map[string]string{key: value})
+			_, _, err := deployLimits()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), key)
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for input, want := range map[string]uint64{
+		"1048576": 1 << 20,
+		"512MiB":  512 << 20,
+		"10GB":    10e9,
+		"1.5 GiB": 3 << 29,
+		"100kb":   100e3,
+	} {
+		got, err := parseByteSize(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "GB", "-1GB", "10 furlongs"} {
+		_, err := parseByteSize(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestDeployQueue(t *testing.T) {
+	ctx := context.Background()
+	oldDataDir, oldInterval := dataDir, queuePollInterval
+	defer func() { dataDir, queuePollInterval = oldDataDir, oldInterval }()
+	dataDir = t.TempDir()
+	queuePollInterval = 10 * time.Millisecond
+
+	store, err := state.New(dataDir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	project := &state.Project{Name: "queued", RepoType: "local", RepoPath: "/srv/queued", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, project))
+
+	t.Run("queue subcommands are registered", func(t *testing.T) {
+		names := make(map[string]bool)
+		for _, c := range queueCmd.Commands() {
+			names[c.Name()] = true
+		}
+		assert.True(t, names["list"])
+		assert.True(t, names["cancel"])
+	})
+
+	t.Run("runs at once when nothing else is running", func(t *testing.T) {
+		q := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v1", PID: os.Getpid()}
+		require.NoError(t, waitInDeployQueue(ctx, store, q, func(string) {}))
+
+		finishQueuedDeploy(ctx, store, q, errors.New("boom"))
+		got, err := store.GetQueuedDeploy(ctx, q.ID)
+		require.NoError(t, err)
+		assert.Equal(t, state.QueueFailed, got.Status)
+		assert.Equal(t, "boom", got.Error)
+	})
+
+	t.Run("waits for the running deploy and is superseded", func(t *testing.T) {
+		running := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v1", PID: os.Getpid()}
+		require.NoError(t, store.EnqueueDeploy(ctx, running))
+		started, err := store.StartQueuedDeploy(ctx, running.ID)
+		require.NoError(t, err)
+		require.True(t, started)
+
+		waits := queueWaits(t, "queued")
+		waiting := make(chan string, 1)
+		done := make(chan error, 1)
+		q := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v2", PID: os.Getpid()}
+		go func() {
+			done <- waitInDeployQueue(ctx, store, q, func(msg string) { waiting <- msg })
+		}()
+		assert.Contains(t, <-waiting, "Waiting for the deploy of v1")
+
+		newer := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v3", PID: os.Getpid()}
+		require.NoError(t, store.EnqueueDeploy(ctx, newer))
+		err = <-done
+		assert.ErrorIs(t, err, apperrors.ErrDeploySuperseded)
+		assert.Equal(t, apperrors.ExitDeploySuperseded, apperrors.ExitCode(err))
+		assert.EqualError(t, err, "superseded by a newer deploy of v3 ("+shortID(newer.ID)+"); the deploy of v2 did not run")
+		assert.Equal(t, newer.ID, q.SupersededBy)
+		assert.Equal(t, waits+1, queueWaits(t, "queued"), "the wait is recorded when the deploy gives up")
+
+		// The newest request runs once the running deploy finishes
+		finishQueuedDeploy(ctx, store, running, nil)
+		started, err = store.StartQueuedDeploy(ctx, newer.ID)
+		require.NoError(t, err)
+		assert.True(t, started)
+		finishQueuedDeploy(ctx, store, newer, nil)
+	})
+
+	t.Run("deploys left by killed processes are failed", func(t *testing.T) {
+		dead := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v4", PID: 99999999}
+		require.NoError(t, store.EnqueueDeploy(ctx, dead))
+		started, err := store.StartQueuedDeploy(ctx, dead.ID)
+		require.NoError(t, err)
+		require.True(t, started)
+
+		// Listing the queue has no side effects
+		queueListCmd.SetContext(ctx)
+		stdout, _, err := captureOutput(func() error { return runQueueList(queueListCmd, []string{"queued"}) })
+		require.NoError(t, err)
+		assert.Contains(t, stdout, shortID(dead.ID))
+		got, err := store.GetQueuedDeploy(ctx, dead.ID)
+		require.NoError(t, err)
+		assert.Equal(t, state.QueueRunning, got.Status)
+
+		q := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v5", PID: os.Getpid()}
+		require.NoError(t, waitInDeployQueue(ctx, store, q, func(string) {}))
+		finishQueuedDeploy(ctx, store, q, nil)
+
+		got, err = store.GetQueuedDeploy(ctx, dead.ID)
+		require.NoError(t, err)
+		assert.Equal(t, state.QueueFailed, got.Status)
+		assert.Contains(t, got.Error, "exited before the deploy finished")
+	})
+
+	t.Run("list and cancel", func(t *testing.T) {
+		running := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v6", PID: os.Getpid()}
+		require.NoError(t, store.EnqueueDeploy(ctx, running))
+		_, err := store.StartQueuedDeploy(ctx, running.ID)
+		require.NoError(t, err)
+		defer finishQueuedDeploy(ctx, store, running, nil)
+
+		pending := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v7", Hosts: []string{"web1"}, TriggerSource: state.TriggerWebhook, TriggeredBy: "ci", PID: os.Getpid()}
+		require.NoError(t, store.EnqueueDeploy(ctx, pending))
+
+		queueListCmd.SetContext(ctx)
+		stdout, _, err := captureOutput(func() error { return runQueueList(queueListCmd, []string{"queued"}) })
+		require.NoError(t, err)
+		assert.Contains(t, stdout, shortID(running.ID))
+		assert.Contains(t, stdout, "running")
+		assert.Contains(t, stdout, "webhook (ci)")
+		assert.Contains(t, stdout, "web1")
+
+		viper.Set("output", outputJSON)
+		stdout, _, err = captureOutput(func() error { return runQueueList(queueListCmd, nil) })
+		viper.Set("output", "")
+		require.NoError(t, err)
+		var doc struct {
+			Kind string
+			Data queueListOutput
+		}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &doc), stdout)
+		assert.Equal(t, kindDeployQueue, doc.Kind)
+		require.Len(t, doc.Data.Deploys, 2)
+		assert.Equal(t, "queued", doc.Data.Deploys[1].Project)
+		assert.Equal(t, []string{"web1"}, doc.Data.Deploys[1].Hosts)
+
+		queueCancelCmd.SetContext(ctx)
+		_, _, err = captureOutput(func() error { return runQueueCancel(queueCancelCmd, []string{shortID(pending.ID)}) })
+		require.NoError(t, err)
+		got, err := store.GetQueuedDeploy(ctx, pending.ID)
+		require.NoError(t, err)
+		assert.Equal(t, state.QueueCancelled, got.Status)
+
+		_, _, err = captureOutput(func() error { return runQueueCancel(queueCancelCmd, []string{running.ID}) })
+		assert.ErrorContains(t, err, "only pending deploys can be cancelled")
+		_, _, err = captureOutput(func() error { return runQueueCancel(queueCancelCmd, []string{"nope"}) })
+		assert.ErrorIs(t, err, apperrors.ErrQueuedDeployNotFound)
+	})
+
+	t.Run("rollbacks wait for running deploys", func(t *testing.T) {
+		running := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v10", PID: os.Getpid()}
+		require.NoError(t, store.EnqueueDeploy(ctx, running))
+		_, err := store.StartQueuedDeploy(ctx, running.ID)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		err = rollbackProject(waitCtx, store, dataDir, "queued", rollbackOptions{Stdout: &out, Stderr: &out})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, out.String(), "Waiting for the deploy of v10")
+
+		// Once the deploy finishes the rollback runs, and its outcome is recorded
+		finishQueuedDeploy(ctx, store, running, nil)
+		err = rollbackProject(ctx, store, dataDir, "queued", rollbackOptions{Stdout: &out, Stderr: &out})
+		assert.ErrorIs(t, err, apperrors.ErrNoActiveDeployment)
+		queued, err := store.ListQueuedDeploys(ctx, project.ID)
+		require.NoError(t, err)
+		assert.Empty(t, queued)
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		running := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v8", PID: os.Getpid()}
+		require.NoError(t, store.EnqueueDeploy(ctx, running))
+		_, err := store.StartQueuedDeploy(ctx, running.ID)
+		require.NoError(t, err)
+		defer finishQueuedDeploy(ctx, store, running, nil)
+
+		q := &state.QueuedDeploy{ID: "queued-v9", ProjectID: project.ID, GitRef: "v9", Hosts: []string{"web2"}, PID: os.Getpid()}
+		errc := make(chan error, 1)
+		go func() {
+			errc <- waitInDeployQueue(ctx, store, q, func(string) {})
+		}()
+		require.Eventually(t, func() bool {
+			return store.CancelQueuedDeploy(ctx, q.ID) == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		err = <-errc
+		assert.ErrorIs(t, err, apperrors.ErrQueuedDeployCancelled)
+		assert.Equal(t, apperrors.ExitQueuedDeployCancelled, apperrors.ExitCode(err))
+	})
+
+	t.Run("waits for an older pending request", func(t *testing.T) {
+		older := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v11", Hosts: []string{"web1"}, PID: os.Getpid()}
+		require.NoError(t, store.EnqueueDeploy(ctx, older))
+
+		waiting := make(chan string, 1)
+		done := make(chan error, 1)
+		q := &state.QueuedDeploy{ProjectID: project.ID, GitRef: "v12", Hosts: []string{"web2"}, PID: os.Getpid()}
+		go func() {
+			done <- waitInDeployQueue(ctx, store, q, func(msg string) { waiting <- msg })
+		}()
+		assert.Contains(t, <-waiting, "Waiting for the deploy of v11")
+
+		started, err := store.StartQueuedDeploy(ctx, older.ID)
+		require.NoError(t, err)
+		require.True(t, started)
+		finishQueuedDeploy(ctx, store, older, nil)
+
+		require.NoError(t, <-done)
+		finishQueuedDeploy(ctx, store, q, nil)
+	})
+}
+
+// queueWaits returns the number of deploy queue waits recorded for project.
+func queueWaits(t *testing.T, project string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, metrics.DeployQueueWait.WithLabelValues(project).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestStructuredOutput(t *testing.T) {
+	ctx := context.Background()
+	oldDataDir := dataDir
+	defer func() { dataDir = oldDataDir }()
+	dataDir = t.TempDir()
+
+	store, err := state.New(dataDir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	project := &state.Project{Name: "myapp", RepoType: "local", RepoPath: "/srv/myapp", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, project))
+	require.NoError(t, store.SetEnvVars(ctx, project.ID, map[string]string{"DB_PASSWORD": "supersecret", "PORT": "80"}))
+	deployment := &state.Deployment{ProjectID: project.ID, GitSHA: "abc1234567890", GitRef: "main", Status: "failed"}
+	require.NoError(t, store.CreateDeployment(ctx, deployment))
+
+	useOutput := func(t *testing.T, format string) {
+		viper.Set("output", format)
+		t.Cleanup(func() { viper.Set("output", "") })
+	}
+	decode := func(t *testing.T, stdout, kind string) map[string]interface{} {
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &doc), stdout)
+		assert.EqualValues(t, outputSchemaVersion, doc["schema_version"])
+		assert.Equal(t, kind, doc["kind"])
+		return doc["data"].(map[string]interface{})
+	}
+
+	t.Run("project list", func(t *testing.T) {
+		useOutput(t, outputJSON)
+		projectListCmd.SetContext(ctx)
+
+		stdout, _, err := captureOutput(func() error { return runProjectList(projectListCmd, nil) })
+		require.NoError(t, err)
+		data := decode(t, stdout, kindProjectList)
+		projects := data["projects"].([]interface{})
+		require.Len(t, projects, 1)
+		p := projects[0].(map[string]interface{})
+		assert.Equal(t, "myapp", p["name"])
+		assert.Equal(t, "local", p["type"])
+		assert.Equal(t, "ready", p["status"])
+		assert.Equal(t, "/srv/myapp", p["repo_path"])
+	})
+
+	t.Run("env list is masked", func(t *testing.T) {
+		useOutput(t, outputJSON)
+		envListCmd.SetContext(ctx)
+
+		stdout, _, err := captureOutput(func() error { return runEnvList(envListCmd, []string{"myapp"}) })
+		require.NoError(t, err)
+		assert.NotContains(t, stdout, "supersecret")
+		data := decode(t, stdout, kindEnvList)
+		assert.Equal(t, true, data["masked"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"key": "DB_PASSWORD", "value": "su*******et"},
+			map[string]interface{}{"key": "PORT", "value": "****"},
+		}, data["variables"])
+	})
+
+	t.Run("history", func(t *testing.T) {
+		useOutput(t, outputJSON)
+		historyCmd.SetContext(ctx)
+
+		stdout, _, err := captureOutput(func() error { return runHistory(historyCmd, []string{"myapp"}) })
+		require.NoError(t, err)
+		data := decode(t, stdout, kindHistory)
+		deployments := data["deployments"].([]interface{})
+		require.Len(t, deployments, 1)
+		d := deployments[0].(map[string]interface{})
+		assert.Equal(t, deployment.ID, d["id"])
+		assert.Equal(t, "abc1234", d["short_sha"])
+		assert.Equal(t, "failed", d["status"])
+	})
+
+	t.Run("yaml uses the json field names", func(t *testing.T) {
+		useOutput(t, outputYAML)
+
+		var buf bytes.Buffer
+		require.NoError(t, writeOutput(&buf, kindEnvList, envListOutput{Project: "myapp", Masked: true, Variables: []envVarEntry{{Key: "PORT", Value: "****"}}}))
+		assert.Equal(t, `schema_version: 1
+kind: EnvList
+data:
+  project: myapp
+  masked: true
+  variables:
+    - key: PORT
+      value: '****'
+`, buf.String())
+	})
+
+	t.Run("unknown formats are rejected", func(t *testing.T) {
+		useOutput(t, "xml")
+		err := checkOutputFormat(statusCmd)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid output format")
+	})
+
+	t.Run("json is rejected by commands that only write text", func(t *testing.T) {
+		useOutput(t, outputJSON)
+		flag := rootCmd.PersistentFlags().Lookup("output")
+		flag.Changed = true
+		defer func() { flag.Changed = false }()
+
+		assert.NoError(t, checkOutputFormat(statusCmd))
+		assert.NoError(t, checkOutputFormat(deployCmd))
+		err := checkOutputFormat(logsCmd)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not supported")
+	})
+}
+
+func TestDeployOutput(t *testing.T) {
+	targets := []target.Target{{Name: "web1"}, {Name: "web2"}, {Name: "web3"}}
+	out := newDeployOutput("myapp", targets)
+
+	out.record(targets[0], &orchestrator.DeployResult{Deployment: &state.Deployment{ID: "d-1"}}, nil)
+	out.record(targets[1], nil, fmt.Errorf("wrapped: %w", &orchestrator.DeployError{
+		DeploymentID: "d-2",
+		Validation: &validate.ValidationResult{
+			Missing: []validate.MissingVar{{Name: "DB_PASSWORD", IsRequired: true, Services: []string{"web"}}},
+			Invalid: []validate.InvalidVar{{Name: "PORT", Message: "must be an integer"}},
+		},
+		Err: errors.New("missing required environment variables"),
+	}))
+
+	assert.Equal(t, "succeeded", out.Hosts[0].Status)
+	assert.Equal(t, "failed", out.Hosts[1].Status)
+	assert.Equal(t, "skipped", out.Hosts[2].Status)
+	assert.Equal(t, map[string]string{"web1": "d-1", "web2": "d-2"}, out.deploymentIDs)
+	assert.Equal(t, &validationFailures{
+		Missing: []missingVarEntry{{Name: "DB_PASSWORD", Services: []string{"web"}}},
+		Invalid: []invalidVarEntry{{Name: "PORT", Message: "must be an integer"}},
+	}, out.Hosts[1].Validation)
+	assert.Nil(t, newValidationFailures(nil))
+}
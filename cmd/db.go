@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect and migrate the state database",
+	Long: `Inspect and migrate the OtterStack state database.
+
+Every command applies pending schema migrations when it opens the database.
+Use these commands to see what would change before upgrading, or to check
+the schema after an upgrade.`,
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending schema migrations",
+	Long: `Show every schema migration known to this version of OtterStack and
+whether it has been applied.
+
+Migrations applied by a newer OtterStack, and applied migrations whose
+contents no longer match the checksum recorded when they ran, are flagged.`,
+	Args: cobra.NoArgs,
+	RunE: runDBStatus,
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	Long: `Apply pending schema migrations to the state database.
+
+Each migration runs in its own transaction, so a failed migration leaves
+the schema at the last one that succeeded.
+
+Examples:
+  otterstack db migrate --dry-run
+  otterstack db migrate`,
+	Args: cobra.NoArgs,
+	RunE: runDBMigrate,
+}
+
+var dbDryRunFlag bool
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+
+	dbMigrateCmd.Flags().BoolVar(&dbDryRunFlag, "dry-run", false, "list pending migrations without applying them")
+}
+
+// openStoreUnmigrated opens the state database without applying migrations.
+func openStoreUnmigrated() (*state.Store, error) {
+	dir, err := getDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := state.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	return store, nil
+}
+
+func runDBStatus(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := openStoreUnmigrated()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	statuses, err := store.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, pending := 0, 0
+	for _, st := range statuses {
+		if st.Applied && st.Version > current {
+			current = st.Version
+		}
+		if !st.Applied {
+			pending++
+		}
+	}
+
+	fmt.Printf("Data directory: %s\n", store.DataDir())
+	fmt.Printf("Schema version: %d\n", current)
+	fmt.Printf("Pending migrations: %d\n\n", pending)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  VERSION\tNAME\tSTATUS\tAPPLIED")
+	fmt.Fprintln(w, "  -------\t----\t------\t-------")
+	for _, st := range statuses {
+		applied := "-"
+		if st.AppliedAt != nil {
+			applied = st.AppliedAt.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "  %03d\t%s\t%s\t%s\n", st.Version, st.Name, migrationState(st), applied)
+	}
+	w.Flush()
+
+	return nil
+}
+
+// migrationState describes a migration in the "db status" table.
+func migrationState(st state.MigrationStatus) string {
+	switch {
+	case st.Unknown:
+		return "applied by newer otterstack"
+	case st.Modified:
+		return "applied, modified since"
+	case st.Applied:
+		return "applied"
+	}
+	return "pending"
+}
+
+func runDBMigrate(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := openStoreUnmigrated()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	pending, err := store.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		fmt.Println("Schema is up to date.")
+		return nil
+	}
+
+	if dbDryRunFlag {
+		fmt.Printf("Would apply %d migration(s):\n", len(pending))
+		for _, m := range pending {
+			fmt.Printf("  %s\n", m.ID())
+		}
+		return nil
+	}
+
+	applied, err := store.Migrate(ctx)
+	for _, m := range applied {
+		fmt.Printf("✓ Applied %s\n", m.ID())
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema is at version %d.\n", applied[len(applied)-1].Version)
+	return nil
+}
@@ -1,15 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
 	"github.com/jayteealao/otterstack/internal/orchestrator"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
 	"github.com/jayteealao/otterstack/internal/validate"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var deployCmd = &cobra.Command{
@@ -19,24 +27,50 @@ var deployCmd = &cobra.Command{
 
 If no reference is specified, the default branch (main/master) is used.
 
+Projects with hosts (see "otterstack project hosts") are deployed to one
+host at a time in their configured order, all at the commit resolved for
+the first host. The rollout stops at the first host that fails; hosts
+already updated keep the new deployment. Every host is checked for a
+working docker connection before the first one is touched.
+
 Examples:
   otterstack deploy myapp v1.0.0
   otterstack deploy myapp main
-  otterstack deploy myapp abc123d`,
-	Args: cobra.RangeArgs(1, 2),
-	RunE: runDeploy,
+  otterstack deploy myapp abc123d
+  otterstack deploy myapp --host web2   # Only one of the project's hosts
+
+With --output json or yaml, progress and docker output go to stderr and a
+DeployResult is written to stdout whether the deployment succeeds or fails,
+with the final deployment record of every host and any environment
+variables that failed validation.
+
+Deployments of all projects on this machine can be limited with
+max_concurrent_deploys in the config file (OTTERSTACK_MAX_CONCURRENT_DEPLOYS),
+and made to wait for free disk and memory with min_free_disk, min_free_memory
+and resource_wait; see the README.`,
+	Args:        cobra.RangeArgs(1, 2),
+	RunE:        runDeploy,
+	Annotations: structuredOutput,
 }
 
 var (
 	deployTimeoutFlag time.Duration
 	skipPullFlag      bool
+	rebuildFlag       bool
+	triggerSourceFlag string
+	triggeredByFlag   string
+	deployHostsFlag   []string
 )
 
 func init() {
 	rootCmd.AddCommand(deployCmd)
 
-	deployCmd.Flags().DurationVar(&deployTimeoutFlag, "timeout", 5*time.Minute, "deployment timeout")
+	deployCmd.Flags().DurationVar(&deployTimeoutFlag, "timeout", 5*time.Minute, "deployment timeout (overrides timeouts.deploy in otterstack.yaml)")
 	deployCmd.Flags().BoolVar(&skipPullFlag, "skip-pull", false, "skip pulling images before deployment")
+	deployCmd.Flags().BoolVar(&rebuildFlag, "rebuild", false, "rebuild images even if they were already built for this commit")
+	deployCmd.Flags().StringVar(&triggerSourceFlag, "trigger-source", state.TriggerCLI, "what started the deployment: cli, webhook or schedule")
+	deployCmd.Flags().StringVar(&triggeredByFlag, "triggered-by", "", "who started the deployment, e.g. a webhook sender or API token name (default: current user)")
+	deployCmd.Flags().StringSliceVar(&deployHostsFlag, "host", nil, "only deploy to these of the project's hosts (repeatable)")
 }
 
 func runDeploy(cmd *cobra.Command, args []string) error {
@@ -51,6 +85,16 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	switch triggerSourceFlag {
+	case state.TriggerCLI, state.TriggerWebhook, state.TriggerSchedule:
+	default:
+		return fmt.Errorf("invalid --trigger-source %q (must be cli, webhook or schedule)", triggerSourceFlag)
+	}
+	triggeredBy := triggeredByFlag
+	if triggeredBy == "" {
+		triggeredBy = currentUser()
+	}
+
 	// Initialize store
 	store, err := initStore()
 	if err != nil {
@@ -62,7 +106,7 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
@@ -80,32 +124,366 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	targets, err := projectTargets(ctx, store, project, deployHostsFlag)
+	if err != nil {
+		return err
+	}
+
 	// Initialize git manager and deployer
 	gitMgr := git.NewManager(project.RepoPath)
 	deployer := orchestrator.NewDeployer(store, gitMgr)
 
-	// Deploy
-	result, err := deployer.Deploy(ctx, project, orchestrator.DeployOptions{
+	maxConcurrent, resources, err := deployLimits()
+	if err != nil {
+		return err
+	}
+
+	// An explicit --timeout overrides otterstack.yaml; otherwise the deployer uses the configured value
+	var timeout time.Duration
+	if cmd.Flags().Changed("timeout") {
+		timeout = deployTimeoutFlag
+	}
+
+	opts := orchestrator.DeployOptions{
 		GitRef:    gitRef,
-		Timeout:   deployTimeoutFlag,
+		Timeout:   timeout,
 		SkipPull:  skipPullFlag,
+		Rebuild:   rebuildFlag,
 		DataDir:   dataDir,
 		OnStatus:  func(msg string) { fmt.Println(msg) },
-		OnVerbose: func(msg string) { printVerbose("%s", msg) },
-	})
+		OnVerbose: printVerboseLine,
+
+		MaxConcurrent: maxConcurrent,
+		Resources:     resources,
+
+		TriggerSource: triggerSourceFlag,
+		TriggeredBy:   triggeredBy,
+	}
+
+	if isStructuredOutput() {
+		// Keep stdout for the result document
+		opts.OnStatus = func(msg string) { fmt.Fprintln(os.Stderr, msg) }
+		opts.Stdout = os.Stderr
+	}
+
+	// Deploys of a project run one at a time; a newer request for the same
+	// hosts replaces this one while it waits
+	queued := &state.QueuedDeploy{
+		ProjectID:     project.ID,
+		GitRef:        gitRef,
+		Hosts:         deployHostsFlag,
+		TriggerSource: triggerSourceFlag,
+		TriggeredBy:   triggeredBy,
+		PID:           os.Getpid(),
+	}
+	if err := waitInDeployQueue(ctx, store, queued, opts.OnStatus); err != nil {
+		if errors.Is(err, apperrors.ErrDeploySuperseded) && isStructuredOutput() {
+			report := newDeployOutput(projectName, targets)
+			report.SupersededBy = queued.SupersededBy
+			report.finish(ctx, store, err)
+			if writeErr := writeOutput(os.Stdout, kindDeployResult, report); writeErr != nil {
+				return writeErr
+			}
+		}
+		return err
+	}
+
+	if isStructuredOutput() {
+		report := newDeployOutput(projectName, targets)
+		result, err := rollout(ctx, deployer, project, targets, opts, report.record)
+		finishQueuedDeploy(ctx, store, queued, err)
+		report.finish(context.WithoutCancel(ctx), store, err)
+		if writeErr := writeOutput(os.Stdout, kindDeployResult, report); writeErr != nil && err == nil {
+			err = writeErr
+		}
+		if err != nil {
+			return err
+		}
+		cleanupWorktrees(ctx, deployer, project, dataDir, result)
+		return nil
+	}
+
+	result, err := rollout(ctx, deployer, project, targets, opts, nil)
+	finishQueuedDeploy(ctx, store, queued, err)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Deployment successful! %s deployed at %s\n", projectName, result.ShortSHA)
+	if isRemote(targets) {
+		fmt.Printf("Deployment successful! %s deployed at %s to %d host(s)\n", projectName, result.ShortSHA, len(targets))
+	} else {
+		fmt.Printf("Deployment successful! %s deployed at %s\n", projectName, result.ShortSHA)
+	}
+	if result.LogPath != "" {
+		printVerbose("Deployment log: otterstack logs %s", result.Deployment.ID)
+	}
+
+	cleanupWorktrees(ctx, deployer, project, dataDir, result)
+	return nil
+}
 
-	// Clean up old worktrees if retention limit exceeded
-	if project.WorktreeRetention > 0 {
-		if err := deployer.CleanupOldWorktrees(ctx, project, dataDir, func(msg string) { printVerbose("%s", msg) }); err != nil {
-			printVerbose("Warning: failed to cleanup old worktrees: %v", err)
+// isRemote reports whether a rollout leaves the local engine, so hosts are
+// named as they are deployed to.
+func isRemote(targets []target.Target) bool {
+	return len(targets) > 1 || !targets[0].IsLocal()
+}
+
+// rollout deploys a project to each target in turn. Remote hosts are checked
+// before the first one is touched, rather than stopping half way, and every
+// host gets the commit resolved for the first even if the branch moves meanwhile.
+// onHost, if set, is called with the outcome of each host deployed to.
+func rollout(ctx context.Context, deployer *orchestrator.Deployer, project *state.Project, targets []target.Target, opts orchestrator.DeployOptions,
+	onHost func(target.Target, *orchestrator.DeployResult, error)) (*orchestrator.DeployResult, error) {
+	remote := isRemote(targets)
+	if remote {
+		for _, t := range targets {
+			opts.OnVerbose(fmt.Sprintf("Checking connection to %s (%s)...", t, t.Endpoint()))
+			if _, err := target.Check(ctx, t); err != nil {
+				return nil, err
+			}
 		}
 	}
 
+	var result *orchestrator.DeployResult
+	for i, t := range targets {
+		if remote {
+			opts.OnStatus(fmt.Sprintf("==> [%d/%d] %s", i+1, len(targets), t))
+		}
+		opts.Target = t
+
+		var err error
+		result, err = deployer.Deploy(ctx, project, opts)
+		if onHost != nil {
+			onHost(t, result, err)
+		}
+		if err != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("deployment to %s failed: %w\n\n%s", t, err, rolloutSummary(targets, i))
+			}
+			return nil, err
+		}
+
+		opts.SHA = result.Deployment.GitSHA
+		opts.GitRef = result.Deployment.GitRef
+	}
+	return result, nil
+}
+
+// cleanupWorktrees removes worktrees beyond the retention limit in effect for
+// a successful deployment.
+func cleanupWorktrees(ctx context.Context, deployer *orchestrator.Deployer, project *state.Project, dataDir string, result *orchestrator.DeployResult) {
+	if result.Config.WorktreeRetention <= 0 {
+		return
+	}
+	retained := *project
+	retained.WorktreeRetention = result.Config.WorktreeRetention
+	if err := deployer.CleanupOldWorktrees(ctx, &retained, dataDir, func(msg string) { printVerbose("%s", msg) }); err != nil {
+		printVerbose("Warning: failed to cleanup old worktrees: %v", err)
+	}
+}
+
+// deployLimits reads the host-wide deployment limits from the config file or
+// OTTERSTACK_* environment variables: the number of deployments that may run
+// at once, and the free disk and memory a deployment waits for.
+func deployLimits() (int, orchestrator.ResourceCheck, error) {
+	var resources orchestrator.ResourceCheck
+
+	var maxConcurrent int
+	if v := viper.GetString("max_concurrent_deploys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, resources, fmt.Errorf("invalid max_concurrent_deploys %q: must be a whole number, 0 for no limit", v)
+		}
+		maxConcurrent = n
+	}
+
+	for _, size := range []struct {
+		key string
+		dst *uint64
+	}{
+		{"min_free_disk", &resources.MinFreeDisk},
+		{"min_free_memory", &resources.MinFreeMemory},
+	} {
+		if v := viper.GetString(size.key); v != "" {
+			n, err := parseByteSize(v)
+			if err != nil {
+				return 0, resources, fmt.Errorf("invalid %s: %w", size.key, err)
+			}
+			*size.dst = n
+		}
+	}
+	resources.DiskPath = viper.GetString("min_free_disk_path")
+
+	if v := viper.GetString("resource_wait"); v != "" {
+		wait, err := time.ParseDuration(v)
+		if err != nil || wait < 0 {
+			return 0, resources, fmt.Errorf("invalid resource_wait %q: must be a duration such as 10m", v)
+		}
+		resources.Wait = wait
+	}
+	return maxConcurrent, resources, nil
+}
+
+// byteSizeUnits maps size suffixes to their multipliers, in the decimal and
+// binary units docker prints.
+var byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// parseByteSize parses a size such as "10GB", "512MiB" or "1048576" into bytes.
+func parseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := byteSizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if err != nil || !ok {
+		return 0, fmt.Errorf("invalid size %q (e.g. 10GB or 512MiB)", s)
+	}
+	return uint64(math.Round(v * unit)), nil
+}
+
+// rolloutSummary describes which hosts a rolling deployment that failed at
+// targets[failed] had already updated.
+func rolloutSummary(targets []target.Target, failed int) string {
+	names := func(ts []target.Target) string {
+		parts := make([]string, len(ts))
+		for i, t := range ts {
+			parts[i] = t.String()
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	return "Updated: " + names(targets[:failed]) + "\nNot updated: " + names(targets[failed:])
+}
+
+// deployOutput is the data of a DeployResult document.
+type deployOutput struct {
+	Project   string             `json:"project"`
+	Succeeded bool               `json:"succeeded"`
+	Error     string             `json:"error,omitempty"`
+	Hosts     []deployHostOutput `json:"hosts"`
+
+	// SupersededBy is the ID of the newer queued deploy that replaced this
+	// one before it ran; nothing was deployed.
+	SupersededBy string `json:"superseded_by,omitempty"`
+
+	deploymentIDs map[string]string // host name to deployment ID
+}
+
+// deployHostOutput is the outcome of a deployment to one host. Status is
+// succeeded, failed, or skipped for hosts a failed rollout did not reach.
+type deployHostOutput struct {
+	Host       string              `json:"host"`
+	Status     string              `json:"status"`
+	Deployment *historyEntry       `json:"deployment,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	Validation *validationFailures `json:"validation,omitempty"`
+}
+
+// validationFailures lists the environment variables that stopped a
+// deployment. Values are never included.
+type validationFailures struct {
+	Missing []missingVarEntry `json:"missing"`
+	Invalid []invalidVarEntry `json:"invalid"`
+}
+
+type missingVarEntry struct {
+	Name     string   `json:"name"`
+	Message  string   `json:"message,omitempty"`
+	Services []string `json:"services,omitempty"`
+}
+
+type invalidVarEntry struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+func newDeployOutput(projectName string, targets []target.Target) *deployOutput {
+	out := &deployOutput{Project: projectName, deploymentIDs: make(map[string]string)}
+	for _, t := range targets {
+		out.Hosts = append(out.Hosts, deployHostOutput{Host: t.String(), Status: "skipped"})
+	}
+	return out
+}
+
+// record is passed to rollout to note the outcome of each host.
+func (o *deployOutput) record(t target.Target, result *orchestrator.DeployResult, err error) {
+	host := o.host(t.String())
+	if host == nil {
+		return
+	}
+
+	var deployErr *orchestrator.DeployError
+	switch {
+	case err == nil:
+		host.Status = "succeeded"
+		o.deploymentIDs[host.Host] = result.Deployment.ID
+	case errors.As(err, &deployErr):
+		host.Status, host.Error = "failed", err.Error()
+		o.deploymentIDs[host.Host] = deployErr.DeploymentID
+		host.Validation = newValidationFailures(deployErr.Validation)
+	default:
+		host.Status, host.Error = "failed", err.Error()
+	}
+}
+
+// finish sets the outcome of the rollout and reads the final record of every
+// deployment it made.
+func (o *deployOutput) finish(ctx context.Context, store *state.Store, err error) {
+	o.Succeeded = err == nil
+	if err != nil {
+		o.Error = err.Error()
+	}
+	for i := range o.Hosts {
+		id := o.deploymentIDs[o.Hosts[i].Host]
+		if id == "" {
+			continue
+		}
+		d, err := store.GetDeployment(ctx, id)
+		if err != nil {
+			printVerbose("Warning: failed to read deployment %s: %v", shortID(id), err)
+			continue
+		}
+		entry := newHistoryEntry(d)
+		o.Hosts[i].Deployment = &entry
+	}
+}
+
+func (o *deployOutput) host(name string) *deployHostOutput {
+	for i := range o.Hosts {
+		if o.Hosts[i].Host == name {
+			return &o.Hosts[i]
+		}
+	}
 	return nil
 }
 
+// newValidationFailures converts a failed env validation, or returns nil if
+// there is none.
+func newValidationFailures(result *validate.ValidationResult) *validationFailures {
+	if result == nil {
+		return nil
+	}
+	failures := &validationFailures{Missing: []missingVarEntry{}, Invalid: []invalidVarEntry{}}
+	for _, v := range result.Missing {
+		failures.Missing = append(failures.Missing, missingVarEntry{Name: v.Name, Message: v.ErrorMessage, Services: v.Services})
+	}
+	for _, v := range result.Invalid {
+		failures.Invalid = append(failures.Invalid, invalidVarEntry{Name: v.Name, Message: v.Message})
+	}
+	return failures
+}
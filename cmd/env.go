@@ -11,6 +11,7 @@ import (
 	"text/tabwriter"
 
 	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/envschema"
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/prompt"
 	"github.com/jayteealao/otterstack/internal/validate"
@@ -63,8 +64,9 @@ By default, values are masked. Use --show-values to reveal them.
 Examples:
   otterstack env list myapp
   otterstack env list myapp --show-values`,
-	Args: cobra.ExactArgs(1),
-	RunE: runEnvList,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runEnvList,
+	Annotations: structuredOutput,
 }
 
 var envUnsetCmd = &cobra.Command{
@@ -145,7 +147,7 @@ func runEnvSet(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
@@ -197,7 +199,7 @@ func runEnvGet(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
@@ -247,7 +249,7 @@ func runEnvList(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
@@ -258,6 +260,18 @@ func runEnvList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get env vars: %w", err)
 	}
 
+	if isStructuredOutput() {
+		result := envListOutput{Project: projectName, Masked: !showValuesFlag, Variables: []envVarEntry{}}
+		for _, k := range sortedKeys(vars) {
+			value := vars[k]
+			if !showValuesFlag {
+				value = maskValue(value)
+			}
+			result.Variables = append(result.Variables, envVarEntry{Key: k, Value: value})
+		}
+		return writeOutput(os.Stdout, kindEnvList, result)
+	}
+
 	if len(vars) == 0 {
 		fmt.Println("No environment variables set.")
 		return nil
@@ -280,6 +294,19 @@ func runEnvList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// envListOutput is the data of an EnvList document. Values are masked
+// unless --show-values is set.
+type envListOutput struct {
+	Project   string        `json:"project"`
+	Masked    bool          `json:"masked"`
+	Variables []envVarEntry `json:"variables"`
+}
+
+type envVarEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
 func runEnvUnset(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	projectName := args[0]
@@ -294,7 +321,7 @@ func runEnvUnset(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
@@ -326,7 +353,7 @@ func runEnvLoad(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
@@ -435,20 +462,25 @@ func runEnvScan(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
 
+	cfg, err := resolveProjectConfig(project, project.RepoPath)
+	if err != nil {
+		return err
+	}
+
 	// Build path to compose file
-	composePath := filepath.Join(project.RepoPath, project.ComposeFile)
+	composePath := filepath.Join(project.RepoPath, cfg.ComposeFile)
 
 	// Check if compose file exists
 	if _, err := os.Stat(composePath); os.IsNotExist(err) {
-		return fmt.Errorf("compose file not found at %s", composePath)
+		return apperrors.WithMessage(apperrors.ErrComposeFileNotFound, fmt.Sprintf("compose file not found at %s", composePath))
 	}
 
-	fmt.Printf("🔍 Scanning %s for environment variables...\n", project.ComposeFile)
+	fmt.Printf("🔍 Scanning %s for environment variables...\n", cfg.ComposeFile)
 
 	// Parse compose file to find all required variables
 	requiredVars, err := compose.ParseEnvVars(composePath)
@@ -456,6 +488,16 @@ func runEnvScan(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to parse compose file: %w", err)
 	}
 
+	// Fold in the repository's env schema, if it ships one
+	schema, err := envschema.Load(project.RepoPath)
+	if err != nil {
+		return err
+	}
+	if schema != nil {
+		fmt.Printf("Using env schema %s\n", envschema.FileName)
+	}
+	requiredVars = schema.Apply(requiredVars)
+
 	if len(requiredVars) == 0 {
 		fmt.Println("✓ No environment variables found in compose file.")
 		return nil
@@ -469,6 +511,12 @@ func runEnvScan(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get stored env vars: %w", err)
 	}
 
+	// Stored values that violate the schema are collected again
+	for _, v := range schema.Validate(envVars) {
+		fmt.Printf("✗ %s does not match the schema: %s\n", v.Name, v.Message)
+		delete(envVars, v.Name)
+	}
+
 	// Identify missing variables
 	missingVars := compose.GetMissingVars(requiredVars, envVars)
 
@@ -480,7 +528,7 @@ func runEnvScan(cmd *cobra.Command, args []string) error {
 	fmt.Printf("\n%d variable(s) need to be configured\n", len(missingVars))
 
 	// Interactively collect missing variables
-	newVars, err := prompt.CollectMissingVars(missingVars)
+	newVars, err := prompt.CollectMissingVars(missingVars, schema)
 	if err != nil {
 		return fmt.Errorf("failed to collect variables: %w", err)
 	}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/orchestrator"
 	"github.com/jayteealao/otterstack/internal/state"
 	"github.com/jayteealao/otterstack/internal/tui"
 	"github.com/spf13/cobra"
@@ -18,14 +21,26 @@ var historyCmd = &cobra.Command{
 	Short: "Show deployment history",
 	Long: `Show the deployment history for a project.
 
-Displays recent deployments with their SHA, ref, status, and timestamp.`,
-	Args: cobra.ExactArgs(1),
-	RunE: runHistory,
+Displays recent deployments with their SHA, ref, status, timestamp, duration
+and who triggered them. Use --timing to break the duration down by phase.
+
+Use --graph to show the lineage of each deployment: arcs link rollbacks and
+redeploys to the deployment whose commit they reused, and deployments that
+were rolled back are marked with ✗.
+
+--json writes the deployments as a bare JSON list, as it always has;
+--output json or yaml writes them in a versioned document like the other
+commands.`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runHistory,
+	Annotations: structuredOutput,
 }
 
 var (
-	historyLimitFlag int
-	historyJSONFlag  bool
+	historyLimitFlag  int
+	historyJSONFlag   bool
+	historyTimingFlag bool
+	historyGraphFlag  bool
 )
 
 func init() {
@@ -33,6 +48,8 @@ func init() {
 
 	historyCmd.Flags().IntVarP(&historyLimitFlag, "limit", "n", 20, "number of deployments to show")
 	historyCmd.Flags().BoolVar(&historyJSONFlag, "json", false, "output in JSON format")
+	historyCmd.Flags().BoolVar(&historyTimingFlag, "timing", false, "show how long each deployment phase took")
+	historyCmd.Flags().BoolVar(&historyGraphFlag, "graph", false, "show rollbacks and redeploys as a lineage graph")
 }
 
 type historyEntry struct {
@@ -45,6 +62,34 @@ type historyEntry struct {
 	FinishedAt  *string `json:"finished_at,omitempty"`
 	Error       string  `json:"error,omitempty"`
 	WorktreePath string `json:"worktree_path,omitempty"`
+
+	TriggerSource string         `json:"trigger_source"`
+	TriggeredBy   string         `json:"triggered_by,omitempty"`
+	PreviousSHA   string         `json:"previous_sha,omitempty"`
+	Phases        []historyPhase `json:"phases,omitempty"`
+
+	Kind                string `json:"kind"`
+	ParentDeploymentID  string `json:"parent_deployment_id,omitempty"`
+	RevertsDeploymentID string `json:"reverts_deployment_id,omitempty"`
+
+	Host string `json:"host,omitempty"`
+}
+
+type historyPhase struct {
+	Name       string `json:"name"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// phaseColumns lists the phases shown by --timing, in execution order.
+var phaseColumns = []string{
+	orchestrator.PhaseFetch,
+	orchestrator.PhaseWorktree,
+	orchestrator.PhasePull,
+	orchestrator.PhaseBuild,
+	orchestrator.PhaseSnapshot,
+	orchestrator.PhaseUp,
+	orchestrator.PhaseHealth,
+	orchestrator.PhaseSwitch,
 }
 
 func runHistory(cmd *cobra.Command, args []string) error {
@@ -61,7 +106,7 @@ func runHistory(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if err == errors.ErrProjectNotFound {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
@@ -72,6 +117,10 @@ func runHistory(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to list deployments: %w", err)
 	}
 
+	if isStructuredOutput() && !historyJSONFlag {
+		return writeOutput(os.Stdout, kindHistory, historyOutput{Project: projectName, Deployments: historyEntries(deployments)})
+	}
+
 	if len(deployments) == 0 {
 		fmt.Printf("No deployments found for project %q.\n", projectName)
 		return nil
@@ -81,27 +130,67 @@ func runHistory(cmd *cobra.Command, args []string) error {
 		return outputHistoryJSON(deployments)
 	}
 
+	if historyGraphFlag {
+		outputHistoryGraph(projectName, deployments)
+		return nil
+	}
+
 	return outputHistoryTable(projectName, deployments)
 }
 
+// historyOutput is the data of a DeploymentHistory document.
+type historyOutput struct {
+	Project     string         `json:"project"`
+	Deployments []historyEntry `json:"deployments"`
+}
+
+// newHistoryEntry converts a deployment record to the form used by every
+// structured output that includes deployments.
+func newHistoryEntry(d *state.Deployment) historyEntry {
+	entry := historyEntry{
+		ID:           d.ID,
+		GitSHA:       d.GitSHA,
+		ShortSHA:     git.ShortSHA(d.GitSHA),
+		GitRef:       d.GitRef,
+		Status:       d.Status,
+		StartedAt:    d.StartedAt.Format("2006-01-02T15:04:05Z"),
+		Error:        d.ErrorMessage,
+		WorktreePath: d.WorktreePath,
+
+		TriggerSource: d.TriggerSource,
+		TriggeredBy:   d.TriggeredBy,
+		PreviousSHA:   d.PreviousSHA,
+
+		Kind:                d.Kind,
+		ParentDeploymentID:  d.ParentDeploymentID,
+		RevertsDeploymentID: d.RevertsDeploymentID,
+
+		Host: d.Host,
+	}
+	for _, p := range d.Phases {
+		entry.Phases = append(entry.Phases, historyPhase{Name: p.Name, DurationMS: p.Duration.Milliseconds()})
+	}
+	if d.FinishedAt != nil {
+		finishedStr := d.FinishedAt.Format("2006-01-02T15:04:05Z")
+		entry.FinishedAt = &finishedStr
+	}
+	return entry
+}
+
+// historyEntries converts deployment records with newHistoryEntry. It
+// returns an empty, not nil, list for no deployments.
+func historyEntries(deployments []*state.Deployment) []historyEntry {
+	entries := make([]historyEntry, 0, len(deployments))
+	for _, d := range deployments {
+		entries = append(entries, newHistoryEntry(d))
+	}
+	return entries
+}
+
 func outputHistoryJSON(deployments []*state.Deployment) error {
 	var entries []historyEntry
 	for _, d := range deployments {
-		entry := historyEntry{
-			ID:           d.ID,
-			GitSHA:       d.GitSHA,
-			ShortSHA:     git.ShortSHA(d.GitSHA),
-			GitRef:       d.GitRef,
-			Status:       d.Status,
-			StartedAt:    d.StartedAt.Format("2006-01-02T15:04:05Z"),
-			Error:        d.ErrorMessage,
-			WorktreePath: d.WorktreePath,
-		}
-		if d.FinishedAt != nil {
-			finishedStr := d.FinishedAt.Format("2006-01-02T15:04:05Z")
-			entry.FinishedAt = &finishedStr
-		}
-		entries = append(entries, entry)
+		entries = append(entries, newHistoryEntry(d))
 	}
 
 	enc := json.NewEncoder(os.Stdout)
@@ -113,8 +202,8 @@ func outputHistoryTable(projectName string, deployments []*state.Deployment) err
 	fmt.Printf("Deployment history for %s:\n\n", projectName)
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "  COMMIT\tREF\tSTATUS\tSTARTED\tDURATION")
-	fmt.Fprintln(w, "  ------\t---\t------\t-------\t--------")
+	fmt.Fprintln(w, "  ID\tCOMMIT\tREF\tHOST\tSTATUS\tSTARTED\tDURATION\tTRIGGERED BY")
+	fmt.Fprintln(w, "  --\t------\t---\t----\t------\t-------\t--------\t------------")
 
 	for _, d := range deployments {
 		ref := d.GitRef
@@ -124,25 +213,226 @@ func outputHistoryTable(projectName string, deployments []*state.Deployment) err
 
 		duration := "-"
 		if d.FinishedAt != nil {
-			dur := d.FinishedAt.Sub(d.StartedAt)
-			if dur.Seconds() < 60 {
-				duration = fmt.Sprintf("%.0fs", dur.Seconds())
-			} else {
-				duration = fmt.Sprintf("%.1fm", dur.Minutes())
-			}
+			duration = formatDuration(d.FinishedAt.Sub(d.StartedAt))
 		}
 
 		statusIcon := tui.GetStatusIcon(d.Status)
 
-		fmt.Fprintf(w, "  %s\t%s\t%s %s\t%s\t%s\n",
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s %s\t%s\t%s\t%s\n",
+			shortID(d.ID),
 			git.ShortSHA(d.GitSHA),
 			ref,
+			hostName(d),
 			statusIcon,
 			d.Status,
 			d.StartedAt.Format("2006-01-02 15:04"),
-			duration)
+			duration,
+			formatTrigger(d))
 	}
 	w.Flush()
 
+	if historyTimingFlag {
+		fmt.Println()
+		fmt.Println("Phase durations:")
+		fmt.Println()
+		outputPhaseTable(deployments)
+	}
+
+	fmt.Println()
+	fmt.Println("View the output of a deployment with: otterstack logs <id>")
+
 	return nil
 }
+
+// outputHistoryGraph prints the deployments newest first with their lineage drawn on the left.
+func outputHistoryGraph(projectName string, deployments []*state.Deployment) {
+	fmt.Printf("Deployment lineage for %s:\n\n", projectName)
+
+	graph := lineageGraph(deployments)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, d := range deployments {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			graph[i],
+			shortID(d.ID),
+			git.ShortSHA(d.GitSHA),
+			d.Kind,
+			d.Status,
+			d.StartedAt.Format("2006-01-02 15:04"),
+			lineageNote(d))
+	}
+	w.Flush()
+}
+
+// lineageGraph draws the graph column for deployments listed newest first.
+// Each deployment is a node, ✗ when it was rolled back or never went live,
+// and every rollback or redeploy is joined by an arc to the deployment it
+// reused. Arcs are packed into the fewest lanes that keep them apart.
+func lineageGraph(deployments []*state.Deployment) []string {
+	row := make(map[string]int, len(deployments))
+	for i, d := range deployments {
+		row[d.ID] = i
+	}
+
+	type arc struct{ from, to, lane int }
+	var arcs []arc
+	var lanes [][]bool // lanes[l][r] is true when lane l is in use at row r
+	for i, d := range deployments {
+		j, ok := row[d.ParentDeploymentID]
+		if d.ParentDeploymentID == "" || !ok || j <= i {
+			continue
+		}
+
+		lane := 0
+		for ; lane < len(lanes); lane++ {
+			free := true
+			for r := i; r <= j; r++ {
+				if lanes[lane][r] {
+					free = false
+					break
+				}
+			}
+			if free {
+				break
+			}
+		}
+		if lane == len(lanes) {
+			lanes = append(lanes, make([]bool, len(deployments)))
+		}
+		for r := i; r <= j; r++ {
+			lanes[lane][r] = true
+		}
+		arcs = append(arcs, arc{from: i, to: j, lane: lane})
+	}
+
+	lines := make([]string, len(deployments))
+	for r, d := range deployments {
+		// Glyph of each lane at this row, and the rightmost lane joined to the node
+		cells := make([]string, len(lanes))
+		joined := -1
+		for _, a := range arcs {
+			switch {
+			case r == a.from:
+				cells[a.lane] = "┬"
+			case r == a.to:
+				cells[a.lane] = "┴"
+			case r > a.from && r < a.to:
+				cells[a.lane] = "│"
+			default:
+				continue
+			}
+			if (r == a.from || r == a.to) && a.lane > joined {
+				joined = a.lane
+			}
+		}
+
+		var b strings.Builder
+		switch d.Status {
+		case "rolled_back", "failed", "interrupted":
+			b.WriteString("✗")
+		default:
+			b.WriteString("●")
+		}
+		for l, cell := range cells {
+			if l > joined {
+				if cell == "" {
+					cell = " "
+				}
+				b.WriteString(" " + cell)
+				continue
+			}
+			switch cell {
+			case "":
+				cell = "─"
+			case "│":
+				cell = "┼"
+			}
+			if l == joined {
+				cell = map[string]string{"┬": "╮", "┴": "╯"}[cell]
+			}
+			b.WriteString("─" + cell)
+		}
+		lines[r] = b.String()
+	}
+
+	return lines
+}
+
+// lineageNote describes how a deployment relates to earlier ones.
+func lineageNote(d *state.Deployment) string {
+	switch d.Kind {
+	case state.KindRollback:
+		var parts []string
+		if d.ParentDeploymentID != "" {
+			parts = append(parts, "restores "+shortID(d.ParentDeploymentID))
+		}
+		if d.RevertsDeploymentID != "" {
+			parts = append(parts, "reverts "+shortID(d.RevertsDeploymentID))
+		}
+		return strings.Join(parts, ", ")
+	case state.KindRedeploy:
+		if d.ParentDeploymentID != "" {
+			return "redeploy of " + shortID(d.ParentDeploymentID)
+		}
+	}
+	return ""
+}
+
+// outputPhaseTable prints one row of phase durations per deployment.
+func outputPhaseTable(deployments []*state.Deployment) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	header := []string{"COMMIT"}
+	rule := []string{"------"}
+	for _, name := range phaseColumns {
+		header = append(header, strings.ToUpper(name))
+		rule = append(rule, strings.Repeat("-", len(name)))
+	}
+	fmt.Fprintln(w, "  "+strings.Join(header, "\t"))
+	fmt.Fprintln(w, "  "+strings.Join(rule, "\t"))
+
+	for _, d := range deployments {
+		durations := make(map[string]time.Duration, len(d.Phases))
+		for _, p := range d.Phases {
+			durations[p.Name] += p.Duration
+		}
+
+		row := []string{git.ShortSHA(d.GitSHA)}
+		for _, name := range phaseColumns {
+			if dur, ok := durations[name]; ok {
+				row = append(row, formatDuration(dur))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		fmt.Fprintln(w, "  "+strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
+
+// formatDuration formats a duration the same way as the monitor's history view.
+func formatDuration(d time.Duration) string {
+	return tui.FormatDuration(d)
+}
+
+// formatPhases summarizes phase durations as "pull 12s, up 8s".
+func formatPhases(phases []state.DeploymentPhase) string {
+	parts := make([]string, 0, len(phases))
+	for _, p := range phases {
+		parts = append(parts, p.Name+" "+formatDuration(p.Duration))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatTrigger describes who started a deployment and how, e.g. "alice (cli)".
+func formatTrigger(d *state.Deployment) string {
+	return tui.FormatTrigger(d)
+}
+
+// shortID returns the leading characters of a deployment ID, enough to pass to "otterstack logs".
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+var hostCmd = &cobra.Command{
+	Use:   "host",
+	Short: "Manage remote Docker hosts",
+	Long: `Register remote Docker hosts that projects can deploy to.
+
+A host is reached over SSH (the docker CLI's DOCKER_HOST=ssh://...) or
+through a docker context. The SSH user must be able to run docker on the
+host without a password prompt. Git checkouts, env files and deployment
+state stay on this machine; only containers, images and volumes live on
+the host.`,
+}
+
+var hostAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Register a remote host",
+	Long: `Register a remote Docker host.
+
+The connection is checked by asking the host's docker engine for its
+version, unless --no-check is given.
+
+Examples:
+  otterstack host add web1 --ssh deploy@web1.example.com
+  otterstack host add web2 --ssh ssh://deploy@10.0.0.12:2222
+  otterstack host add staging --context staging`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHostAdd,
+}
+
+var hostListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List registered hosts",
+	Args:    cobra.NoArgs,
+	RunE:    runHostList,
+}
+
+var hostRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a registered host",
+	Long: `Remove a registered host. Projects deploying to it must be moved off it
+first with "otterstack project hosts". Deployment history keeps the host name.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHostRemove,
+}
+
+var hostCheckCmd = &cobra.Command{
+	Use:   "check [name...]",
+	Short: "Check the connection to hosts",
+	Long:  `Connect to the docker engine of each named host, or of every registered host.`,
+	RunE:  runHostCheck,
+}
+
+var projectHostsCmd = &cobra.Command{
+	Use:   "hosts <project> [host...]",
+	Short: "Show or set the hosts a project deploys to",
+	Long: `Show or set the hosts a project deploys to.
+
+With host names, replaces the project's hosts. Deployments go to the hosts
+one at a time in the order given, and stop at the first host that fails.
+With --local, the project deploys to the local Docker engine again.
+
+Examples:
+  otterstack project hosts myapp               # Show the hosts
+  otterstack project hosts myapp web1 web2     # Deploy to web1, then web2
+  otterstack project hosts myapp --local       # Deploy locally again`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runProjectHosts,
+}
+
+var (
+	hostSSHFlag     string
+	hostContextFlag string
+	hostNoCheckFlag bool
+	hostsLocalFlag  bool
+)
+
+func init() {
+	rootCmd.AddCommand(hostCmd)
+	hostCmd.AddCommand(hostAddCmd)
+	hostCmd.AddCommand(hostListCmd)
+	hostCmd.AddCommand(hostRemoveCmd)
+	hostCmd.AddCommand(hostCheckCmd)
+	projectCmd.AddCommand(projectHostsCmd)
+
+	hostAddCmd.Flags().StringVar(&hostSSHFlag, "ssh", "", "SSH address of the host: [ssh://][user@]host[:port]")
+	hostAddCmd.Flags().StringVar(&hostContextFlag, "context", "", "docker context to reach the host through")
+	hostAddCmd.Flags().BoolVar(&hostNoCheckFlag, "no-check", false, "register the host without connecting to it")
+	hostAddCmd.MarkFlagsMutuallyExclusive("ssh", "context")
+	hostAddCmd.MarkFlagsOneRequired("ssh", "context")
+
+	projectHostsCmd.Flags().BoolVar(&hostsLocalFlag, "local", false, "deploy the project to the local Docker engine")
+}
+
+func runHostAdd(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+
+	if err := validate.HostName(name); err != nil {
+		return err
+	}
+
+	host := &state.Host{Name: name}
+	if hostSSHFlag != "" {
+		dockerHost, err := target.ParseSSH(hostSSHFlag)
+		if err != nil {
+			return err
+		}
+		host.DockerHost = dockerHost
+	} else {
+		if err := target.ValidateContext(hostContextFlag); err != nil {
+			return err
+		}
+		host.DockerContext = hostContextFlag
+	}
+
+	if !hostNoCheckFlag {
+		fmt.Printf("Connecting to %s...\n", host.Target().Endpoint())
+		version, err := target.Check(ctx, host.Target())
+		if err != nil {
+			return fmt.Errorf("%w (use --no-check to register it anyway)", err)
+		}
+		fmt.Printf("✓ Docker %s\n", version)
+	}
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.CreateHost(ctx, host); err != nil {
+		if errors.Is(err, apperrors.ErrHostExists) {
+			return apperrors.WithMessage(err, fmt.Sprintf("host %q already exists", name))
+		}
+		return err
+	}
+
+	fmt.Printf("✓ Host %s added (%s)\n", name, host.Target().Endpoint())
+	fmt.Printf("\nDeploy a project to it with: otterstack project hosts <project> %s\n", name)
+	return nil
+}
+
+func runHostList(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	hosts, err := store.ListHosts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No hosts registered. Projects deploy to the local Docker engine.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tENDPOINT\tPROJECTS")
+	fmt.Fprintln(w, "----\t--------\t--------")
+	for _, h := range hosts {
+		projects, err := store.HostProjects(ctx, h.ID)
+		if err != nil {
+			return err
+		}
+		projectList := "-"
+		if len(projects) > 0 {
+			projectList = strings.Join(projects, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", h.Name, h.Target().Endpoint(), projectList)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runHostRemove(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.DeleteHost(ctx, name); err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrHostNotFound):
+			return hostNotFound(name)
+		case errors.Is(err, apperrors.ErrHostInUse):
+			return fmt.Errorf("%w\n\nMove the projects to other hosts first: otterstack project hosts <project> <host>...", err)
+		}
+		return err
+	}
+
+	fmt.Printf("✓ Host %s removed\n", name)
+	return nil
+}
+
+func runHostCheck(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var hosts []*state.Host
+	if len(args) == 0 {
+		hosts, err = store.ListHosts(ctx)
+		if err != nil {
+			return err
+		}
+		if len(hosts) == 0 {
+			fmt.Println("No hosts registered.")
+			return nil
+		}
+	} else {
+		for _, name := range args {
+			h, err := store.GetHost(ctx, name)
+			if err != nil {
+				if errors.Is(err, apperrors.ErrHostNotFound) {
+					return hostNotFound(name)
+				}
+				return err
+			}
+			hosts = append(hosts, h)
+		}
+	}
+
+	failed := 0
+	for _, h := range hosts {
+		version, err := target.Check(ctx, h.Target())
+		if err != nil {
+			failed++
+			fmt.Printf("✗ %s: %v\n", h.Name, err)
+			continue
+		}
+		fmt.Printf("✓ %s: docker %s (%s)\n", h.Name, version, h.Target().Endpoint())
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hosts unreachable", failed, len(hosts))
+	}
+	return nil
+}
+
+func runProjectHosts(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+	hostNames := args[1:]
+
+	if hostsLocalFlag && len(hostNames) > 0 {
+		return fmt.Errorf("--local cannot be combined with host names")
+	}
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	project, err := store.GetProject(ctx, name)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrProjectNotFound) {
+			return projectNotFound(name)
+		}
+		return err
+	}
+
+	if hostsLocalFlag || len(hostNames) > 0 {
+		if err := store.SetProjectHosts(ctx, project.ID, hostNames); err != nil {
+			return err
+		}
+		fmt.Println("✓ Hosts updated. Running deployments are not moved; deploy to apply.")
+	}
+
+	hosts, err := store.ListProjectHosts(ctx, project.ID)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		fmt.Printf("%s deploys to the local Docker engine.\n", name)
+		return nil
+	}
+
+	fmt.Printf("%s deploys to, in order:\n", name)
+	for i, h := range hosts {
+		fmt.Printf("  %d. %s (%s)\n", i+1, h.Name, h.Target().Endpoint())
+	}
+	return nil
+}
+
+// projectTargets returns the engines a project deploys to in rolling order:
+// its hosts, or the local engine if it has none. only limits the result to
+// the named hosts, which must belong to the project.
+func projectTargets(ctx context.Context, store *state.Store, project *state.Project, only []string) ([]target.Target, error) {
+	hosts, err := store.ListProjectHosts(ctx, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		if len(only) > 0 {
+			return nil, fmt.Errorf("project %q deploys to the local engine; add hosts with: otterstack project hosts %s <host>...", project.Name, project.Name)
+		}
+		return []target.Target{target.Local}, nil
+	}
+
+	selected := make(map[string]bool, len(only))
+	for _, name := range only {
+		selected[name] = true
+	}
+
+	var targets []target.Target
+	for _, h := range hosts {
+		if len(only) == 0 || selected[h.Name] {
+			targets = append(targets, h.Target())
+			delete(selected, h.Name)
+		}
+	}
+	for name := range selected {
+		return nil, fmt.Errorf("project %q does not deploy to host %q", project.Name, name)
+	}
+	return targets, nil
+}
+
+// activeDeploymentOn returns a project's active deployment on the named host,
+// or its only active deployment if host is empty.
+func activeDeploymentOn(ctx context.Context, store state.StateStore, project *state.Project, host string) (*state.Deployment, error) {
+	active, err := store.ListActiveDeployments(ctx, project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if host != "" {
+		if host == target.LocalName {
+			host = ""
+		}
+		for _, d := range active {
+			if d.Host == host {
+				return d, nil
+			}
+		}
+		return nil, apperrors.ErrNoActiveDeployment
+	}
+
+	switch len(active) {
+	case 0:
+		return nil, apperrors.ErrNoActiveDeployment
+	case 1:
+		return active[0], nil
+	}
+	names := make([]string, len(active))
+	for i, d := range active {
+		names[i] = hostName(d)
+	}
+	return nil, fmt.Errorf("project %q runs on several hosts (%s); choose one with --host", project.Name, strings.Join(names, ", "))
+}
+
+// hostName returns the host a deployment ran on, or "local".
+func hostName(d *state.Deployment) string {
+	if d.Host == "" {
+		return target.LocalName
+	}
+	return d.Host
+}
@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/oplog"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <project|deployment> [service]",
+	Short: "Show container logs or the recorded output of a deployment",
+	Long: `Show the logs of a project's containers, or the recorded output of a
+deployment.
+
+Given a project name, streams the container logs of its active deployment,
+optionally for a single service. Use --deployment to read the containers of
+an older deployment that is still present.
+
+Given a deployment ID (or a unique prefix of it, as shown by
+"otterstack history") or <project>@<sha>, shows the full recorded output of
+that deployment or rollback, including OtterStack status messages and docker
+compose output.
+
+Examples:
+  otterstack logs myapp                          # Container logs of the active deployment
+  otterstack logs myapp web --follow             # Stream one service
+  otterstack logs myapp --since 1h --grep error  # Search the last hour
+  otterstack logs myapp --deployment abc123d     # Containers of an older deployment
+  otterstack logs myapp --host web2              # Containers on one of several hosts
+  otterstack logs 3f2a9c1e                       # Recorded output of a deployment
+  otterstack logs myapp@abc123d --follow         # Stream a deployment in progress
+  otterstack logs 3f2a9c1e --export deploy.log   # Save the recorded output to a file`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runLogs,
+}
+
+var (
+	logsFollowFlag     bool
+	logsExportFlag     string
+	logsSinceFlag      string
+	logsUntilFlag      string
+	logsGrepFlag       string
+	logsDeploymentFlag string
+	logsTailFlag       int
+	logsTimestampsFlag bool
+	logsHostFlag       string
+)
+
+// containerLogFlags only apply to container logs.
+var containerLogFlags = []string{"since", "until", "grep", "deployment", "tail", "timestamps", "host"}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().BoolVarP(&logsFollowFlag, "follow", "f", false, "keep streaming new output")
+	logsCmd.Flags().StringVar(&logsExportFlag, "export", "", "write a deployment's recorded output to a file")
+	logsCmd.Flags().StringVar(&logsSinceFlag, "since", "", "show container logs since a timestamp or relative duration (e.g. 42m)")
+	logsCmd.Flags().StringVar(&logsUntilFlag, "until", "", "show container logs before a timestamp or relative duration")
+	logsCmd.Flags().StringVar(&logsGrepFlag, "grep", "", "only show container log lines matching a regular expression")
+	logsCmd.Flags().StringVar(&logsDeploymentFlag, "deployment", "", "read containers of the deployment with this SHA instead of the active one")
+	logsCmd.Flags().IntVarP(&logsTailFlag, "tail", "n", 0, "number of container log lines to show from the end (0 for all)")
+	logsCmd.Flags().BoolVarP(&logsTimestampsFlag, "timestamps", "t", false, "show container log timestamps")
+	logsCmd.Flags().StringVar(&logsHostFlag, "host", "", "read the active deployment on this host, for projects deployed to several hosts")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// A project name selects container logs; anything else is a deployment reference
+	if validate.ProjectName(args[0]) == nil {
+		project, err := store.GetProject(ctx, args[0])
+		if err == nil {
+			if logsExportFlag != "" {
+				return fmt.Errorf("--export applies to a deployment's recorded output, not container logs")
+			}
+			var service string
+			if len(args) > 1 {
+				service = args[1]
+			}
+			return runContainerLogs(ctx, store, project, service)
+		}
+		if !errors.Is(err, apperrors.ErrProjectNotFound) {
+			return err
+		}
+	}
+
+	if len(args) > 1 {
+		return projectNotFound(args[0])
+	}
+	for _, name := range containerLogFlags {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s applies to container logs; pass a project name", name)
+		}
+	}
+	return runDeploymentLogs(ctx, store, args[0])
+}
+
+// runContainerLogs streams the container logs of a project's active deployment,
+// or of the deployment selected with --deployment.
+func runContainerLogs(ctx context.Context, store state.StateStore, project *state.Project, service string) error {
+	var deployment *state.Deployment
+	var err error
+	if logsDeploymentFlag != "" {
+		if logsHostFlag != "" {
+			return fmt.Errorf("--host selects an active deployment and cannot be combined with --deployment")
+		}
+		if err := validate.GitRef(logsDeploymentFlag); err != nil {
+			return fmt.Errorf("invalid --deployment value: %w", err)
+		}
+		deployment, err = store.GetDeploymentBySHA(ctx, project.ID, logsDeploymentFlag)
+		if err != nil {
+			return fmt.Errorf("cannot find deployment with SHA %s: %w", logsDeploymentFlag, err)
+		}
+	} else {
+		deployment, err = activeDeploymentOn(ctx, store, project, logsHostFlag)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrNoActiveDeployment) {
+				return apperrors.WithMessage(err, fmt.Sprintf("project %q has no active deployment (use --deployment <sha> for an older one)", project.Name))
+			}
+			return err
+		}
+	}
+
+	// Read the containers on the host the deployment ran on
+	host, err := state.DeploymentTarget(ctx, store, deployment)
+	if err != nil {
+		return err
+	}
+	ctx = target.With(ctx, host)
+
+	opts := compose.LogOptions{
+		Service:    service,
+		Follow:     logsFollowFlag,
+		Since:      logsSinceFlag,
+		Until:      logsUntilFlag,
+		Tail:       logsTailFlag,
+		Timestamps: logsTimestampsFlag,
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if logsGrepFlag != "" {
+		re, err := regexp.Compile(logsGrepFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --grep pattern: %w", err)
+		}
+		grep := &grepWriter{w: os.Stdout, re: re}
+		defer grep.Flush()
+		out = grep
+		// Color codes would interfere with matching
+		opts.NoColor = true
+	}
+
+	composeProjectName := compose.GenerateProjectName(project.Name, git.ShortSHA(deployment.GitSHA))
+	exists, err := compose.ProjectExists(ctx, composeProjectName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no containers found for deployment %s (compose project %s); they are removed once a deployment is replaced",
+			git.ShortSHA(deployment.GitSHA), composeProjectName)
+	}
+
+	printVerbose("Reading logs of compose project %s", composeProjectName)
+	return compose.StreamProjectLogs(ctx, composeProjectName, opts, out)
+}
+
+// runDeploymentLogs shows, follows or exports the recorded output of a deployment.
+func runDeploymentLogs(ctx context.Context, store state.StateStore, ref string) error {
+	if logsFollowFlag && logsExportFlag != "" {
+		return fmt.Errorf("--follow and --export cannot be used together")
+	}
+
+	deployment, err := resolveDeploymentRef(ctx, store, ref)
+	if err != nil {
+		return err
+	}
+
+	logs, err := store.ListDeploymentOperationLogs(ctx, deployment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list operation logs: %w", err)
+	}
+	if len(logs) == 0 {
+		return fmt.Errorf("no recorded output for deployment %s (%s)", deployment.ID, git.ShortSHA(deployment.GitSHA))
+	}
+
+	if logsExportFlag != "" {
+		f, err := os.OpenFile(logsExportFlag, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer f.Close()
+
+		if err := writeOperationLogs(ctx, store, f, logs, false); err != nil {
+			return err
+		}
+		fmt.Printf("Exported log of deployment %s to %s\n", git.ShortSHA(deployment.GitSHA), logsExportFlag)
+		return nil
+	}
+
+	return writeOperationLogs(ctx, store, os.Stdout, logs, logsFollowFlag)
+}
+
+// resolveDeploymentRef finds a deployment by ID, unique ID prefix or <project>@<sha>.
+func resolveDeploymentRef(ctx context.Context, store state.StateStore, ref string) (*state.Deployment, error) {
+	if projectName, sha, ok := strings.Cut(ref, "@"); ok {
+		if err := validate.ProjectName(projectName); err != nil {
+			return nil, fmt.Errorf("invalid project name: %w", err)
+		}
+		if err := validate.GitRef(sha); err != nil {
+			return nil, fmt.Errorf("invalid SHA: %w", err)
+		}
+
+		project, err := store.GetProject(ctx, projectName)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrProjectNotFound) {
+				return nil, projectNotFound(projectName)
+			}
+			return nil, err
+		}
+
+		deployment, err := store.GetDeploymentBySHA(ctx, project.ID, sha)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find deployment with SHA %s: %w", sha, err)
+		}
+		return deployment, nil
+	}
+
+	deployment, err := store.GetDeploymentByIDPrefix(ctx, ref)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrDeploymentNotFound) {
+			return nil, apperrors.WithMessage(err, fmt.Sprintf("deployment %q not found (use a deployment ID or <project>@<sha>)", ref))
+		}
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// writeOperationLogs copies the recorded logs to w, oldest first.
+// When follow is set, the last log is streamed until its operation finishes.
+func writeOperationLogs(ctx context.Context, store state.StateStore, w io.Writer, logs []*state.OperationLog, follow bool) error {
+	for i, l := range logs {
+		if len(logs) > 1 {
+			fmt.Fprintf(w, "==> %s started %s (%s) <==\n", l.Operation, l.StartedAt.Format("2006-01-02 15:04:05"), l.Status)
+		}
+
+		if _, err := os.Stat(l.LogPath); err != nil {
+			if os.IsNotExist(err) {
+				fmt.Fprintf(w, "(log file %s no longer exists)\n", l.LogPath)
+				continue
+			}
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+
+		if follow && i == len(logs)-1 {
+			running := func() bool {
+				current, err := store.GetOperationLog(ctx, l.ID)
+				return err == nil && current.Status == oplog.StatusRunning
+			}
+			return oplog.Follow(ctx, l.LogPath, w, running, oplog.FollowInterval)
+		}
+
+		f, err := os.Open(l.LogPath)
+		if err != nil {
+			return fmt.Errorf("failed to open log: %w", err)
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+	}
+	return nil
+}
+
+// grepWriter passes through only the complete lines that match a pattern.
+type grepWriter struct {
+	w   io.Writer
+	re  *regexp.Regexp
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (g *grepWriter) Write(p []byte) (int, error) {
+	g.buf = append(g.buf, p...)
+	for {
+		i := bytes.IndexByte(g.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := g.writeLine(g.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		g.buf = g.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing line that was not terminated by a newline.
+func (g *grepWriter) Flush() error {
+	if len(g.buf) == 0 {
+		return nil
+	}
+	line := append(g.buf, '\n')
+	g.buf = nil
+	return g.writeLine(line)
+}
+
+func (g *grepWriter) writeLine(line []byte) error {
+	if !g.re.Match(bytes.TrimSuffix(line, []byte("\n"))) {
+		return nil
+	}
+	_, err := g.w.Write(line)
+	return err
+}
+
+// operationOutput prints command output and mirrors it into an operation log.
+// Output goes to stdout and stderr unless other writers are set.
+type operationOutput struct {
+	rec    *oplog.Recorder
+	stdout io.Writer
+	stderr io.Writer
+	log    logging.Logger // If nil, uses cmdLogger
+}
+
+func (o operationOutput) logger() logging.Logger {
+	if o.log == nil {
+		return cmdLogger
+	}
+	return o.log
+}
+
+func (o operationOutput) getStdout() io.Writer {
+	if o.stdout == nil {
+		return os.Stdout
+	}
+	return o.stdout
+}
+
+func (o operationOutput) getStderr() io.Writer {
+	if o.stderr == nil {
+		return os.Stderr
+	}
+	return o.stderr
+}
+
+// Printf writes a line to stdout and the log.
+func (o operationOutput) Printf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(o.getStdout(), msg)
+	o.rec.Line(msg)
+	o.logger().Info(strings.TrimSpace(msg))
+}
+
+// Warnf writes a line to stderr and the log.
+func (o operationOutput) Warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(o.getStderr(), msg)
+	o.rec.Line(msg)
+	o.logger().Warn(strings.TrimSpace(msg))
+}
+
+// Verbosef writes a line to stderr in verbose mode. The log always receives it.
+func (o operationOutput) Verbosef(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if isVerbose() {
+		fmt.Fprintln(o.getStderr(), msg)
+	}
+	o.rec.Line(msg)
+	o.logger().Debug(strings.TrimSpace(msg))
+}
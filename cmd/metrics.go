@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsCollectTimeout bounds the store queries of a scrape.
+const metricsCollectTimeout = 10 * time.Second
+
+var (
+	deploymentsDesc = prometheus.NewDesc("otterstack_deployments",
+		"Deployments recorded in the database, by project and current status.",
+		[]string{"project", "status"}, nil)
+	sinceSuccessDesc = prometheus.NewDesc("otterstack_seconds_since_last_successful_deploy",
+		"Seconds since the last deployment of the project that went live finished.",
+		[]string{"project"}, nil)
+	activeDeploymentDesc = prometheus.NewDesc("otterstack_active_deployment_info",
+		"The commit running on each host; always 1.",
+		[]string{"project", "host", "sha", "ref"}, nil)
+)
+
+// deploymentCollector reports the number of deployments of each project by
+// current status, the time since each project's last successful deployment
+// and the commit each host runs, read from the store on every scrape.
+// Deployment counts and durations as they finish are recorded by the
+// deployer in metrics.DeploymentsTotal and metrics.DeploymentDuration.
+type deploymentCollector struct {
+	store *state.Store
+}
+
+func (c deploymentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- deploymentsDesc
+	ch <- sinceSuccessDesc
+	ch <- activeDeploymentDesc
+}
+
+func (c deploymentCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsCollectTimeout)
+	defer cancel()
+	if err := c.collect(ctx, ch); err != nil {
+		ch <- prometheus.NewInvalidMetric(deploymentsDesc, err)
+	}
+}
+
+func (c deploymentCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	projects, err := c.store.ListProjects(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+	names := make(map[string]string, len(projects))
+	for _, p := range projects {
+		names[p.ID] = p.Name
+	}
+
+	counts, err := c.store.CountDeploymentsByStatus(ctx)
+	if err != nil {
+		return err
+	}
+	for _, count := range counts {
+		if name, ok := names[count.ProjectID]; ok {
+			ch <- prometheus.MustNewConstMetric(deploymentsDesc, prometheus.GaugeValue, float64(count.Count), name, count.Status)
+		}
+	}
+
+	now := time.Now()
+	for _, p := range projects {
+		last, err := c.store.GetLastSuccessfulDeployment(ctx, p.ID)
+		switch {
+		case err == nil:
+			ch <- prometheus.MustNewConstMetric(sinceSuccessDesc, prometheus.GaugeValue, now.Sub(*last.FinishedAt).Seconds(), p.Name)
+		case !errors.Is(err, apperrors.ErrDeploymentNotFound):
+			return err
+		}
+
+		active, err := c.store.ListActiveDeployments(ctx, p.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list active deployments of %s: %w", p.Name, err)
+		}
+		for _, d := range active {
+			host := d.Host
+			if host == "" {
+				host = target.LocalName
+			}
+			ch <- prometheus.MustNewConstMetric(activeDeploymentDesc, prometheus.GaugeValue, 1, p.Name, host, d.GitSHA, d.GitRef)
+		}
+	}
+	return nil
+}
+
+// metricsErrorLog reports scrape errors in verbose mode.
+type metricsErrorLog struct{}
+
+func (metricsErrorLog) Println(v ...interface{}) {
+	printVerbose("Failed to collect metrics: %s", fmt.Sprint(v...))
+}
+
+// serveMetrics serves the metrics of the default Prometheus registry and the
+// deployment metrics of store on addr until ctx is done. It returns once the
+// listener is open, so an unusable address is reported before watching starts.
+// A failing collector is logged and the other metrics are still served.
+func serveMetrics(ctx context.Context, store *state.Store, addr string) error {
+	collector := deploymentCollector{store: store}
+	if err := prometheus.Register(collector); err != nil {
+		return fmt.Errorf("failed to register deployment metrics: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		prometheus.Unregister(collector)
+		return fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			ErrorLog:      metricsErrorLog{},
+			ErrorHandling: promhttp.ContinueOnError,
+		})))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		prometheus.Unregister(collector)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			printVerbose("Metrics server stopped: %v", err)
+		}
+	}()
+
+	fmt.Printf("Serving metrics on http://%s/metrics\n", listener.Addr())
+	return nil
+}
@@ -1,11 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/orchestrator"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
 	"github.com/jayteealao/otterstack/internal/tui"
+	"github.com/jayteealao/otterstack/internal/validate"
 	"github.com/spf13/cobra"
 )
 
@@ -17,15 +26,33 @@ var monitorCmd = &cobra.Command{
 The dashboard shows:
 - All registered projects
 - Current deployment status
-- Service health status
+- Service health status and resource usage
 - Real-time updates
 
+From a project's detail view you can deploy a branch, tag or commit, roll
+back to an earlier deployment, restart a service, follow its logs and
+browse its deployment history, comparing the commits and compose files of
+any two deployments.
+Deploys, rollbacks and restarts ask for confirmation, take the project lock
+like the CLI commands do, and show their progress below the services.
+
 Navigation:
-  ↑/↓     Navigate projects
+  ↑/↓     Navigate projects, or services in the detail view
   Enter   View project details
   Esc     Go back
   r       Refresh
-  q       Quit`,
+  q       Quit
+
+Detail view actions:
+  d       Deploy a ref (type to filter branches and tags, or enter a SHA)
+  b       Roll back to a chosen deployment
+  R       Restart the selected service
+  l       Follow the selected service's logs
+  h       Browse deployment history
+
+History view:
+  Space   Mark a deployment; Enter compares the two marked ones
+  b       Roll back to the deployment under the cursor`,
 	RunE: runMonitor,
 }
 
@@ -44,7 +71,13 @@ func runMonitor(cmd *cobra.Command, args []string) error {
 	}
 	defer store.Close()
 
-	model := tui.NewModel(cmd.Context(), store, monitorRefreshFlag)
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+
+	model := tui.NewModel(cmd.Context(), store, monitorRefreshFlag).
+		WithActions(&monitorActions{store: store, dataDir: dataDir})
 
 	p := tea.NewProgram(model, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
@@ -53,3 +86,150 @@ func runMonitor(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// monitorActions runs the actions started from the monitor's detail view
+// through the same code as the deploy and rollback commands. Output goes to
+// the dashboard rather than the terminal.
+type monitorActions struct {
+	store   *state.Store
+	dataDir string
+}
+
+var _ tui.Actions = (*monitorActions)(nil)
+
+// Refs lists the default branch first, then the other branches and the tags,
+// highest version first. Remote repositories are fetched first.
+func (a *monitorActions) Refs(ctx context.Context, project *state.Project) ([]string, error) {
+	gitMgr := git.NewManager(project.RepoPath)
+	if project.RepoType == "remote" {
+		if err := gitMgr.Fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	branches, err := gitMgr.ListBranches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if def, err := gitMgr.GetDefaultBranch(ctx); err == nil {
+		for i, b := range branches {
+			if b == def {
+				branches = append(append([]string{def}, branches[:i]...), branches[i+1:]...)
+				break
+			}
+		}
+	}
+
+	tags, err := gitMgr.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(branches, tags...), nil
+}
+
+// Deploy deploys a project at ref to all of its hosts.
+func (a *monitorActions) Deploy(ctx context.Context, project *state.Project, ref string, out io.Writer) error {
+	if err := validate.GitRef(ref); err != nil {
+		return fmt.Errorf("invalid git ref: %w", err)
+	}
+
+	// The dashboard's copy may be stale
+	project, err := a.store.GetProject(ctx, project.Name)
+	if err != nil {
+		return err
+	}
+	if project.Status != "ready" {
+		return fmt.Errorf("project is not ready (status: %s)", project.Status)
+	}
+
+	targets, err := projectTargets(ctx, a.store, project, nil)
+	if err != nil {
+		return err
+	}
+
+	deployer := orchestrator.NewDeployer(a.store, git.NewManager(project.RepoPath))
+
+	maxConcurrent, resources, err := deployLimits()
+	if err != nil {
+		return err
+	}
+	opts := orchestrator.DeployOptions{
+		GitRef:    ref,
+		DataDir:   a.dataDir,
+		OnStatus:  func(msg string) { fmt.Fprintln(out, msg) },
+		OnVerbose: func(msg string) {},
+		Stdout:    out,
+		Stderr:    out,
+
+		MaxConcurrent: maxConcurrent,
+		Resources:     resources,
+
+		TriggerSource: state.TriggerCLI,
+		TriggeredBy:   currentUser(),
+	}
+
+	queued := &state.QueuedDeploy{
+		ProjectID:     project.ID,
+		GitRef:        ref,
+		TriggerSource: opts.TriggerSource,
+		TriggeredBy:   opts.TriggeredBy,
+		PID:           os.Getpid(),
+	}
+	if err := waitInDeployQueue(ctx, a.store, queued, opts.OnStatus); err != nil {
+		return err
+	}
+	result, err := rollout(ctx, deployer, project, targets, opts, nil)
+	finishQueuedDeploy(ctx, a.store, queued, err)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Deployment successful! %s deployed at %s\n", project.Name, result.ShortSHA)
+
+	cleanupWorktrees(ctx, deployer, project, a.dataDir, result)
+	return nil
+}
+
+// Rollback returns a project to the commit of an earlier deployment.
+func (a *monitorActions) Rollback(ctx context.Context, project *state.Project, to *state.Deployment, out io.Writer) error {
+	return rollbackProject(ctx, a.store, a.dataDir, project.Name, rollbackOptions{
+		To:     to.GitSHA,
+		Stdout: out,
+		Stderr: out,
+	})
+}
+
+// Restart restarts one service of a deployment on the host it runs on,
+// holding the project lock so it cannot overlap a deployment.
+func (a *monitorActions) Restart(ctx context.Context, project *state.Project, deployment *state.Deployment, service string, out io.Writer) error {
+	lockMgr, err := initLockManager()
+	if err != nil {
+		return err
+	}
+	lock, err := lockMgr.Acquire(ctx, project.Name)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer lock.Release()
+
+	host, err := state.DeploymentTarget(ctx, a.store, deployment)
+	if err != nil {
+		return err
+	}
+	ctx = target.With(ctx, host)
+
+	cfg, err := resolveProjectConfig(project, deployment.WorktreePath)
+	if err != nil {
+		return err
+	}
+
+	projectName := compose.GenerateProjectName(project.Name, git.ShortSHA(deployment.GitSHA))
+	composeFiles := compose.WithBuildOverride(deployment.WorktreePath, cfg.ComposeFile)
+	composeMgr := compose.NewManager(deployment.WorktreePath, composeFiles, projectName)
+	composeMgr.SetOutputStreams(out, out)
+
+	if err := composeMgr.Restart(ctx, service); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Restarted %s\n", service)
+	return nil
+}
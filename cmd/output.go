@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by --output.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// outputSchemaVersion is the version of every document written with
+// --output json or yaml. It only changes when a field is removed or changes
+// meaning; fields may be added without a new version.
+const outputSchemaVersion = 1
+
+// structuredOutputAnnotation marks commands that support --output json and yaml.
+const structuredOutputAnnotation = "otterstack.io/structured-output"
+
+// structuredOutput is the annotation set on commands whose results can be
+// written as JSON or YAML.
+var structuredOutput = map[string]string{structuredOutputAnnotation: "true"}
+
+// Kinds of structured output documents.
+const (
+	kindStatusList     = "StatusList"
+	kindProjectStatus  = "ProjectStatus"
+	kindProjectList    = "ProjectList"
+	kindEnvList        = "EnvList"
+	kindCleanupReport  = "CleanupReport"
+	kindDeployResult   = "DeployResult"
+	kindRollbackResult = "RollbackResult"
+	kindHistory        = "DeploymentHistory"
+	kindDeployQueue    = "DeployQueue"
+)
+
+// outputDocument wraps every structured result, so scripts can check what
+// they are reading before they read it.
+type outputDocument struct {
+	SchemaVersion int         `json:"schema_version"`
+	Kind          string      `json:"kind"`
+	Data          interface{} `json:"data"`
+}
+
+// outputFormat returns the format selected with --output or
+// OTTERSTACK_OUTPUT, defaulting to table.
+func outputFormat() string {
+	format := strings.ToLower(viper.GetString("output"))
+	if format == "" {
+		return outputTable
+	}
+	return format
+}
+
+// isStructuredOutput reports whether results are written as JSON or YAML
+// rather than tables and text.
+func isStructuredOutput() bool {
+	return outputFormat() != outputTable
+}
+
+// checkOutputFormat rejects unknown formats, and a --output json or yaml
+// given to a command that only writes text.
+func checkOutputFormat(cmd *cobra.Command) error {
+	switch format := outputFormat(); format {
+	case outputTable:
+		return nil
+	case outputJSON, outputYAML:
+		if cmd.Annotations[structuredOutputAnnotation] == "" && cmd.Root().PersistentFlags().Changed("output") {
+			return fmt.Errorf("--output %s is not supported by %q", format, cmd.CommandPath())
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q (must be table, json or yaml)", format)
+	}
+}
+
+// writeOutput writes data to w as a versioned document of the given kind, in
+// the format selected with --output. YAML documents use the same field names
+// as JSON.
+func writeOutput(w io.Writer, kind string, data interface{}) error {
+	doc := outputDocument{SchemaVersion: outputSchemaVersion, Kind: kind, Data: data}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	if outputFormat() != outputYAML {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	// JSON is YAML, so decoding it keeps the field order; only the flow style is dropped
+	var node yaml.Node
+	if err := yaml.Unmarshal(buf.Bytes(), &node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	blockStyle(&node)
+	enc2 := yaml.NewEncoder(w)
+	enc2.SetIndent(2)
+	if err := enc2.Encode(&node); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return enc2.Close()
+}
+
+// blockStyle resets the style of a node decoded from JSON, so it is written
+// as block YAML with strings quoted only where needed.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
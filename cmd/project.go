@@ -11,10 +11,13 @@ import (
 	"text/tabwriter"
 
 	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/envschema"
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
 	"github.com/jayteealao/otterstack/internal/prompt"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
 	"github.com/jayteealao/otterstack/internal/validate"
 	"github.com/spf13/cobra"
 )
@@ -37,7 +40,8 @@ The repository can be:
 Examples:
   otterstack project add myapp /srv/myapp
   otterstack project add myapp https://github.com/user/repo.git
-  otterstack project add myapp git@github.com:user/repo.git`,
+  otterstack project add myapp git@github.com:user/repo.git
+  otterstack project add myapp /srv/myapp --host web1 --host web2`,
 	Args: cobra.ExactArgs(2),
 	RunE: runProjectAdd,
 }
@@ -47,6 +51,8 @@ var projectListCmd = &cobra.Command{
 	Aliases: []string{"ls"},
 	Short:   "List all projects",
 	RunE:    runProjectList,
+
+	Annotations: structuredOutput,
 }
 
 var projectRemoveCmd = &cobra.Command{
@@ -56,7 +62,7 @@ var projectRemoveCmd = &cobra.Command{
 	Long: `Remove a project from OtterStack.
 
 This stops any running services and removes the project from tracking.
-Use --force to also remove worktrees and cloned repositories.`,
+Use --force to also remove worktrees, volume snapshots and cloned repositories.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runProjectRemove,
 }
@@ -74,11 +80,32 @@ Examples:
 	RunE: runProjectValidate,
 }
 
+var projectConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect project configuration",
+}
+
+var projectConfigShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show the effective project configuration",
+	Long: `Show the effective configuration for a project.
+
+Settings stored for the project are merged with otterstack.yaml from the
+repository. By default the file is read from the active deployment's worktree,
+falling back to the repository checkout when nothing is deployed.
+
+Examples:
+  otterstack project config show myapp`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProjectConfigShow,
+}
+
 var (
 	composeFileFlag      string
 	retentionFlag        int
 	forceFlag            bool
 	traefikRoutingFlag   bool
+	projectHostsFlag     []string
 )
 
 func init() {
@@ -87,11 +114,14 @@ func init() {
 	projectCmd.AddCommand(projectListCmd)
 	projectCmd.AddCommand(projectRemoveCmd)
 	projectCmd.AddCommand(projectValidateCmd)
+	projectCmd.AddCommand(projectConfigCmd)
+	projectConfigCmd.AddCommand(projectConfigShowCmd)
 
 	// Add flags
 	projectAddCmd.Flags().StringVarP(&composeFileFlag, "compose-file", "f", "", "compose file name (default: auto-detect)")
 	projectAddCmd.Flags().IntVar(&retentionFlag, "retention", 3, "number of worktrees to retain")
 	projectAddCmd.Flags().BoolVar(&traefikRoutingFlag, "traefik-routing", false, "enable Traefik priority-based routing for zero-downtime deployments")
+	projectAddCmd.Flags().StringSliceVar(&projectHostsFlag, "host", nil, "deploy to this registered host instead of the local engine (repeat for a rolling order)")
 
 	projectRemoveCmd.Flags().BoolVarP(&forceFlag, "force", "f", false, "force removal including worktrees and cloned repos")
 }
@@ -115,7 +145,7 @@ func runProjectAdd(cmd *cobra.Command, args []string) error {
 	// Check if project already exists
 	_, err = store.GetProject(ctx, name)
 	if err == nil {
-		return fmt.Errorf("project %q already exists", name)
+		return apperrors.WithMessage(apperrors.ErrProjectExists, fmt.Sprintf("project %q already exists", name))
 	}
 	if !errors.Is(err, apperrors.ErrProjectNotFound) {
 		return err
@@ -184,6 +214,13 @@ func runProjectAdd(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
+	if len(projectHostsFlag) > 0 {
+		if err := store.SetProjectHosts(ctx, project.ID, projectHostsFlag); err != nil {
+			projectCreated = false
+			return fmt.Errorf("failed to set project hosts: %w", err)
+		}
+	}
+
 	// Perform clone if remote
 	if isRemote {
 		// Clone the repository (uses atomic clone with temp dir)
@@ -233,6 +270,19 @@ func runProjectAdd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		fmt.Printf("⚠ Warning: failed to parse compose file for env vars: %v\n", err)
 	} else {
+		// Fold in the repository's env schema, if it ships one
+		schema, err := envschema.Load(repoPath)
+		if err != nil {
+			fmt.Printf("⚠ Warning: %v\n", err)
+		}
+		requiredVars = schema.Apply(requiredVars)
+
+		// Values loaded from .env.<project-name> that violate the schema are collected again
+		for _, v := range schema.Validate(envVars) {
+			fmt.Printf("⚠ %s does not match the schema: %s\n", v.Name, v.Message)
+			delete(envVars, v.Name)
+		}
+
 		// 3. Identify missing variables
 		missingVars := compose.GetMissingVars(requiredVars, envVars)
 
@@ -240,7 +290,7 @@ func runProjectAdd(cmd *cobra.Command, args []string) error {
 			fmt.Printf("\nFound %d missing variables. Let's configure them:\n", len(missingVars))
 
 			// 4. Interactively collect missing variables
-			newVars, err := prompt.CollectMissingVars(missingVars)
+			newVars, err := prompt.CollectMissingVars(missingVars, schema)
 			if err != nil {
 				// Non-fatal - user might have cancelled or encountered an error
 				fmt.Printf("⚠ Warning: failed to collect variables: %v\n", err)
@@ -269,9 +319,9 @@ func runProjectAdd(cmd *cobra.Command, args []string) error {
 		examplePath := filepath.Join(repoPath, ".env.example")
 		if err := compose.GenerateEnvExample(requiredVars, examplePath); err != nil {
 			// Non-fatal
-			printVerbose(fmt.Sprintf("Could not generate .env.example: %v", err))
+			printVerbose("Could not generate .env.example: %v", err)
 		} else {
-			printVerbose(fmt.Sprintf("Generated .env.example"))
+			printVerbose("Generated .env.example")
 		}
 	}
 
@@ -284,7 +334,7 @@ func runProjectAdd(cmd *cobra.Command, args []string) error {
 			// Filter to only truly required vars (not optional with defaults)
 			hasRequiredMissing := false
 			for _, mv := range missingRequired {
-				if mv.IsRequired || !mv.HasDefault {
+				if mv.NeedsValue() {
 					hasRequiredMissing = true
 					break
 				}
@@ -335,6 +385,14 @@ func runProjectList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to list projects: %w", err)
 	}
 
+	if isStructuredOutput() {
+		entries := make([]projectEntry, 0, len(projects))
+		for _, p := range projects {
+			entries = append(entries, newProjectEntry(p))
+		}
+		return writeOutput(os.Stdout, kindProjectList, projectListOutput{Projects: entries})
+	}
+
 	if len(projects) == 0 {
 		fmt.Println("No projects configured.")
 		return nil
@@ -352,6 +410,34 @@ func runProjectList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// projectEntry is a project in structured output.
+type projectEntry struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	RepoPath    string `json:"repo_path"`
+	RepoURL     string `json:"repo_url,omitempty"`
+	ComposeFile string `json:"compose_file"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// projectListOutput is the data of a ProjectList document.
+type projectListOutput struct {
+	Projects []projectEntry `json:"projects"`
+}
+
+func newProjectEntry(p *state.Project) projectEntry {
+	return projectEntry{
+		Name:        p.Name,
+		Type:        p.RepoType,
+		Status:      p.Status,
+		RepoPath:    p.RepoPath,
+		RepoURL:     p.RepoURL,
+		ComposeFile: p.ComposeFile,
+		CreatedAt:   p.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
 func runProjectRemove(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 	name := args[0]
@@ -366,23 +452,35 @@ func runProjectRemove(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, name)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", name)
+			return projectNotFound(name)
 		}
 		return err
 	}
 
-	// Check for active deployment
-	activeDeployment, err := store.GetActiveDeployment(ctx, project.ID)
-	if err != nil && !errors.Is(err, apperrors.ErrNoActiveDeployment) {
+	// Check for active deployments, one per host the project runs on
+	activeDeployments, err := store.ListActiveDeployments(ctx, project.ID)
+	if err != nil {
 		return fmt.Errorf("failed to check active deployment: %w", err)
 	}
 
-	if activeDeployment != nil {
-		fmt.Printf("Stopping active deployment %s...\n", git.ShortSHA(activeDeployment.GitSHA))
+	for _, activeDeployment := range activeDeployments {
+		host, err := state.DeploymentTarget(ctx, store, activeDeployment)
+		if err != nil {
+			if !forceFlag {
+				return fmt.Errorf("%w (use --force to continue)", err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		if host.IsLocal() {
+			fmt.Printf("Stopping active deployment %s...\n", git.ShortSHA(activeDeployment.GitSHA))
+		} else {
+			fmt.Printf("Stopping active deployment %s on %s...\n", git.ShortSHA(activeDeployment.GitSHA), host)
+		}
 
 		// Stop the compose services
 		projectName := compose.GenerateProjectName(name, git.ShortSHA(activeDeployment.GitSHA))
-		if err := compose.StopProjectByName(ctx, projectName, 0); err != nil {
+		if err := compose.StopProjectByName(target.With(ctx, host), projectName, 0); err != nil {
 			if !forceFlag {
 				return fmt.Errorf("failed to stop services: %w (use --force to continue)", err)
 			}
@@ -399,6 +497,12 @@ func runProjectRemove(cmd *cobra.Command, args []string) error {
 			fmt.Fprintf(os.Stderr, "Warning: failed to remove worktrees: %v\n", err)
 		}
 
+		// Volume snapshots are only useful with the project's deployment history
+		printVerbose("Removing volume snapshots...")
+		if err := os.RemoveAll(filepath.Join(dataDir, "snapshots", name)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove volume snapshots: %v\n", err)
+		}
+
 		// If remote repo, remove cloned repo
 		if project.RepoType == "remote" {
 			printVerbose("Removing cloned repository...")
@@ -431,7 +535,7 @@ func runProjectValidate(cmd *cobra.Command, args []string) error {
 	project, err := store.GetProject(ctx, name)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", name)
+			return projectNotFound(name)
 		}
 		return err
 	}
@@ -467,9 +571,14 @@ func runProjectValidate(cmd *cobra.Command, args []string) error {
 		defer os.Remove(envFilePath)
 	}
 
+	cfg, err := resolveProjectConfig(project, project.RepoPath)
+	if err != nil {
+		return err
+	}
+
 	// Validate compose file with env vars
-	fmt.Printf("Validating compose file: %s\n", project.ComposeFile)
-	composeMgr := compose.NewManager(project.RepoPath, project.ComposeFile, name)
+	fmt.Printf("Validating compose file: %s\n", cfg.ComposeFile)
+	composeMgr := compose.NewManager(project.RepoPath, cfg.ComposeFile, name)
 
 	if err := composeMgr.ValidateWithEnv(ctx, envFilePath); err != nil {
 		return fmt.Errorf("compose validation failed: %w\n\nTo fix:\n  1. Review the error above\n  2. Set missing env vars: otterstack env set %s KEY=value\n  3. Retry validation: otterstack project validate %s", err, name, name)
@@ -575,3 +684,60 @@ func loadEnvFile(path string) (map[string]string, error) {
 
 	return vars, nil
 }
+
+func runProjectConfigShow(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	project, err := store.GetProject(ctx, name)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrProjectNotFound) {
+			return projectNotFound(name)
+		}
+		return err
+	}
+
+	// Prefer the config versioned with what is currently running
+	dir := project.RepoPath
+	active, err := store.GetActiveDeployment(ctx, project.ID)
+	if err == nil && active.WorktreePath != "" {
+		if _, statErr := os.Stat(active.WorktreePath); statErr == nil {
+			dir = active.WorktreePath
+		}
+	} else if err != nil && !errors.Is(err, apperrors.ErrNoActiveDeployment) {
+		return err
+	}
+
+	cfg, err := resolveProjectConfig(project, dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Project: %s\n", project.Name)
+	fmt.Printf("Config:  %s\n\n", filepath.Join(dir, projectconfig.FileName))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	fmt.Fprintln(w, "---\t-----\t------")
+	for _, key := range projectconfig.Keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, cfg.Value(key), cfg.Sources[key])
+	}
+	w.Flush()
+
+	return nil
+}
+
+// resolveProjectConfig merges the stored project settings with otterstack.yaml from dir.
+func resolveProjectConfig(project *state.Project, dir string) (*projectconfig.Config, error) {
+	file, err := projectconfig.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return projectconfig.Resolve(project, file), nil
+}
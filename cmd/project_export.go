@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/projectexport"
+	"github.com/jayteealao/otterstack/internal/prompt"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+var projectExportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Short: "Export a project to move it to another host",
+	Long: `Export a project's registration, settings, environment variables and
+deployment history to a JSON file that "otterstack project import" reads on
+another host.
+
+Environment variables are written in plain text unless --encrypt is given,
+which asks for a passphrase and seals them with AES-256-GCM. Worktrees,
+logs, volumes and running containers are not exported.
+
+Examples:
+  otterstack project export myapp
+  otterstack project export myapp --encrypt -f /tmp/myapp.json
+  otterstack project export myapp --passphrase-file ~/.otterstack-pass`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProjectExport,
+}
+
+var projectImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a project exported from another host",
+	Long: `Register a project from a file written by "otterstack project export".
+
+Remote repositories are cloned again into the data directory. Local
+repositories keep their path and must be copied to this host separately.
+Deployment history is kept for reference, but nothing is running here yet:
+deploy the project to start it.
+
+Examples:
+  otterstack project import myapp.otterstack.json
+  otterstack project import myapp.otterstack.json --name myapp-staging`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProjectImport,
+}
+
+var (
+	exportFileFlag           string
+	exportEncryptFlag        bool
+	exportPassphraseFileFlag string
+	importNameFlag           string
+	importPassphraseFileFlag string
+)
+
+func init() {
+	projectCmd.AddCommand(projectExportCmd)
+	projectCmd.AddCommand(projectImportCmd)
+
+	projectExportCmd.Flags().StringVarP(&exportFileFlag, "file", "f", "", `export file, "-" for stdout (default <name>.otterstack.json)`)
+	projectExportCmd.Flags().BoolVar(&exportEncryptFlag, "encrypt", false, "encrypt environment variables with a passphrase")
+	projectExportCmd.Flags().StringVar(&exportPassphraseFileFlag, "passphrase-file", "", "read the encryption passphrase from a file (implies --encrypt)")
+
+	projectImportCmd.Flags().StringVar(&importNameFlag, "name", "", "register the project under a different name")
+	projectImportCmd.Flags().StringVar(&importPassphraseFileFlag, "passphrase-file", "", "read the passphrase for encrypted environment variables from a file")
+}
+
+func runProjectExport(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	project, err := store.GetProject(ctx, name)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrProjectNotFound) {
+			return projectNotFound(name)
+		}
+		return err
+	}
+
+	vars, err := store.GetEnvVars(ctx, project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get env vars: %w", err)
+	}
+
+	deployments, err := store.ListDeployments(ctx, project.ID, math.MaxInt32)
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	var passphrase string
+	if (exportEncryptFlag || exportPassphraseFileFlag != "") && len(vars) > 0 {
+		passphrase, err = readPassphrase(exportPassphraseFileFlag, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	export, err := projectexport.New(project, vars, deployments, passphrase)
+	if err != nil {
+		return err
+	}
+	export.OtterStackVersion = Version
+
+	dest := exportFileFlag
+	if dest == "" {
+		dest = name + ".otterstack.json"
+	}
+	if dest == "-" {
+		return export.Write(os.Stdout)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	defer f.Close()
+	if err := export.Write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+
+	fmt.Printf("✓ Exported %s to %s\n", name, dest)
+	fmt.Printf("  Environment variables: %d", len(vars))
+	switch {
+	case export.Sealed():
+		fmt.Print(" (encrypted)")
+	case len(vars) > 0:
+		fmt.Print(" (plain text; use --encrypt to protect them)")
+	}
+	fmt.Println()
+	fmt.Printf("  Deployments: %d\n", len(deployments))
+
+	return nil
+}
+
+func runProjectImport(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", args[0], err)
+	}
+	export, err := projectexport.Read(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	name := export.Project.Name
+	if importNameFlag != "" {
+		name = importNameFlag
+	}
+	if err := validate.ProjectName(name); err != nil {
+		return fmt.Errorf("invalid project name: %w", err)
+	}
+
+	// Unseal before anything is created, so a wrong passphrase leaves no trace
+	var passphrase string
+	if export.Sealed() {
+		passphrase, err = readPassphrase(importPassphraseFileFlag, false)
+		if err != nil {
+			return err
+		}
+	}
+	vars, err := export.EnvVars(passphrase)
+	if err != nil {
+		return err
+	}
+
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	project := &state.Project{
+		Name:                  name,
+		RepoType:              export.Project.RepoType,
+		RepoURL:               export.Project.RepoURL,
+		RepoPath:              export.Project.RepoPath,
+		ComposeFile:           export.Project.ComposeFile,
+		WorktreeRetention:     export.Project.WorktreeRetention,
+		TraefikRoutingEnabled: export.Project.TraefikRouting,
+		Status:                "cloning",
+	}
+	if project.RepoType == "remote" {
+		project.RepoPath = filepath.Join(dataDir, "repos", name)
+	}
+
+	if err := store.CreateProject(ctx, project); err != nil {
+		if errors.Is(err, apperrors.ErrProjectExists) {
+			return apperrors.WithMessage(err, fmt.Sprintf("project %q already exists (use --name to import it under another name)", name))
+		}
+		return fmt.Errorf("failed to create project: %w", err)
+	}
+
+	// Remove the half-imported project on any later error
+	imported := false
+	defer func() {
+		if !imported {
+			if err := store.DeleteProject(ctx, name); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to cleanup project: %v\n", err)
+			}
+		}
+	}()
+
+	if err := restoreRepository(ctx, store, dataDir, project); err != nil {
+		if project.RepoType == "remote" {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	status := export.Project.Status
+	if status == "" || status == "cloning" || status == "clone_failed" {
+		status = "ready"
+	}
+	if err := store.UpdateProjectStatus(ctx, name, status); err != nil {
+		return fmt.Errorf("failed to update project: %w", err)
+	}
+
+	if len(vars) > 0 {
+		if err := store.SetEnvVars(ctx, project.ID, vars); err != nil {
+			return fmt.Errorf("failed to import env vars: %w", err)
+		}
+	}
+
+	deployments := export.StateDeployments(project.ID, func(sha string) string {
+		return git.GetWorktreePath(dataDir, name, sha)
+	})
+	if err := store.ImportDeployments(ctx, deployments); err != nil {
+		return err
+	}
+
+	imported = true
+
+	fmt.Printf("✓ Imported %s\n", name)
+	fmt.Printf("  Environment variables: %d\n", len(vars))
+	fmt.Printf("  Deployments: %d\n", len(deployments))
+	fmt.Println()
+	fmt.Printf("Start it with: otterstack deploy %s\n", name)
+	return nil
+}
+
+// readPassphrase reads a passphrase from a file, or prompts for one when no
+// file is given. confirm asks twice, for choosing a new passphrase.
+func readPassphrase(file string, confirm bool) (string, error) {
+	if file == "" {
+		passphrase, err := prompt.Passphrase("Passphrase for environment variables", confirm)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		return passphrase, nil
+	}
+
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", file)
+	}
+	if confirm && len(passphrase) < prompt.MinPassphraseLength {
+		return "", fmt.Errorf("passphrase must be at least %d characters", prompt.MinPassphraseLength)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/lock"
+	"github.com/jayteealao/otterstack/internal/metrics"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Manage deploys waiting for a running deploy to finish",
+	Long: `Manage the deploy queue.
+
+Deploys of a project run one at a time, whether started by "otterstack
+deploy", "otterstack rollback", the monitor or a schedule. A deploy started
+while another is running is queued and waits for it to finish. Only the
+newest queued deploy of a project and set of hosts matters: when another one
+is queued, the older one is superseded and exits without deploying, so a
+burst of webhook or CI triggered deploys ends with a single deploy of the
+latest ref.
+
+Queued deploys are stored in the database and waited for by the otterstack
+process that requested them; stopping that process cancels the deploy.`,
+}
+
+var queueListCmd = &cobra.Command{
+	Use:         "list [project]",
+	Aliases:     []string{"ls"},
+	Short:       "List queued and running deploys",
+	Args:        cobra.MaximumNArgs(1),
+	RunE:        runQueueList,
+	Annotations: structuredOutput,
+}
+
+var queueCancelCmd = &cobra.Command{
+	Use:   "cancel <id>",
+	Short: "Cancel a queued deploy",
+	Long: `Cancel a queued deploy before it runs.
+
+The ID may be shortened to any unique prefix, as shown by "otterstack queue
+list". The waiting otterstack deploy exits with an error. Running deploys
+cannot be cancelled this way; stop their process instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runQueueCancel,
+}
+
+// queuePollInterval is how often a queued deploy checks whether it can run.
+var queuePollInterval = time.Second
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queueCancelCmd)
+}
+
+func runQueueList(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var projectID string
+	if len(args) > 0 {
+		project, err := store.GetProject(ctx, args[0])
+		if err != nil {
+			if errors.Is(err, apperrors.ErrProjectNotFound) {
+				return projectNotFound(args[0])
+			}
+			return err
+		}
+		projectID = project.ID
+	}
+
+	queued, err := store.ListQueuedDeploys(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]string)
+	for _, q := range queued {
+		if _, ok := names[q.ProjectID]; ok {
+			continue
+		}
+		project, err := store.GetProjectByID(ctx, q.ProjectID)
+		if err != nil {
+			return err
+		}
+		names[q.ProjectID] = project.Name
+	}
+
+	if isStructuredOutput() {
+		out := queueListOutput{Deploys: []queuedDeployEntry{}}
+		for _, q := range queued {
+			out.Deploys = append(out.Deploys, newQueuedDeployEntry(q, names[q.ProjectID]))
+		}
+		return writeOutput(os.Stdout, kindDeployQueue, out)
+	}
+
+	if len(queued) == 0 {
+		fmt.Println("No queued deploys")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPROJECT\tREF\tHOSTS\tSTATUS\tTRIGGERED BY\tQUEUED")
+	fmt.Fprintln(w, "--\t-------\t---\t-----\t------\t------------\t------")
+	for _, q := range queued {
+		hosts := strings.Join(q.Hosts, ",")
+		if hosts == "" {
+			hosts = "all"
+		}
+		triggeredBy := q.TriggerSource
+		if q.TriggeredBy != "" {
+			triggeredBy += " (" + q.TriggeredBy + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			shortID(q.ID), names[q.ProjectID], describeQueuedRef(q), hosts, q.Status, triggeredBy,
+			q.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runQueueCancel(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	q, err := resolveQueuedDeploy(ctx, store, args[0])
+	if err != nil {
+		return err
+	}
+	if err := store.CancelQueuedDeploy(ctx, q.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Cancelled the queued %s (%s)\n", describeQueued(q), shortID(q.ID))
+	return nil
+}
+
+// resolveQueuedDeploy finds a queued deploy by ID, or by a prefix of the ID
+// of a queued or running deploy.
+func resolveQueuedDeploy(ctx context.Context, store *state.Store, ref string) (*state.QueuedDeploy, error) {
+	q, err := store.GetQueuedDeploy(ctx, ref)
+	if err == nil || !errors.Is(err, apperrors.ErrQueuedDeployNotFound) {
+		return q, err
+	}
+
+	queued, err := store.ListQueuedDeploys(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var matches []*state.QueuedDeploy
+	for _, q := range queued {
+		if strings.HasPrefix(q.ID, ref) {
+			matches = append(matches, q)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, apperrors.WithMessage(apperrors.ErrQueuedDeployNotFound, fmt.Sprintf("queued deploy %q not found", ref))
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("queued deploy ID %q is ambiguous: matches %d deploys", ref, len(matches))
+	}
+}
+
+// waitInDeployQueue queues a deploy request and waits until no other deploy
+// of the project is running and no older request is pending. It returns with
+// q running. If a newer request
+// superseded q while it waited, it fails with ErrDeploySuperseded and
+// q.SupersededBy names the newer request. If ctx is cancelled while waiting,
+// q is cancelled. The time spent waiting is recorded in
+// metrics.DeployQueueWait.
+func waitInDeployQueue(ctx context.Context, store *state.Store, q *state.QueuedDeploy, onStatus func(string)) error {
+	project, err := store.GetProjectByID(ctx, q.ProjectID)
+	if err != nil {
+		return err
+	}
+	if err := store.EnqueueDeploy(ctx, q); err != nil {
+		return err
+	}
+	start := time.Now()
+	defer func() {
+		metrics.DeployQueueWait.WithLabelValues(project.Name).Observe(time.Since(start).Seconds())
+	}()
+
+	waiting := false
+	for {
+		if err := reapQueuedDeploys(ctx, store, q.ProjectID); err != nil {
+			return err
+		}
+		started, err := store.StartQueuedDeploy(ctx, q.ID)
+		if err != nil {
+			return err
+		}
+		if started {
+			return nil
+		}
+
+		current, err := store.GetQueuedDeploy(ctx, q.ID)
+		if err != nil {
+			return err
+		}
+		switch current.Status {
+		case state.QueueSuperseded:
+			q.Status, q.SupersededBy = current.Status, current.SupersededBy
+			return supersededError(ctx, store, q)
+		case state.QueueCancelled:
+			return apperrors.WithMessage(apperrors.ErrQueuedDeployCancelled,
+				fmt.Sprintf("queued deploy %s was cancelled", shortID(q.ID)))
+		case state.QueuePending:
+		default:
+			return fmt.Errorf("queued deploy %s is %s", shortID(q.ID), current.Status)
+		}
+
+		queued, err := store.ListQueuedDeploys(ctx, q.ProjectID)
+		if err != nil {
+			return err
+		}
+		// Wait for the running deploy, or for an older pending request that
+		// starts first
+		var ahead *state.QueuedDeploy
+		for _, other := range queued {
+			if other.Status == state.QueueRunning {
+				ahead = other
+			}
+		}
+		if ahead == nil && len(queued) > 0 && queued[0].ID != q.ID {
+			ahead = queued[0]
+		}
+		if ahead != nil && !waiting {
+			onStatus(fmt.Sprintf("Waiting for the %s (%s, PID %d) to finish...",
+				describeQueued(ahead), shortID(ahead.ID), ahead.PID))
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := store.FinishQueuedDeploy(context.WithoutCancel(ctx), q.ID, state.QueueCancelled, ""); err != nil {
+				printVerbose("Warning: failed to cancel queued deploy: %v", err)
+			}
+			return ctx.Err()
+		case <-time.After(queuePollInterval):
+		}
+	}
+}
+
+// supersededError describes a queued deploy that a newer request replaced.
+func supersededError(ctx context.Context, store *state.Store, q *state.QueuedDeploy) error {
+	newer := "deploy"
+	if other, err := store.GetQueuedDeploy(ctx, q.SupersededBy); err == nil {
+		newer = describeQueued(other)
+	}
+	return apperrors.WithMessage(apperrors.ErrDeploySuperseded,
+		fmt.Sprintf("superseded by a newer %s (%s); the %s did not run", newer, shortID(q.SupersededBy), describeQueued(q)))
+}
+
+// finishQueuedDeploy records the outcome of a queued deploy that ran.
+func finishQueuedDeploy(ctx context.Context, store *state.Store, q *state.QueuedDeploy, deployErr error) {
+	status, msg := state.QueueSucceeded, ""
+	if deployErr != nil {
+		status, msg = state.QueueFailed, deployErr.Error()
+	}
+	if err := store.FinishQueuedDeploy(context.WithoutCancel(ctx), q.ID, status, msg); err != nil {
+		printVerbose("Warning: failed to record queued deploy outcome: %v", err)
+	}
+}
+
+// reapQueuedDeploys marks the queued and running deploys of a project whose
+// otterstack process was killed as failed, so they no longer hold up the
+// queue. Listing the queue leaves them as they are.
+func reapQueuedDeploys(ctx context.Context, store *state.Store, projectID string) error {
+	queued, err := store.ListQueuedDeploys(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	for _, q := range queued {
+		if lock.ProcessRunning(q.PID) {
+			continue
+		}
+		msg := fmt.Sprintf("otterstack process %d exited before the deploy finished", q.PID)
+		if err := store.FinishQueuedDeploy(ctx, q.ID, state.QueueFailed, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeQueuedRef names the ref a queued deploy deploys.
+func describeQueuedRef(q *state.QueuedDeploy) string {
+	switch {
+	case q.GitRef != "":
+		return q.GitRef
+	case q.TriggerSource == state.TriggerRollback:
+		return "the previous deployment"
+	default:
+		return "the default branch"
+	}
+}
+
+// describeQueued names a queued deploy or rollback, e.g. "deploy of v1.2.0"
+// or "rollback to the previous deployment".
+func describeQueued(q *state.QueuedDeploy) string {
+	if q.TriggerSource == state.TriggerRollback {
+		return "rollback to " + describeQueuedRef(q)
+	}
+	return "deploy of " + describeQueuedRef(q)
+}
+
+// queueListOutput is the data of a DeployQueue document.
+type queueListOutput struct {
+	Deploys []queuedDeployEntry `json:"deploys"`
+}
+
+type queuedDeployEntry struct {
+	ID            string     `json:"id"`
+	Project       string     `json:"project"`
+	GitRef        string     `json:"git_ref,omitempty"`
+	Hosts         []string   `json:"hosts"`
+	Status        string     `json:"status"`
+	TriggerSource string     `json:"trigger_source"`
+	TriggeredBy   string     `json:"triggered_by,omitempty"`
+	PID           int        `json:"pid"`
+	QueuedAt      time.Time  `json:"queued_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+}
+
+func newQueuedDeployEntry(q *state.QueuedDeploy, projectName string) queuedDeployEntry {
+	hosts := q.Hosts
+	if hosts == nil {
+		hosts = []string{}
+	}
+	return queuedDeployEntry{
+		ID:            q.ID,
+		Project:       projectName,
+		GitRef:        q.GitRef,
+		Hosts:         hosts,
+		Status:        q.Status,
+		TriggerSource: q.TriggerSource,
+		TriggeredBy:   q.TriggeredBy,
+		PID:           q.PID,
+		QueuedAt:      q.CreatedAt,
+		StartedAt:     q.StartedAt,
+	}
+}
@@ -1,17 +1,24 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
-	"time"
 
 	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/envschema"
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/oplog"
+	"github.com/jayteealao/otterstack/internal/orchestrator"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
 	"github.com/jayteealao/otterstack/internal/validate"
 	"github.com/spf13/cobra"
 )
@@ -23,24 +30,38 @@ var rollbackCmd = &cobra.Command{
 
 This stops the current deployment and starts the previous one.
 
+With --restore-volumes, the volumes listed under snapshots in otterstack.yaml
+are also restored to the snapshot taken right after the target commit was
+last live, undoing data changes such as migrations made since. The current
+deployment is stopped before the volumes are restored, so the project is
+briefly down.
+
 Examples:
-  otterstack rollback myapp                  # Rollback to previous deployment
-  otterstack rollback myapp --to abc123d     # Rollback to specific SHA`,
-	Args: cobra.ExactArgs(1),
-	RunE: runRollback,
+  otterstack rollback myapp                    # Rollback to previous deployment
+  otterstack rollback myapp --to abc123d       # Rollback to specific SHA
+  otterstack rollback myapp --restore-volumes  # Also restore volume snapshots
+
+With --output json or yaml, progress and docker output go to stderr and a
+RollbackResult is written to stdout whether the rollback succeeds or fails,
+with the final records of the deployment rolled back, the one restored and
+the rollback itself.`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runRollback,
+	Annotations: structuredOutput,
 }
 
-var rollbackToFlag string
+var (
+	rollbackToFlag             string
+	rollbackRestoreVolumesFlag bool
+)
 
 func init() {
 	rootCmd.AddCommand(rollbackCmd)
 	rollbackCmd.Flags().StringVar(&rollbackToFlag, "to", "", "rollback to specific SHA")
+	rollbackCmd.Flags().BoolVar(&rollbackRestoreVolumesFlag, "restore-volumes", false, "restore the volume snapshots taken while the target was live")
 }
 
 func runRollback(cmd *cobra.Command, args []string) error {
-	ctx := cmd.Context()
-	projectName := args[0]
-
 	// Initialize store
 	store, err := initStore()
 	if err != nil {
@@ -48,12 +69,132 @@ func runRollback(cmd *cobra.Command, args []string) error {
 	}
 	defer store.Close()
 
+	dataDir, err := getDataDir()
+	if err != nil {
+		return err
+	}
+
+	opts := rollbackOptions{
+		To:             rollbackToFlag,
+		RestoreVolumes: rollbackRestoreVolumesFlag,
+	}
+	if !isStructuredOutput() {
+		return rollbackProject(cmd.Context(), store, dataDir, args[0], opts)
+	}
+
+	// Keep stdout for the result document
+	opts.Stdout = os.Stderr
+	opts.Result = &rollbackResult{}
+	err = rollbackProject(cmd.Context(), store, dataDir, args[0], opts)
+
+	report := newRollbackOutput(context.WithoutCancel(cmd.Context()), store, args[0], opts.Result, err)
+	if writeErr := writeOutput(os.Stdout, kindRollbackResult, report); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+// rollbackResult records the deployments a rollback involved, as far as it got.
+type rollbackResult struct {
+	From       *state.Deployment // The deployment rolled back
+	To         *state.Deployment // The earlier deployment whose commit is restored
+	Deployment *state.Deployment // The record of the rollback itself
+}
+
+// rollbackOutput is the data of a RollbackResult document.
+type rollbackOutput struct {
+	Project    string        `json:"project"`
+	Succeeded  bool          `json:"succeeded"`
+	Error      string        `json:"error,omitempty"`
+	From       *historyEntry `json:"from,omitempty"`
+	To         *historyEntry `json:"to,omitempty"`
+	Deployment *historyEntry `json:"deployment,omitempty"`
+}
+
+// newRollbackOutput builds the result of a rollback from the final state of
+// the deployments it involved.
+func newRollbackOutput(ctx context.Context, store *state.Store, projectName string, result *rollbackResult, err error) rollbackOutput {
+	out := rollbackOutput{Project: projectName, Succeeded: err == nil}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	final := func(d *state.Deployment) *historyEntry {
+		if d == nil {
+			return nil
+		}
+		if current, err := store.GetDeployment(ctx, d.ID); err == nil {
+			d = current
+		} else {
+			printVerbose("Warning: failed to read deployment %s: %v", shortID(d.ID), err)
+		}
+		entry := newHistoryEntry(d)
+		return &entry
+	}
+	out.From = final(result.From)
+	out.To = final(result.To)
+	out.Deployment = final(result.Deployment)
+	return out
+}
+
+// rollbackOptions selects the rollback target and where its output goes.
+type rollbackOptions struct {
+	To             string // commit to roll back to; empty for the previous deployment
+	RestoreVolumes bool
+
+	// Stdout and Stderr receive progress and docker output. They default to
+	// os.Stdout and os.Stderr.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Result, if set, is filled in with the deployments involved.
+	Result *rollbackResult
+}
+
+// rollbackProject stops the current deployment of a project and starts an
+// earlier one. It waits in the deploy queue for running deploys of the
+// project, then holds the project lock throughout.
+func rollbackProject(ctx context.Context, store *state.Store, dataDir, projectName string, opts rollbackOptions) (retErr error) {
+	ctx = logging.WithFields(ctx, logging.String("project", projectName))
+	defer func() {
+		if retErr != nil {
+			logging.FromContext(ctx).Error("rollback failed", logging.Error(retErr))
+		}
+	}()
+
+	// Get project
+	project, err := store.GetProject(ctx, projectName)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrProjectNotFound) {
+			return projectNotFound(projectName)
+		}
+		return err
+	}
+
 	// Initialize lock manager
 	lockMgr, err := initLockManager()
 	if err != nil {
 		return err
 	}
 
+	// Rollbacks take their turn with deploys of the project
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	queued := &state.QueuedDeploy{
+		ProjectID:     project.ID,
+		GitRef:        opts.To,
+		TriggerSource: state.TriggerRollback,
+		TriggeredBy:   currentUser(),
+		PID:           os.Getpid(),
+	}
+	if err := waitInDeployQueue(ctx, store, queued, func(msg string) { fmt.Fprintln(stdout, msg) }); err != nil {
+		return err
+	}
+	// Registered before the lock is released, so it runs after
+	defer func() { finishQueuedDeploy(ctx, store, queued, retErr) }()
+
 	// Acquire project lock
 	printVerbose("Acquiring lock for project %s...", projectName)
 	lock, err := lockMgr.Acquire(ctx, projectName)
@@ -62,37 +203,56 @@ func runRollback(cmd *cobra.Command, args []string) error {
 	}
 	defer lock.Release()
 
-	// Get project
-	project, err := store.GetProject(ctx, projectName)
+	// Record the full output of the rollback
+	rec, err := oplog.Start(ctx, store, dataDir, project, oplog.OperationRollback)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+		printVerbose("Warning: rollback output will not be recorded: %v", err)
+	}
+	defer func() {
+		if err := rec.Finish(context.WithoutCancel(ctx), retErr); err != nil {
+			printVerbose("Warning: failed to record rollback outcome: %v", err)
 		}
+	}()
+	out := operationOutput{rec: rec, stdout: opts.Stdout, stderr: opts.Stderr, log: logging.FromContext(ctx)}
+
+	// Get current active deployment
+	active, err := store.ListActiveDeployments(ctx, project.ID)
+	if err != nil {
 		return err
 	}
+	if len(active) == 0 {
+		return apperrors.WithMessage(apperrors.ErrNoActiveDeployment, "no active deployment to rollback from")
+	}
+	if len(active) > 1 {
+		return fmt.Errorf("%s runs on %d hosts; roll them all back by redeploying the previous commit: otterstack deploy %s <sha>",
+			projectName, len(active), projectName)
+	}
+	currentDeployment := active[0]
+	if opts.Result != nil {
+		opts.Result.From = currentDeployment
+	}
 
-	// Get current active deployment
-	currentDeployment, err := store.GetActiveDeployment(ctx, project.ID)
+	// Run every docker command on the host the current deployment is on
+	host, err := state.DeploymentTarget(ctx, store, currentDeployment)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrNoActiveDeployment) {
-			return fmt.Errorf("no active deployment to rollback from")
-		}
 		return err
 	}
+	ctx = target.With(ctx, host)
 
 	// Determine target deployment
 	var targetDeployment *state.Deployment
 
-	if rollbackToFlag != "" {
-		if err := validate.GitRef(rollbackToFlag); err != nil {
+	if opts.To != "" {
+		if err := validate.GitRef(opts.To); err != nil {
 			return fmt.Errorf("invalid git ref for --to flag: %w", err)
 		}
 		// Rollback to specific SHA
-		targetDeployment, err = store.GetDeploymentBySHA(ctx, project.ID, rollbackToFlag)
+		targetDeployment, err = store.GetDeploymentBySHA(ctx, project.ID, opts.To)
 		if err != nil {
-			return fmt.Errorf("cannot find deployment with SHA %s: %w", rollbackToFlag, err)
+			return fmt.Errorf("cannot find deployment with SHA %s: %w", opts.To, err)
 		}
-		if targetDeployment.ID == currentDeployment.ID {
+		// A commit can have several records, so compare commits rather than IDs
+		if targetDeployment.GitSHA == currentDeployment.GitSHA {
 			return fmt.Errorf("cannot rollback to current active deployment")
 		}
 	} else {
@@ -100,26 +260,65 @@ func runRollback(cmd *cobra.Command, args []string) error {
 		targetDeployment, err = store.GetPreviousDeployment(ctx, project.ID)
 		if err != nil {
 			if errors.Is(err, apperrors.ErrNoPreviousDeployment) {
-				return fmt.Errorf("no previous deployment to rollback to")
+				return apperrors.WithMessage(err, "no previous deployment to rollback to")
 			}
 			return err
 		}
 	}
 
-	fmt.Printf("Rolling back %s from %s to %s\n",
+	if opts.Result != nil {
+		opts.Result.To = targetDeployment
+	}
+	ctx = logging.WithFields(ctx, logging.String("git_sha", targetDeployment.GitSHA))
+	out.log = logging.FromContext(ctx)
+
+	// Until the rollback record exists, file the log under the target so failed attempts can be found
+	if err := rec.SetDeployment(ctx, targetDeployment.ID); err != nil {
+		printVerbose("Warning: failed to link rollback log: %v", err)
+	}
+
+	out.Printf("Rolling back %s from %s to %s",
 		projectName,
 		git.ShortSHA(currentDeployment.GitSHA),
 		git.ShortSHA(targetDeployment.GitSHA))
+	if !host.IsLocal() {
+		out.Printf("Host: %s (%s)", host, host.Endpoint())
+	}
 
 	// Check that target commit still exists
 	gitMgr := git.NewManager(project.RepoPath)
 	if !gitMgr.CommitExists(ctx, targetDeployment.GitSHA) {
-		return fmt.Errorf("target deployment commit %s no longer exists in repository", git.ShortSHA(targetDeployment.GitSHA))
+		return apperrors.WithMessage(apperrors.ErrGitRefNotFound, fmt.Sprintf("target deployment commit %s no longer exists in repository", git.ShortSHA(targetDeployment.GitSHA)))
+	}
+
+	// Find the volume snapshots before anything is stopped
+	var snapshots []*state.VolumeSnapshot
+	if opts.RestoreVolumes {
+		snapshots, err = store.GetRollbackSnapshots(ctx, project.ID, targetDeployment.GitSHA)
+		if err != nil {
+			if errors.Is(err, apperrors.ErrNoVolumeSnapshot) {
+				return apperrors.WithMessage(err, fmt.Sprintf("no volume snapshot taken since %s was live (list volumes under snapshots in %s to enable them)",
+					git.ShortSHA(targetDeployment.GitSHA), projectconfig.FileName))
+			}
+			return err
+		}
+		if err := orchestrator.VerifySnapshots(snapshots); err != nil {
+			return err
+		}
+	}
+
+	// Use the settings versioned with the target commit
+	cfg, err := resolveProjectConfig(project, targetDeployment.WorktreePath)
+	if err != nil {
+		return err
 	}
 
 	// Start the target deployment
 	targetProjectName := compose.GenerateProjectName(projectName, git.ShortSHA(targetDeployment.GitSHA))
-	composeMgr := compose.NewManager(targetDeployment.WorktreePath, project.ComposeFile, targetProjectName)
+	// Reuse the images built for the target commit rather than rebuilding them
+	composeFiles := compose.WithBuildOverride(targetDeployment.WorktreePath, cfg.ComposeFile)
+	composeMgr := compose.NewManager(targetDeployment.WorktreePath, composeFiles, targetProjectName)
+	composeMgr.SetOutputStreams(rec.Tee(out.getStdout()), rec.Tee(out.getStderr()))
 
 	// Validate compose file
 	if err := composeMgr.Validate(ctx); err != nil {
@@ -127,40 +326,57 @@ func runRollback(cmd *cobra.Command, args []string) error {
 	}
 
 	// Write env file if project has env vars
-	dataDir, err := getDataDir()
+	envVars, err := store.GetEnvVars(ctx, project.ID)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to get env vars: %w", err)
 	}
 
-	envVars, err := store.GetEnvVars(ctx, project.ID)
+	// Apply defaults declared by the target commit's env schema
+	schema, err := envschema.Load(targetDeployment.WorktreePath)
 	if err != nil {
-		return fmt.Errorf("failed to get env vars: %w", err)
+		return err
 	}
+	envVars = schema.ApplyDefaults(envVars)
 
 	envFilePath, err := writeRollbackEnvFile(dataDir, projectName, envVars)
 	if err != nil {
 		return fmt.Errorf("failed to write env file: %w", err)
 	}
 	if envFilePath != "" {
-		printVerbose("Using env file: %s", envFilePath)
+		out.Verbosef("Using env file: %s", envFilePath)
 	}
 
-	fmt.Println("Starting target deployment...")
+	currentProjectName := compose.GenerateProjectName(projectName, git.ShortSHA(currentDeployment.GitSHA))
+
+	// Volumes can only be restored once nothing uses them, so stop the current deployment first
+	if opts.RestoreVolumes {
+		out.Printf("Stopping current deployment...")
+		if err := compose.StopProjectByName(ctx, currentProjectName, cfg.StopTimeout); err != nil {
+			return fmt.Errorf("failed to stop current deployment: %w", err)
+		}
+		if err := orchestrator.RestoreSnapshots(ctx, snapshots, func(msg string) { out.Printf("%s", msg) }); err != nil {
+			return fmt.Errorf("failed to restore volumes (current deployment is stopped; start it again with otterstack deploy %s %s): %w",
+				projectName, git.ShortSHA(currentDeployment.GitSHA), err)
+		}
+	}
+
+	out.Printf("Starting target deployment...")
 	if err := composeMgr.Up(ctx, envFilePath); err != nil {
 		return fmt.Errorf("failed to start target deployment: %w", err)
 	}
 
 	// Stop current deployment
-	currentProjectName := compose.GenerateProjectName(projectName, git.ShortSHA(currentDeployment.GitSHA))
-	fmt.Println("Stopping current deployment...")
-	if err := compose.StopProjectByName(ctx, currentProjectName, 30*time.Second); err != nil {
-		printVerbose("Warning: failed to stop current deployment: %v", err)
+	if !opts.RestoreVolumes {
+		out.Printf("Stopping current deployment...")
+		if err := compose.StopProjectByName(ctx, currentProjectName, cfg.StopTimeout); err != nil {
+			out.Verbosef("Warning: failed to stop current deployment: %v", err)
+		}
 	}
 
 	// Update database state
 	// Mark current as rolled_back
 	if err := store.UpdateDeploymentStatus(ctx, currentDeployment.ID, "rolled_back", nil); err != nil {
-		printVerbose("Warning: failed to update current deployment status: %v", err)
+		out.Verbosef("Warning: failed to update current deployment status: %v", err)
 	}
 
 	// Create new deployment record for the rollback
@@ -170,12 +386,30 @@ func runRollback(cmd *cobra.Command, args []string) error {
 		GitRef:       targetDeployment.GitRef,
 		WorktreePath: targetDeployment.WorktreePath,
 		Status:       "active",
+
+		TriggerSource: state.TriggerRollback,
+		TriggeredBy:   currentUser(),
+		PreviousSHA:   currentDeployment.GitSHA,
+
+		Kind:                state.KindRollback,
+		ParentDeploymentID:  targetDeployment.ID,
+		RevertsDeploymentID: currentDeployment.ID,
+
+		Host: currentDeployment.Host,
 	}
 	if err := store.CreateDeployment(ctx, rollbackDeployment); err != nil {
 		return fmt.Errorf("failed to create rollback deployment record: %w", err)
 	}
+	if opts.Result != nil {
+		opts.Result.Deployment = rollbackDeployment
+	}
+	if err := rec.SetDeployment(ctx, rollbackDeployment.ID); err != nil {
+		printVerbose("Warning: failed to link rollback log: %v", err)
+	}
+	ctx = logging.WithFields(ctx, logging.String("deployment_id", rollbackDeployment.ID))
+	out.log = logging.FromContext(ctx)
 
-	fmt.Printf("Rollback successful! %s now running at %s\n", projectName, git.ShortSHA(targetDeployment.GitSHA))
+	out.Printf("Rollback successful! %s now running at %s", projectName, git.ShortSHA(targetDeployment.GitSHA))
 
 	return nil
 }
@@ -4,13 +4,20 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/signal"
+	"os/user"
 	"path/filepath"
 	"syscall"
+	"time"
 
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/lock"
+	"github.com/jayteealao/otterstack/internal/logging"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/tracing"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,11 +27,28 @@ import (
 var Version = "v0.2.2"
 
 var (
-	cfgFile string
-	dataDir string
-	verbose bool
+	cfgFile   string
+	dataDir   string
+	verbose   bool
+	traceFile string
+	logFormat string
+	logFile   string
+	output    string
+
+	// shutdownTracing flushes the spans recorded by the command.
+	shutdownTracing = func(context.Context) error { return nil }
+
+	// cmdLogger receives structured logs of the command. It discards them
+	// unless --log-format or --log-file is set.
+	cmdLogger = logging.NewNoOpLogger()
+	// closeLogFile closes the --log-file, if one was opened.
+	closeLogFile = func() error { return nil }
 )
 
+// traceFlushInterval is how often spans are exported while a long-running
+// command such as watch is still going.
+const traceFlushInterval = 5 * time.Second
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "otterstack",
@@ -35,6 +59,18 @@ designed for single Linux VPS environments.
 It manages deployments using git worktrees for atomic releases and provides
 rollback capabilities with zero-downtime deployments.`,
 	Version: Version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(cmd); err != nil {
+			return err
+		}
+		if err := initLogging(); err != nil {
+			return err
+		}
+		ctx := tracing.ContextFromEnviron(cmd.Context(), os.Getenv)
+		cmd.SetContext(logging.WithLogger(ctx, cmdLogger))
+		cmdLogger.Debug("running command", logging.String("command", cmd.CommandPath()))
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -52,22 +88,42 @@ func Execute() {
 		cancel()
 	}()
 
-	if err := rootCmd.ExecuteContext(ctx); err != nil {
-		os.Exit(1)
+	err := rootCmd.ExecuteContext(ctx)
+	if err != nil {
+		cmdLogger.Error("command failed", logging.Error(err))
+	}
+	closeLogFile()
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer flushCancel()
+	if flushErr := shutdownTracing(flushCtx); flushErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %v\n", flushErr)
+	}
+
+	if err != nil {
+		os.Exit(apperrors.ExitCode(err))
 	}
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initTracing)
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.otterstack/config.yaml)")
 	rootCmd.PersistentFlags().StringVar(&dataDir, "data-dir", "", "data directory (default is $HOME/.otterstack)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose output")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "write structured logs as json or text (default json with --log-file, otherwise off)")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "append structured logs to this file instead of stderr")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "append trace spans as JSON lines to this file (\"-\" for stdout)")
+	rootCmd.PersistentFlags().StringVar(&output, "output", outputTable, "result format: table, json or yaml")
 
 	// Bind flags to viper
 	viper.BindPFlag("data-dir", rootCmd.PersistentFlags().Lookup("data-dir"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("log-format", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("log-file", rootCmd.PersistentFlags().Lookup("log-file"))
+	viper.BindPFlag("trace-file", rootCmd.PersistentFlags().Lookup("trace-file"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -94,6 +150,58 @@ func initConfig() {
 	}
 }
 
+// initTracing exports trace spans to the --trace-file and to the OTLP
+// endpoint set by the standard OTEL_EXPORTER_OTLP_* variables, if any.
+// Commands continue the trace of a TRACEPARENT in their environment.
+// A tracing setup failure is reported but does not stop the command.
+func initTracing() {
+	cfg := tracing.ConfigFromEnv(os.Getenv)
+	cfg.File = viper.GetString("trace-file")
+
+	shutdown, err := tracing.Setup(context.Background(), cfg, Version, traceFlushInterval, func(err error) {
+		printVerbose("Warning: failed to export traces: %v", err)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: tracing disabled: %v\n", err)
+		return
+	}
+	shutdownTracing = shutdown
+}
+
+// initLogging sets up cmdLogger from --log-format and --log-file. Logs go to
+// stderr unless a file is given, at debug level in verbose mode and info
+// level otherwise.
+func initLogging() error {
+	format, path := viper.GetString("log-format"), viper.GetString("log-file")
+	if format == "" && path == "" {
+		return nil
+	}
+	if format == "" {
+		format = logging.FormatJSON
+	}
+
+	var w io.Writer = os.Stderr
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		w, closeLogFile = f, f.Close
+	}
+
+	level := slog.LevelInfo
+	if isVerbose() {
+		level = slog.LevelDebug
+	}
+	logger, err := logging.New(w, format, level)
+	if err != nil {
+		closeLogFile()
+		return err
+	}
+	cmdLogger = logger
+	return nil
+}
+
 // getDataDir returns the data directory, defaulting to $HOME/.otterstack
 func getDataDir() (string, error) {
 	if dataDir != "" {
@@ -125,6 +233,18 @@ func initStore() (*state.Store, error) {
 	return store, nil
 }
 
+// projectNotFound reports that no project has the given name. The error
+// matches apperrors.ErrProjectNotFound, so the command exits with its code.
+func projectNotFound(name string) error {
+	return apperrors.WithMessage(apperrors.ErrProjectNotFound, fmt.Sprintf("project %q not found", name))
+}
+
+// hostNotFound reports that no host is registered under the given name. The
+// error matches apperrors.ErrHostNotFound.
+func hostNotFound(name string) error {
+	return apperrors.WithMessage(apperrors.ErrHostNotFound, fmt.Sprintf("host %q not found", name))
+}
+
 // initLockManager initializes and returns the lock manager.
 func initLockManager() (*lock.Manager, error) {
 	dir, err := getDataDir()
@@ -146,10 +266,32 @@ func isVerbose() bool {
 }
 
 // printVerbose prints a message if verbose mode is enabled.
+// The message is also logged at debug level.
 func printVerbose(format string, args ...interface{}) {
 	if isVerbose() {
 		fmt.Fprintf(os.Stderr, format+"\n", args...)
 	}
+	cmdLogger.Debug(fmt.Sprintf(format, args...))
+}
+
+// printVerboseLine prints msg if verbose mode is enabled, without logging
+// it. It suits callbacks of operations that log their messages themselves.
+func printVerboseLine(msg string) {
+	if isVerbose() {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+}
+
+// currentUser returns the unix user running the command.
+// Under sudo the invoking user is reported rather than root.
+func currentUser() string {
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return sudoUser
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
 }
 
 // checkContext returns an error if the context is cancelled.
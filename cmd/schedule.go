@@ -0,0 +1,497 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/lock"
+	"github.com/jayteealao/otterstack/internal/notify"
+	"github.com/jayteealao/otterstack/internal/orchestrator"
+	"github.com/jayteealao/otterstack/internal/schedule"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Deploy projects automatically when a branch or tag moves",
+	Long: `Manage deploy schedules.
+
+A scheduled project follows a branch, or the highest semver tag matching a
+pattern. "otterstack watch" checks it at the configured interval and deploys
+when the branch or tag points at a commit it has not seen before. Each commit
+is tried once: a failed deployment, or a manual rollback, is left alone until
+the next commit.`,
+}
+
+var scheduleSetCmd = &cobra.Command{
+	Use:   "set <project>",
+	Short: "Create or replace a project's deploy schedule",
+	Long: `Create or replace a project's deploy schedule.
+
+Maintenance windows restrict when automatic deployments may start. A window
+is "[days] HH:MM-HH:MM", where days are names or ranges such as Mon-Fri or
+Sat,Sun; a window ending before it starts runs past midnight. Outside every
+window the project is not checked at all.
+
+Examples:
+  otterstack schedule set myapp --branch main --every 5m
+  otterstack schedule set myapp --tag 'v*' --every 1h
+  otterstack schedule set myapp --branch main --window 'Mon-Fri 02:00-05:00' --window 'Sat,Sun 00:00-24:00'
+  otterstack schedule set myapp --tag 'v*' --window '22:00-06:00' --timezone Europe/Berlin`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScheduleSet,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List deploy schedules",
+	Args:    cobra.NoArgs,
+	RunE:    runScheduleList,
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:     "remove <project>",
+	Aliases: []string{"rm"},
+	Short:   "Stop deploying a project automatically",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runScheduleRemove,
+}
+
+var (
+	scheduleBranchFlag   string
+	scheduleTagFlag      string
+	scheduleEveryFlag    time.Duration
+	scheduleWindowFlag   []string
+	scheduleTimezoneFlag string
+)
+
+// scheduleTick is how often the watch process looks for due schedules.
+const scheduleTick = 30 * time.Second
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleSetCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleRemoveCmd)
+
+	scheduleSetCmd.Flags().StringVar(&scheduleBranchFlag, "branch", "", "follow this branch")
+	scheduleSetCmd.Flags().StringVar(&scheduleTagFlag, "tag", "", `follow the highest semver tag matching this glob, e.g. "v*"`)
+	scheduleSetCmd.Flags().DurationVar(&scheduleEveryFlag, "every", 5*time.Minute, "how often to check for a new commit")
+	scheduleSetCmd.Flags().StringArrayVar(&scheduleWindowFlag, "window", nil, `maintenance window, e.g. "Mon-Fri 02:00-05:00" (repeatable; default any time)`)
+	scheduleSetCmd.Flags().StringVar(&scheduleTimezoneFlag, "timezone", "", "time zone of the windows, e.g. Europe/Berlin (default: the server's)")
+	scheduleSetCmd.MarkFlagsMutuallyExclusive("branch", "tag")
+	scheduleSetCmd.MarkFlagsOneRequired("branch", "tag")
+}
+
+func runScheduleSet(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+
+	if scheduleBranchFlag != "" {
+		if err := validate.GitRef(scheduleBranchFlag); err != nil {
+			return fmt.Errorf("invalid branch: %w", err)
+		}
+	}
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	project, err := store.GetProject(ctx, name)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrProjectNotFound) {
+			return projectNotFound(name)
+		}
+		return err
+	}
+
+	sc := &state.Schedule{
+		ProjectID:  project.ID,
+		Branch:     scheduleBranchFlag,
+		TagPattern: scheduleTagFlag,
+		Interval:   scheduleEveryFlag,
+		Windows:    strings.Join(scheduleWindowFlag, "; "),
+		Timezone:   scheduleTimezoneFlag,
+	}
+	spec, err := schedule.Parse(sc)
+	if err != nil {
+		return err
+	}
+
+	if err := store.SetSchedule(ctx, sc); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ %s follows %s, checked every %s\n", name, spec.Tracks(), sc.Interval)
+	for _, w := range spec.Windows {
+		fmt.Printf("  Window: %s (%s)\n", w, spec.Location)
+	}
+	fmt.Println("\nSchedules run inside \"otterstack watch\".")
+	return nil
+}
+
+func runScheduleList(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	schedules, err := store.ListSchedules(ctx)
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		fmt.Println("No deploy schedules. Add one with: otterstack schedule set <project> --branch main")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tFOLLOWS\tEVERY\tWINDOWS\tLAST CHECK\tCOMMIT\tSTATUS")
+	fmt.Fprintln(w, "-------\t-------\t-----\t-------\t----------\t------\t------")
+	for _, sc := range schedules {
+		project, err := store.GetProjectByID(ctx, sc.ProjectID)
+		if err != nil {
+			return err
+		}
+
+		windows := sc.Windows
+		if windows == "" {
+			windows = "any time"
+		} else if sc.Timezone != "" {
+			windows += " " + sc.Timezone
+		}
+
+		lastCheck, commit := "never", "-"
+		if sc.LastCheckedAt != nil {
+			lastCheck = sc.LastCheckedAt.Local().Format("2006-01-02 15:04")
+		}
+		if sc.LastSHA != "" {
+			commit = git.ShortSHA(sc.LastSHA)
+		}
+
+		status := "ok"
+		spec, err := schedule.Parse(sc)
+		switch {
+		case err != nil:
+			status = "invalid: " + err.Error()
+		case sc.LastError != "":
+			status = "error: " + firstLine(sc.LastError)
+		case !spec.Open(now):
+			status = "outside window"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			project.Name, describeSchedule(sc), sc.Interval, windows, lastCheck, commit, status)
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runScheduleRemove(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+
+	store, err := initStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	project, err := store.GetProject(ctx, name)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrProjectNotFound) {
+			return projectNotFound(name)
+		}
+		return err
+	}
+
+	if err := store.DeleteSchedule(ctx, project.ID); err != nil {
+		if errors.Is(err, apperrors.ErrScheduleNotFound) {
+			return fmt.Errorf("project %q has no deploy schedule", name)
+		}
+		return err
+	}
+
+	fmt.Printf("✓ %s is no longer deployed automatically\n", name)
+	return nil
+}
+
+// describeSchedule returns what a schedule follows without parsing it.
+func describeSchedule(sc *state.Schedule) string {
+	if sc.Branch != "" {
+		return "branch " + sc.Branch
+	}
+	return "tag " + sc.TagPattern
+}
+
+// firstLine returns the first line of a possibly multi-line message.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// runScheduler checks deploy schedules until ctx is cancelled. It is started
+// by "otterstack watch".
+func runScheduler(ctx context.Context, store *state.Store, notifyMgr *notify.Manager, dataDir, projectFilter string) {
+	ticker := time.NewTicker(scheduleTick)
+	defer ticker.Stop()
+
+	for {
+		runDueSchedules(ctx, store, notifyMgr, dataDir, projectFilter, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueSchedules checks every schedule that is due at now, one at a time.
+func runDueSchedules(ctx context.Context, store *state.Store, notifyMgr *notify.Manager, dataDir, projectFilter string, now time.Time) {
+	schedules, err := store.ListSchedules(ctx)
+	if err != nil {
+		printVerbose("Failed to list schedules: %v", err)
+		return
+	}
+
+	for _, sc := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+
+		project, err := store.GetProjectByID(ctx, sc.ProjectID)
+		if err != nil {
+			printVerbose("Schedule for project %s: %v", sc.ProjectID, err)
+			continue
+		}
+		if projectFilter != "" && project.Name != projectFilter {
+			continue
+		}
+
+		spec, err := schedule.Parse(sc)
+		if err != nil {
+			printVerbose("[%s] %s: invalid schedule: %v", now.Format("15:04:05"), project.Name, err)
+			continue
+		}
+		if !spec.Due(now) {
+			continue
+		}
+
+		checkSchedule(ctx, store, notifyMgr, dataDir, project, spec)
+	}
+}
+
+// checkSchedule fetches a scheduled project, resolves the ref it follows and
+// deploys the commit if the ref moved to one not seen before and some host is
+// not running it yet.
+func checkSchedule(ctx context.Context, store *state.Store, notifyMgr *notify.Manager, dataDir string, project *state.Project, spec *schedule.Spec) {
+	logf := func(format string, args ...interface{}) {
+		fmt.Printf("[%s] %s: %s\n", time.Now().Format("15:04:05"), project.Name, fmt.Sprintf(format, args...))
+	}
+	record := func(sha string, checkErr error) {
+		var msg string
+		if checkErr != nil {
+			msg = checkErr.Error()
+			logf("schedule check failed: %s", firstLine(msg))
+		}
+		if err := store.RecordScheduleCheck(context.WithoutCancel(ctx), project.ID, sha, msg); err != nil {
+			printVerbose("Failed to record schedule check: %v", err)
+		}
+	}
+
+	// Leave the project alone while a deployment holds its lock; the check is
+	// not recorded, so it runs again on the next tick
+	lockMgr, err := lock.NewManager(dataDir)
+	if err != nil {
+		record("", err)
+		return
+	}
+	if locked, _, err := lockMgr.IsLocked(project.Name); err == nil && locked {
+		printVerbose("[%s] %s: deployment in progress, schedule check postponed", time.Now().Format("15:04:05"), project.Name)
+		return
+	}
+	if err := reapQueuedDeploys(ctx, store, project.ID); err != nil {
+		record("", err)
+		return
+	}
+	if queued, err := store.ListQueuedDeploys(ctx, project.ID); err == nil && len(queued) > 0 {
+		printVerbose("[%s] %s: deploy queued, schedule check postponed", time.Now().Format("15:04:05"), project.Name)
+		return
+	}
+
+	gitMgr := git.NewManager(project.RepoPath)
+	if project.RepoType == "remote" {
+		if err := gitMgr.Fetch(ctx); err != nil {
+			record("", err)
+			return
+		}
+	}
+
+	ref, sha, err := spec.Resolve(ctx, gitMgr)
+	if err != nil {
+		record("", err)
+		return
+	}
+	if sha == spec.LastSHA {
+		record(sha, nil)
+		return
+	}
+
+	targets, err := projectTargets(ctx, store, project, nil)
+	if err != nil {
+		record("", err)
+		return
+	}
+	active, err := store.ListActiveDeployments(ctx, project.ID)
+	if err != nil {
+		record("", err)
+		return
+	}
+	pending := outdatedTargets(targets, active, sha)
+	if len(pending) == 0 {
+		printVerbose("[%s] %s: %s is already running %s", time.Now().Format("15:04:05"), project.Name, ref, git.ShortSHA(sha))
+		record(sha, nil)
+		return
+	}
+
+	if project.Status != "ready" {
+		record("", fmt.Errorf("project is not ready (status: %s)", project.Status))
+		return
+	}
+
+	maxConcurrent, resources, err := deployLimits()
+	if err != nil {
+		record("", err)
+		return
+	}
+
+	// Take a turn in the deploy queue so deploys started meanwhile wait for
+	// this one rather than fail on the lock
+	queued := &state.QueuedDeploy{
+		ProjectID:     project.ID,
+		GitRef:        ref,
+		Hosts:         targetHosts(targets, pending),
+		TriggerSource: state.TriggerSchedule,
+		TriggeredBy:   spec.Tracks(),
+		PID:           os.Getpid(),
+	}
+	if err := waitInDeployQueue(ctx, store, queued, func(msg string) { logf("%s", msg) }); err != nil {
+		// A deploy queued meanwhile replaced this one: check again next tick
+		if errors.Is(err, apperrors.ErrDeploySuperseded) {
+			printVerbose("[%s] %s: %s, schedule check postponed", time.Now().Format("15:04:05"), project.Name, err)
+			return
+		}
+		record(sha, err)
+		return
+	}
+
+	logf("%s moved to %s, deploying", spec.Tracks(), git.ShortSHA(sha))
+	sendScheduleEvent(ctx, notifyMgr, notify.EventDeployStarted, project, ref, sha, "Scheduled deployment started")
+
+	var output io.Writer = io.Discard
+	if isVerbose() {
+		output = os.Stderr
+	}
+	opts := orchestrator.DeployOptions{
+		GitRef:    ref,
+		SHA:       sha,
+		DataDir:   dataDir,
+		OnStatus:  func(msg string) { logf("%s", msg) },
+		OnVerbose: printVerboseLine,
+		Stdout:    output,
+		Stderr:    output,
+
+		MaxConcurrent: maxConcurrent,
+		Resources:     resources,
+
+		TriggerSource: state.TriggerSchedule,
+		TriggeredBy:   spec.Tracks(),
+	}
+
+	deployer := orchestrator.NewDeployer(store, gitMgr)
+	result, err := rollout(ctx, deployer, project, pending, opts, nil)
+	finishQueuedDeploy(ctx, store, queued, err)
+	if err != nil {
+		// Another deployment took the lock after the check above: try again next tick
+		if errors.Is(err, apperrors.ErrProjectLocked) {
+			printVerbose("[%s] %s: deployment in progress, schedule check postponed", time.Now().Format("15:04:05"), project.Name)
+			return
+		}
+		record(sha, err)
+		sendScheduleEvent(ctx, notifyMgr, notify.EventDeployFailed, project, ref, sha, firstLine(err.Error()))
+		return
+	}
+
+	record(sha, nil)
+	logf("deployed %s at %s", ref, result.ShortSHA)
+	sendScheduleEvent(ctx, notifyMgr, notify.EventDeploySucceeded, project, ref, sha, "Scheduled deployment succeeded")
+	cleanupWorktrees(ctx, deployer, project, dataDir, result)
+}
+
+// targetHosts returns the host names of the subset of a project's targets a
+// deployment goes to, or nil if it goes to all of them.
+func targetHosts(all, subset []target.Target) []string {
+	if len(subset) == len(all) {
+		return nil
+	}
+	hosts := make([]string, len(subset))
+	for i, t := range subset {
+		hosts[i] = t.Name
+	}
+	return hosts
+}
+
+// outdatedTargets returns the targets whose active deployment is not at sha.
+func outdatedTargets(targets []target.Target, active []*state.Deployment, sha string) []target.Target {
+	running := make(map[string]string, len(active))
+	for _, d := range active {
+		running[d.Host] = d.GitSHA
+	}
+
+	var outdated []target.Target
+	for _, t := range targets {
+		if running[t.Name] != sha {
+			outdated = append(outdated, t)
+		}
+	}
+	return outdated
+}
+
+func sendScheduleEvent(ctx context.Context, notifyMgr *notify.Manager, eventType notify.EventType, project *state.Project, ref, sha, message string) {
+	if notifyMgr.Count() == 0 {
+		return
+	}
+	event := notify.Event{
+		Type:    eventType,
+		Project: project.Name,
+		Message: message,
+		Details: map[string]string{
+			"ref":     ref,
+			"sha":     git.ShortSHA(sha),
+			"trigger": state.TriggerSchedule,
+		},
+	}
+	if err := notifyMgr.Notify(ctx, event); err != nil {
+		printVerbose("Notification error: %v", err)
+	}
+}
@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/jayteealao/otterstack/internal/compose"
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/tui"
 	"github.com/spf13/cobra"
 )
 
@@ -18,9 +24,12 @@ var statusCmd = &cobra.Command{
 	Long: `Show the status of deployments.
 
 Without arguments, shows status of all projects.
-With a project name, shows detailed status for that project.`,
-	Args: cobra.MaximumNArgs(1),
-	RunE: runStatus,
+With a project name, shows detailed status for that project. With --services,
+each container of the active deployment is listed with its CPU, memory usage
+against its limit, restart count, uptime, and network and block I/O.`,
+	Args:        cobra.MaximumNArgs(1),
+	RunE:        runStatus,
+	Annotations: structuredOutput,
 }
 
 var (
@@ -30,7 +39,7 @@ var (
 func init() {
 	rootCmd.AddCommand(statusCmd)
 
-	statusCmd.Flags().BoolVarP(&statusServicesFlag, "services", "s", false, "show service status")
+	statusCmd.Flags().BoolVarP(&statusServicesFlag, "services", "s", false, "show service status and resource usage")
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -55,47 +64,57 @@ func showAllProjectsStatus(cmd *cobra.Command) error {
 		return fmt.Errorf("failed to list projects: %w", err)
 	}
 
+	if isStructuredOutput() {
+		result := statusListOutput{Projects: []projectStatusEntry{}}
+		for _, p := range projects {
+			deployments, err := store.ListActiveDeployments(ctx, p.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get deployment for %s: %w", p.Name, err)
+			}
+			entry := projectStatusEntry{Name: p.Name, Status: p.Status, ActiveDeployments: []activeDeploymentEntry{}}
+			for _, deployment := range deployments {
+				entry.ActiveDeployments = append(entry.ActiveDeployments, activeDeploymentStatus(ctx, store, p.Name, deployment, false))
+			}
+			result.Projects = append(result.Projects, entry)
+		}
+		return writeOutput(os.Stdout, kindStatusList, result)
+	}
+
 	if len(projects) == 0 {
 		fmt.Println("No projects configured.")
 		return nil
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "PROJECT\tSTATUS\tDEPLOYMENT\tREF\tSERVICES")
-	fmt.Fprintln(w, "-------\t------\t----------\t---\t--------")
+	fmt.Fprintln(w, "PROJECT\tSTATUS\tDEPLOYMENT\tREF\tHOST\tSERVICES")
+	fmt.Fprintln(w, "-------\t------\t----------\t---\t----\t--------")
 
 	for _, p := range projects {
-		deployment, err := store.GetActiveDeployment(ctx, p.ID)
-		if err != nil && !errors.Is(err, apperrors.ErrNoActiveDeployment) {
+		deployments, err := store.ListActiveDeployments(ctx, p.ID)
+		if err != nil {
 			return fmt.Errorf("failed to get deployment for %s: %w", p.Name, err)
 		}
 
-		deploymentInfo := "-"
-		refInfo := "-"
-		servicesInfo := "-"
+		if len(deployments) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\n", p.Name, p.Status)
+			continue
+		}
 
-		if deployment != nil {
-			deploymentInfo = git.ShortSHA(deployment.GitSHA)
+		// One row per host the project runs on
+		for _, deployment := range deployments {
+			refInfo := "-"
 			if deployment.GitRef != "" {
 				refInfo = deployment.GitRef
 			}
 
-			// Get service status
-			projectName := compose.GenerateProjectName(p.Name, git.ShortSHA(deployment.GitSHA))
-			services, err := compose.GetProjectStatus(ctx, projectName)
-			if err == nil && len(services) > 0 {
-				running := 0
-				for _, s := range services {
-					if compose.IsServiceRunning(s.Status) {
-						running++
-					}
-				}
-				servicesInfo = fmt.Sprintf("%d/%d running", running, len(services))
+			servicesInfo := "-"
+			if status := activeDeploymentStatus(ctx, store, p.Name, deployment, false); status.ServicesTotal > 0 {
+				servicesInfo = fmt.Sprintf("%d/%d running", status.ServicesRunning, status.ServicesTotal)
 			}
-		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-			p.Name, p.Status, deploymentInfo, refInfo, servicesInfo)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				p.Name, p.Status, git.ShortSHA(deployment.GitSHA), refInfo, hostName(deployment), servicesInfo)
+		}
 	}
 	w.Flush()
 
@@ -114,11 +133,15 @@ func showProjectStatus(cmd *cobra.Command, projectName string) error {
 	project, err := store.GetProject(ctx, projectName)
 	if err != nil {
 		if errors.Is(err, apperrors.ErrProjectNotFound) {
-			return fmt.Errorf("project %q not found", projectName)
+			return projectNotFound(projectName)
 		}
 		return err
 	}
 
+	if isStructuredOutput() {
+		return writeProjectStatus(ctx, store, project)
+	}
+
 	fmt.Printf("Project: %s\n", project.Name)
 	fmt.Printf("Status:  %s\n", project.Status)
 	fmt.Printf("Type:    %s\n", project.RepoType)
@@ -127,26 +150,91 @@ func showProjectStatus(cmd *cobra.Command, projectName string) error {
 		fmt.Printf("URL:     %s\n", project.RepoURL)
 	}
 	fmt.Printf("Compose: %s\n", project.ComposeFile)
+	hosts, err := store.ListProjectHosts(ctx, project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get hosts: %w", err)
+	}
+	if len(hosts) > 0 {
+		names := make([]string, len(hosts))
+		for i, h := range hosts {
+			names[i] = h.Name
+		}
+		fmt.Printf("Hosts:   %s\n", strings.Join(names, ", "))
+	}
 	fmt.Println()
 
-	// Get active deployment
-	deployment, err := store.GetActiveDeployment(ctx, project.ID)
+	// Get active deployments, one per host
+	deployments, err := store.ListActiveDeployments(ctx, project.ID)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrNoActiveDeployment) {
-			fmt.Println("No active deployment.")
-			return nil
-		}
 		return fmt.Errorf("failed to get deployment: %w", err)
 	}
+	if len(deployments) == 0 {
+		fmt.Println("No active deployment.")
+		return nil
+	}
+
+	for _, deployment := range deployments {
+		showActiveDeployment(ctx, store, projectName, deployment)
+	}
 
-	fmt.Println("Active Deployment:")
+	// Show recent deployments
+	recent, err := store.ListDeployments(ctx, project.ID, 5)
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	if len(recent) > 1 {
+		fmt.Println("Recent Deployments:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  COMMIT\tSTATUS\tREF\tHOST\tSTARTED\tTRIGGERED BY")
+		for _, d := range recent {
+			ref := d.GitRef
+			if ref == "" {
+				ref = "-"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+				git.ShortSHA(d.GitSHA),
+				d.Status,
+				ref,
+				hostName(d),
+				d.StartedAt.Format("2006-01-02 15:04:05"),
+				formatTrigger(d))
+		}
+		w.Flush()
+	}
+
+	return nil
+}
+
+// showActiveDeployment prints an active deployment and, with --services, the
+// state of its containers on the host it runs on.
+func showActiveDeployment(ctx context.Context, store *state.Store, projectName string, deployment *state.Deployment) {
+	host, hostErr := state.DeploymentTarget(ctx, store, deployment)
+
+	if deployment.Host == "" {
+		fmt.Println("Active Deployment:")
+	} else {
+		fmt.Printf("Active Deployment on %s:\n", deployment.Host)
+	}
 	fmt.Printf("  Commit:     %s\n", git.ShortSHA(deployment.GitSHA))
 	if deployment.GitRef != "" {
 		fmt.Printf("  Ref:        %s\n", deployment.GitRef)
 	}
+	if deployment.Host != "" && hostErr == nil {
+		fmt.Printf("  Host:       %s (%s)\n", deployment.Host, host.Endpoint())
+	}
 	fmt.Printf("  Started:    %s\n", deployment.StartedAt.Format("2006-01-02 15:04:05"))
 	if deployment.FinishedAt != nil {
 		fmt.Printf("  Finished:   %s\n", deployment.FinishedAt.Format("2006-01-02 15:04:05"))
+		duration := formatDuration(deployment.FinishedAt.Sub(deployment.StartedAt))
+		if len(deployment.Phases) > 0 {
+			duration += " (" + formatPhases(deployment.Phases) + ")"
+		}
+		fmt.Printf("  Duration:   %s\n", duration)
+	}
+	fmt.Printf("  Trigger:    %s\n", formatTrigger(deployment))
+	if deployment.PreviousSHA != "" {
+		fmt.Printf("  Previous:   %s\n", git.ShortSHA(deployment.PreviousSHA))
 	}
 	fmt.Printf("  Worktree:   %s\n", deployment.WorktreePath)
 	fmt.Println()
@@ -155,49 +243,196 @@ func showProjectStatus(cmd *cobra.Command, projectName string) error {
 	if statusServicesFlag {
 		fmt.Println("Services:")
 		composeProjectName := compose.GenerateProjectName(projectName, git.ShortSHA(deployment.GitSHA))
-		services, err := compose.GetProjectStatus(ctx, composeProjectName)
+		var services []compose.ServiceStatus
+		err := hostErr
+		if err == nil {
+			services, err = compose.GetProjectStatus(target.With(ctx, host), composeProjectName)
+		}
 		if err != nil {
 			fmt.Printf("  Error getting services: %v\n", err)
 		} else if len(services) == 0 {
 			fmt.Println("  No services running.")
 		} else {
+			// Resource usage is best effort; services are listed without it
+			stats, statsErr := compose.GetProjectStats(target.With(ctx, host), composeProjectName)
+			if statsErr != nil {
+				printVerbose("Failed to get resource usage: %v", statsErr)
+			}
+			byName := make(map[string]compose.ContainerStats, len(stats))
+			for _, st := range stats {
+				byName[st.Name] = st
+			}
+
+			now := time.Now()
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "  NAME\tSTATUS\tHEALTH")
+			fmt.Fprintln(w, "  NAME\tSTATUS\tHEALTH\tCPU\tMEMORY\tRESTARTS\tUPTIME\tNET I/O\tBLOCK I/O")
 			for _, s := range services {
 				health := s.Health
 				if health == "" {
 					health = "-"
 				}
-				fmt.Fprintf(w, "  %s\t%s\t%s\n", s.Name, s.Status, health)
+				usage := "-\t-\t-\t-\t-\t-"
+				if st, ok := byName[s.Name]; ok {
+					usage = fmt.Sprintf("%.1f%%\t%s\t%d\t%s\t%s / %s\t%s / %s",
+						st.CPUPercent, tui.FormatMemory(st), st.RestartCount, tui.FormatUptime(st.Uptime(now)),
+						tui.FormatBytes(st.NetRx), tui.FormatBytes(st.NetTx),
+						tui.FormatBytes(st.BlockRead), tui.FormatBytes(st.BlockWrite))
+				}
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", s.Name, s.Status, health, usage)
 			}
 			w.Flush()
 		}
 		fmt.Println()
 	}
+}
 
-	// Show recent deployments
-	deployments, err := store.ListDeployments(ctx, project.ID, 5)
+// statusListOutput is the data of a StatusList document.
+type statusListOutput struct {
+	Projects []projectStatusEntry `json:"projects"`
+}
+
+type projectStatusEntry struct {
+	Name              string                  `json:"name"`
+	Status            string                  `json:"status"`
+	ActiveDeployments []activeDeploymentEntry `json:"active_deployments"`
+}
+
+// projectStatusOutput is the data of a ProjectStatus document.
+type projectStatusOutput struct {
+	Project           projectEntry            `json:"project"`
+	Hosts             []string                `json:"hosts"`
+	ActiveDeployments []activeDeploymentEntry `json:"active_deployments"`
+	RecentDeployments []historyEntry          `json:"recent_deployments"`
+}
+
+// activeDeploymentEntry is an active deployment and the state of its
+// containers. Services lists them one by one with --services.
+type activeDeploymentEntry struct {
+	historyEntry
+	Endpoint        string         `json:"endpoint,omitempty"`
+	ServicesRunning int            `json:"services_running"`
+	ServicesTotal   int            `json:"services_total"`
+	Services        []serviceEntry `json:"services,omitempty"`
+	ServicesError   string         `json:"services_error,omitempty"`
+}
+
+type serviceEntry struct {
+	Name    string        `json:"name"`
+	Service string        `json:"service"`
+	Status  string        `json:"status"`
+	Health  string        `json:"health,omitempty"`
+	Usage   *serviceUsage `json:"usage,omitempty"`
+}
+
+// serviceUsage is the resource usage of a container, omitted when it could
+// not be sampled.
+type serviceUsage struct {
+	CPUPercent      float64 `json:"cpu_percent"`
+	MemoryBytes     uint64  `json:"memory_bytes"`
+	MemoryLimit     uint64  `json:"memory_limit_bytes"`
+	MemoryPercent   float64 `json:"memory_percent"`
+	RestartCount    int     `json:"restart_count"`
+	UptimeSeconds   int64   `json:"uptime_seconds"`
+	NetRxBytes      uint64  `json:"net_rx_bytes"`
+	NetTxBytes      uint64  `json:"net_tx_bytes"`
+	BlockReadBytes  uint64  `json:"block_read_bytes"`
+	BlockWriteBytes uint64  `json:"block_write_bytes"`
+}
+
+// activeDeploymentStatus counts the running containers of an active
+// deployment on the host it runs on and, if withServices is set, lists them
+// with their resource usage.
+func activeDeploymentStatus(ctx context.Context, store *state.Store, projectName string, deployment *state.Deployment, withServices bool) activeDeploymentEntry {
+	entry := activeDeploymentEntry{historyEntry: newHistoryEntry(deployment)}
+
+	host, err := state.DeploymentTarget(ctx, store, deployment)
 	if err != nil {
-		return fmt.Errorf("failed to list deployments: %w", err)
+		entry.ServicesError = err.Error()
+		return entry
+	}
+	if !host.IsLocal() {
+		entry.Endpoint = host.Endpoint()
 	}
 
-	if len(deployments) > 1 {
-		fmt.Println("Recent Deployments:")
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "  COMMIT\tSTATUS\tREF\tSTARTED")
-		for _, d := range deployments {
-			ref := d.GitRef
-			if ref == "" {
-				ref = "-"
+	hostCtx := target.With(ctx, host)
+	composeProjectName := compose.GenerateProjectName(projectName, git.ShortSHA(deployment.GitSHA))
+	services, err := compose.GetProjectStatus(hostCtx, composeProjectName)
+	if err != nil {
+		entry.ServicesError = err.Error()
+		return entry
+	}
+	entry.ServicesTotal = len(services)
+	for _, s := range services {
+		if compose.IsServiceRunning(s.Status) {
+			entry.ServicesRunning++
+		}
+	}
+	if !withServices || len(services) == 0 {
+		return entry
+	}
+
+	// Resource usage is best effort; services are listed without it
+	stats, err := compose.GetProjectStats(hostCtx, composeProjectName)
+	if err != nil {
+		printVerbose("Failed to get resource usage: %v", err)
+	}
+	byName := make(map[string]compose.ContainerStats, len(stats))
+	for _, st := range stats {
+		byName[st.Name] = st
+	}
+
+	now := time.Now()
+	for _, s := range services {
+		svc := serviceEntry{Name: s.Name, Service: s.Service, Status: s.Status, Health: s.Health}
+		if st, ok := byName[s.Name]; ok {
+			svc.Usage = &serviceUsage{
+				CPUPercent:      st.CPUPercent,
+				MemoryBytes:     st.MemUsage,
+				MemoryLimit:     st.MemLimit,
+				MemoryPercent:   st.MemPercent,
+				RestartCount:    st.RestartCount,
+				UptimeSeconds:   int64(st.Uptime(now).Seconds()),
+				NetRxBytes:      st.NetRx,
+				NetTxBytes:      st.NetTx,
+				BlockReadBytes:  st.BlockRead,
+				BlockWriteBytes: st.BlockWrite,
 			}
-			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n",
-				git.ShortSHA(d.GitSHA),
-				d.Status,
-				ref,
-				d.StartedAt.Format("2006-01-02 15:04:05"))
 		}
-		w.Flush()
+		entry.Services = append(entry.Services, svc)
+	}
+	return entry
+}
+
+// writeProjectStatus writes the detailed status of a project as a
+// ProjectStatus document.
+func writeProjectStatus(ctx context.Context, store *state.Store, project *state.Project) error {
+	result := projectStatusOutput{
+		Project:           newProjectEntry(project),
+		Hosts:             []string{},
+		ActiveDeployments: []activeDeploymentEntry{},
 	}
 
-	return nil
+	hosts, err := store.ListProjectHosts(ctx, project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get hosts: %w", err)
+	}
+	for _, h := range hosts {
+		result.Hosts = append(result.Hosts, h.Name)
+	}
+
+	deployments, err := store.ListActiveDeployments(ctx, project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment: %w", err)
+	}
+	for _, deployment := range deployments {
+		result.ActiveDeployments = append(result.ActiveDeployments, activeDeploymentStatus(ctx, store, project.Name, deployment, statusServicesFlag))
+	}
+
+	recent, err := store.ListDeployments(ctx, project.ID, 5)
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	result.RecentDeployments = historyEntries(recent)
+
+	return writeOutput(os.Stdout, kindProjectStatus, result)
 }
@@ -10,8 +10,11 @@ import (
 
 	"github.com/jayteealao/otterstack/internal/compose"
 	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/metrics"
 	"github.com/jayteealao/otterstack/internal/notify"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/tui"
 	"github.com/spf13/cobra"
 )
 
@@ -22,6 +25,19 @@ var watchCmd = &cobra.Command{
 
 If no project is specified, all projects are monitored.
 
+Deploy schedules (see "otterstack schedule") run in the same process, so
+projects following a branch or tag are deployed while watch is running.
+Use --no-schedule to only monitor.
+
+With --metrics-addr, Prometheus metrics are served on /metrics: deployment
+counts and durations, time since the last successful deployment, the active
+commit per host, service up and healthy gauges from the health checks,
+notification failures and deploy queue wait times.
+
+Resource usage is sampled on every check. A container whose memory stays at
+or above --memory-threshold percent of its limit for --sustain consecutive
+checks, or whose restart count increases, raises an alert.
+
 Notifications can be sent via:
   - Webhook: --webhook-url <url>
   - Discord: --discord-webhook <url>
@@ -32,7 +48,10 @@ Examples:
   otterstack watch myapp                        # Watch specific project
   otterstack watch --interval 10s               # Check every 10 seconds
   otterstack watch --webhook-url http://...     # Send webhook notifications
-  otterstack watch --discord-webhook https://...# Send Discord notifications`,
+  otterstack watch --discord-webhook https://...# Send Discord notifications
+  otterstack watch --no-schedule                # Monitor without auto-deploying
+  otterstack watch --memory-threshold 80        # Alert on memory above 80% of the limit
+  otterstack watch --metrics-addr :9469         # Serve Prometheus metrics`,
 	RunE: runWatch,
 }
 
@@ -42,6 +61,10 @@ var (
 	watchDiscordFlag       string
 	watchSlackFlag         string
 	watchSlackChannelFlag  string
+	watchNoScheduleFlag    bool
+	watchMemThresholdFlag  float64
+	watchSustainFlag       int
+	watchMetricsAddrFlag   string
 )
 
 func init() {
@@ -52,12 +75,22 @@ func init() {
 	watchCmd.Flags().StringVar(&watchDiscordFlag, "discord-webhook", "", "Discord webhook URL")
 	watchCmd.Flags().StringVar(&watchSlackFlag, "slack-webhook", "", "Slack webhook URL")
 	watchCmd.Flags().StringVar(&watchSlackChannelFlag, "slack-channel", "", "Slack channel (optional)")
+	watchCmd.Flags().BoolVar(&watchNoScheduleFlag, "no-schedule", false, "do not run deploy schedules")
+	watchCmd.Flags().Float64Var(&watchMemThresholdFlag, "memory-threshold", 90, "alert when memory use stays at or above this percent of the limit (0 disables)")
+	watchCmd.Flags().IntVar(&watchSustainFlag, "sustain", 3, "consecutive checks above a threshold before alerting")
+	watchCmd.Flags().StringVar(&watchMetricsAddrFlag, "metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9469")
 }
 
 // ServiceState tracks the state of a service for change detection.
 type ServiceState struct {
-	Status string
-	Health string
+	Service string // Compose service the container belongs to
+	Status  string
+	Health  string
+
+	// Resource usage, known once stats were collected for the container
+	HasUsage     bool
+	RestartCount int
+	HighMemory   int // Consecutive checks at or above the memory threshold
 }
 
 // ProjectState tracks the state of a project's services.
@@ -111,6 +144,12 @@ func runWatch(cmd *cobra.Command, args []string) error {
 		projectFilter = args[0]
 	}
 
+	if watchMetricsAddrFlag != "" {
+		if err := serveMetrics(ctx, store, watchMetricsAddrFlag); err != nil {
+			return err
+		}
+	}
+
 	// Track previous state for change detection
 	previousState := make(map[string]*ProjectState)
 
@@ -120,6 +159,33 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	} else {
 		fmt.Println("No notification backends configured (use --webhook-url, --discord-webhook, or --slack-webhook)")
 	}
+
+	// Run deploy schedules alongside the health checks. On shutdown, wait for
+	// a scheduled deployment to wind down before the store is closed.
+	schedulerDone := make(chan struct{})
+	if watchNoScheduleFlag {
+		close(schedulerDone)
+	} else {
+		schedules, err := store.ListSchedules(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list schedules: %w", err)
+		}
+		dataDir, err := getDataDir()
+		if err != nil {
+			return err
+		}
+		if len(schedules) > 0 {
+			fmt.Printf("Deploy schedules: %d (see otterstack schedule list)\n", len(schedules))
+		}
+		go func() {
+			defer close(schedulerDone)
+			runScheduler(ctx, store, notifyMgr, dataDir, projectFilter)
+		}()
+	}
+	defer func() {
+		cancel()
+		<-schedulerDone
+	}()
 	fmt.Println("Press Ctrl+C to stop")
 	fmt.Println()
 
@@ -157,83 +223,190 @@ func checkHealth(ctx context.Context, store *state.Store, notifyMgr *notify.Mana
 			continue
 		}
 
-		// Get active deployment
-		deployment, err := store.GetActiveDeployment(ctx, project.ID)
-		if err != nil {
-			continue // No active deployment
-		}
-
-		// Get service status
-		projectName := compose.GenerateProjectName(project.Name, git.ShortSHA(deployment.GitSHA))
-		services, err := compose.GetProjectStatus(ctx, projectName)
+		// Get the active deployment on each host
+		deployments, err := store.ListActiveDeployments(ctx, project.ID)
 		if err != nil {
-			printVerbose("[%s] %s: failed to get status: %v", timestamp, project.Name, err)
+			printVerbose("[%s] %s: failed to get active deployments: %v", timestamp, project.Name, err)
 			continue
 		}
 
-		// Initialize previous state if needed
-		if previousState[project.Name] == nil {
-			previousState[project.Name] = &ProjectState{
-				ProjectName: project.Name,
-				Services:    make(map[string]ServiceState),
-			}
+		for _, deployment := range deployments {
+			checkDeploymentHealth(ctx, store, notifyMgr, project, deployment, timestamp, previousState)
 		}
-		prevState := previousState[project.Name]
+	}
 
-		// Check each service for changes
-		for _, svc := range services {
-			prev, exists := prevState.Services[svc.Name]
-			current := ServiceState{Status: svc.Status, Health: svc.Health}
-
-			// Detect status changes
-			if exists && (prev.Status != current.Status || prev.Health != current.Health) {
-				event := detectEvent(project.Name, svc.Name, prev, current)
-				if event != nil {
-					// Log the change
-					fmt.Printf("[%s] %s/%s: %s -> %s",
-						timestamp, project.Name, svc.Name, prev.Status, current.Status)
-					if current.Health != "" {
-						fmt.Printf(" (health: %s)", current.Health)
-					}
-					fmt.Println()
-
-					// Send notification
-					if notifyMgr.Count() > 0 {
-						if err := notifyMgr.Notify(ctx, *event); err != nil {
-							printVerbose("Notification error: %v", err)
-						}
-					}
+	return nil
+}
+
+// checkDeploymentHealth compares the services of one active deployment with
+// the previous check and reports changes. Deployments on remote hosts are
+// tracked as <project>@<host>.
+func checkDeploymentHealth(ctx context.Context, store *state.Store, notifyMgr *notify.Manager, project *state.Project, deployment *state.Deployment, timestamp string, previousState map[string]*ProjectState) {
+	label := project.Name
+	if deployment.Host != "" {
+		label += "@" + deployment.Host
+	}
+
+	host, err := state.DeploymentTarget(ctx, store, deployment)
+	if err != nil {
+		printVerbose("[%s] %s: %v", timestamp, label, err)
+		return
+	}
+
+	// Get service status
+	projectName := compose.GenerateProjectName(project.Name, git.ShortSHA(deployment.GitSHA))
+	services, err := compose.GetProjectStatus(target.With(ctx, host), projectName)
+	if err != nil {
+		printVerbose("[%s] %s: failed to get status: %v", timestamp, label, err)
+		return
+	}
+
+	// Resource usage is best effort; status changes are reported without it
+	stats, err := compose.GetProjectStats(target.With(ctx, host), projectName)
+	if err != nil {
+		printVerbose("[%s] %s: failed to get resource usage: %v", timestamp, label, err)
+	}
+	usage := make(map[string]*compose.ContainerStats, len(stats))
+	for i := range stats {
+		usage[stats[i].Name] = &stats[i]
+	}
+
+	// Initialize previous state if needed
+	if previousState[label] == nil {
+		previousState[label] = &ProjectState{
+			ProjectName: project.Name,
+			Services:    make(map[string]ServiceState),
+		}
+	}
+	prevState := previousState[label]
+
+	// Check each service for changes
+	for _, svc := range services {
+		prev, exists := prevState.Services[svc.Name]
+		current := ServiceState{Service: svc.Service, Status: svc.Status, Health: svc.Health}
+		setServiceMetrics(project.Name, host.String(), svc.Name, current)
+		usageEvents := detectUsageEvents(project.Name, svc.Name, prev, &current, usage[svc.Name], watchMemThresholdFlag, watchSustainFlag)
+
+		// Detect status changes
+		if exists && (prev.Status != current.Status || prev.Health != current.Health) {
+			event := detectEvent(project.Name, svc.Name, prev, current)
+			if event != nil {
+				if deployment.Host != "" {
+					event.Details = map[string]string{"host": deployment.Host}
 				}
-			} else if !exists {
-				// First time seeing this service
-				fmt.Printf("[%s] %s/%s: %s", timestamp, project.Name, svc.Name, current.Status)
+
+				// Log the change
+				fmt.Printf("[%s] %s/%s: %s -> %s",
+					timestamp, label, svc.Name, prev.Status, current.Status)
 				if current.Health != "" {
 					fmt.Printf(" (health: %s)", current.Health)
 				}
 				fmt.Println()
-			}
 
-			// Update state
-			prevState.Services[svc.Name] = current
+				sendWatchEvent(ctx, notifyMgr, event)
+			}
+		} else if !exists {
+			// First time seeing this service
+			fmt.Printf("[%s] %s/%s: %s", timestamp, label, svc.Name, current.Status)
+			if current.Health != "" {
+				fmt.Printf(" (health: %s)", current.Health)
+			}
+			fmt.Println()
 		}
 
-		// Check for removed services
-		for name := range prevState.Services {
-			found := false
-			for _, svc := range services {
-				if svc.Name == name {
-					found = true
-					break
-				}
+		for _, event := range usageEvents {
+			if deployment.Host != "" {
+				event.Details = map[string]string{"host": deployment.Host}
 			}
-			if !found {
-				fmt.Printf("[%s] %s/%s: service removed\n", timestamp, project.Name, name)
-				delete(prevState.Services, name)
+			fmt.Printf("[%s] %s/%s: %s\n", timestamp, label, svc.Name, event.Message)
+			sendWatchEvent(ctx, notifyMgr, event)
+		}
+
+		// Update state
+		prevState.Services[svc.Name] = current
+	}
+
+	// Check for removed services
+	for name := range prevState.Services {
+		found := false
+		for _, svc := range services {
+			if svc.Name == name {
+				found = true
+				break
 			}
 		}
+		if !found {
+			fmt.Printf("[%s] %s/%s: service removed\n", timestamp, label, name)
+			service := prevState.Services[name].Service
+			metrics.ServiceUp.DeleteLabelValues(project.Name, host.String(), service, name)
+			metrics.ServiceHealthy.DeleteLabelValues(project.Name, host.String(), service, name)
+			delete(prevState.Services, name)
+		}
 	}
+}
 
-	return nil
+// setServiceMetrics updates the up and healthy gauges of a container.
+// Containers without a health check count as healthy while running.
+func setServiceMetrics(project, host, container string, s ServiceState) {
+	up, healthy := 0.0, 0.0
+	if compose.IsServiceRunning(s.Status) {
+		up = 1
+		if s.Health == "" || s.Health == "healthy" {
+			healthy = 1
+		}
+	}
+	metrics.ServiceUp.WithLabelValues(project, host, s.Service, container).Set(up)
+	metrics.ServiceHealthy.WithLabelValues(project, host, s.Service, container).Set(healthy)
+}
+
+// sendWatchEvent sends an event to the configured notification backends.
+func sendWatchEvent(ctx context.Context, notifyMgr *notify.Manager, event *notify.Event) {
+	if notifyMgr.Count() == 0 {
+		return
+	}
+	if err := notifyMgr.Notify(ctx, *event); err != nil {
+		printVerbose("Notification error: %v", err)
+	}
+}
+
+// detectUsageEvents records the resource usage of a container in current and
+// returns alerts for a restart count increase and for memory use that stays
+// at or above memThreshold percent for sustain checks. Memory alerts are
+// raised once until usage drops below the threshold. Without stats, usage
+// carries over from prev.
+func detectUsageEvents(projectName, serviceName string, prev ServiceState, current *ServiceState, stats *compose.ContainerStats, memThreshold float64, sustain int) []*notify.Event {
+	if stats == nil {
+		current.HasUsage = prev.HasUsage
+		current.RestartCount = prev.RestartCount
+		return nil
+	}
+	current.HasUsage = true
+	current.RestartCount = stats.RestartCount
+
+	var events []*notify.Event
+	if prev.HasUsage && stats.RestartCount > prev.RestartCount {
+		events = append(events, &notify.Event{
+			Type:    notify.EventServiceRestarted,
+			Project: projectName,
+			Service: serviceName,
+			Status:  current.Status,
+			Message: fmt.Sprintf("restart count %d -> %d", prev.RestartCount, stats.RestartCount),
+		})
+	}
+
+	if memThreshold > 0 && stats.MemPercent >= memThreshold {
+		current.HighMemory = prev.HighMemory + 1
+		if current.HighMemory == max(sustain, 1) {
+			events = append(events, &notify.Event{
+				Type:    notify.EventServiceHighMemory,
+				Project: projectName,
+				Service: serviceName,
+				Status:  current.Status,
+				Message: fmt.Sprintf("memory at %.1f%% of %s for %d checks", stats.MemPercent, tui.FormatBytes(stats.MemLimit), current.HighMemory),
+			})
+		}
+	}
+	return events
 }
 
 func detectEvent(projectName, serviceName string, prev, current ServiceState) *notify.Event {
@@ -0,0 +1,333 @@
+// Package backup reads and writes OtterStack backup archives.
+//
+// An archive is a zstd-compressed tar file. Its first entry, manifest.json,
+// describes what was backed up and lists every other entry with its size and
+// SHA-256, so a damaged archive is detected before anything is restored:
+//
+//	manifest.json
+//	otterstack.db              # online backup of the state database
+//	envfiles/<project>.env     # env files written for deployments
+//	volumes/<volume>.tar       # contents of named volumes, when requested
+//
+// Compression uses the zstd command line tool, which must be installed.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/volume"
+)
+
+// FormatVersion is the archive layout written by this version of OtterStack.
+const FormatVersion = 1
+
+// ManifestName is the name of the manifest entry.
+const ManifestName = "manifest.json"
+
+// Entry kinds.
+const (
+	KindDatabase = "database"
+	KindEnvFile  = "envfile"
+	KindVolume   = "volume"
+)
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	FormatVersion     int       `json:"format_version"`
+	CreatedAt         time.Time `json:"created_at"`
+	OtterStackVersion string    `json:"otterstack_version"`
+	SchemaVersion     int       `json:"schema_version"`
+	Project           string    `json:"project,omitempty"` // empty when every project was backed up
+	Entries           []Entry   `json:"entries"`
+}
+
+// Entry is a file in the archive.
+type Entry struct {
+	Path    string         `json:"path"` // slash-separated, relative to the archive root
+	Kind    string         `json:"kind"`
+	Project string         `json:"project,omitempty"`
+	Volume  *volume.Volume `json:"volume,omitempty"`
+	Size    int64          `json:"size"`
+	SHA256  string         `json:"sha256"`
+}
+
+// EntriesOf returns the entries of a kind.
+func (m *Manifest) EntriesOf(kind string) []Entry {
+	var entries []Entry
+	for _, e := range m.Entries {
+		if e.Kind == kind {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// checkZstd reports a clear error when the zstd tool is missing.
+func checkZstd() error {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		return fmt.Errorf("zstd command not found: install zstd to create or read backups")
+	}
+	return nil
+}
+
+// Write archives the files in dir listed by m.Entries to dest. Sizes and
+// checksums of the entries are filled in before the manifest is written.
+// The archive is written to a temporary file and renamed into place.
+func Write(ctx context.Context, dest string, m *Manifest, dir string) error {
+	if err := checkZstd(); err != nil {
+		return err
+	}
+
+	for i := range m.Entries {
+		e := &m.Entries[i]
+		if err := validEntryPath(e.Path); err != nil {
+			return err
+		}
+		size, sum, err := fileChecksum(filepath.Join(dir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return err
+		}
+		e.Size, e.SHA256 = size, sum
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	tmp := dest + ".partial"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	zstd := exec.CommandContext(ctx, "zstd", "-q", "-c", "-T0")
+	var stderr bytes.Buffer
+	zstd.Stdout = out
+	zstd.Stderr = &stderr
+	stdin, err := zstd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to start zstd: %w", err)
+	}
+	if err := zstd.Start(); err != nil {
+		return fmt.Errorf("failed to start zstd: %w", err)
+	}
+
+	writeErr := writeTar(stdin, manifest, m.Entries, dir)
+	stdin.Close()
+	if err := zstd.Wait(); err != nil {
+		return fmt.Errorf("zstd failed: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return fmt.Errorf("failed to move archive into place: %w", err)
+	}
+	return nil
+}
+
+func writeTar(w io.Writer, manifest []byte, entries []Entry, dir string) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    ManifestName,
+		Mode:    0600,
+		Size:    int64(len(manifest)),
+		ModTime: now,
+	}); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    e.Path,
+			Mode:    0600,
+			Size:    e.Size,
+			ModTime: now,
+		}); err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.Path, err)
+		}
+
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(e.Path)))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		_, err = io.CopyN(tw, f, e.Size)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+// Extract unpacks an archive into dir, which should be empty, and returns
+// its manifest after checking every entry against it.
+func Extract(ctx context.Context, archive, dir string) (*Manifest, error) {
+	if err := checkZstd(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	zstd := exec.CommandContext(ctx, "zstd", "-q", "-d", "-c", archive)
+	var stderr bytes.Buffer
+	zstd.Stderr = &stderr
+	stdout, err := zstd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+	if err := zstd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start zstd: %w", err)
+	}
+
+	m, readErr := readTar(stdout, dir)
+	if readErr != nil {
+		// Stop zstd rather than draining the rest of a bad archive
+		cancel()
+	} else {
+		// Drain the tar padding so zstd is not left blocked writing it
+		io.Copy(io.Discard, stdout)
+	}
+	waitErr := zstd.Wait()
+	if readErr != nil {
+		return nil, readErr
+	}
+	if waitErr != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w\n%s", archive, waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return m, nil
+}
+
+func readTar(r io.Reader, dir string) (*Manifest, error) {
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	if hdr.Name != ManifestName {
+		return nil, fmt.Errorf("not a backup archive: first entry is %q, expected %s", hdr.Name, ManifestName)
+	}
+
+	var m Manifest
+	if err := json.NewDecoder(tr).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("archive format %d is newer than this otterstack supports (%d); upgrade otterstack", m.FormatVersion, FormatVersion)
+	}
+
+	expected := make(map[string]Entry, len(m.Entries))
+	for _, e := range m.Entries {
+		if err := validEntryPath(e.Path); err != nil {
+			return nil, err
+		}
+		expected[e.Path] = e
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		e, ok := expected[hdr.Name]
+		if !ok || hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+		delete(expected, hdr.Name)
+
+		if err := extractEntry(tr, e, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	for p := range expected {
+		return nil, fmt.Errorf("archive is missing %s", p)
+	}
+	return &m, nil
+}
+
+// extractEntry writes one entry below dir and checks it against the manifest.
+func extractEntry(r io.Reader, e Entry, dir string) error {
+	dest := filepath.Join(dir, filepath.FromSlash(e.Path))
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", e.Path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", e.Path, err)
+	}
+	if n != e.Size || hex.EncodeToString(h.Sum(nil)) != e.SHA256 {
+		return fmt.Errorf("%s does not match its checksum: archive is corrupt", e.Path)
+	}
+	return f.Close()
+}
+
+// validEntryPath rejects paths that would escape the extraction directory.
+func validEntryPath(p string) error {
+	if p == "" || p == ManifestName || path.IsAbs(p) || path.Clean(p) != p || strings.HasPrefix(p, "..") || strings.Contains(p, "\\") {
+		return fmt.Errorf("invalid archive entry path %q", p)
+	}
+	return nil
+}
+
+// fileChecksum returns the size and hex SHA-256 of a file.
+func fileChecksum(name string) (int64, string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
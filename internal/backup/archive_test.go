@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/volume"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireZstd(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not installed")
+	}
+}
+
+// writeTestArchive stages a database, env file and volume and archives them.
+func writeTestArchive(t *testing.T) (string, *Manifest) {
+	t.Helper()
+
+	staging := t.TempDir()
+	files := map[string]string{
+		"otterstack.db":       "database contents",
+		"envfiles/myapp.env":  "SECRET=value\n",
+		"volumes/myapp_a.tar": "volume contents",
+	}
+	for name, content := range files {
+		path := filepath.Join(staging, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	m := &Manifest{
+		FormatVersion:     FormatVersion,
+		CreatedAt:         time.Now().UTC().Truncate(time.Second),
+		OtterStackVersion: "test",
+		SchemaVersion:     6,
+		Project:           "myapp",
+		Entries: []Entry{
+			{Path: "otterstack.db", Kind: KindDatabase},
+			{Path: "envfiles/myapp.env", Kind: KindEnvFile, Project: "myapp"},
+			{Path: "volumes/myapp_a.tar", Kind: KindVolume, Project: "myapp",
+				Volume: &volume.Volume{Name: "myapp_a", ComposeProject: "myapp-abc1234", ComposeVolume: "a"}},
+		},
+	}
+
+	dest := filepath.Join(t.TempDir(), "backup.tar.zst")
+	require.NoError(t, Write(context.Background(), dest, m, staging))
+	return dest, m
+}
+
+func TestWriteExtract(t *testing.T) {
+	requireZstd(t)
+	ctx := context.Background()
+
+	t.Run("round trip", func(t *testing.T) {
+		archive, written := writeTestArchive(t)
+
+		info, err := os.Stat(archive)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		_, err = os.Stat(archive + ".partial")
+		assert.True(t, os.IsNotExist(err))
+
+		dir := t.TempDir()
+		m, err := Extract(ctx, archive, dir)
+		require.NoError(t, err)
+
+		assert.Equal(t, written.CreatedAt, m.CreatedAt)
+		assert.Equal(t, "myapp", m.Project)
+		assert.Equal(t, 6, m.SchemaVersion)
+		require.Len(t, m.EntriesOf(KindVolume), 1)
+		assert.Equal(t, "myapp-abc1234", m.EntriesOf(KindVolume)[0].Volume.ComposeProject)
+		assert.Equal(t, int64(len("SECRET=value\n")), m.EntriesOf(KindEnvFile)[0].Size)
+
+		data, err := os.ReadFile(filepath.Join(dir, "envfiles", "myapp.env"))
+		require.NoError(t, err)
+		assert.Equal(t, "SECRET=value\n", string(data))
+	})
+
+	t.Run("corrupt archive", func(t *testing.T) {
+		archive, _ := writeTestArchive(t)
+		require.NoError(t, os.WriteFile(archive, []byte("not zstd"), 0600))
+
+		_, err := Extract(ctx, archive, t.TempDir())
+		assert.Error(t, err)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		staging := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(staging, "otterstack.db"), []byte("database"), 0600))
+		manifest := []byte(`{"format_version":1,"entries":[{"path":"otterstack.db","kind":"database","size":8,"sha256":"00"}]}`)
+		pr, pw, err := os.Pipe()
+		require.NoError(t, err)
+		go func() {
+			writeTar(pw, manifest, []Entry{{Path: "otterstack.db", Size: 8}}, staging)
+			pw.Close()
+		}()
+		_, err = readTar(pr, t.TempDir())
+		assert.ErrorContains(t, err, "does not match its checksum")
+	})
+
+	t.Run("newer format", func(t *testing.T) {
+		pr, pw, err := os.Pipe()
+		require.NoError(t, err)
+		go func() {
+			writeTar(pw, []byte(`{"format_version":99,"entries":[]}`), nil, "")
+			pw.Close()
+		}()
+		_, err = readTar(pr, t.TempDir())
+		assert.ErrorContains(t, err, "upgrade otterstack")
+	})
+}
+
+func TestValidEntryPath(t *testing.T) {
+	for _, p := range []string{"otterstack.db", "envfiles/myapp.env", "volumes/myapp_data.tar"} {
+		assert.NoError(t, validEntryPath(p), p)
+	}
+	for _, p := range []string{"", ManifestName, "/etc/passwd", "../escape", "envfiles/../../escape", "a//b", `envfiles\x.env`} {
+		assert.Error(t, validEntryPath(p), p)
+	}
+}
@@ -0,0 +1,136 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/target"
+	"gopkg.in/yaml.v3"
+)
+
+// BuildOverrideFile is the override written into a worktree to pin images
+// built from source to the commit being deployed.
+const BuildOverrideFile = "docker-compose.otterstack-build.yml"
+
+// BuildServices returns the sorted names of services that declare a build section.
+func BuildServices(composePath string) ([]string, error) {
+	data, err := os.ReadFile(composePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	var doc struct {
+		Services map[string]map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+
+	var services []string
+	for name, service := range doc.Services {
+		if _, ok := service["build"]; ok {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// BuildImageTag returns the image reference for a service built at a commit.
+// The tag embeds the SHA so each commit keeps its own image for rollback.
+func BuildImageTag(projectName, service, shortSHA string) string {
+	return fmt.Sprintf("otterstack/%s-%s:%s", projectName, strings.ToLower(service), shortSHA)
+}
+
+// WriteBuildOverride writes BuildOverrideFile into dir, setting the image of
+// each built service. Returns the path of the override file.
+func WriteBuildOverride(dir string, images map[string]string) (string, error) {
+	services := make(map[string]map[string]string, len(images))
+	for service, image := range images {
+		services[service] = map[string]string{"image": image}
+	}
+
+	data, err := yaml.Marshal(map[string]interface{}{"services": services})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode build override: %w", err)
+	}
+
+	content := "# Generated by OtterStack to pin images built from source to this commit\n" + string(data)
+	overridePath := filepath.Join(dir, BuildOverrideFile)
+	if err := os.WriteFile(overridePath, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write build override: %w", err)
+	}
+	return overridePath, nil
+}
+
+// WithBuildOverride appends BuildOverrideFile to composeFile when the override
+// exists in dir, so compose uses the images built for that worktree's commit.
+func WithBuildOverride(dir, composeFile string) string {
+	if _, err := os.Stat(filepath.Join(dir, BuildOverrideFile)); err == nil {
+		return composeFile + "," + BuildOverrideFile
+	}
+	return composeFile
+}
+
+// Build builds images for services with a build section using BuildKit.
+// Docker output streams in real-time to configured output streams (see SetOutputStreams).
+//
+// Parameters:
+//   - ctx: Context for timeout and cancellation
+//   - envFilePath: Optional path to .env file for variable substitution (empty string to skip)
+//   - buildArgs: Values passed as --build-arg; they are handed over through the
+//     environment so they do not show up in the process list
+//
+// Returns:
+//   - errors.ErrComposeTimeout if context deadline exceeded
+//   - context.Canceled if context cancelled
+//   - error if the build fails
+func (m *Manager) Build(ctx context.Context, envFilePath string, buildArgs map[string]string) (err error) {
+	ctx, done := m.operation(ctx, "build")
+	defer done(&err)
+
+	args := m.baseArgs()
+	args = append(args, m.envFileArgs(envFilePath)...)
+	args = append(args, "build")
+
+	env := append(target.Environ(ctx), "DOCKER_BUILDKIT=1", "COMPOSE_DOCKER_CLI_BUILD=1")
+	keys := make([]string, 0, len(buildArgs))
+	for k := range buildArgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// --build-arg KEY without a value takes it from the environment
+		args = append(args, "--build-arg", k)
+		env = append(env, k+"="+buildArgs[k])
+	}
+
+	cmd := target.Docker(ctx, args...)
+	cmd.Dir = m.workingDir
+	cmd.Env = env
+	cmd.Stdout = m.getStdout()
+	cmd.Stderr = m.getStderr()
+
+	err = cmd.Run()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w", errors.ErrComposeTimeout)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("compose build cancelled: %w", ctx.Err())
+		}
+		return fmt.Errorf("compose build failed: %w", err)
+	}
+	return nil
+}
+
+// ImageExists reports whether an image is present in the image store of the engine targeted by ctx.
+func ImageExists(ctx context.Context, image string) bool {
+	cmd := target.Docker(ctx, "image", "inspect", "--format", "{{.Id}}", image)
+	return cmd.Run() == nil
+}
@@ -0,0 +1,66 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildServices(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	require.NoError(t, os.WriteFile(composePath, []byte(`
+services:
+  worker:
+    build:
+      context: ./worker
+  web:
+    build: .
+    image: registry.example.com/web:latest
+  db:
+    image: postgres:16
+`), 0644))
+
+	services, err := BuildServices(composePath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web", "worker"}, services)
+
+	_, err = BuildServices(filepath.Join(dir, "missing.yml"))
+	assert.Error(t, err)
+}
+
+func TestBuildImageTag(t *testing.T) {
+	assert.Equal(t, "otterstack/myapp-web:abc1234", BuildImageTag("myapp", "web", "abc1234"))
+	assert.Equal(t, "otterstack/myapp-api_v2:abc1234", BuildImageTag("myapp", "API_v2", "abc1234"))
+}
+
+func TestWriteBuildOverride(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.Equal(t, "compose.yaml", WithBuildOverride(dir, "compose.yaml"), "no override written yet")
+
+	path, err := WriteBuildOverride(dir, map[string]string{
+		"web":    "otterstack/myapp-web:abc1234",
+		"worker": "otterstack/myapp-worker:abc1234",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, BuildOverrideFile), path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var doc struct {
+		Services map[string]struct {
+			Image string `yaml:"image"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &doc))
+	assert.Equal(t, "otterstack/myapp-web:abc1234", doc.Services["web"].Image)
+	assert.Equal(t, "otterstack/myapp-worker:abc1234", doc.Services["worker"].Image)
+
+	assert.Equal(t, "compose.yaml,"+BuildOverrideFile, WithBuildOverride(dir, "compose.yaml"))
+}
@@ -2,9 +2,10 @@
 package compose
 
 import (
+	"bufio"
 	"fmt"
 	"os"
-	"regexp"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -13,31 +14,65 @@ import (
 
 // EnvVarReference represents an environment variable reference found in a compose file.
 type EnvVarReference struct {
-	Name         string   // Variable name (e.g., "DATABASE_URL")
-	HasDefault   bool     // Has default value (e.g., ${VAR:-default} or ${VAR-default})
-	DefaultValue string   // The default value if HasDefault is true
-	IsRequired   bool     // Explicitly marked required (e.g., ${VAR:?error} or ${VAR?error})
-	ErrorMessage string   // Custom error message if IsRequired is true
-	Locations    []string // Where in compose file this var appears (service names)
+	Name         string        // Variable name (e.g., "DATABASE_URL")
+	HasDefault   bool          // Has default value (e.g., ${VAR:-default} or ${VAR-default})
+	DefaultValue string        // The default value if HasDefault is true
+	IsRequired   bool          // Explicitly marked required (e.g., ${VAR:?error} or ${VAR?error})
+	ErrorMessage string        // Custom error message if IsRequired is true
+	Locations    []string      // Where in compose file this var appears (service names)
+	Usages       []EnvVarUsage // Per-service breakdown of how the variable is referenced
+	InDotEnv     bool          // A value is provided by the .env file next to the compose file
 }
 
-// envVarPattern matches all forms of environment variable references:
-// - ${VAR} or $VAR - basic substitution
-// - ${VAR:-default} - default if unset or empty
-// - ${VAR-default} - default if unset (not if empty)
-// - ${VAR:?error} - error if unset or empty
-// - ${VAR?error} - error if unset (not if empty)
-var envVarPattern = regexp.MustCompile(
-	// Group 1: ${VAR...} form - variable name
-	// Group 2: operator (:-|:?|?|-)
-	// Group 3: value after operator
-	`\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:-|:\?|\?|-)([^}]*))?\}` +
-		// OR Group 4: $VAR form
-		`|\$([A-Za-z_][A-Za-z0-9_]*)`,
-)
+// EnvVarUsage describes how a single service (or top-level section) references a variable.
+type EnvVarUsage struct {
+	Service    string // Service name, empty for top-level sections such as networks or volumes
+	HasDefault bool   // Every reference here resolves without the variable being set
+	IsRequired bool   // At least one reference here uses ${VAR:?error} or ${VAR?error}
+}
+
+// NeedsValue reports whether a value must be supplied for the compose file to
+// interpolate as intended. A variable needs a value when it is explicitly
+// required, or when some service references it without a fallback, unless the
+// project's .env file already provides it.
+func (r EnvVarReference) NeedsValue() bool {
+	if r.InDotEnv {
+		return false
+	}
+	if r.IsRequired {
+		return true
+	}
+	if len(r.Usages) == 0 {
+		return !r.HasDefault
+	}
+	for _, u := range r.Usages {
+		if u.IsRequired || !u.HasDefault {
+			return true
+		}
+	}
+	return false
+}
+
+// ServicesNeedingValue returns the services that reference the variable
+// without a fallback, or that explicitly require it.
+func (r EnvVarReference) ServicesNeedingValue() []string {
+	var services []string
+	for _, u := range r.Usages {
+		if u.Service != "" && (u.IsRequired || !u.HasDefault) {
+			services = append(services, u.Service)
+		}
+	}
+	return services
+}
 
 // ParseEnvVars extracts all environment variable references from a compose file.
 // It returns a deduplicated list of variables with metadata about defaults and requirements.
+//
+// The compose file is walked as a YAML tree and every scalar value is parsed with
+// the compose interpolation grammar, so comments, mapping keys and $$ escapes are
+// never reported. Pass-through entries in a service's environment block (e.g. "- FOO")
+// are reported unless one of the service's env_file entries defines them, and
+// variables defined in the .env file next to the compose file are marked InDotEnv.
 func ParseEnvVars(composeFilePath string) ([]EnvVarReference, error) {
 	// Read compose file
 	data, err := os.ReadFile(composeFilePath)
@@ -45,172 +80,406 @@ func ParseEnvVars(composeFilePath string) ([]EnvVarReference, error) {
 		return nil, fmt.Errorf("failed to read compose file: %w", err)
 	}
 
-	// Parse YAML to get service structure for better location tracking
-	var composeData map[string]interface{}
-	if err := yaml.Unmarshal(data, &composeData); err != nil {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return nil, fmt.Errorf("failed to parse compose YAML: %w", err)
 	}
 
-	// Extract service names for location tracking
-	serviceNames := extractServiceNames(composeData)
+	projectDir := filepath.Dir(composeFilePath)
+	c := newEnvVarCollector(projectDir)
+	if err := c.walkRoot(&root); err != nil {
+		return nil, err
+	}
+
+	// Variables provided by the project's .env file need no further input
+	dotEnv, err := readDotEnv(filepath.Join(projectDir, ".env"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read .env file: %w", err)
+	}
+	for name := range dotEnv {
+		if ref, ok := c.vars[name]; ok {
+			ref.InDotEnv = true
+		}
+	}
+
+	return c.result(), nil
+}
 
-	// Find all variable references in the raw content with positions
-	content := string(data)
-	matchesWithIndex := envVarPattern.FindAllStringSubmatchIndex(content, -1)
-	matches := envVarPattern.FindAllStringSubmatch(content, -1)
+// GetMissingVars compares required variables against stored variables
+// and returns a list of variables that are missing or empty.
+// Variables provided by the project's .env file are never reported as missing.
+func GetMissingVars(required []EnvVarReference, stored map[string]string) []EnvVarReference {
+	var missing []EnvVarReference
 
-	// Use map to deduplicate variables
-	// Key: variable name, Value: EnvVarReference with aggregated metadata
-	vars := make(map[string]*EnvVarReference)
+	for _, req := range required {
+		if req.InDotEnv {
+			continue
+		}
 
-	for i, match := range matches {
-		var name, operator, value string
+		value, exists := stored[req.Name]
 
-		// Check which form matched
-		if match[1] != "" {
-			// ${VAR...} form
-			name = match[1]
-			operator = match[2]
-			value = match[3]
-		} else if match[4] != "" {
-			// $VAR form (no operator, no value)
-			name = match[4]
-		} else {
-			continue // Shouldn't happen with our regex, but be safe
+		// Consider variable missing if:
+		// 1. Not in stored map, OR
+		// 2. Empty string in stored map
+		if !exists || value == "" {
+			missing = append(missing, req)
+		}
+	}
+
+	return missing
+}
+
+// envVarCollector accumulates variable references while walking a compose file.
+type envVarCollector struct {
+	projectDir string
+	vars       map[string]*EnvVarReference
+	usages     map[string]map[string]*EnvVarUsage // variable name -> service -> usage
+}
+
+func newEnvVarCollector(projectDir string) *envVarCollector {
+	return &envVarCollector{
+		projectDir: projectDir,
+		vars:       make(map[string]*EnvVarReference),
+		usages:     make(map[string]map[string]*EnvVarUsage),
+	}
+}
+
+// walkRoot walks the top-level compose document, attributing references
+// under services.<name> to that service.
+func (c *envVarCollector) walkRoot(root *yaml.Node) error {
+	doc := root
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil
 		}
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return c.walk(doc, "")
+	}
 
-		// Get or create reference
-		ref, exists := vars[name]
-		if !exists {
-			ref = &EnvVarReference{
-				Name:      name,
-				Locations: []string{},
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], resolveAlias(doc.Content[i+1])
+		if key.Value != "services" || value.Kind != yaml.MappingNode {
+			if err := c.walk(value, ""); err != nil {
+				return err
 			}
-			vars[name] = ref
+			continue
 		}
 
-		// Parse operator to determine type
-		switch operator {
-		case ":-", "-":
-			// Default value forms
-			ref.HasDefault = true
-			// Use the longest/most specific default value seen
-			if value != "" && (ref.DefaultValue == "" || len(value) > len(ref.DefaultValue)) {
-				ref.DefaultValue = value
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			if err := c.walkService(value.Content[j].Value, resolveAlias(value.Content[j+1])); err != nil {
+				return err
 			}
-		case ":?", "?":
-			// Required forms with error message
-			ref.IsRequired = true
-			// Use the most specific error message seen
-			if value != "" && ref.ErrorMessage == "" {
-				ref.ErrorMessage = value
+		}
+	}
+
+	return nil
+}
+
+// walkService walks a single service definition. The environment block is
+// handled separately so pass-through entries can be checked against env_file.
+func (c *envVarCollector) walkService(service string, node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return c.walk(node, service)
+	}
+
+	var environment *yaml.Node
+	envFileKeys := make(map[string]bool)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, resolveAlias(node.Content[i+1])
+		switch key {
+		case "environment":
+			environment = value
+			continue
+		case "env_file":
+			if err := c.readServiceEnvFiles(service, value, envFileKeys); err != nil {
+				return err
 			}
-		default:
-			// Basic ${VAR} or $VAR form - no special handling
-			// These are "soft" requirements (compose uses empty string if unset)
 		}
+		if err := c.walk(value, service); err != nil {
+			return err
+		}
+	}
 
-		// Track approximate location (which service likely uses it)
-		// Use the position of this specific match
-		matchStartPos := matchesWithIndex[i][0]
-		location := findVariableLocationAt(content, matchStartPos, serviceNames)
-		if location != "" && !contains(ref.Locations, location) {
-			ref.Locations = append(ref.Locations, location)
+	if environment != nil {
+		return c.walkEnvironment(service, environment, envFileKeys)
+	}
+	return nil
+}
+
+// walkEnvironment handles both the list ("KEY=value" / "KEY") and mapping
+// forms of a service's environment block.
+func (c *envVarCollector) walkEnvironment(service string, node *yaml.Node, envFileKeys map[string]bool) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, resolveAlias(node.Content[i+1])
+			if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+				c.addPassThrough(key, service, envFileKeys)
+				continue
+			}
+			if err := c.walk(value, service); err != nil {
+				return err
+			}
 		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			item = resolveAlias(item)
+			if item.Kind == yaml.ScalarNode && !strings.Contains(item.Value, "=") {
+				c.addPassThrough(item.Value, service, envFileKeys)
+				continue
+			}
+			if err := c.walk(item, service); err != nil {
+				return err
+			}
+		}
+	default:
+		return c.walk(node, service)
 	}
+	return nil
+}
 
-	// Convert map to sorted slice
-	result := make([]EnvVarReference, 0, len(vars))
-	for _, ref := range vars {
-		// Sort locations for deterministic output
-		sort.Strings(ref.Locations)
-		result = append(result, *ref)
+// readServiceEnvFiles records the keys defined by a service's env_file entries
+// and collects references from their values, which compose also interpolates.
+// Missing files are skipped: compose config reports them during validation.
+func (c *envVarCollector) readServiceEnvFiles(service string, node *yaml.Node, keys map[string]bool) error {
+	var paths []string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		paths = append(paths, node.Value)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			item = resolveAlias(item)
+			switch item.Kind {
+			case yaml.ScalarNode:
+				paths = append(paths, item.Value)
+			case yaml.MappingNode:
+				for i := 0; i+1 < len(item.Content); i += 2 {
+					if item.Content[i].Value == "path" {
+						paths = append(paths, item.Content[i+1].Value)
+					}
+				}
+			}
+		}
 	}
 
-	// Sort by name for deterministic output
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Name < result[j].Name
-	})
+	for _, p := range paths {
+		// Paths built from variables can't be resolved here
+		if strings.Contains(p, "$") {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(c.projectDir, p)
+		}
 
-	return result, nil
+		values, err := readDotEnv(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read env_file for service %s: %w", service, err)
+		}
+		for k, v := range values {
+			keys[k] = true
+			// Single-quoted values are taken literally by compose; quoting is
+			// lost by readDotEnv, so unparseable values are skipped, not fatal.
+			_ = c.addString(v, service)
+		}
+	}
+	return nil
 }
 
-// GetMissingVars compares required variables against stored variables
-// and returns a list of variables that are missing or empty.
-func GetMissingVars(required []EnvVarReference, stored map[string]string) []EnvVarReference {
-	var missing []EnvVarReference
+// walk collects references from every scalar value below node.
+// Mapping keys are skipped because compose does not interpolate them.
+func (c *envVarCollector) walk(node *yaml.Node, service string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := c.walk(child, service); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := c.walk(node.Content[i+1], service); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		return c.addString(node.Value, service)
+	case yaml.AliasNode:
+		return c.walk(resolveAlias(node), service)
+	}
+	return nil
+}
 
-	for _, req := range required {
-		value, exists := stored[req.Name]
+// addString parses a scalar value and records every reference it contains.
+func (c *envVarCollector) addString(value, service string) error {
+	if !strings.Contains(value, "$") {
+		return nil
+	}
 
-		// Consider variable missing if:
-		// 1. Not in stored map, OR
-		// 2. Empty string in stored map
-		if !exists || value == "" {
-			missing = append(missing, req)
+	refs, err := parseInterpolation(value)
+	if err != nil {
+		if service != "" {
+			return fmt.Errorf("service %s: %w", service, err)
 		}
+		return err
 	}
 
-	return missing
+	for _, r := range refs {
+		ref := c.reference(r.Name)
+
+		switch r.Operator {
+		case ":-", "-":
+			ref.HasDefault = true
+			// Use the longest/most specific default value seen
+			if r.Value != "" && (ref.DefaultValue == "" || len(r.Value) > len(ref.DefaultValue)) {
+				ref.DefaultValue = r.Value
+			}
+		case ":?", "?":
+			// Only top-level requirements abort the deployment
+			if !r.Nested {
+				ref.IsRequired = true
+				if r.Value != "" && ref.ErrorMessage == "" {
+					ref.ErrorMessage = r.Value
+				}
+			}
+		}
+
+		c.recordUsage(r.Name, service, r.hasFallback(), r.isRequired() && !r.Nested)
+	}
+	return nil
 }
 
-// extractServiceNames extracts service names from parsed compose data.
-// Returns a slice of service names found in the "services" section.
-func extractServiceNames(composeData map[string]interface{}) []string {
-	var names []string
+// addPassThrough records an environment entry without a value, which compose
+// fills from the project environment when the variable is set.
+func (c *envVarCollector) addPassThrough(name, service string, envFileKeys map[string]bool) {
+	if envFileKeys[name] || !isValidName(name) {
+		return
+	}
+	c.reference(name)
+	c.recordUsage(name, service, true, false)
+}
 
-	services, ok := composeData["services"].(map[string]interface{})
+// reference returns the aggregated reference for name, creating it if needed.
+func (c *envVarCollector) reference(name string) *EnvVarReference {
+	ref, ok := c.vars[name]
 	if !ok {
-		return names
+		ref = &EnvVarReference{Name: name, Locations: []string{}}
+		c.vars[name] = ref
 	}
+	return ref
+}
 
-	for name := range services {
-		names = append(names, name)
+// recordUsage merges a single reference into the per-service usage.
+func (c *envVarCollector) recordUsage(name, service string, hasFallback, required bool) {
+	byService, ok := c.usages[name]
+	if !ok {
+		byService = make(map[string]*EnvVarUsage)
+		c.usages[name] = byService
 	}
 
-	sort.Strings(names)
-	return names
+	usage, ok := byService[service]
+	if !ok {
+		byService[service] = &EnvVarUsage{Service: service, HasDefault: hasFallback, IsRequired: required}
+		return
+	}
+	usage.HasDefault = usage.HasDefault && hasFallback
+	usage.IsRequired = usage.IsRequired || required
 }
 
-// findVariableLocationAt attempts to determine which service uses a variable
-// by finding the service definition closest to where the variable appears at the given position.
-func findVariableLocationAt(content string, position int, serviceNames []string) string {
-	if position < 0 || position >= len(content) {
-		return ""
+// result converts the collected references to a slice sorted by name,
+// with locations and usages sorted by service name.
+func (c *envVarCollector) result() []EnvVarReference {
+	result := make([]EnvVarReference, 0, len(c.vars))
+	for name, ref := range c.vars {
+		for service, usage := range c.usages[name] {
+			ref.Usages = append(ref.Usages, *usage)
+			if service != "" {
+				ref.Locations = append(ref.Locations, service)
+			}
+		}
+		sort.Strings(ref.Locations)
+		sort.Slice(ref.Usages, func(i, j int) bool {
+			return ref.Usages[i].Service < ref.Usages[j].Service
+		})
+		result = append(result, *ref)
 	}
 
-	// Look backwards for service definition
-	// Find the most recent "servicename:" pattern before this variable
-	beforeContent := content[:position]
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
 
-	var closestService string
-	closestDistance := len(beforeContent)
+	return result
+}
 
-	for _, serviceName := range serviceNames {
-		// Look for "  servicename:" pattern (2-space indent for service definition)
-		pattern := fmt.Sprintf("  %s:", serviceName)
-		lastIndex := strings.LastIndex(beforeContent, pattern)
+// resolveAlias follows YAML aliases (*anchor) to the node they reference.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
 
-		if lastIndex != -1 {
-			distance := position - lastIndex
-			if distance < closestDistance {
-				closestDistance = distance
-				closestService = serviceName
-			}
+// isValidName reports whether s is a valid interpolation variable name.
+func isValidName(s string) bool {
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
 		}
 	}
-
-	return closestService
+	return true
 }
 
-// contains checks if a string slice contains a specific string.
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+// readDotEnv parses a dotenv file (KEY=value per line) as used by compose for
+// .env and env_file. Comments, blank lines and an optional "export " prefix are
+// ignored, and surrounding quotes are removed from values.
+func readDotEnv(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !isValidName(key) {
+			continue
 		}
+		if !found {
+			// Bare keys in env files are passed through, not defined
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			// Inline comments are only allowed after unquoted values
+			value = strings.TrimSpace(value[:i])
+		}
+		vars[key] = value
 	}
-	return false
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
 }
 
 // GenerateEnvExample creates a .env.example file from environment variable references.
@@ -221,13 +490,10 @@ func GenerateEnvExample(vars []EnvVarReference, outputPath string) error {
 	// Group by required vs optional
 	var required, optional []EnvVarReference
 	for _, v := range vars {
-		if v.IsRequired {
+		if v.NeedsValue() {
 			required = append(required, v)
-		} else if v.HasDefault {
-			optional = append(optional, v)
 		} else {
-			// Basic variables (neither required nor optional) - treat as required
-			required = append(required, v)
+			optional = append(optional, v)
 		}
 	}
 
@@ -291,6 +291,222 @@ services:
 	}
 }
 
+// TestParseEnvVars_EscapesAndComments tests that $$ escapes and YAML comments are ignored
+func TestParseEnvVars_EscapesAndComments(t *testing.T) {
+	composeContent := `
+services:
+  web:
+    image: nginx
+    # image: myapp:${OLD_VERSION}
+    command: ["sh", "-c", "echo $$HOME"]
+    environment:
+      - GREETING=cost is $$5 # not ${COMMENTED}
+`
+
+	tmpfile := createTempComposeFile(t, composeContent)
+	defer os.Remove(tmpfile)
+
+	vars, err := ParseEnvVars(tmpfile)
+	require.NoError(t, err)
+	assert.Empty(t, vars)
+}
+
+// TestParseEnvVars_NestedDefaults tests that nested references only count as conditional
+func TestParseEnvVars_NestedDefaults(t *testing.T) {
+	composeContent := `
+services:
+  web:
+    image: nginx
+    environment:
+      DATABASE_URL: ${DATABASE_URL:-${FALLBACK_DB_URL}}
+`
+
+	tmpfile := createTempComposeFile(t, composeContent)
+	defer os.Remove(tmpfile)
+
+	vars, err := ParseEnvVars(tmpfile)
+	require.NoError(t, err)
+	require.Len(t, vars, 2)
+
+	dbURL := findVar(vars, "DATABASE_URL")
+	require.NotNil(t, dbURL)
+	assert.True(t, dbURL.HasDefault)
+	assert.Equal(t, "${FALLBACK_DB_URL}", dbURL.DefaultValue)
+	assert.False(t, dbURL.NeedsValue())
+
+	fallback := findVar(vars, "FALLBACK_DB_URL")
+	require.NotNil(t, fallback)
+	assert.Equal(t, []string{"web"}, fallback.Locations)
+	assert.False(t, fallback.NeedsValue(), "nested reference is only used when the outer variable is unset")
+}
+
+// TestParseEnvVars_MappingKeysNotInterpolated tests that only values are scanned
+func TestParseEnvVars_MappingKeysNotInterpolated(t *testing.T) {
+	composeContent := `
+services:
+  web:
+    image: nginx
+    labels:
+      "${NOT_A_VAR}": "value-${REAL_VAR}"
+`
+
+	tmpfile := createTempComposeFile(t, composeContent)
+	defer os.Remove(tmpfile)
+
+	vars, err := ParseEnvVars(tmpfile)
+	require.NoError(t, err)
+	require.Len(t, vars, 1)
+	assert.Equal(t, "REAL_VAR", vars[0].Name)
+}
+
+// TestParseEnvVars_ServiceUsages tests per-service classification of a shared variable
+func TestParseEnvVars_ServiceUsages(t *testing.T) {
+	composeContent := `
+x-common: &common
+  image: myapp:${VERSION:-latest}
+services:
+  web:
+    <<: *common
+    environment:
+      - API_KEY=${API_KEY}
+  worker:
+    <<: *common
+    environment:
+      - API_KEY=${API_KEY:-dev-key}
+networks:
+  default:
+    name: ${NETWORK_NAME:-app}
+`
+
+	tmpfile := createTempComposeFile(t, composeContent)
+	defer os.Remove(tmpfile)
+
+	vars, err := ParseEnvVars(tmpfile)
+	require.NoError(t, err)
+
+	apiKey := findVar(vars, "API_KEY")
+	require.NotNil(t, apiKey)
+	assert.Equal(t, []EnvVarUsage{
+		{Service: "web", HasDefault: false},
+		{Service: "worker", HasDefault: true},
+	}, apiKey.Usages)
+	assert.True(t, apiKey.NeedsValue(), "web has no fallback")
+	assert.Equal(t, []string{"web"}, apiKey.ServicesNeedingValue())
+
+	// Merge keys pull anchored values into each service
+	version := findVar(vars, "VERSION")
+	require.NotNil(t, version)
+	assert.Equal(t, []string{"web", "worker"}, version.Locations)
+
+	// Top-level sections are tracked without a service name
+	network := findVar(vars, "NETWORK_NAME")
+	require.NotNil(t, network)
+	assert.Empty(t, network.Locations)
+	require.Len(t, network.Usages, 1)
+	assert.Equal(t, "", network.Usages[0].Service)
+}
+
+// TestParseEnvVars_EnvironmentPassThrough tests bare environment entries and env_file
+func TestParseEnvVars_EnvironmentPassThrough(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "web.env"), []byte("# web settings\nFROM_FILE=1\nINTERPOLATED=${FILE_REF}\n"), 0644))
+
+	composeContent := `
+services:
+  web:
+    image: nginx
+    env_file:
+      - web.env
+      - path: missing.env
+        required: false
+    environment:
+      - FROM_FILE
+      - FROM_HOST
+  worker:
+    image: nginx
+    environment:
+      FROM_FILE:
+      LITERAL: "yes"
+`
+	composePath := filepath.Join(dir, "compose.yaml")
+	require.NoError(t, os.WriteFile(composePath, []byte(composeContent), 0644))
+
+	vars, err := ParseEnvVars(composePath)
+	require.NoError(t, err)
+
+	// Defined by web's env_file, passed through from the host by worker only
+	fromFile := findVar(vars, "FROM_FILE")
+	require.NotNil(t, fromFile)
+	assert.Equal(t, []string{"worker"}, fromFile.Locations)
+	assert.False(t, fromFile.NeedsValue(), "pass-through entries may be left unset")
+
+	fromHost := findVar(vars, "FROM_HOST")
+	require.NotNil(t, fromHost)
+	assert.Equal(t, []string{"web"}, fromHost.Locations)
+
+	// References inside env_file values are interpolated by compose too
+	fileRef := findVar(vars, "FILE_REF")
+	require.NotNil(t, fileRef)
+	assert.Equal(t, []string{"web"}, fileRef.Locations)
+
+	assert.Nil(t, findVar(vars, "LITERAL"))
+}
+
+// TestParseEnvVars_DotEnv tests that variables defined in the project's .env are satisfied
+func TestParseEnvVars_DotEnv(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("export REGISTRY=ghcr.io/acme\nTAG='v1'\n"), 0644))
+
+	composeContent := `
+services:
+  web:
+    image: ${REGISTRY:?registry required}/web:${TAG}
+    environment:
+      - SECRET=${SECRET}
+`
+	composePath := filepath.Join(dir, "compose.yaml")
+	require.NoError(t, os.WriteFile(composePath, []byte(composeContent), 0644))
+
+	vars, err := ParseEnvVars(composePath)
+	require.NoError(t, err)
+
+	registry := findVar(vars, "REGISTRY")
+	require.NotNil(t, registry)
+	assert.True(t, registry.InDotEnv)
+	assert.False(t, registry.NeedsValue())
+
+	tag := findVar(vars, "TAG")
+	require.NotNil(t, tag)
+	assert.True(t, tag.InDotEnv)
+
+	secret := findVar(vars, "SECRET")
+	require.NotNil(t, secret)
+	assert.False(t, secret.InDotEnv)
+	assert.True(t, secret.NeedsValue())
+
+	missing := GetMissingVars(vars, map[string]string{})
+	require.Len(t, missing, 1)
+	assert.Equal(t, "SECRET", missing[0].Name)
+}
+
+// TestParseEnvVars_InvalidInterpolation tests that malformed references are reported
+func TestParseEnvVars_InvalidInterpolation(t *testing.T) {
+	composeContent := `
+services:
+  web:
+    image: nginx
+    command: echo ${UNTERMINATED
+`
+
+	tmpfile := createTempComposeFile(t, composeContent)
+	defer os.Remove(tmpfile)
+
+	_, err := ParseEnvVars(tmpfile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "service web")
+	assert.Contains(t, err.Error(), "invalid interpolation format")
+}
+
 // TestParseEnvVars_InvalidFile tests error handling for non-existent files
 func TestParseEnvVars_InvalidFile(t *testing.T) {
 	_, err := ParseEnvVars("/nonexistent/file.yml")
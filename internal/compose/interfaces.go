@@ -12,7 +12,8 @@ type ComposeOperations interface {
 	Pull(ctx context.Context) error
 	Logs(ctx context.Context, service string, tail int) (string, error)
 	IsRunning(ctx context.Context) (bool, error)
-	Restart(ctx context.Context) error
+	Restart(ctx context.Context, services ...string) error
+	Run(ctx context.Context, envFilePath, service string, command, env []string) error
 	ComposeFilePath() string
 }
 
@@ -0,0 +1,155 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// interpolationRef is a single variable reference found by the interpolation parser.
+type interpolationRef struct {
+	Name     string // Variable name
+	Operator string // One of "", ":-", "-", ":?", "?", ":+", "+"
+	Value    string // Raw text after the operator (default, error message or replacement)
+	Nested   bool   // Reference appears inside another reference's default, error or replacement
+}
+
+// hasFallback reports whether compose can resolve the reference without the variable being set.
+// Nested references are only evaluated when their enclosing variable is unset,
+// so they never make a deployment fail on their own.
+func (r interpolationRef) hasFallback() bool {
+	switch r.Operator {
+	case ":-", "-", ":+", "+":
+		return true
+	}
+	return r.Nested && !r.isRequired()
+}
+
+// isRequired reports whether compose aborts when the variable is unset.
+func (r interpolationRef) isRequired() bool {
+	return r.Operator == ":?" || r.Operator == "?"
+}
+
+// interpolationOperators lists the braced operators in match order.
+// Two-character forms must come first so ":-" is not read as ":" followed by "-".
+var interpolationOperators = []string{":-", ":?", ":+", "-", "?", "+"}
+
+// parseInterpolation extracts variable references from a string using the
+// docker compose interpolation grammar:
+//   - $$ is an escaped literal dollar sign
+//   - $VAR and ${VAR} are plain substitutions
+//   - ${VAR:-default}, ${VAR-default}, ${VAR:?error}, ${VAR?error},
+//     ${VAR:+replacement} and ${VAR+replacement} are modifier forms
+//
+// Defaults, error messages and replacements may themselves contain references,
+// which are returned with Nested set.
+func parseInterpolation(s string) ([]interpolationRef, error) {
+	return parseInterpolationDepth(s, false)
+}
+
+func parseInterpolationDepth(s string, nested bool) ([]interpolationRef, error) {
+	var refs []interpolationRef
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		if i+1 >= len(s) {
+			return nil, fmt.Errorf("invalid interpolation format in %q: trailing $ (use $$ for a literal dollar sign)", s)
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			// Escaped dollar sign, skip both characters
+			i++
+
+		case next == '{':
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid interpolation format in %q: missing closing brace", s)
+			}
+
+			ref, err := parseBracedReference(s[i+2 : end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid interpolation format in %q: %w", s, err)
+			}
+			ref.Nested = nested
+			refs = append(refs, ref)
+
+			if ref.Value != "" {
+				inner, err := parseInterpolationDepth(ref.Value, true)
+				if err != nil {
+					return nil, err
+				}
+				refs = append(refs, inner...)
+			}
+			i = end
+
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			refs = append(refs, interpolationRef{Name: s[i+1 : j], Nested: nested})
+			i = j - 1
+
+		default:
+			return nil, fmt.Errorf("invalid interpolation format in %q: unexpected %q after $ (use $$ for a literal dollar sign)", s, string(next))
+		}
+	}
+
+	return refs, nil
+}
+
+// parseBracedReference parses the body of a ${...} expression.
+func parseBracedReference(body string) (interpolationRef, error) {
+	n := 0
+	for n < len(body) && (isNameChar(body[n]) && (n > 0 || isNameStart(body[n]))) {
+		n++
+	}
+	if n == 0 {
+		return interpolationRef{}, fmt.Errorf("invalid variable name in ${%s}", body)
+	}
+
+	ref := interpolationRef{Name: body[:n]}
+	rest := body[n:]
+	if rest == "" {
+		return ref, nil
+	}
+
+	for _, op := range interpolationOperators {
+		if strings.HasPrefix(rest, op) {
+			ref.Operator = op
+			ref.Value = rest[len(op):]
+			return ref, nil
+		}
+	}
+
+	return interpolationRef{}, fmt.Errorf("unsupported modifier in ${%s}", body)
+}
+
+// matchingBrace returns the index of the brace closing the one at open,
+// accounting for nested ${...} expressions. Returns -1 if unbalanced.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
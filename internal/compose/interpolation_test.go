@@ -0,0 +1,113 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInterpolation(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []interpolationRef
+	}{
+		{
+			name:  "no references",
+			input: "nginx:latest",
+			want:  nil,
+		},
+		{
+			name:  "simple form",
+			input: "myapp:$VERSION",
+			want:  []interpolationRef{{Name: "VERSION"}},
+		},
+		{
+			name:  "braced form",
+			input: "${HOST}:${PORT}",
+			want:  []interpolationRef{{Name: "HOST"}, {Name: "PORT"}},
+		},
+		{
+			name:  "escaped dollar is not a reference",
+			input: "echo $$HOME and $${PATH}",
+			want:  nil,
+		},
+		{
+			name:  "escape followed by reference",
+			input: "$$$USER",
+			want:  []interpolationRef{{Name: "USER"}},
+		},
+		{
+			name:  "all modifiers",
+			input: "${A:-a}${B-b}${C:?c}${D?d}${E:+e}${F+f}",
+			want: []interpolationRef{
+				{Name: "A", Operator: ":-", Value: "a"},
+				{Name: "B", Operator: "-", Value: "b"},
+				{Name: "C", Operator: ":?", Value: "c"},
+				{Name: "D", Operator: "?", Value: "d"},
+				{Name: "E", Operator: ":+", Value: "e"},
+				{Name: "F", Operator: "+", Value: "f"},
+			},
+		},
+		{
+			name:  "nested default",
+			input: "${A:-${B}}",
+			want: []interpolationRef{
+				{Name: "A", Operator: ":-", Value: "${B}"},
+				{Name: "B", Nested: true},
+			},
+		},
+		{
+			name:  "nested default with its own modifier",
+			input: "${A:-${B:-fallback}/path}",
+			want: []interpolationRef{
+				{Name: "A", Operator: ":-", Value: "${B:-fallback}/path"},
+				{Name: "B", Operator: ":-", Value: "fallback", Nested: true},
+			},
+		},
+		{
+			name:  "empty default",
+			input: "${A:-}",
+			want:  []interpolationRef{{Name: "A", Operator: ":-"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInterpolation(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseInterpolation_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"trailing dollar", "price: 5$"},
+		{"unterminated brace", "${VAR"},
+		{"invalid name", "${1VAR}"},
+		{"unsupported modifier", "${VAR/foo/bar}"},
+		{"dollar before invalid character", "$(command)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseInterpolation(tt.input)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid interpolation format")
+		})
+	}
+}
+
+func TestInterpolationRef_Fallback(t *testing.T) {
+	assert.False(t, interpolationRef{Name: "A"}.hasFallback())
+	assert.True(t, interpolationRef{Name: "A", Operator: ":-"}.hasFallback())
+	assert.True(t, interpolationRef{Name: "A", Operator: "+"}.hasFallback())
+	assert.False(t, interpolationRef{Name: "A", Operator: ":?"}.hasFallback())
+	assert.True(t, interpolationRef{Name: "A", Nested: true}.hasFallback())
+	assert.False(t, interpolationRef{Name: "A", Operator: "?", Nested: true}.hasFallback())
+}
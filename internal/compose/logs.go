@@ -0,0 +1,138 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/target"
+)
+
+// serviceNamePattern matches compose service names.
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// LogOptions selects the container logs returned by StreamProjectLogs.
+type LogOptions struct {
+	Service    string // Empty for all services
+	Follow     bool   // Keep streaming new output until ctx is cancelled
+	Since      string // Timestamp (RFC 3339, date or Unix) or relative duration such as "42m"
+	Until      string // Same formats as Since
+	Tail       int    // Number of lines per container from the end; 0 for all
+	Timestamps bool
+	NoColor    bool
+}
+
+// Validate checks the options before they are passed to docker.
+func (o LogOptions) Validate() error {
+	if o.Service != "" && !serviceNamePattern.MatchString(o.Service) {
+		return fmt.Errorf("invalid service name %q", o.Service)
+	}
+	if err := validateLogTime(o.Since); err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+	if err := validateLogTime(o.Until); err != nil {
+		return fmt.Errorf("invalid --until value: %w", err)
+	}
+	if o.Tail < 0 {
+		return fmt.Errorf("tail cannot be negative")
+	}
+	return nil
+}
+
+// args returns the "docker compose logs" flags for the options.
+func (o LogOptions) args() []string {
+	var args []string
+	if o.Follow {
+		args = append(args, "--follow")
+	}
+	if o.Since != "" {
+		args = append(args, "--since="+o.Since)
+	}
+	if o.Until != "" {
+		args = append(args, "--until="+o.Until)
+	}
+	if o.Tail > 0 {
+		args = append(args, "--tail="+strconv.Itoa(o.Tail))
+	}
+	if o.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	if o.NoColor {
+		args = append(args, "--no-color")
+	}
+	if o.Service != "" {
+		args = append(args, o.Service)
+	}
+	return args
+}
+
+// validateLogTime accepts the time formats docker understands for --since and --until.
+func validateLogTime(value string) error {
+	if value == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return fmt.Errorf("duration %q cannot be negative", value)
+		}
+		return nil
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if _, err := time.Parse(layout, value); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a duration (e.g. 42m), Unix timestamp or RFC 3339 time", value)
+}
+
+// StreamProjectLogs writes the container logs of a compose project to w as
+// docker produces them. It only needs the compose project name, so logs of a
+// deployment whose worktree has been removed can still be read while its
+// containers exist. A follow stream ends without error when ctx is cancelled.
+func StreamProjectLogs(ctx context.Context, projectName string, opts LogOptions, w io.Writer) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	args := append([]string{"compose", "-p", projectName, "logs"}, opts.args()...)
+	cmd := target.Docker(ctx, args...)
+
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("compose logs for project %s failed: %w\n%s", projectName, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ProjectExists reports whether a compose project has containers, running or stopped.
+func ProjectExists(ctx context.Context, projectName string) (bool, error) {
+	cmd := target.Docker(ctx, "compose", "ls", "--all", "--format", "{{.Name}}")
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, fmt.Errorf("compose ls cancelled: %w", ctx.Err())
+		}
+		return false, fmt.Errorf("compose ls failed: %w", err)
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == projectName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
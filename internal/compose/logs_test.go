@@ -0,0 +1,61 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogOptions_Args(t *testing.T) {
+	opts := LogOptions{
+		Service:    "web",
+		Follow:     true,
+		Since:      "10m",
+		Until:      "2026-01-02T15:04:05Z",
+		Tail:       100,
+		Timestamps: true,
+		NoColor:    true,
+	}
+
+	assert.Equal(t, []string{
+		"--follow",
+		"--since=10m",
+		"--until=2026-01-02T15:04:05Z",
+		"--tail=100",
+		"--timestamps",
+		"--no-color",
+		"web",
+	}, opts.args())
+
+	assert.Empty(t, LogOptions{}.args())
+}
+
+func TestLogOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    LogOptions
+		wantErr bool
+	}{
+		{"empty", LogOptions{}, false},
+		{"relative since", LogOptions{Since: "42m"}, false},
+		{"unix since", LogOptions{Since: "1767225600"}, false},
+		{"date until", LogOptions{Until: "2026-01-02"}, false},
+		{"rfc3339 until", LogOptions{Until: "2026-01-02T15:04:05+01:00"}, false},
+		{"service with dots", LogOptions{Service: "api.v2_worker-1"}, false},
+		{"garbage since", LogOptions{Since: "yesterday"}, true},
+		{"negative duration", LogOptions{Since: "-5m"}, true},
+		{"flag as service", LogOptions{Service: "--privileged"}, true},
+		{"negative tail", LogOptions{Tail: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -3,15 +3,19 @@ package compose
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Manager handles docker compose operations.
@@ -25,9 +29,10 @@ type Manager struct {
 
 // ServiceStatus represents the status of a compose service.
 type ServiceStatus struct {
-	Name   string
-	Status string
-	Health string
+	Name    string // Container name
+	Service string // Compose service the container belongs to
+	Status  string
+	Health  string
 }
 
 // NewManager creates a new compose manager.
@@ -100,22 +105,23 @@ func (m *Manager) SetOutputStreams(stdout, stderr io.Writer) {
 //
 // The method uses --wait flag to block until containers are healthy or timeout.
 // Orphaned containers from previous runs are automatically removed.
-func (m *Manager) Up(ctx context.Context, envFilePath string) error {
+func (m *Manager) Up(ctx context.Context, envFilePath string) (err error) {
+	ctx, done := m.operation(ctx, "up")
+	defer done(&err)
+
 	args := m.baseArgs()
 
 	// Add env file if provided
-	if envFilePath != "" {
-		args = append(args, "--env-file", envFilePath)
-	}
+	args = append(args, m.envFileArgs(envFilePath)...)
 
 	args = append(args, "up", "-d", "--wait", "--remove-orphans")
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 	cmd.Stdout = m.getStdout()
 	cmd.Stderr = m.getStderr()
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			return fmt.Errorf("%w", errors.ErrComposeTimeout)
@@ -140,19 +146,22 @@ func (m *Manager) Up(ctx context.Context, envFilePath string) error {
 //   - error if command fails
 //
 // Networks and containers are always removed. Volumes are only removed if removeVolumes is true.
-func (m *Manager) Down(ctx context.Context, removeVolumes bool) error {
+func (m *Manager) Down(ctx context.Context, removeVolumes bool) (err error) {
+	ctx, done := m.operation(ctx, "down")
+	defer done(&err)
+
 	args := m.baseArgs()
 	args = append(args, "down")
 	if removeVolumes {
 		args = append(args, "-v")
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 	cmd.Stdout = m.getStdout()
 	cmd.Stderr = m.getStderr()
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		if ctx.Err() != nil {
 			return fmt.Errorf("compose down cancelled: %w", ctx.Err())
@@ -166,11 +175,14 @@ func (m *Manager) Down(ctx context.Context, removeVolumes bool) error {
 // Note: This method uses buffered output (not streaming) because it needs to
 // parse the command output into structured ServiceStatus data. Users don't
 // need to see the raw docker compose ps output - they get structured data instead.
-func (m *Manager) Status(ctx context.Context) ([]ServiceStatus, error) {
+func (m *Manager) Status(ctx context.Context) (_ []ServiceStatus, err error) {
+	ctx, done := m.operation(ctx, "status")
+	defer done(&err)
+
 	args := m.baseArgs()
-	args = append(args, "ps", "--format", "{{.Name}}\t{{.Status}}\t{{.Health}}")
+	args = append(args, "ps", "--format", serviceStatusFormat)
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 
 	output, err := cmd.Output()
@@ -178,42 +190,26 @@ func (m *Manager) Status(ctx context.Context) ([]ServiceStatus, error) {
 		return nil, fmt.Errorf("compose ps failed: %w", err)
 	}
 
-	var services []ServiceStatus
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Split(line, "\t")
-		if len(parts) >= 2 {
-			status := ServiceStatus{
-				Name:   parts[0],
-				Status: parts[1],
-			}
-			if len(parts) >= 3 {
-				status.Health = parts[2]
-			}
-			services = append(services, status)
-		}
-	}
-
-	return services, nil
+	return parseServiceStatus(output), nil
 }
 
 // Validate validates the compose file.
 // Validation errors and warnings stream in real-time to configured output streams.
 // The --quiet flag suppresses success messages, but validation errors are still displayed.
 // Note: This method now uses streaming (not buffering) for consistency with other operations.
-func (m *Manager) Validate(ctx context.Context) error {
+func (m *Manager) Validate(ctx context.Context) (err error) {
+	ctx, done := m.operation(ctx, "validate")
+	defer done(&err)
+
 	args := m.baseArgs()
 	args = append(args, "config", "--quiet")
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 	cmd.Stdout = m.getStdout()
 	cmd.Stderr = m.getStderr()
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		return fmt.Errorf("%w", errors.ErrComposeInvalid)
 	}
@@ -224,22 +220,23 @@ func (m *Manager) Validate(ctx context.Context) error {
 // Validation errors and warnings stream in real-time to configured output streams.
 // The --quiet flag suppresses success messages, but validation errors are still displayed.
 // Note: This method now uses streaming (not buffering) for consistency with other operations.
-func (m *Manager) ValidateWithEnv(ctx context.Context, envFilePath string) error {
+func (m *Manager) ValidateWithEnv(ctx context.Context, envFilePath string) (err error) {
+	ctx, done := m.operation(ctx, "validate_with_env")
+	defer done(&err)
+
 	args := m.baseArgs()
 
 	// Add env file if provided
-	if envFilePath != "" {
-		args = append(args, "--env-file", envFilePath)
-	}
+	args = append(args, m.envFileArgs(envFilePath)...)
 
 	args = append(args, "config", "--quiet")
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 	cmd.Stdout = m.getStdout()
 	cmd.Stderr = m.getStderr()
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		return fmt.Errorf("%w", errors.ErrComposeInvalid)
 	}
@@ -258,16 +255,19 @@ func (m *Manager) ValidateWithEnv(ctx context.Context, envFilePath string) error
 //   - error if command fails
 //
 // This method is useful for pre-downloading images before starting services.
-func (m *Manager) Pull(ctx context.Context) error {
+func (m *Manager) Pull(ctx context.Context) (err error) {
+	ctx, done := m.operation(ctx, "pull")
+	defer done(&err)
+
 	args := m.baseArgs()
 	args = append(args, "pull")
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 	cmd.Stdout = m.getStdout()
 	cmd.Stderr = m.getStderr()
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		if ctx.Err() != nil {
 			return fmt.Errorf("compose pull cancelled: %w", ctx.Err())
@@ -288,22 +288,23 @@ func (m *Manager) Pull(ctx context.Context) error {
 // Returns:
 //   - context.Canceled if context cancelled
 //   - error if command fails
-func (m *Manager) PullWithEnv(ctx context.Context, envFilePath string) error {
+func (m *Manager) PullWithEnv(ctx context.Context, envFilePath string) (err error) {
+	ctx, done := m.operation(ctx, "pull_with_env")
+	defer done(&err)
+
 	args := m.baseArgs()
 
 	// Add env file if provided
-	if envFilePath != "" {
-		args = append(args, "--env-file", envFilePath)
-	}
+	args = append(args, m.envFileArgs(envFilePath)...)
 
 	args = append(args, "pull")
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 	cmd.Stdout = m.getStdout()
 	cmd.Stderr = m.getStderr()
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		if ctx.Err() != nil {
 			return fmt.Errorf("compose pull cancelled: %w", ctx.Err())
@@ -317,7 +318,10 @@ func (m *Manager) PullWithEnv(ctx context.Context, envFilePath string) error {
 // Note: This method uses buffered output (not streaming) because it returns
 // logs as a string for the caller to process or display. The interface signature
 // requires returning string data, so callers expect complete log output.
-func (m *Manager) Logs(ctx context.Context, service string, tail int) (string, error) {
+func (m *Manager) Logs(ctx context.Context, service string, tail int) (_ string, err error) {
+	ctx, done := m.operation(ctx, "logs")
+	defer done(&err)
+
 	args := m.baseArgs()
 	args = append(args, "logs")
 	if tail > 0 {
@@ -327,7 +331,7 @@ func (m *Manager) Logs(ctx context.Context, service string, tail int) (string, e
 		args = append(args, service)
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 
 	output, err := cmd.Output()
@@ -337,8 +341,57 @@ func (m *Manager) Logs(ctx context.Context, service string, tail int) (string, e
 	return string(output), nil
 }
 
+// VolumeNames maps the keys of the compose file's top-level volumes section to
+// the Docker volume names compose uses for them in this project, e.g.
+// "db-data" to "myapp-a1b2c3d_db-data", or to the name pinned with "name:".
+func (m *Manager) VolumeNames(ctx context.Context, envFilePath string) (_ map[string]string, err error) {
+	ctx, done := m.operation(ctx, "volume_names")
+	defer done(&err)
+
+	args := m.baseArgs()
+	args = append(args, m.envFileArgs(envFilePath)...)
+	args = append(args, "config", "--format", "json")
+
+	cmd := target.Docker(ctx, args...)
+	cmd.Dir = m.workingDir
+
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("compose config cancelled: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("compose config failed: %w", err)
+	}
+	return parseConfigVolumes(output, m.projectName)
+}
+
+// parseConfigVolumes reads the volume names from "docker compose config --format json" output.
+func parseConfigVolumes(data []byte, projectName string) (map[string]string, error) {
+	var config struct {
+		Volumes map[string]struct {
+			Name string `json:"name"`
+		} `json:"volumes"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse compose config: %w", err)
+	}
+
+	names := make(map[string]string, len(config.Volumes))
+	for key, v := range config.Volumes {
+		name := v.Name
+		if name == "" {
+			name = projectName + "_" + key
+		}
+		names[key] = name
+	}
+	return names, nil
+}
+
 // IsRunning checks if any services are currently running.
-func (m *Manager) IsRunning(ctx context.Context) (bool, error) {
+func (m *Manager) IsRunning(ctx context.Context) (_ bool, err error) {
+	ctx, done := m.operation(ctx, "is_running")
+	defer done(&err)
+
 	services, err := m.Status(ctx)
 	if err != nil {
 		return false, err
@@ -354,27 +407,32 @@ func (m *Manager) IsRunning(ctx context.Context) (bool, error) {
 	return false, nil
 }
 
-// Restart stops and then restarts all running services.
+// Restart stops and then restarts the given services, or all running services if none are given.
 // Docker output streams in real-time to configured output streams (see SetOutputStreams).
 //
 // Parameters:
 //   - ctx: Context for timeout and cancellation
+//   - services: Services to restart (empty for all)
 //
 // Returns:
 //   - context.Canceled if context cancelled
 //   - error if command fails
 //
 // Containers are restarted without recreating them. Use Down/Up to recreate containers.
-func (m *Manager) Restart(ctx context.Context) error {
+func (m *Manager) Restart(ctx context.Context, services ...string) (err error) {
+	ctx, done := m.operation(ctx, "restart")
+	defer done(&err)
+
 	args := m.baseArgs()
 	args = append(args, "restart")
+	args = append(args, services...)
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := target.Docker(ctx, args...)
 	cmd.Dir = m.workingDir
 	cmd.Stdout = m.getStdout()
 	cmd.Stderr = m.getStderr()
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		if ctx.Err() != nil {
 			return fmt.Errorf("compose restart cancelled: %w", ctx.Err())
@@ -384,6 +442,54 @@ func (m *Manager) Restart(ctx context.Context) error {
 	return nil
 }
 
+// Run executes a command in a one-off container of a service.
+// Docker output streams in real-time to configured output streams (see SetOutputStreams).
+//
+// Parameters:
+//   - ctx: Context for timeout and cancellation
+//   - envFilePath: Optional path to .env file for variable substitution (empty string to skip)
+//   - service: Service whose image and configuration the container uses
+//   - command: Command and arguments to run instead of the service default
+//   - env: Extra KEY=value pairs set in the container
+//
+// Returns:
+//   - errors.ErrComposeTimeout if context deadline exceeded
+//   - context.Canceled if context cancelled
+//   - error if command fails or exits non-zero
+//
+// The container is removed when the command exits. Services it depends on are
+// started in the same compose project if they are not already running.
+func (m *Manager) Run(ctx context.Context, envFilePath, service string, command, env []string) (err error) {
+	ctx, done := m.operation(ctx, "run")
+	defer done(&err)
+
+	args := m.baseArgs()
+	args = append(args, m.envFileArgs(envFilePath)...)
+	args = append(args, "run", "--rm", "-T")
+	for _, kv := range env {
+		args = append(args, "-e", kv)
+	}
+	args = append(args, service)
+	args = append(args, command...)
+
+	cmd := target.Docker(ctx, args...)
+	cmd.Dir = m.workingDir
+	cmd.Stdout = m.getStdout()
+	cmd.Stderr = m.getStderr()
+
+	err = cmd.Run()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w", errors.ErrComposeTimeout)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("compose run cancelled: %w", ctx.Err())
+		}
+		return fmt.Errorf("compose run failed: %w", err)
+	}
+	return nil
+}
+
 // baseArgs returns the base docker compose arguments.
 func (m *Manager) baseArgs() []string {
 	args := []string{"compose"}
@@ -391,15 +497,34 @@ func (m *Manager) baseArgs() []string {
 	// Add project name for isolation
 	args = append(args, "-p", m.projectName)
 
-	// Add compose file
-	args = append(args, "-f", m.composeFile)
+	// Add compose files; a comma-separated list layers overrides on the base file
+	for _, file := range strings.Split(m.composeFile, ",") {
+		args = append(args, "-f", file)
+	}
 
 	return args
 }
 
+// envFileArgs returns the --env-file arguments for envFilePath.
+// Passing --env-file stops compose from reading the project's .env file, so when
+// one exists next to the compose file it is listed first; later files win, so
+// stored variables still override the repository's defaults.
+func (m *Manager) envFileArgs(envFilePath string) []string {
+	if envFilePath == "" {
+		return nil
+	}
+
+	var args []string
+	dotEnv := filepath.Join(filepath.Dir(m.ComposeFilePath()), ".env")
+	if _, err := os.Stat(dotEnv); err == nil {
+		args = append(args, "--env-file", dotEnv)
+	}
+	return append(args, "--env-file", envFilePath)
+}
+
 // CheckDockerCompose verifies docker compose is available.
 func CheckDockerCompose(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "docker", "compose", "version")
+	cmd := target.Docker(ctx, "compose", "version")
 	if err := cmd.Run(); err != nil {
 		return errors.ErrComposeNotFound
 	}
@@ -414,7 +539,7 @@ func GenerateProjectName(projectName, shortSHA string) string {
 // FindRunningProjects finds all OtterStack-managed compose projects.
 func FindRunningProjects(ctx context.Context, prefix string) ([]string, error) {
 	// Use docker compose ls to list all projects
-	cmd := exec.CommandContext(ctx, "docker", "compose", "ls", "--format", "{{.Name}}")
+	cmd := target.Docker(ctx, "compose", "ls", "--format", "{{.Name}}")
 	output, err := cmd.Output()
 	if err != nil {
 		ctxErr := ctx.Err()
@@ -449,7 +574,7 @@ func StopProjectByName(ctx context.Context, projectName string, timeout time.Dur
 
 	// Use --timeout 0 to force immediate container kill instead of graceful shutdown
 	// This is critical for rollback scenarios where containers may be unhealthy/restarting
-	cmd := exec.CommandContext(ctxTimeout, "docker", "compose", "-p", projectName, "down", "--timeout", "0")
+	cmd := target.Docker(ctxTimeout, "compose", "-p", projectName, "down", "--timeout", "0")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		ctxErr := ctxTimeout.Err()
@@ -466,13 +591,21 @@ func StopProjectByName(ctx context.Context, projectName string, timeout time.Dur
 
 // GetProjectStatus returns detailed status for a compose project.
 func GetProjectStatus(ctx context.Context, projectName string) ([]ServiceStatus, error) {
-	cmd := exec.CommandContext(ctx, "docker", "compose", "-p", projectName, "ps", "--format", "{{.Name}}\t{{.Status}}\t{{.Health}}")
+	cmd := target.Docker(ctx, "compose", "-p", projectName, "ps", "--format", serviceStatusFormat)
 	output, err := cmd.Output()
 	if err != nil {
 		// Project might not exist or have no running containers
 		return nil, nil
 	}
 
+	return parseServiceStatus(output), nil
+}
+
+// serviceStatusFormat is the compose ps format read by parseServiceStatus.
+const serviceStatusFormat = "{{.Name}}\t{{.Status}}\t{{.Health}}\t{{.Service}}"
+
+// parseServiceStatus parses compose ps output in serviceStatusFormat.
+func parseServiceStatus(output []byte) []ServiceStatus {
 	var services []ServiceStatus
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	for _, line := range lines {
@@ -488,16 +621,19 @@ func GetProjectStatus(ctx context.Context, projectName string) ([]ServiceStatus,
 			if len(parts) >= 3 {
 				status.Health = parts[2]
 			}
+			if len(parts) >= 4 {
+				status.Service = parts[3]
+			}
 			services = append(services, status)
 		}
 	}
-
-	return services, nil
+	return services
 }
 
 // ComposeFilePath returns the full path to the compose file.
+// When overrides are layered on top, this is the base file.
 func (m *Manager) ComposeFilePath() string {
-	return filepath.Join(m.workingDir, m.composeFile)
+	return filepath.Join(m.workingDir, strings.Split(m.composeFile, ",")[0])
 }
 
 // ParseEnvVars extracts environment variable references from the compose file.
@@ -512,3 +648,23 @@ func (m *Manager) ParseEnvVars() ([]EnvVarReference, error) {
 func IsServiceRunning(status string) bool {
 	return status == "running" || status == "Up" || (len(status) > 0 && status[0] == 'U')
 }
+
+// operation traces and logs a compose operation on the manager's compose project. The
+// returned function ends it with the operation's error, if any.
+func (m *Manager) operation(ctx context.Context, name string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "compose."+name, trace.WithAttributes(tracing.AttrComposeProject.String(m.projectName)))
+	ctx = logging.WithFields(ctx, logging.String("compose_project", m.projectName))
+	log := logging.FromContext(ctx)
+
+	return ctx, func(errp *error) {
+		tracing.EndErr(span, errp)
+		if errp != nil && *errp != nil {
+			log.Warn("compose command failed", logging.String("operation", name),
+				logging.Duration("duration", time.Since(start)), logging.Error(*errp))
+			return
+		}
+		log.Debug("compose command finished", logging.String("operation", name),
+			logging.Duration("duration", time.Since(start)))
+	}
+}
@@ -240,6 +240,13 @@ services:
 	t.Logf("Restart output: %s", output)
 
 	assert.True(t, len(output) > 0, "Should have output from Docker")
+
+	// Restart a single service by name
+	err = manager.Restart(ctx, "test")
+	assert.NoError(t, err)
+
+	err = manager.Restart(ctx, "missing")
+	assert.Error(t, err, "Restarting an unknown service should fail")
 }
 
 // TestErrorHandling tests that errors are properly reported
@@ -43,6 +43,14 @@ func TestManager_BaseArgs(t *testing.T) {
 	assert.Contains(t, args, "compose.yaml")
 }
 
+func TestManager_BaseArgs_Overrides(t *testing.T) {
+	m := NewManager("/path/to/dir", "compose.yaml,docker-compose.traefik.yml", "myproject")
+	args := m.baseArgs()
+
+	assert.Equal(t, []string{"compose", "-p", "myproject", "-f", "compose.yaml", "-f", "docker-compose.traefik.yml"}, args)
+	assert.Equal(t, filepath.Join("/path/to/dir", "compose.yaml"), m.ComposeFilePath())
+}
+
 func TestManager_ProjectName(t *testing.T) {
 	m := NewManager("/path", "compose.yaml", "test-project")
 	assert.Equal(t, "test-project", m.ProjectName())
@@ -200,3 +208,62 @@ func TestSafeBuffer_String(t *testing.T) {
 
 	assert.Equal(t, content, buf.String())
 }
+
+func TestManager_EnvFileArgs(t *testing.T) {
+	t.Run("no env file", func(t *testing.T) {
+		m := NewManager(t.TempDir(), "compose.yaml", "test")
+		assert.Empty(t, m.envFileArgs(""))
+	})
+
+	t.Run("stored env file only", func(t *testing.T) {
+		m := NewManager(t.TempDir(), "compose.yaml", "test")
+		assert.Equal(t, []string{"--env-file", "/data/test.env"}, m.envFileArgs("/data/test.env"))
+	})
+
+	t.Run("project .env is listed before stored env file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1\n"), 0644))
+
+		m := NewManager(dir, "compose.yaml", "test")
+		assert.Equal(t, []string{
+			"--env-file", filepath.Join(dir, ".env"),
+			"--env-file", "/data/test.env",
+		}, m.envFileArgs("/data/test.env"))
+	})
+}
+
+func TestParseConfigVolumes(t *testing.T) {
+	data := []byte(`{
+  "name": "myapp-abc1234",
+  "services": {"db": {"image": "postgres:16-alpine"}},
+  "volumes": {
+    "db-data": {"name": "myapp-abc1234_db-data"},
+    "shared": {"name": "shared-cache", "external": true},
+    "legacy": {}
+  }
+}`)
+
+	names, err := parseConfigVolumes(data, "myapp-abc1234")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"db-data": "myapp-abc1234_db-data",
+		"shared":  "shared-cache",
+		"legacy":  "myapp-abc1234_legacy",
+	}, names)
+
+	_, err = parseConfigVolumes([]byte("volumes: {}"), "myapp-abc1234")
+	assert.Error(t, err)
+}
+
+func TestParseServiceStatus(t *testing.T) {
+	output := []byte("myapp-abc1234-web-1\tUp 5 minutes\thealthy\tweb\n" +
+		"myapp-abc1234-worker-1\tUp 5 minutes\t\tworker\n" +
+		"\n" +
+		"old-format-1\tExited (1)\n")
+
+	assert.Equal(t, []ServiceStatus{
+		{Name: "myapp-abc1234-web-1", Service: "web", Status: "Up 5 minutes", Health: "healthy"},
+		{Name: "myapp-abc1234-worker-1", Service: "worker", Status: "Up 5 minutes"},
+		{Name: "old-format-1", Status: "Exited (1)"},
+	}, parseServiceStatus(output))
+}
@@ -0,0 +1,171 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/target"
+)
+
+// ContainerStats is a point-in-time resource usage sample of one container.
+type ContainerStats struct {
+	Name         string // Container name
+	Service      string // Compose service the container belongs to
+	CPUPercent   float64
+	MemUsage     uint64 // Bytes
+	MemLimit     uint64 // Bytes; the host's memory when the container has no limit
+	MemPercent   float64
+	NetRx        uint64 // Bytes received
+	NetTx        uint64 // Bytes sent
+	BlockRead    uint64 // Bytes
+	BlockWrite   uint64 // Bytes
+	RestartCount int
+	StartedAt    time.Time
+}
+
+// Uptime returns how long the container has been running at now.
+func (s ContainerStats) Uptime(now time.Time) time.Duration {
+	if s.StartedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(s.StartedAt)
+}
+
+// GetProjectStats samples the resource usage of the running containers of a
+// compose project. It returns nil if the project has no running containers.
+func GetProjectStats(ctx context.Context, projectName string) ([]ContainerStats, error) {
+	output, err := target.Docker(ctx, "compose", "-p", projectName, "ps", "-q").Output()
+	if err != nil {
+		return nil, fmt.Errorf("compose ps failed: %w", err)
+	}
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	inspectOutput, err := target.Docker(ctx, append([]string{"inspect", "--format", containerInfoFormat}, ids...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker inspect failed: %w", err)
+	}
+
+	statsOutput, err := target.Docker(ctx, append([]string{"stats", "--no-stream", "--format", "{{json .}}"}, ids...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker stats failed: %w", err)
+	}
+
+	return parseContainerStats(statsOutput, inspectOutput)
+}
+
+// containerInfoFormat is the docker inspect format read by parseContainerStats.
+const containerInfoFormat = `{{.Name}}{{"\t"}}{{.RestartCount}}{{"\t"}}{{.State.StartedAt}}{{"\t"}}{{index .Config.Labels "com.docker.compose.service"}}`
+
+// dockerStatsLine is one line of docker stats --format '{{json .}}' output.
+type dockerStatsLine struct {
+	Name     string `json:"Name"`
+	CPUPerc  string `json:"CPUPerc"`
+	MemUsage string `json:"MemUsage"`
+	MemPerc  string `json:"MemPerc"`
+	NetIO    string `json:"NetIO"`
+	BlockIO  string `json:"BlockIO"`
+}
+
+// parseContainerStats combines docker stats output with docker inspect output
+// in containerInfoFormat, matching containers by name.
+func parseContainerStats(statsOutput, inspectOutput []byte) ([]ContainerStats, error) {
+	type info struct {
+		service   string
+		restarts  int
+		startedAt time.Time
+	}
+	infos := make(map[string]info)
+	for _, line := range strings.Split(strings.TrimSpace(string(inspectOutput)), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) < 4 {
+			continue
+		}
+		restarts, _ := strconv.Atoi(parts[1])
+		startedAt, _ := time.Parse(time.RFC3339Nano, parts[2])
+		infos[strings.TrimPrefix(parts[0], "/")] = info{service: parts[3], restarts: restarts, startedAt: startedAt}
+	}
+
+	var stats []ContainerStats
+	for _, line := range bytes.Split(bytes.TrimSpace(statsOutput), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var raw dockerStatsLine
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse docker stats output: %w", err)
+		}
+
+		s := ContainerStats{
+			Name:       raw.Name,
+			CPUPercent: parsePercent(raw.CPUPerc),
+			MemPercent: parsePercent(raw.MemPerc),
+		}
+		s.MemUsage, s.MemLimit = parseSizePair(raw.MemUsage)
+		s.NetRx, s.NetTx = parseSizePair(raw.NetIO)
+		s.BlockRead, s.BlockWrite = parseSizePair(raw.BlockIO)
+		if i, ok := infos[raw.Name]; ok {
+			s.Service = i.service
+			s.RestartCount = i.restarts
+			s.StartedAt = i.startedAt
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// parsePercent parses a docker stats percentage such as "12.34%". Values
+// docker cannot report, shown as "--", parse as zero.
+func parsePercent(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	return v
+}
+
+// parseSizePair parses a docker stats pair such as "12.5MiB / 128MiB".
+func parseSizePair(s string) (uint64, uint64) {
+	first, second, _ := strings.Cut(s, "/")
+	return parseSize(first), parseSize(second)
+}
+
+// sizeUnits maps the unit suffixes docker stats uses to their multipliers.
+// Memory is reported in binary units, network and block I/O in decimal ones.
+var sizeUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// parseSize parses a docker stats size such as "12.5MiB" or "648B" into
+// bytes. Unparseable sizes are zero.
+func parseSize(s string) uint64 {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i <= 0 {
+		return 0
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0
+	}
+	return uint64(math.Round(v * unit))
+}
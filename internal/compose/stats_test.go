@@ -0,0 +1,51 @@
+package compose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContainerStats(t *testing.T) {
+	statsOutput := []byte(`{"BlockIO":"4.1MB / 0B","CPUPerc":"0.52%","Container":"1a2b","ID":"1a2b","MemPerc":"9.77%","MemUsage":"12.5MiB / 128MiB","Name":"myapp-abc1234-web-1","NetIO":"1.2kB / 648B","PIDs":"3"}
+{"BlockIO":"0B / 0B","CPUPerc":"--","Container":"3c4d","ID":"3c4d","MemPerc":"--","MemUsage":"-- / --","Name":"myapp-abc1234-db-1","NetIO":"0B / 0B","PIDs":"0"}
+`)
+	inspectOutput := []byte("/myapp-abc1234-web-1\t2\t2026-10-18T09:00:00.123456789Z\tweb\n" +
+		"/myapp-abc1234-db-1\t0\t0001-01-01T00:00:00Z\tdb\n")
+
+	stats, err := parseContainerStats(statsOutput, inspectOutput)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	web := stats[0]
+	assert.Equal(t, "myapp-abc1234-web-1", web.Name)
+	assert.Equal(t, "web", web.Service)
+	assert.InDelta(t, 0.52, web.CPUPercent, 0.001)
+	assert.InDelta(t, 9.77, web.MemPercent, 0.001)
+	assert.Equal(t, uint64(12.5*(1<<20)), web.MemUsage)
+	assert.Equal(t, uint64(128<<20), web.MemLimit)
+	assert.Equal(t, uint64(1200), web.NetRx)
+	assert.Equal(t, uint64(648), web.NetTx)
+	assert.Equal(t, uint64(4100000), web.BlockRead)
+	assert.Equal(t, 2, web.RestartCount)
+	assert.Equal(t, 30*time.Minute, web.Uptime(time.Date(2026, 10, 18, 9, 30, 0, 123456789, time.UTC)))
+
+	db := stats[1]
+	assert.Equal(t, "db", db.Service)
+	assert.Zero(t, db.CPUPercent, "unavailable values are zero")
+	assert.Zero(t, db.MemLimit)
+
+	_, err = parseContainerStats([]byte("not json"), nil)
+	assert.Error(t, err)
+}
+
+func TestParseSize(t *testing.T) {
+	assert.Equal(t, uint64(0), parseSize("0B"))
+	assert.Equal(t, uint64(648), parseSize("648B"))
+	assert.Equal(t, uint64(1536), parseSize("1.5KiB"))
+	assert.Equal(t, uint64(2e9), parseSize(" 2GB "))
+	assert.Equal(t, uint64(0), parseSize("--"))
+	assert.Equal(t, uint64(0), parseSize("12XB"))
+}
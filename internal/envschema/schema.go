@@ -0,0 +1,286 @@
+// Package envschema loads and enforces the environment variable schema a
+// repository ships alongside its compose file.
+//
+// The schema lives at .otterstack/env.schema.yaml in the repository root:
+//
+//	variables:
+//	  DATABASE_URL:
+//	    type: url
+//	    required: true
+//	    secret: true
+//	    description: Primary Postgres connection string
+//	  LOG_LEVEL:
+//	    default: info
+//	    enum: [debug, info, warn, error]
+//	  RELEASE_TAG:
+//	    pattern: '^v[0-9]+\.[0-9]+\.[0-9]+$'
+//
+// Because the file is read from each deployment's worktree, every commit is
+// validated against the contract it was written for.
+package envschema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the schema location relative to the repository root.
+const FileName = ".otterstack/env.schema.yaml"
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Schema is the set of variables declared by a repository.
+type Schema struct {
+	Variables map[string]*Variable `yaml:"variables"`
+}
+
+// Variable declares the contract for a single environment variable.
+type Variable struct {
+	Name        string   `yaml:"-"`
+	Type        Type     `yaml:"type"`
+	Required    bool     `yaml:"required"`
+	Default     *string  `yaml:"default"`
+	Pattern     string   `yaml:"pattern"`
+	Enum        []string `yaml:"enum"`
+	Description string   `yaml:"description"`
+	Secret      bool     `yaml:"secret"`
+
+	pattern *regexp.Regexp
+}
+
+// Violation describes a stored value that does not satisfy the schema.
+// The offending value is deliberately not included so secrets are never echoed.
+type Violation struct {
+	Name    string
+	Message string
+}
+
+// Load reads the schema from a repository directory.
+// Returns nil and no error when the repository does not ship a schema.
+func Load(dir string) (*Schema, error) {
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read env schema: %w", err)
+	}
+
+	schema, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid env schema %s: %w", FileName, err)
+	}
+	return schema, nil
+}
+
+// Parse decodes and checks a schema document.
+// Unknown keys are rejected so typos like "requried" do not silently weaken the contract.
+func Parse(data []byte) (*Schema, error) {
+	schema := &Schema{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(schema); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if schema.Variables == nil {
+		schema.Variables = map[string]*Variable{}
+	}
+
+	for name, v := range schema.Variables {
+		if !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		if v == nil {
+			v = &Variable{}
+			schema.Variables[name] = v
+		}
+		v.Name = name
+
+		if v.Type == "" {
+			v.Type = TypeString
+		}
+		if !v.Type.Valid() {
+			return nil, fmt.Errorf("%s: unknown type %q", name, v.Type)
+		}
+
+		if v.Pattern != "" {
+			re, err := regexp.Compile(v.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern: %w", name, err)
+			}
+			v.pattern = re
+		}
+
+		for _, option := range v.Enum {
+			if err := CheckType(v.Type, option); err != nil {
+				return nil, fmt.Errorf("%s: enum value %q: %w", name, option, err)
+			}
+		}
+
+		if v.Default != nil {
+			if err := v.Check(*v.Default); err != nil {
+				return nil, fmt.Errorf("%s: default does not satisfy its own constraints: %w", name, err)
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// Lookup returns the declaration for a variable, if any. Safe to call on a nil schema.
+func (s *Schema) Lookup(name string) (*Variable, bool) {
+	if s == nil {
+		return nil, false
+	}
+	v, ok := s.Variables[name]
+	return v, ok
+}
+
+// Names returns the declared variable names in sorted order.
+func (s *Schema) Names() []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, 0, len(s.Variables))
+	for name := range s.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check validates a value against the variable's type, enum and pattern.
+// Empty values pass; use Required to decide whether a value must be present.
+func (v *Variable) Check(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if err := CheckType(v.Type, value); err != nil {
+		return err
+	}
+
+	if len(v.Enum) > 0 {
+		found := false
+		for _, option := range v.Enum {
+			if value == option {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of: %s", strings.Join(v.Enum, ", "))
+		}
+	}
+
+	if v.pattern != nil && !v.pattern.MatchString(value) {
+		return fmt.Errorf("must match pattern %s", v.Pattern)
+	}
+
+	return nil
+}
+
+// NeedsValue reports whether the variable must be provided by the user.
+func (v *Variable) NeedsValue() bool {
+	return v.Required && v.Default == nil
+}
+
+// Validate checks stored values against their declarations.
+// Variables that are unset or not declared are skipped; missing values are
+// reported through Apply and compose.GetMissingVars instead.
+func (s *Schema) Validate(vars map[string]string) []Violation {
+	var violations []Violation
+	for _, name := range s.Names() {
+		value, ok := vars[name]
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.Variables[name].Check(value); err != nil {
+			violations = append(violations, Violation{Name: name, Message: err.Error()})
+		}
+	}
+	return violations
+}
+
+// ApplyDefaults returns a copy of vars with declared defaults filled in for unset variables.
+func (s *Schema) ApplyDefaults(vars map[string]string) map[string]string {
+	merged := make(map[string]string, len(vars))
+	for k, v := range vars {
+		merged[k] = v
+	}
+	if s == nil {
+		return merged
+	}
+	for name, v := range s.Variables {
+		if v.Default == nil || merged[name] != "" {
+			continue
+		}
+		merged[name] = *v.Default
+	}
+	return merged
+}
+
+// Apply folds the schema into references parsed from a compose file.
+//
+// A declaration overrides what compose interpolation implies: its required flag
+// and default decide whether a value is needed, and its description stands in
+// for a missing error message. Declared variables that the compose file never
+// references (for example ones read by the application through env_file) are
+// appended so they are collected and validated too. Returns refs unchanged when
+// the schema is nil.
+func (s *Schema) Apply(refs []compose.EnvVarReference) []compose.EnvVarReference {
+	if s == nil {
+		return refs
+	}
+
+	result := make([]compose.EnvVarReference, 0, len(refs)+len(s.Variables))
+	seen := make(map[string]bool, len(refs))
+
+	for _, ref := range refs {
+		seen[ref.Name] = true
+		if v, ok := s.Variables[ref.Name]; ok {
+			ref = v.applyTo(ref)
+		}
+		result = append(result, ref)
+	}
+
+	for _, name := range s.Names() {
+		if seen[name] {
+			continue
+		}
+		result = append(result, s.Variables[name].applyTo(compose.EnvVarReference{Name: name}))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (v *Variable) applyTo(ref compose.EnvVarReference) compose.EnvVarReference {
+	// Per-service usages describe compose fallbacks, which the declaration replaces.
+	ref.Usages = nil
+	ref.IsRequired = v.NeedsValue()
+	ref.HasDefault = !ref.IsRequired
+	ref.DefaultValue = ""
+	if v.Default != nil {
+		ref.DefaultValue = *v.Default
+	}
+	if ref.ErrorMessage == "" {
+		ref.ErrorMessage = v.Description
+	}
+	return ref
+}
@@ -0,0 +1,195 @@
+package envschema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+variables:
+  DATABASE_URL:
+    type: url
+    required: true
+    secret: true
+    description: Primary database
+  LOG_LEVEL:
+    default: info
+    enum: [debug, info, warn, error]
+  PORT:
+    type: port
+    default: "3000"
+  RELEASE_TAG:
+    pattern: '^v[0-9]+\.[0-9]+\.[0-9]+$'
+  WORKER_TOKEN:
+    required: true
+`
+
+func TestLoad(t *testing.T) {
+	t.Run("missing schema", func(t *testing.T) {
+		schema, err := Load(t.TempDir())
+		require.NoError(t, err)
+		assert.Nil(t, schema)
+	})
+
+	t.Run("schema in repository", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, ".otterstack"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(testSchema), 0644))
+
+		schema, err := Load(dir)
+		require.NoError(t, err)
+		require.NotNil(t, schema)
+		assert.Equal(t, []string{"DATABASE_URL", "LOG_LEVEL", "PORT", "RELEASE_TAG", "WORKER_TOKEN"}, schema.Names())
+
+		v, ok := schema.Lookup("DATABASE_URL")
+		require.True(t, ok)
+		assert.Equal(t, TypeURL, v.Type)
+		assert.True(t, v.Secret)
+		assert.True(t, v.NeedsValue())
+
+		v, ok = schema.Lookup("LOG_LEVEL")
+		require.True(t, ok)
+		assert.Equal(t, TypeString, v.Type, "type defaults to string")
+		assert.False(t, v.NeedsValue())
+	})
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"unknown key", "variables:\n  A:\n    requried: true\n"},
+		{"unknown type", "variables:\n  A:\n    type: float\n"},
+		{"invalid name", "variables:\n  1A: {}\n"},
+		{"bad pattern", "variables:\n  A:\n    pattern: '('\n"},
+		{"enum value of wrong type", "variables:\n  A:\n    type: port\n    enum: [http]\n"},
+		{"default violates enum", "variables:\n  A:\n    default: x\n    enum: [a, b]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.schema))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	schema, err := Parse([]byte(""))
+	require.NoError(t, err)
+	assert.Empty(t, schema.Names())
+}
+
+func TestVariable_Check(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		varName string
+		value   string
+		wantErr string
+	}{
+		{"valid url", "DATABASE_URL", "postgres://db/app", ""},
+		{"invalid url", "DATABASE_URL", "not a url", "invalid URL format"},
+		{"enum match", "LOG_LEVEL", "warn", ""},
+		{"enum mismatch", "LOG_LEVEL", "verbose", "must be one of: debug, info, warn, error"},
+		{"port out of range", "PORT", "70000", "port must be between 1 and 65535"},
+		{"pattern match", "RELEASE_TAG", "v1.2.3", ""},
+		{"pattern mismatch", "RELEASE_TAG", "1.2", "must match pattern"},
+		{"empty always passes", "PORT", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := schema.Lookup(tt.varName)
+			require.True(t, ok)
+
+			err := v.Check(tt.value)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+
+	violations := schema.Validate(map[string]string{
+		"DATABASE_URL": "s3cr3t-not-a-url",
+		"LOG_LEVEL":    "info",
+		"PORT":         "",
+		"UNDECLARED":   "anything",
+	})
+
+	require.Len(t, violations, 1)
+	assert.Equal(t, "DATABASE_URL", violations[0].Name)
+	assert.NotContains(t, violations[0].Message, "s3cr3t", "values must not leak into messages")
+}
+
+func TestSchema_ApplyDefaults(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+
+	stored := map[string]string{"PORT": "8080"}
+	merged := schema.ApplyDefaults(stored)
+
+	assert.Equal(t, "8080", merged["PORT"], "stored values win")
+	assert.Equal(t, "info", merged["LOG_LEVEL"])
+	assert.NotContains(t, merged, "DATABASE_URL")
+	assert.Len(t, stored, 1, "input map is not modified")
+
+	var nilSchema *Schema
+	assert.Equal(t, stored, nilSchema.ApplyDefaults(stored))
+}
+
+func TestSchema_Apply(t *testing.T) {
+	schema, err := Parse([]byte(testSchema))
+	require.NoError(t, err)
+
+	refs := []compose.EnvVarReference{
+		// Compose supplies a fallback, but the schema marks it required
+		{Name: "DATABASE_URL", HasDefault: true, DefaultValue: "sqlite://", Locations: []string{"web"},
+			Usages: []compose.EnvVarUsage{{Service: "web", HasDefault: true}}},
+		// Compose has no fallback, but the schema declares a default
+		{Name: "PORT", Locations: []string{"web"}, Usages: []compose.EnvVarUsage{{Service: "web"}}},
+		// Not declared in the schema
+		{Name: "OTHER", Locations: []string{"web"}, Usages: []compose.EnvVarUsage{{Service: "web"}}},
+	}
+
+	applied := schema.Apply(refs)
+
+	byName := make(map[string]compose.EnvVarReference)
+	var names []string
+	for _, ref := range applied {
+		byName[ref.Name] = ref
+		names = append(names, ref.Name)
+	}
+
+	assert.Equal(t, []string{"DATABASE_URL", "LOG_LEVEL", "OTHER", "PORT", "RELEASE_TAG", "WORKER_TOKEN"}, names)
+
+	assert.True(t, byName["DATABASE_URL"].NeedsValue())
+	assert.Equal(t, "Primary database", byName["DATABASE_URL"].ErrorMessage)
+	assert.Equal(t, []string{"web"}, byName["DATABASE_URL"].Locations)
+
+	assert.False(t, byName["PORT"].NeedsValue())
+	assert.Equal(t, "3000", byName["PORT"].DefaultValue)
+
+	assert.True(t, byName["OTHER"].NeedsValue(), "undeclared references keep compose semantics")
+	assert.True(t, byName["WORKER_TOKEN"].NeedsValue(), "declared-only variables are added")
+	assert.False(t, byName["RELEASE_TAG"].NeedsValue())
+
+	var nilSchema *Schema
+	assert.Equal(t, refs, nilSchema.Apply(refs))
+}
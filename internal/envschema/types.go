@@ -0,0 +1,83 @@
+package envschema
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Type is the declared type of an environment variable.
+type Type string
+
+const (
+	TypeString  Type = "string"
+	TypeURL     Type = "url"
+	TypeEmail   Type = "email"
+	TypePort    Type = "port"
+	TypeInteger Type = "integer"
+	TypeBoolean Type = "boolean"
+)
+
+// booleanValues lists the accepted spellings of a boolean value.
+var booleanValues = []string{"true", "false", "yes", "no", "1", "0", "t", "f", "y", "n"}
+
+// Valid reports whether t is a known type.
+func (t Type) Valid() bool {
+	switch t {
+	case TypeString, TypeURL, TypeEmail, TypePort, TypeInteger, TypeBoolean:
+		return true
+	}
+	return false
+}
+
+// CheckType validates a non-empty value against a type.
+// Empty values are accepted; whether a value may be empty is decided by Required.
+func CheckType(t Type, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch t {
+	case TypeURL:
+		u, err := url.ParseRequestURI(value)
+		if err != nil {
+			return fmt.Errorf("invalid URL format")
+		}
+		// Check that it has a scheme (http, https, etc.)
+		if u.Scheme == "" {
+			return fmt.Errorf("URL must include a scheme (e.g., https://)")
+		}
+
+	case TypeEmail:
+		if _, err := mail.ParseAddress(value); err != nil {
+			return fmt.Errorf("invalid email format")
+		}
+
+	case TypePort:
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("port must be a number")
+		}
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("port must be between 1 and 65535")
+		}
+
+	case TypeInteger:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("must be a valid integer")
+		}
+
+	case TypeBoolean:
+		lower := strings.ToLower(strings.TrimSpace(value))
+		for _, valid := range booleanValues {
+			if lower == valid {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be true/false, yes/no, or 1/0")
+	}
+
+	return nil
+}
@@ -67,11 +67,100 @@ var (
 
 	// ErrNoPreviousDeployment indicates there is no previous deployment to rollback to.
 	ErrNoPreviousDeployment = errors.New("no previous deployment to rollback to")
+
+	// ErrHookFailed indicates a blocking deploy hook failed.
+	ErrHookFailed = errors.New("deploy hook failed")
+
+	// ErrOperationLogNotFound indicates the requested operation log does not exist.
+	ErrOperationLogNotFound = errors.New("operation log not found")
+
+	// ErrNoVolumeSnapshot indicates no volume snapshot can be restored for a rollback.
+	ErrNoVolumeSnapshot = errors.New("no volume snapshot to restore")
+
+	// ErrHealthCheckFailed indicates the new containers did not become healthy.
+	ErrHealthCheckFailed = errors.New("health check failed")
+
+	// ErrInsufficientResources indicates the host lacks the free disk or memory a deployment requires.
+	ErrInsufficientResources = errors.New("insufficient free resources")
+)
+
+// Host errors
+var (
+	// ErrHostNotFound indicates the requested remote host is not registered.
+	ErrHostNotFound = errors.New("host not found")
+
+	// ErrHostExists indicates a host with the given name is already registered.
+	ErrHostExists = errors.New("host already exists")
+
+	// ErrHostInUse indicates a host cannot be removed while projects deploy to it.
+	ErrHostInUse = errors.New("host is used by a project")
+
+	// ErrInvalidHostName indicates the host name does not match validation rules.
+	ErrInvalidHostName = errors.New("invalid host name: must be lowercase alphanumeric with hyphens, 1-64 characters, and not \"local\"")
 )
 
 // Environment variable errors
 var (
 	// ErrInvalidEnvKey indicates the environment variable key is invalid.
 	ErrInvalidEnvKey = errors.New("invalid environment variable key: must start with letter or underscore, contain only letters, numbers, and underscores")
+
+	// ErrInvalidPassphrase indicates encrypted environment variables could not be decrypted with the given passphrase.
+	ErrInvalidPassphrase = errors.New("invalid passphrase")
+
+	// ErrMissingEnvVars indicates variables the compose file requires are not set.
+	ErrMissingEnvVars = errors.New("missing required environment variables")
+
+	// ErrInvalidEnvVars indicates stored values violate the env schema.
+	ErrInvalidEnvVars = errors.New("invalid environment variables")
 )
 
+
+// State store errors
+var (
+	// ErrSchemaTooNew indicates the database was migrated by a newer version of OtterStack.
+	ErrSchemaTooNew = errors.New("database schema is newer than this version of otterstack")
+
+	// ErrMigrationModified indicates an applied migration no longer matches its recorded checksum.
+	ErrMigrationModified = errors.New("applied migration was modified after it ran")
+)
+
+// Schedule errors
+var (
+	// ErrScheduleNotFound indicates the project has no deploy schedule.
+	ErrScheduleNotFound = errors.New("no deploy schedule")
+
+	// ErrNoMatchingTag indicates no semver tag matches a schedule's tag pattern.
+	ErrNoMatchingTag = errors.New("no semver tag matches the pattern")
+)
+
+// Deploy queue errors
+var (
+	// ErrQueuedDeployNotFound indicates no queued deploy has the given ID.
+	ErrQueuedDeployNotFound = errors.New("queued deploy not found")
+
+	// ErrQueuedDeployCancelled indicates a queued deploy was cancelled before it ran.
+	ErrQueuedDeployCancelled = errors.New("queued deploy was cancelled")
+
+	// ErrDeploySuperseded indicates a newer deploy of the same project and
+	// hosts replaced a queued deploy before it ran.
+	ErrDeploySuperseded = errors.New("deploy was superseded by a newer deploy")
+)
+
+// WithMessage returns an error that reads msg but still matches err with
+// errors.Is and errors.As, for reporting a sentinel in the caller's words.
+func WithMessage(err error, msg string) error {
+	return &messageError{msg: msg, err: err}
+}
+
+type messageError struct {
+	msg string
+	err error
+}
+
+func (e *messageError) Error() string {
+	return e.msg
+}
+
+func (e *messageError) Unwrap() error {
+	return e.err
+}
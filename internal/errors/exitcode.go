@@ -0,0 +1,140 @@
+package errors
+
+import (
+	"context"
+	"errors"
+)
+
+// Exit codes of the otterstack command. Failures without a more specific
+// code exit with ExitFailure. Codes are grouped by area in steps of ten and
+// never reused, so scripts can rely on them across versions.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+
+	// Invalid input
+	ExitInvalidInput = 2 // Invalid project name, host name or env var key
+
+	// Projects
+	ExitProjectNotFound = 10
+	ExitProjectLocked   = 11 // Another operation holds the project lock; safe to retry
+	ExitProjectExists   = 12
+
+	// Git
+	ExitGitRefNotFound = 20
+	ExitGitFailed      = 21 // git missing, not a repository, or clone, fetch or worktree failed
+
+	// Compose
+	ExitComposeInvalid     = 30 // Compose file invalid or not found
+	ExitComposeTimeout     = 31
+	ExitComposeUnavailable = 32 // docker compose is not installed
+
+	// Environment variables
+	ExitMissingEnvVars    = 40
+	ExitInvalidEnvVars    = 41
+	ExitInvalidPassphrase = 42
+
+	// Deployment
+	ExitHealthCheckFailed     = 50
+	ExitHookFailed            = 51
+	ExitInsufficientResources = 52 // Free disk or memory below the configured minimum
+
+	// Deployment history
+	ExitDeploymentNotFound   = 60 // Deployment or its operation log not found
+	ExitNoActiveDeployment   = 61
+	ExitNoPreviousDeployment = 62
+	ExitNoVolumeSnapshot     = 63
+
+	// Hosts
+	ExitHostNotFound = 70
+	ExitHostExists   = 71
+	ExitHostInUse    = 72
+
+	// State database
+	ExitStateIncompatible = 80 // Schema too new or an applied migration was modified
+
+	// Schedules
+	ExitScheduleNotFound = 90
+	ExitNoMatchingTag    = 91
+
+	// Deploy queue
+	ExitQueuedDeployNotFound  = 100
+	ExitQueuedDeployCancelled = 101
+	ExitDeploySuperseded      = 102 // A newer deploy replaced it before it ran; nothing was deployed
+
+	// ExitCancelled follows the shell convention for SIGINT.
+	ExitCancelled = 130
+)
+
+// exitCodes maps sentinels to exit codes. The first match wins: after a
+// cancelled command, lock contention comes first so a failure that is safe to
+// retry is never reported as something else.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{context.Canceled, ExitCancelled},
+	{ErrProjectLocked, ExitProjectLocked},
+
+	{ErrInvalidProjectName, ExitInvalidInput},
+	{ErrInvalidHostName, ExitInvalidInput},
+	{ErrInvalidEnvKey, ExitInvalidInput},
+
+	{ErrProjectNotFound, ExitProjectNotFound},
+	{ErrProjectExists, ExitProjectExists},
+
+	{ErrGitRefNotFound, ExitGitRefNotFound},
+	{ErrGitNotFound, ExitGitFailed},
+	{ErrNotGitRepo, ExitGitFailed},
+	{ErrGitCloneFailed, ExitGitFailed},
+	{ErrGitFetchFailed, ExitGitFailed},
+	{ErrWorktreeCreateFailed, ExitGitFailed},
+	{ErrWorktreeRemoveFailed, ExitGitFailed},
+
+	{ErrComposeInvalid, ExitComposeInvalid},
+	{ErrComposeFileNotFound, ExitComposeInvalid},
+	{ErrComposeTimeout, ExitComposeTimeout},
+	{ErrComposeNotFound, ExitComposeUnavailable},
+
+	{ErrMissingEnvVars, ExitMissingEnvVars},
+	{ErrInvalidEnvVars, ExitInvalidEnvVars},
+	{ErrInvalidPassphrase, ExitInvalidPassphrase},
+
+	{ErrHealthCheckFailed, ExitHealthCheckFailed},
+	{ErrHookFailed, ExitHookFailed},
+	{ErrInsufficientResources, ExitInsufficientResources},
+
+	{ErrDeploymentNotFound, ExitDeploymentNotFound},
+	{ErrOperationLogNotFound, ExitDeploymentNotFound},
+	{ErrNoActiveDeployment, ExitNoActiveDeployment},
+	{ErrNoPreviousDeployment, ExitNoPreviousDeployment},
+	{ErrNoVolumeSnapshot, ExitNoVolumeSnapshot},
+
+	{ErrHostNotFound, ExitHostNotFound},
+	{ErrHostExists, ExitHostExists},
+	{ErrHostInUse, ExitHostInUse},
+
+	{ErrSchemaTooNew, ExitStateIncompatible},
+	{ErrMigrationModified, ExitStateIncompatible},
+
+	{ErrScheduleNotFound, ExitScheduleNotFound},
+	{ErrNoMatchingTag, ExitNoMatchingTag},
+
+	{ErrQueuedDeployNotFound, ExitQueuedDeployNotFound},
+	{ErrQueuedDeployCancelled, ExitQueuedDeployCancelled},
+	{ErrDeploySuperseded, ExitDeploySuperseded},
+}
+
+// ExitCode returns the exit code for err: ExitOK for nil, the code of the
+// first sentinel in its chain, or ExitFailure.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	for _, c := range exitCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ExitFailure
+}
@@ -0,0 +1,58 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"unknown error", errors.New("boom"), ExitFailure},
+		{"project not found", ErrProjectNotFound, ExitProjectNotFound},
+		{"wrapped lock", fmt.Errorf("failed to acquire deployment lock: %w", fmt.Errorf("%w: held by PID 42", ErrProjectLocked)), ExitProjectLocked},
+		{"compose invalid", fmt.Errorf("compose validation failed: %w", ErrComposeInvalid), ExitComposeInvalid},
+		{"compose timeout", fmt.Errorf("failed to start services: %w", ErrComposeTimeout), ExitComposeTimeout},
+		{"missing env vars", fmt.Errorf("%w (see above for details)", ErrMissingEnvVars), ExitMissingEnvVars},
+		{"health check", fmt.Errorf("%w: %w", ErrHealthCheckFailed, errors.New("health check timeout after 5m0s")), ExitHealthCheckFailed},
+		{"git ref", fmt.Errorf("failed to resolve ref %q: %w", "v9", fmt.Errorf("%w: v9", ErrGitRefNotFound)), ExitGitRefNotFound},
+		{"hook", fmt.Errorf("%w: pre_deploy hook %q: exit status 1", ErrHookFailed, "migrate"), ExitHookFailed},
+		{"superseded", WithMessage(ErrDeploySuperseded, "superseded by a newer deploy of v2"), ExitDeploySuperseded},
+		{"cancelled", fmt.Errorf("compose up cancelled: %w", context.Canceled), ExitCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExitCode(tt.err))
+		})
+	}
+}
+
+func TestExitCode_Distinct(t *testing.T) {
+	// Sentinels of different kinds must not share a code
+	seen := make(map[int]error)
+	for _, c := range exitCodes {
+		if other, ok := seen[c.code]; ok && c.code != ExitInvalidInput && c.code != ExitGitFailed &&
+			c.code != ExitComposeInvalid && c.code != ExitDeploymentNotFound && c.code != ExitStateIncompatible {
+			t.Errorf("%q and %q share exit code %d", other, c.err, c.code)
+		}
+		seen[c.code] = c.err
+		assert.NotEqual(t, ExitFailure, c.code)
+	}
+}
+
+func TestWithMessage(t *testing.T) {
+	err := WithMessage(ErrProjectNotFound, `project "myapp" not found`)
+
+	assert.EqualError(t, err, `project "myapp" not found`)
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+	assert.Equal(t, ExitProjectNotFound, ExitCode(fmt.Errorf("backup failed: %w", err)))
+}
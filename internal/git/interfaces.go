@@ -9,6 +9,9 @@ type GitOperations interface {
 	Clone(ctx context.Context, url string) error
 	Fetch(ctx context.Context) error
 	ResolveRef(ctx context.Context, ref string) (string, error)
+	ResolveBranch(ctx context.Context, branch string) (string, error)
+	ListBranches(ctx context.Context) ([]string, error)
+	ListTags(ctx context.Context) ([]string, error)
 	CreateWorktree(ctx context.Context, worktreePath, commit string) error
 	RemoveWorktree(ctx context.Context, worktreePath string) error
 	ListWorktrees(ctx context.Context) ([]WorktreeInfo, error)
@@ -17,6 +20,8 @@ type GitOperations interface {
 	GetRemoteURL(ctx context.Context) (string, error)
 	GetDefaultBranch(ctx context.Context) (string, error)
 	CommitExists(ctx context.Context, commit string) bool
+	Log(ctx context.Context, from, to string) ([]Commit, error)
+	Diff(ctx context.Context, from, to string, paths ...string) (string, error)
 }
 
 // Ensure Manager implements GitOperations
@@ -8,9 +8,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Manager handles git operations for a repository.
@@ -37,13 +42,19 @@ func (m *Manager) RepoPath() string {
 
 // IsGitRepo checks if the path is a valid git repository.
 func (m *Manager) IsGitRepo(ctx context.Context) bool {
+	ctx, done := m.operation(ctx, "is_git_repo")
+	defer done(nil)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "rev-parse", "--git-dir")
 	return cmd.Run() == nil
 }
 
 // Clone clones a repository from URL to the repo path.
 // Uses atomic clone with temp directory and rename to prevent partial clones.
-func (m *Manager) Clone(ctx context.Context, url string) error {
+func (m *Manager) Clone(ctx context.Context, url string) (err error) {
+	ctx, done := m.operation(ctx, "clone")
+	defer done(&err)
+
 	// Create temp directory for atomic clone
 	parentDir := filepath.Dir(m.repoPath)
 	if err := os.MkdirAll(parentDir, 0755); err != nil {
@@ -102,7 +113,10 @@ func CheckAuth(ctx context.Context, url string) error {
 }
 
 // Fetch fetches from the remote repository.
-func (m *Manager) Fetch(ctx context.Context) error {
+func (m *Manager) Fetch(ctx context.Context) (err error) {
+	ctx, done := m.operation(ctx, "fetch")
+	defer done(&err)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "fetch", "--all", "--tags", "--prune")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -112,7 +126,10 @@ func (m *Manager) Fetch(ctx context.Context) error {
 }
 
 // ResolveRef resolves a git reference to a full SHA.
-func (m *Manager) ResolveRef(ctx context.Context, ref string) (string, error) {
+func (m *Manager) ResolveRef(ctx context.Context, ref string) (_ string, err error) {
+	ctx, done := m.operation(ctx, "resolve_ref")
+	defer done(&err)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "rev-parse", "--verify", ref+"^{commit}")
 	output, err := cmd.Output()
 	if err != nil {
@@ -126,6 +143,59 @@ func (m *Manager) ResolveRef(ctx context.Context, ref string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// ResolveBranch resolves a branch to a full SHA, preferring the remote-tracking
+// branch so a fetch is picked up even though the local branch is never updated.
+func (m *Manager) ResolveBranch(ctx context.Context, branch string) (_ string, err error) {
+	ctx, done := m.operation(ctx, "resolve_branch")
+	defer done(&err)
+
+	for _, ref := range []string{"refs/remotes/origin/" + branch, "refs/heads/" + branch} {
+		cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+		if output, err := cmd.Output(); err == nil {
+			return strings.TrimSpace(string(output)), nil
+		}
+	}
+	return "", fmt.Errorf("%w: branch %s", errors.ErrGitRefNotFound, branch)
+}
+
+// ListBranches returns the names of the branches in the repository: those
+// fetched from origin and local ones, without duplicates.
+func (m *Manager) ListBranches(ctx context.Context) (_ []string, err error) {
+	ctx, done := m.operation(ctx, "list_branches")
+	defer done(&err)
+
+	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "for-each-ref", "--format=%(refname)", "refs/remotes/origin", "refs/heads")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var branches []string
+	for _, ref := range strings.Fields(string(output)) {
+		name := strings.TrimPrefix(strings.TrimPrefix(ref, "refs/remotes/origin/"), "refs/heads/")
+		if name == "HEAD" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		branches = append(branches, name)
+	}
+	return branches, nil
+}
+
+// ListTags returns the names of all tags in the repository, highest version first.
+func (m *Manager) ListTags(ctx context.Context) (_ []string, err error) {
+	ctx, done := m.operation(ctx, "list_tags")
+	defer done(&err)
+
+	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "for-each-ref", "--sort=-v:refname", "--format=%(refname:short)", "refs/tags")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
 // ShortSHA returns the 7-character short SHA.
 func ShortSHA(fullSHA string) string {
 	if len(fullSHA) < 7 {
@@ -135,7 +205,10 @@ func ShortSHA(fullSHA string) string {
 }
 
 // CreateWorktree creates a new git worktree at the specified path for the given commit.
-func (m *Manager) CreateWorktree(ctx context.Context, worktreePath, commit string) error {
+func (m *Manager) CreateWorktree(ctx context.Context, worktreePath, commit string) (err error) {
+	ctx, done := m.operation(ctx, "create_worktree")
+	defer done(&err)
+
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(worktreePath), 0755); err != nil {
 		return fmt.Errorf("failed to create worktree parent directory: %w", err)
@@ -151,7 +224,10 @@ func (m *Manager) CreateWorktree(ctx context.Context, worktreePath, commit strin
 }
 
 // RemoveWorktree removes a worktree.
-func (m *Manager) RemoveWorktree(ctx context.Context, worktreePath string) error {
+func (m *Manager) RemoveWorktree(ctx context.Context, worktreePath string) (err error) {
+	ctx, done := m.operation(ctx, "remove_worktree")
+	defer done(&err)
+
 	// First try to remove with --force
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "worktree", "remove", "--force", worktreePath)
 	output, err := cmd.CombinedOutput()
@@ -168,7 +244,10 @@ func (m *Manager) RemoveWorktree(ctx context.Context, worktreePath string) error
 }
 
 // ListWorktrees returns a list of all worktrees for the repository.
-func (m *Manager) ListWorktrees(ctx context.Context) ([]WorktreeInfo, error) {
+func (m *Manager) ListWorktrees(ctx context.Context) (_ []WorktreeInfo, err error) {
+	ctx, done := m.operation(ctx, "list_worktrees")
+	defer done(&err)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "worktree", "list", "--porcelain")
 	output, err := cmd.Output()
 	if err != nil {
@@ -209,7 +288,10 @@ func (m *Manager) ListWorktrees(ctx context.Context) ([]WorktreeInfo, error) {
 }
 
 // PruneWorktrees removes worktree references for deleted directories.
-func (m *Manager) PruneWorktrees(ctx context.Context) error {
+func (m *Manager) PruneWorktrees(ctx context.Context) (err error) {
+	ctx, done := m.operation(ctx, "prune_worktrees")
+	defer done(&err)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "worktree", "prune")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -219,7 +301,10 @@ func (m *Manager) PruneWorktrees(ctx context.Context) error {
 }
 
 // GetCurrentCommit returns the current HEAD commit of the repository.
-func (m *Manager) GetCurrentCommit(ctx context.Context) (string, error) {
+func (m *Manager) GetCurrentCommit(ctx context.Context) (_ string, err error) {
+	ctx, done := m.operation(ctx, "get_current_commit")
+	defer done(&err)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "rev-parse", "HEAD")
 	output, err := cmd.Output()
 	if err != nil {
@@ -229,7 +314,10 @@ func (m *Manager) GetCurrentCommit(ctx context.Context) (string, error) {
 }
 
 // GetRemoteURL returns the remote URL of the repository.
-func (m *Manager) GetRemoteURL(ctx context.Context) (string, error) {
+func (m *Manager) GetRemoteURL(ctx context.Context) (_ string, err error) {
+	ctx, done := m.operation(ctx, "get_remote_u_r_l")
+	defer done(&err)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "remote", "get-url", "origin")
 	output, err := cmd.Output()
 	if err != nil {
@@ -239,7 +327,10 @@ func (m *Manager) GetRemoteURL(ctx context.Context) (string, error) {
 }
 
 // GetDefaultBranch returns the default branch name (main or master).
-func (m *Manager) GetDefaultBranch(ctx context.Context) (string, error) {
+func (m *Manager) GetDefaultBranch(ctx context.Context) (_ string, err error) {
+	ctx, done := m.operation(ctx, "get_default_branch")
+	defer done(&err)
+
 	// Try to get from remote HEAD
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "symbolic-ref", "refs/remotes/origin/HEAD")
 	output, err := cmd.Output()
@@ -270,6 +361,9 @@ func (m *Manager) GetDefaultBranch(ctx context.Context) (string, error) {
 
 // CommitExists checks if a commit exists in the repository.
 func (m *Manager) CommitExists(ctx context.Context, commit string) bool {
+	ctx, done := m.operation(ctx, "commit_exists")
+	defer done(nil)
+
 	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "cat-file", "-t", commit)
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
@@ -279,8 +373,78 @@ func (m *Manager) CommitExists(ctx context.Context, commit string) bool {
 	return strings.TrimSpace(stdout.String()) == "commit"
 }
 
+// Commit summarizes one commit.
+type Commit struct {
+	SHA     string
+	Author  string
+	Date    time.Time
+	Subject string
+}
+
+// Log returns the commits reachable from to but not from from, newest first.
+func (m *Manager) Log(ctx context.Context, from, to string) (_ []Commit, err error) {
+	ctx, done := m.operation(ctx, "log")
+	defer done(&err)
+
+	cmd := exec.CommandContext(ctx, "git", "-C", m.repoPath, "log", "--format=%H%x1f%an%x1f%at%x1f%s", from+".."+to, "--")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits between %s and %s: %w", ShortSHA(from), ShortSHA(to), err)
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		unix, _ := strconv.ParseInt(fields[2], 10, 64)
+		commits = append(commits, Commit{
+			SHA:     fields[0],
+			Author:  fields[1],
+			Date:    time.Unix(unix, 0),
+			Subject: fields[3],
+		})
+	}
+	return commits, nil
+}
+
+// Diff returns a unified diff between two commits, limited to paths if any are given.
+func (m *Manager) Diff(ctx context.Context, from, to string, paths ...string) (_ string, err error) {
+	ctx, done := m.operation(ctx, "diff")
+	defer done(&err)
+
+	args := append([]string{"-C", m.repoPath, "diff", from, to, "--"}, paths...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s and %s: %w", ShortSHA(from), ShortSHA(to), err)
+	}
+	return string(output), nil
+}
+
 // GetWorktreePath generates a worktree path for a given project and commit.
 func GetWorktreePath(dataDir, projectName, commit string) string {
 	shortSHA := ShortSHA(commit)
 	return filepath.Join(dataDir, "worktrees", projectName, shortSHA)
 }
+
+// operation traces and logs a git operation on the manager's repository. The
+// returned function ends it with the operation's error, if any.
+func (m *Manager) operation(ctx context.Context, name string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "git."+name, trace.WithAttributes(tracing.AttrRepo.String(m.repoPath)))
+	ctx = logging.WithFields(ctx, logging.String("repo", m.repoPath))
+	log := logging.FromContext(ctx)
+
+	return ctx, func(errp *error) {
+		tracing.EndErr(span, errp)
+		if errp != nil && *errp != nil {
+			log.Warn("git command failed", logging.String("operation", name),
+				logging.Duration("duration", time.Since(start)), logging.Error(*errp))
+			return
+		}
+		log.Debug("git command finished", logging.String("operation", name),
+			logging.Duration("duration", time.Since(start)))
+	}
+}
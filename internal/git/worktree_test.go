@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -106,6 +107,97 @@ func TestManager_ResolveRef(t *testing.T) {
 	})
 }
 
+func TestManager_ResolveBranch(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	origin := NewManager(repoPath)
+	first, err := origin.GetCurrentCommit(ctx)
+	require.NoError(t, err)
+
+	branch := strings.TrimSpace(gitOutput(t, repoPath, "branch", "--show-current"))
+
+	clonePath := filepath.Join(filepath.Dir(repoPath), "clone")
+	clone := NewManager(clonePath)
+	require.NoError(t, clone.Clone(ctx, repoPath))
+
+	// Advance the origin and fetch: the clone's local branch stays behind
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "CHANGES.md"), []byte("v2"), 0644))
+	gitOutput(t, repoPath, "add", ".")
+	gitOutput(t, repoPath, "commit", "-m", "Second commit")
+	second, err := origin.GetCurrentCommit(ctx)
+	require.NoError(t, err)
+	require.NoError(t, clone.Fetch(ctx))
+
+	t.Run("prefers the remote-tracking branch", func(t *testing.T) {
+		sha, err := clone.ResolveBranch(ctx, branch)
+		require.NoError(t, err)
+		assert.Equal(t, second, sha)
+
+		stale, err := clone.ResolveRef(ctx, branch)
+		require.NoError(t, err)
+		assert.Equal(t, first, stale)
+	})
+
+	t.Run("falls back to the local branch", func(t *testing.T) {
+		sha, err := origin.ResolveBranch(ctx, branch)
+		require.NoError(t, err)
+		assert.Equal(t, second, sha)
+	})
+
+	t.Run("unknown branch", func(t *testing.T) {
+		_, err := clone.ResolveBranch(ctx, "nonexistent")
+		assert.Error(t, err)
+	})
+}
+
+func TestManager_ListTags(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	manager := NewManager(repoPath)
+
+	tags, err := manager.ListTags(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	gitOutput(t, repoPath, "tag", "v1.0.0")
+	gitOutput(t, repoPath, "tag", "-a", "v1.1.0", "-m", "Release 1.1.0")
+
+	gitOutput(t, repoPath, "tag", "v1.10.0")
+
+	tags, err = manager.ListTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.10.0", "v1.1.0", "v1.0.0"}, tags, "highest version first")
+}
+
+func TestManager_ListBranches(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	branch := strings.TrimSpace(gitOutput(t, repoPath, "branch", "--show-current"))
+	gitOutput(t, repoPath, "branch", "feature")
+
+	clonePath := filepath.Join(filepath.Dir(repoPath), "clone")
+	clone := NewManager(clonePath)
+	require.NoError(t, clone.Clone(ctx, repoPath))
+
+	branches, err := clone.ListBranches(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{branch, "feature"}, branches, "remote and local branches without HEAD or duplicates")
+}
+
+// gitOutput runs a git command in a repository and fails the test on error.
+func gitOutput(t *testing.T, repoPath string, args ...string) string {
+	t.Helper()
+	output, err := exec.Command("git", append([]string{"-C", repoPath}, args...)...).CombinedOutput()
+	require.NoError(t, err, string(output))
+	return string(output)
+}
+
 func TestManager_CreateAndRemoveWorktree(t *testing.T) {
 	repoPath, cleanup := setupTestRepo(t)
 	defer cleanup()
@@ -203,3 +295,50 @@ func TestGetWorktreePath(t *testing.T) {
 	expected := filepath.Join("/data", "worktrees", "myproject", "abc123d")
 	assert.Equal(t, expected, path)
 }
+
+func TestManager_LogAndDiff(t *testing.T) {
+	repoPath, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	manager := NewManager(repoPath)
+	first := strings.TrimSpace(gitOutput(t, repoPath, "rev-parse", "HEAD"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "compose.yaml"), []byte("services:\n  web:\n    image: nginx:1\n"), 0644))
+	gitOutput(t, repoPath, "add", ".")
+	gitOutput(t, repoPath, "commit", "-m", "Add compose file")
+	require.NoError(t, os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Changed"), 0644))
+	gitOutput(t, repoPath, "commit", "-am", "Update readme")
+	last := strings.TrimSpace(gitOutput(t, repoPath, "rev-parse", "HEAD"))
+
+	t.Run("log lists new commits newest first", func(t *testing.T) {
+		commits, err := manager.Log(ctx, first, last)
+		require.NoError(t, err)
+		require.Len(t, commits, 2)
+		assert.Equal(t, last, commits[0].SHA)
+		assert.Equal(t, "Update readme", commits[0].Subject)
+		assert.Equal(t, "Test", commits[0].Author)
+		assert.False(t, commits[0].Date.IsZero())
+		assert.Equal(t, "Add compose file", commits[1].Subject)
+
+		commits, err = manager.Log(ctx, last, first)
+		require.NoError(t, err)
+		assert.Empty(t, commits, "nothing is reachable from an ancestor only")
+	})
+
+	t.Run("diff is limited to the given paths", func(t *testing.T) {
+		diff, err := manager.Diff(ctx, first, last, "compose.yaml")
+		require.NoError(t, err)
+		assert.Contains(t, diff, "+    image: nginx:1")
+		assert.NotContains(t, diff, "README.md")
+
+		diff, err = manager.Diff(ctx, first, last)
+		require.NoError(t, err)
+		assert.Contains(t, diff, "README.md")
+	})
+
+	t.Run("unknown commits fail", func(t *testing.T) {
+		_, err := manager.Log(ctx, first, "0000000000000000000000000000000000000000")
+		assert.Error(t, err)
+	})
+}
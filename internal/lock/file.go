@@ -8,10 +8,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gofrs/flock"
 	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/logging"
 )
 
 // Lock represents a file lock for a project.
@@ -22,6 +24,15 @@ type Lock struct {
 	project  string
 }
 
+// Slot is one of the slots of a counting lock, which lets a limited number
+// of holders in at once.
+type Slot struct {
+	flock   *flock.Flock
+	pidFile string
+	name    string
+	index   int
+}
+
 // Manager manages project locks.
 type Manager struct {
 	lockDir string
@@ -38,7 +49,14 @@ func NewManager(dataDir string) (*Manager, error) {
 
 // Acquire attempts to acquire a lock for a project.
 // It will check for stale locks and remove them before attempting to acquire.
+// It does not wait: if another operation holds the lock it fails at once with
+// ErrProjectLocked. Deploys that should wait their turn go through the deploy
+// queue instead.
 func (m *Manager) Acquire(ctx context.Context, project string) (*Lock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+
 	lockPath := filepath.Join(m.lockDir, project+".lock")
 	pidFile := filepath.Join(m.lockDir, project+".pid")
 
@@ -49,12 +67,16 @@ func (m *Manager) Acquire(ctx context.Context, project string) (*Lock, error) {
 
 	fl := flock.New(lockPath)
 
-	// Try to acquire lock with timeout
-	locked, err := fl.TryLockContext(ctx, 100*time.Millisecond)
+	log := logging.FromContext(ctx).With(logging.String("lock", project))
+	log.Debug("acquiring project lock")
+	start := time.Now()
+	locked, err := fl.TryLock()
 	if err != nil {
+		log.Warn("failed to acquire project lock", logging.Duration("duration", time.Since(start)), logging.Error(err))
 		return nil, fmt.Errorf("failed to acquire lock: %w", err)
 	}
 	if !locked {
+		log.Warn("project is locked by another operation", logging.Duration("duration", time.Since(start)))
 		// Check if another process holds it
 		if pid, err := readPIDFile(pidFile); err == nil {
 			return nil, fmt.Errorf("%w: held by PID %d", errors.ErrProjectLocked, pid)
@@ -67,6 +89,7 @@ func (m *Manager) Acquire(ctx context.Context, project string) (*Lock, error) {
 		fl.Unlock()
 		return nil, fmt.Errorf("failed to write PID file: %w", err)
 	}
+	log.Debug("acquired project lock", logging.Duration("duration", time.Since(start)))
 
 	return &Lock{
 		flock:    fl,
@@ -111,6 +134,80 @@ func (m *Manager) TryAcquire(project string) (*Lock, error) {
 	}, nil
 }
 
+// AcquireSlot takes one of max slots of the counting lock name, waiting until
+// a slot is free or ctx is done. A slot is freed when it is released or its
+// process exits, so slots need no stale detection.
+func (m *Manager) AcquireSlot(ctx context.Context, name string, max int) (*Slot, error) {
+	log := logging.FromContext(ctx).With(logging.String("lock", name), logging.Int("slots", max))
+	log.Debug("acquiring lock slot")
+	start := time.Now()
+	for {
+		slot, err := m.TryAcquireSlot(name, max)
+		if err != nil {
+			return nil, err
+		}
+		if slot != nil {
+			log.Debug("acquired lock slot", logging.Int("slot", slot.index), logging.Duration("duration", time.Since(start)))
+			return slot, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Warn("failed to acquire lock slot", logging.Duration("duration", time.Since(start)), logging.Error(ctx.Err()))
+			return nil, fmt.Errorf("failed to acquire %s slot: %w", name, ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// TryAcquireSlot takes a free slot of the counting lock name without waiting.
+// Returns nil if all max slots are taken.
+func (m *Manager) TryAcquireSlot(name string, max int) (*Slot, error) {
+	if max < 1 {
+		return nil, fmt.Errorf("invalid number of %s slots: %d", name, max)
+	}
+
+	for i := 0; i < max; i++ {
+		fl := flock.New(filepath.Join(m.lockDir, fmt.Sprintf("%s.slot%d.lock", name, i)))
+		locked, err := fl.TryLock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to try lock: %w", err)
+		}
+		if !locked {
+			continue
+		}
+
+		pidFile := filepath.Join(m.lockDir, fmt.Sprintf("%s.slot%d.pid", name, i))
+		if err := writePIDFile(pidFile); err != nil {
+			fl.Unlock()
+			return nil, fmt.Errorf("failed to write PID file: %w", err)
+		}
+		return &Slot{flock: fl, pidFile: pidFile, name: name, index: i}, nil
+	}
+	return nil, nil
+}
+
+// SlotHolders returns the PIDs of the processes holding slots of the
+// counting lock name, where they are known.
+func (m *Manager) SlotHolders(name string, max int) []int {
+	var pids []int
+	for i := 0; i < max; i++ {
+		fl := flock.New(filepath.Join(m.lockDir, fmt.Sprintf("%s.slot%d.lock", name, i)))
+		locked, err := fl.TryLock()
+		if err != nil {
+			continue
+		}
+		if locked {
+			fl.Unlock()
+			continue
+		}
+		if pid, err := readPIDFile(filepath.Join(m.lockDir, fmt.Sprintf("%s.slot%d.pid", name, i))); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
 // IsLocked checks if a project is currently locked.
 func (m *Manager) IsLocked(project string) (bool, int, error) {
 	lockPath := filepath.Join(m.lockDir, project+".lock")
@@ -179,6 +276,17 @@ func (l *Lock) Project() string {
 	return l.project
 }
 
+// Release frees the slot. The lock file is kept, since other processes may
+// be waiting on it.
+func (s *Slot) Release() error {
+	os.Remove(s.pidFile)
+
+	if err := s.flock.Unlock(); err != nil {
+		return fmt.Errorf("failed to release %s slot: %w", s.name, err)
+	}
+	return nil
+}
+
 // writePIDFile writes the current process PID to the given file.
 func writePIDFile(path string) error {
 	pid := os.Getpid()
@@ -198,6 +306,12 @@ func readPIDFile(path string) (int, error) {
 	return pid, nil
 }
 
+// ProcessRunning reports whether a process with the given PID is running on
+// this machine, assuming it is when that cannot be determined.
+func ProcessRunning(pid int) bool {
+	return isProcessRunning(pid)
+}
+
 // isProcessRunning checks if a process with the given PID is running.
 func isProcessRunning(pid int) bool {
 	proc, err := os.FindProcess(pid)
@@ -207,8 +321,7 @@ func isProcessRunning(pid int) bool {
 
 	// On Unix, FindProcess always succeeds. We need to send signal 0 to check.
 	// On Windows, FindProcess only succeeds if the process exists.
-	// We use a cross-platform approach by trying to get process info.
-	err = proc.Signal(os.Signal(nil))
+	err = proc.Signal(syscall.Signal(0))
 	if err == nil {
 		return true
 	}
@@ -2,11 +2,13 @@ package lock
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -143,6 +145,11 @@ func TestManager_StaleLockDetection(t *testing.T) {
 	lock.Release()
 }
 
+func TestIsProcessRunning(t *testing.T) {
+	assert.True(t, isProcessRunning(os.Getpid()))
+	assert.False(t, isProcessRunning(999999999))
+}
+
 func TestManager_ContextCancellation(t *testing.T) {
 	manager, cleanup := setupTestManager(t)
 	defer cleanup()
@@ -155,13 +162,102 @@ func TestManager_ContextCancellation(t *testing.T) {
 	require.NotNil(t, lock1)
 	defer lock1.Release()
 
-	// Try to acquire with a cancelled context
+	// Try to acquire with a context that has a deadline
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
 	defer cancel()
 
 	lock2, err := manager.Acquire(ctx, project)
-	assert.Error(t, err) // Should fail due to context timeout
+	assert.Error(t, err) // Should fail because the lock is held
 	assert.Nil(t, lock2)
+
+	// A cancelled context fails even if the lock is free
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	lock3, err := manager.Acquire(cancelled, "ctx-free")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, lock3)
+}
+
+func TestManager_AcquireContended(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Two managers open the lock file separately, like two processes
+	holder, err := NewManager(tmpDir)
+	require.NoError(t, err)
+	other, err := NewManager(tmpDir)
+	require.NoError(t, err)
+
+	held, err := holder.Acquire(context.Background(), "busy")
+	require.NoError(t, err)
+	defer held.Release()
+
+	start := time.Now()
+	lock, err := other.Acquire(context.Background(), "busy")
+	assert.Less(t, time.Since(start), time.Second, "Acquire should not wait for the holder")
+	assert.Nil(t, lock)
+	assert.ErrorIs(t, err, apperrors.ErrProjectLocked)
+	assert.Equal(t, apperrors.ExitProjectLocked, apperrors.ExitCode(err))
+	assert.Contains(t, err.Error(), fmt.Sprintf("held by PID %d", os.Getpid()))
+
+	require.NoError(t, held.Release())
+	lock, err = other.Acquire(context.Background(), "busy")
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
+
+func TestManager_Slots(t *testing.T) {
+	manager, cleanup := setupTestManager(t)
+	defer cleanup()
+
+	first, err := manager.TryAcquireSlot("deploys", 2)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	second, err := manager.TryAcquireSlot("deploys", 2)
+	require.NoError(t, err)
+	require.NotNil(t, second)
+	assert.NotEqual(t, first.index, second.index)
+
+	t.Run("full", func(t *testing.T) {
+		slot, err := manager.TryAcquireSlot("deploys", 2)
+		require.NoError(t, err)
+		assert.Nil(t, slot)
+		assert.Equal(t, []int{os.Getpid(), os.Getpid()}, manager.SlotHolders("deploys", 2))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		slot, err = manager.AcquireSlot(ctx, "deploys", 2)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, slot)
+	})
+
+	t.Run("released slots are taken again", func(t *testing.T) {
+		require.NoError(t, first.Release())
+		assert.Equal(t, []int{os.Getpid()}, manager.SlotHolders("deploys", 2))
+
+		slot, err := manager.AcquireSlot(context.Background(), "deploys", 2)
+		require.NoError(t, err)
+		assert.Equal(t, first.index, slot.index)
+		require.NoError(t, slot.Release())
+		require.NoError(t, second.Release())
+	})
+
+	t.Run("raising the limit adds slots", func(t *testing.T) {
+		var slots []*Slot
+		for i := 0; i < 3; i++ {
+			slot, err := manager.TryAcquireSlot("deploys", 3)
+			require.NoError(t, err)
+			require.NotNil(t, slot)
+			slots = append(slots, slot)
+		}
+		for _, slot := range slots {
+			require.NoError(t, slot.Release())
+		}
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		_, err := manager.TryAcquireSlot("deploys", 0)
+		assert.Error(t, err)
+	})
 }
 
 func TestReadWritePIDFile(t *testing.T) {
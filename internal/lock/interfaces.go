@@ -7,6 +7,8 @@ type LockOperations interface {
 	Acquire(ctx context.Context, project string) (*Lock, error)
 	TryAcquire(project string) (*Lock, error)
 	IsLocked(project string) (bool, int, error)
+	AcquireSlot(ctx context.Context, name string, max int) (*Slot, error)
+	TryAcquireSlot(name string, max int) (*Slot, error)
 }
 
 // Ensure Manager implements LockOperations
@@ -0,0 +1,24 @@
+package logging
+
+import "context"
+
+type contextKey struct{}
+
+// WithLogger adds a logger to the context.
+func WithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext retrieves the logger from context.
+// Returns NoOpLogger if no logger is found.
+func FromContext(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return logger
+	}
+	return NewNoOpLogger()
+}
+
+// WithFields returns a context whose logger has fields attached.
+func WithFields(ctx context.Context, fields ...Field) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(fields...))
+}
@@ -0,0 +1,120 @@
+// Package logging provides structured logging through a context-carried Logger.
+//
+// Logging is opt-in: FromContext returns a no-op logger unless one was added
+// with WithLogger. New builds a Logger on log/slog writing JSON or text.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Logger is the interface for structured logging in OtterStack.
+// Users can implement this interface to integrate their preferred logging library.
+type Logger interface {
+	// Debug logs a debug-level message with optional fields.
+	Debug(msg string, fields ...Field)
+
+	// Info logs an info-level message with optional fields.
+	Info(msg string, fields ...Field)
+
+	// Warn logs a warning-level message with optional fields.
+	Warn(msg string, fields ...Field)
+
+	// Error logs an error-level message with optional fields.
+	Error(msg string, fields ...Field)
+
+	// With returns a new Logger with additional fields attached.
+	// All subsequent log calls on the returned logger will include these fields.
+	With(fields ...Field) Logger
+}
+
+// Field represents a key-value pair for structured logging.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string field.
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int returns an integer field.
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Error returns the standard "error" field.
+func Error(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// Duration returns a duration field.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Any returns a field of any value.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Formats accepted by New.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// SlogLogger wraps slog.Logger to implement the Logger interface.
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger creates a logger that wraps slog.
+func NewSlogLogger(slogLogger *slog.Logger) Logger {
+	return &SlogLogger{logger: slogLogger}
+}
+
+// New creates a slog logger writing records at level and above to w, as
+// one JSON object per line or as logfmt-style text.
+func New(w io.Writer, format string, level slog.Level) (Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case FormatJSON:
+		return NewSlogLogger(slog.New(slog.NewJSONHandler(w, opts))), nil
+	case FormatText:
+		return NewSlogLogger(slog.New(slog.NewTextHandler(w, opts))), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q: must be %s or %s", format, FormatJSON, FormatText)
+}
+
+func (l *SlogLogger) Debug(msg string, fields ...Field) {
+	l.logger.Debug(msg, convertFields(fields)...)
+}
+
+func (l *SlogLogger) Info(msg string, fields ...Field) {
+	l.logger.Info(msg, convertFields(fields)...)
+}
+
+func (l *SlogLogger) Warn(msg string, fields ...Field) {
+	l.logger.Warn(msg, convertFields(fields)...)
+}
+
+func (l *SlogLogger) Error(msg string, fields ...Field) {
+	l.logger.Error(msg, convertFields(fields)...)
+}
+
+func (l *SlogLogger) With(fields ...Field) Logger {
+	return &SlogLogger{logger: l.logger.With(convertFields(fields)...)}
+}
+
+func convertFields(fields []Field) []any {
+	attrs := make([]any, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	return attrs
+}
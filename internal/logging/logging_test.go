@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	deploy := logger.With(String("project", "myapp"), String("deployment_id", "d1"))
+	deploy.Debug("below the level")
+	deploy.Info("phase finished", String("phase", "build"), Duration("duration", 2*time.Second), Int("attempt", 1))
+	deploy.Error("deployment failed", Error(errors.New("health check timeout")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var rec map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+	assert.Equal(t, "INFO", rec["level"])
+	assert.Equal(t, "phase finished", rec["msg"])
+	assert.Equal(t, "myapp", rec["project"])
+	assert.Equal(t, "d1", rec["deployment_id"])
+	assert.Equal(t, "build", rec["phase"])
+	assert.Equal(t, float64(2*time.Second), rec["duration"])
+	assert.Equal(t, float64(1), rec["attempt"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+	assert.Equal(t, "ERROR", rec["level"])
+	assert.Equal(t, "health check timeout", rec["error"])
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatText, slog.LevelDebug)
+	require.NoError(t, err)
+
+	logger.Debug("compose command finished", String("operation", "up"), Duration("duration", 1500*time.Millisecond))
+	assert.Contains(t, buf.String(), `level=DEBUG msg="compose command finished" operation=up duration=1.5s`)
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid log format "xml"`)
+}
+
+func TestFromContext(t *testing.T) {
+	assert.IsType(t, &NoOpLogger{}, FromContext(context.Background()))
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	ctx := WithLogger(context.Background(), logger)
+	ctx = WithFields(ctx, String("project", "myapp"))
+	ctx = WithFields(ctx, String("phase", "up"))
+	FromContext(ctx).Info("starting services")
+
+	var rec map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "myapp", rec["project"])
+	assert.Equal(t, "up", rec["phase"])
+}
+
+func TestNoOpLogger(t *testing.T) {
+	logger := NewNoOpLogger()
+	assert.Same(t, logger, logger.With(String("project", "myapp")))
+	logger.Info("discarded")
+}
@@ -0,0 +1,16 @@
+package logging
+
+// NoOpLogger is a logger that discards all log output.
+// This is the default logger when no logger is configured.
+type NoOpLogger struct{}
+
+func (l *NoOpLogger) Debug(msg string, fields ...Field) {}
+func (l *NoOpLogger) Info(msg string, fields ...Field)  {}
+func (l *NoOpLogger) Warn(msg string, fields ...Field)  {}
+func (l *NoOpLogger) Error(msg string, fields ...Field) {}
+func (l *NoOpLogger) With(fields ...Field) Logger       { return l }
+
+// NewNoOpLogger creates a new no-op logger.
+func NewNoOpLogger() Logger {
+	return &NoOpLogger{}
+}
@@ -0,0 +1,49 @@
+// Package metrics defines otterstack's Prometheus metrics.
+//
+// Metrics updated as events happen, such as deployments finishing and
+// notification failures, are package variables registered with the default
+// Prometheus registry. Metrics derived from stored state are collected at
+// scrape time by collectors the metrics server registers.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics recorded as events happen. Service gauges are set by the watch loop.
+var (
+	ServiceUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "otterstack_service_up",
+		Help: "Whether a service container is running (1) or not (0), as seen by the last watch check.",
+	}, []string{"project", "host", "service", "container"})
+	ServiceHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "otterstack_service_healthy",
+		Help: "Whether a service container passes its health check (1) or not (0); containers without one report 1 while running.",
+	}, []string{"project", "host", "service", "container"})
+	NotificationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otterstack_notification_failures_total",
+		Help: "Notifications that could not be sent, by notifier.",
+	}, []string{"notifier"})
+	DeployQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "otterstack_deploy_queue_wait_seconds",
+		Help:    "Time a deploy spent queued behind the project's running deploy before it started or stopped waiting.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300},
+	}, []string{"project"})
+	DeploymentsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otterstack_deployments_total",
+		Help: "Deployments run by this process that finished, by project and outcome (succeeded or failed).",
+	}, []string{"project", "status"})
+	DeploymentDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "otterstack_deployment_duration_seconds",
+		Help:    "Duration of the deployments run by this process, by project and outcome (succeeded or failed).",
+		Buckets: []float64{10, 30, 60, 120, 300, 600, 1200, 1800},
+	}, []string{"project", "status"})
+)
+
+// Deployment outcomes, the status label of DeploymentsTotal and
+// DeploymentDuration.
+const (
+	DeploySucceeded = "succeeded"
+	DeployFailed    = "failed"
+)
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRegistered(t *testing.T) {
+	ServiceUp.WithLabelValues("myapp", "local", "web", "myapp-web-1").Set(1)
+	ServiceHealthy.WithLabelValues("myapp", "local", "web", "myapp-web-1").Set(1)
+	NotificationFailures.WithLabelValues("slack").Inc()
+	DeployQueueWait.WithLabelValues("myapp").Observe(0.2)
+	DeploymentsTotal.WithLabelValues("myapp", DeploySucceeded).Inc()
+	DeploymentDuration.WithLabelValues("myapp", DeploySucceeded).Observe(42)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+
+	for _, name := range []string{
+		"otterstack_service_up",
+		"otterstack_service_healthy",
+		"otterstack_notification_failures_total",
+		"otterstack_deploy_queue_wait_seconds",
+		"otterstack_deployments_total",
+		"otterstack_deployment_duration_seconds",
+	} {
+		assert.True(t, names[name], "%s should be served", name)
+	}
+	assert.True(t, names["go_goroutines"], "the Go runtime collector is registered by default")
+}
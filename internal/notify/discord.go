@@ -166,7 +166,7 @@ func (d *DiscordNotifier) getColor(event Event) int {
 		return ColorGreen
 	case EventDeployFailed, EventServiceDown:
 		return ColorRed
-	case EventServiceUnhealthy:
+	case EventServiceUnhealthy, EventServiceHighMemory, EventServiceRestarted:
 		return ColorYellow
 	default:
 		return ColorBlue
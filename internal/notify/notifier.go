@@ -7,6 +7,11 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/metrics"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Event represents a notification event.
@@ -32,6 +37,8 @@ const (
 	EventServiceRecovered EventType = "service_recovered"
 	EventServiceDown     EventType = "service_down"
 	EventServiceUp       EventType = "service_up"
+	EventServiceHighMemory EventType = "service_high_memory"
+	EventServiceRestarted  EventType = "service_restarted"
 )
 
 // Notifier is the interface for notification backends.
@@ -71,10 +78,17 @@ func (m *Manager) Register(n Notifier) {
 }
 
 // Notify sends an event to all registered notifiers.
-func (m *Manager) Notify(ctx context.Context, event Event) error {
+func (m *Manager) Notify(ctx context.Context, event Event) (err error) {
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
 	}
+	ctx, span := tracing.Tracer().Start(ctx, "notify", trace.WithAttributes(
+		tracing.AttrEvent.String(string(event.Type)),
+		tracing.AttrProject.String(event.Project)))
+	defer tracing.EndErr(span, &err)
+	log := logging.FromContext(ctx).With(
+		logging.String("project", event.Project),
+		logging.String("event", string(event.Type)))
 
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -84,11 +98,19 @@ func (m *Manager) Notify(ctx context.Context, event Event) error {
 		wg.Add(1)
 		go func(notifier Notifier) {
 			defer wg.Done()
-			if err := notifier.Send(ctx, event); err != nil {
+			ctx, span := tracing.Tracer().Start(ctx, "notify.send",
+				trace.WithAttributes(tracing.AttrNotifier.String(notifier.Name())))
+			err := notifier.Send(ctx, event)
+			tracing.EndErr(span, &err)
+			if err != nil {
+				log.Warn("notification failed", logging.String("notifier", notifier.Name()), logging.Error(err))
+				metrics.NotificationFailures.WithLabelValues(notifier.Name()).Inc()
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("%s: %w", notifier.Name(), err))
 				mu.Unlock()
+				return
 			}
+			log.Debug("notification sent", logging.String("notifier", notifier.Name()))
 		}(n)
 	}
 	wg.Wait()
@@ -138,6 +160,10 @@ func FormatMessage(event Event) string {
 		return fmt.Sprintf("🔴 Service down: %s/%s", event.Project, event.Service)
 	case EventServiceUp:
 		return fmt.Sprintf("🟢 Service up: %s/%s", event.Project, event.Service)
+	case EventServiceHighMemory:
+		return fmt.Sprintf("🧠 High memory: %s/%s - %s", event.Project, event.Service, event.Message)
+	case EventServiceRestarted:
+		return fmt.Sprintf("🔁 Service restarted: %s/%s - %s", event.Project, event.Service, event.Message)
 	default:
 		return fmt.Sprintf("[%s] %s: %s", event.Type, event.Project, event.Message)
 	}
@@ -162,6 +188,10 @@ func GetEventTitle(event Event) string {
 		return "🔴 Service Down"
 	case EventServiceUp:
 		return "🟢 Service Up"
+	case EventServiceHighMemory:
+		return "🧠 High Memory Usage"
+	case EventServiceRestarted:
+		return "🔁 Service Restarted"
 	default:
 		return string(event.Type)
 	}
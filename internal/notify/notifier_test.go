@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jayteealao/otterstack/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -181,6 +183,9 @@ func TestManager_Notify(t *testing.T) {
 		assert.Contains(t, err.Error(), "notification errors")
 		assert.Contains(t, err.Error(), "mock1")
 		assert.Contains(t, err.Error(), "mock3")
+
+		assert.GreaterOrEqual(t, testutil.ToFloat64(metrics.NotificationFailures.WithLabelValues("mock1")), 1.0)
+		assert.Zero(t, testutil.ToFloat64(metrics.NotificationFailures.WithLabelValues("mock2")))
 	})
 
 	t.Run("returns nil when no notifiers registered", func(t *testing.T) {
@@ -313,6 +318,26 @@ func TestFormatMessage(t *testing.T) {
 			},
 			expected: "🟢 Service up: myapp/db",
 		},
+		{
+			name: "service_high_memory",
+			event: Event{
+				Type:    EventServiceHighMemory,
+				Project: "myapp",
+				Service: "web",
+				Message: "memory at 95% of 128.0MiB for 3 checks",
+			},
+			expected: "🧠 High memory: myapp/web - memory at 95% of 128.0MiB for 3 checks",
+		},
+		{
+			name: "service_restarted",
+			event: Event{
+				Type:    EventServiceRestarted,
+				Project: "myapp",
+				Service: "web",
+				Message: "restart count 1 -> 2",
+			},
+			expected: "🔁 Service restarted: myapp/web - restart count 1 -> 2",
+		},
 		{
 			name: "unknown event type",
 			event: Event{
@@ -350,6 +375,8 @@ func TestGetEventTitle(t *testing.T) {
 		{"service_recovered", EventServiceRecovered, "💚 Service Recovered"},
 		{"service_down", EventServiceDown, "🔴 Service Down"},
 		{"service_up", EventServiceUp, "🟢 Service Up"},
+		{"service_high_memory", EventServiceHighMemory, "🧠 High Memory Usage"},
+		{"service_restarted", EventServiceRestarted, "🔁 Service Restarted"},
 		{"unknown", EventType("unknown_type"), "unknown_type"},
 	}
 
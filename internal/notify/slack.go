@@ -165,7 +165,7 @@ func (s *SlackNotifier) getColor(event Event) string {
 		return SlackColorGood
 	case EventDeployFailed, EventServiceDown:
 		return SlackColorDanger
-	case EventServiceUnhealthy:
+	case EventServiceUnhealthy, EventServiceHighMemory, EventServiceRestarted:
 		return SlackColorWarning
 	default:
 		return ""
@@ -0,0 +1,45 @@
+package oplog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FollowInterval is how often Follow polls a log file for new output.
+const FollowInterval = 500 * time.Millisecond
+
+// Follow copies the log file at path to w and keeps copying output as it is
+// appended, until running reports that the operation has finished or ctx is
+// cancelled. Output written between the last poll and the end of the
+// operation is still copied.
+func Follow(ctx context.Context, path string, w io.Writer, running func() bool, interval time.Duration) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer f.Close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Check before copying so the final copy sees everything written before the operation finished
+		done := !running()
+
+		if _, err := io.Copy(w, f); err != nil {
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
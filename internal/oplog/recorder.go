@@ -0,0 +1,215 @@
+// Package oplog persists the full output of deploy, rollback and cleanup
+// operations.
+//
+// Each operation writes to its own file under <dataDir>/logs and is recorded
+// in the operation_logs table, so the log of any past deployment can be read
+// back with "otterstack logs <deployment>". Recording is best effort: a
+// Recorder that fails to write never fails the operation it records, and
+// every method is safe to call on a nil Recorder.
+package oplog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/state"
+)
+
+// Operations recorded in operation_logs.
+const (
+	OperationDeploy   = "deploy"
+	OperationRollback = "rollback"
+	OperationCleanup  = "cleanup"
+)
+
+// Statuses recorded in operation_logs.
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// timestampFormat names log files so they sort chronologically.
+const timestampFormat = "20060102-150405.000"
+
+// Path returns the log file location for an operation started at t.
+// Operations that span every project, such as cleanup, pass an empty projectName.
+func Path(dataDir, projectName, operation string, t time.Time) string {
+	name := operation + "-" + t.UTC().Format(timestampFormat) + ".log"
+	if projectName == "" {
+		return filepath.Join(dataDir, "logs", name)
+	}
+	return filepath.Join(dataDir, "logs", projectName, name)
+}
+
+// Recorder writes operation output to a log file and tracks it in the state store.
+type Recorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	path      string
+	operation string
+	store     state.StateStore
+	ids       []string // operation_logs rows sharing this file
+}
+
+// Create opens a new log file at path. Call Track to record it in the state store.
+func Create(store state.StateStore, path, operation string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create operation log: %w", err)
+	}
+
+	return &Recorder{
+		file:      file,
+		path:      path,
+		operation: operation,
+		store:     store,
+	}, nil
+}
+
+// Start creates the log file for an operation on a project and records it.
+func Start(ctx context.Context, store state.StateStore, dataDir string, project *state.Project, operation string) (*Recorder, error) {
+	r, err := Create(store, Path(dataDir, project.Name, operation, time.Now()), operation)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Track(ctx, project.ID); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Path returns the log file location.
+func (r *Recorder) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+// Track records the log file as an operation on a project.
+// A cleanup spans every project, so it is tracked once per project.
+func (r *Recorder) Track(ctx context.Context, projectID string) error {
+	if r == nil {
+		return nil
+	}
+
+	entry := &state.OperationLog{
+		ProjectID: projectID,
+		Operation: r.operation,
+		Status:    StatusRunning,
+		LogPath:   r.path,
+	}
+	if err := r.store.CreateOperationLog(ctx, entry); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.ids = append(r.ids, entry.ID)
+	r.mu.Unlock()
+	return nil
+}
+
+// SetDeployment links the operation to the deployment it produced.
+func (r *Recorder) SetDeployment(ctx context.Context, deploymentID string) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	ids := append([]string(nil), r.ids...)
+	r.mu.Unlock()
+
+	for _, id := range ids {
+		if err := r.store.SetOperationLogDeployment(ctx, id, deploymentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write appends p to the log file. Write errors are dropped so a full disk
+// never fails the operation being recorded.
+func (r *Recorder) Write(p []byte) (int, error) {
+	if r == nil {
+		return len(p), nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil {
+		r.file.Write(p)
+	}
+	return len(p), nil
+}
+
+// Line appends a status line to the log file.
+func (r *Recorder) Line(msg string) {
+	if r == nil {
+		return
+	}
+	if len(msg) == 0 || msg[len(msg)-1] != '\n' {
+		msg += "\n"
+	}
+	r.Write([]byte(msg))
+}
+
+// Tee returns a writer that writes to w and to the log file.
+func (r *Recorder) Tee(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return io.MultiWriter(w, r)
+}
+
+// Finish records the outcome of the operation and closes the log file.
+// A nil opErr marks the operation successful; otherwise the error is appended to the log.
+func (r *Recorder) Finish(ctx context.Context, opErr error) error {
+	if r == nil {
+		return nil
+	}
+
+	status := StatusSuccess
+	if opErr != nil {
+		status = StatusFailed
+		r.Line("Error: " + opErr.Error())
+	}
+	r.Close()
+
+	r.mu.Lock()
+	ids := append([]string(nil), r.ids...)
+	r.mu.Unlock()
+
+	for _, id := range ids {
+		if err := r.store.FinishOperationLog(ctx, id, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the log file without recording an outcome.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,130 @@
+package oplog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestProject(t *testing.T) (*state.Store, *state.Project) {
+	t.Helper()
+
+	store, err := state.New(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	project := &state.Project{Name: "myapp", RepoType: "local", RepoPath: "/srv/myapp", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(context.Background(), project))
+	return store, project
+}
+
+func TestPath(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 890000000, time.UTC)
+
+	assert.Equal(t, "/data/logs/myapp/deploy-20260304-050607.890.log", Path("/data", "myapp", OperationDeploy, at))
+	assert.Equal(t, "/data/logs/cleanup-20260304-050607.890.log", Path("/data", "", OperationCleanup, at))
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("records output and outcome", func(t *testing.T) {
+		store, project := setupTestProject(t)
+		dataDir := t.TempDir()
+
+		rec, err := Start(ctx, store, dataDir, project, OperationDeploy)
+		require.NoError(t, err)
+
+		var console bytes.Buffer
+		rec.Line("Pulling images...")
+		_, err = rec.Tee(&console).Write([]byte("layer 1 done\n"))
+		require.NoError(t, err)
+		require.NoError(t, rec.Finish(ctx, errors.New("compose up failed")))
+
+		assert.Equal(t, "layer 1 done\n", console.String())
+
+		data, err := os.ReadFile(rec.Path())
+		require.NoError(t, err)
+		assert.Equal(t, "Pulling images...\nlayer 1 done\nError: compose up failed\n", string(data))
+
+		logs, err := store.ListOperationLogs(ctx, project.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, StatusFailed, logs[0].Status)
+		assert.Equal(t, OperationDeploy, logs[0].Operation)
+		assert.Equal(t, rec.Path(), logs[0].LogPath)
+		assert.True(t, strings.HasPrefix(rec.Path(), filepath.Join(dataDir, "logs", "myapp")))
+	})
+
+	t.Run("links deployment and marks success", func(t *testing.T) {
+		store, project := setupTestProject(t)
+
+		deployment := &state.Deployment{ProjectID: project.ID, GitSHA: "abc1234", Status: "deploying"}
+		require.NoError(t, store.CreateDeployment(ctx, deployment))
+
+		rec, err := Start(ctx, store, t.TempDir(), project, OperationRollback)
+		require.NoError(t, err)
+		require.NoError(t, rec.SetDeployment(ctx, deployment.ID))
+		require.NoError(t, rec.Finish(ctx, nil))
+
+		logs, err := store.ListDeploymentOperationLogs(ctx, deployment.ID)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, StatusSuccess, logs[0].Status)
+	})
+
+	t.Run("writes after finish are dropped", func(t *testing.T) {
+		store, project := setupTestProject(t)
+
+		rec, err := Start(ctx, store, t.TempDir(), project, OperationDeploy)
+		require.NoError(t, err)
+		require.NoError(t, rec.Finish(ctx, nil))
+
+		n, err := rec.Write([]byte("late"))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+	})
+
+	t.Run("nil recorder is a no-op", func(t *testing.T) {
+		var rec *Recorder
+		var console bytes.Buffer
+
+		assert.Same(t, &console, rec.Tee(&console))
+		rec.Line("ignored")
+		assert.NoError(t, rec.Track(ctx, "project"))
+		assert.NoError(t, rec.SetDeployment(ctx, "deployment"))
+		assert.NoError(t, rec.Finish(ctx, errors.New("ignored")))
+		assert.Empty(t, rec.Path())
+	})
+}
+
+func TestFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.log")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0600))
+
+	polls := 0
+	running := func() bool {
+		polls++
+		if polls == 2 {
+			// Output appended while the operation is still running
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+			require.NoError(t, err)
+			f.WriteString("second\n")
+			f.Close()
+		}
+		return polls < 3
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, Follow(context.Background(), path, &out, running, time.Millisecond))
+	assert.Equal(t, "first\nsecond\n", out.String())
+}
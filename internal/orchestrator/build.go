@@ -0,0 +1,110 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
+)
+
+// buildPhase builds images from source for one deployment.
+type buildPhase struct {
+	worktreePath string
+	cfg          *projectconfig.Config
+	projectName  string
+	composeName  string // compose project name
+	shortSHA     string
+	envFilePath  string
+	envVars      map[string]string
+	logPath      string
+	rebuild      bool
+	stdout       io.Writer
+	stderr       io.Writer
+	onStatus     func(string)
+	onVerbose    func(string)
+}
+
+// run builds every service with a build section and tags its image with the
+// commit SHA through a generated override file, so a later rollback to this
+// commit starts the same images instead of rebuilding. The build is skipped
+// when all images for the commit already exist, unless rebuild is set.
+//
+// Returns the compose file list to use for the rest of the deployment; it is
+// cfg.ComposeFile unchanged when nothing needs building.
+func (b *buildPhase) run(ctx context.Context) (string, error) {
+	services, err := compose.BuildServices(filepath.Join(b.worktreePath, b.cfg.ComposeFile))
+	if err != nil {
+		return "", err
+	}
+	if len(services) == 0 {
+		return b.cfg.ComposeFile, nil
+	}
+
+	images := make(map[string]string, len(services))
+	for _, service := range services {
+		images[service] = compose.BuildImageTag(b.projectName, service, b.shortSHA)
+	}
+	if _, err := compose.WriteBuildOverride(b.worktreePath, images); err != nil {
+		return "", err
+	}
+	composeFiles := compose.WithBuildOverride(b.worktreePath, b.cfg.ComposeFile)
+
+	if !b.rebuild && allImagesExist(ctx, images) {
+		b.onStatus(fmt.Sprintf("Reusing images built for %s", b.shortSHA))
+		return composeFiles, nil
+	}
+
+	buildArgs := make(map[string]string)
+	for _, name := range b.cfg.BuildArgs {
+		value, ok := b.envVars[name]
+		if !ok {
+			b.onVerbose(fmt.Sprintf("Warning: build arg %s is not set for this project", name))
+			continue
+		}
+		buildArgs[name] = value
+	}
+
+	// Keep the build log alongside the console output
+	if err := os.MkdirAll(filepath.Dir(b.logPath), 0700); err != nil {
+		return "", fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logFile, err := os.OpenFile(b.logPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create build log: %w", err)
+	}
+	defer logFile.Close()
+
+	composeMgr := compose.NewManager(b.worktreePath, composeFiles, b.composeName)
+	composeMgr.SetOutputStreams(io.MultiWriter(b.stdout, logFile), io.MultiWriter(b.stderr, logFile))
+
+	b.onStatus(fmt.Sprintf("Building images (%d service(s))...", len(services)))
+	b.onVerbose(fmt.Sprintf("Build log: %s", b.logPath))
+
+	buildCtx, cancel := context.WithTimeout(ctx, b.cfg.BuildTimeout)
+	defer cancel()
+
+	if err := composeMgr.Build(buildCtx, b.envFilePath, buildArgs); err != nil {
+		return "", fmt.Errorf("failed to build images: %w (log: %s)", err, b.logPath)
+	}
+
+	return composeFiles, nil
+}
+
+// allImagesExist reports whether every image is already in the target engine's image store.
+func allImagesExist(ctx context.Context, images map[string]string) bool {
+	for _, image := range images {
+		if !compose.ImageExists(ctx, image) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildLogPath returns where the build log for a commit is kept.
+func buildLogPath(dataDir, projectName, shortSHA string) string {
+	return filepath.Join(dataDir, "logs", projectName, "build-"+shortSHA+".log")
+}
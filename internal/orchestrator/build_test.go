@@ -0,0 +1,40 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPhase_NoBuildServices(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte("services:\n  web:\n    image: nginx\n"), 0644))
+
+	cfg := projectconfig.Resolve(&state.Project{ComposeFile: "docker-compose.yml"}, nil)
+	build := &buildPhase{
+		worktreePath: dir,
+		cfg:          cfg,
+		projectName:  "myapp",
+		shortSHA:     "abc1234",
+		onStatus:     func(string) {},
+		onVerbose:    func(string) {},
+	}
+
+	composeFiles, err := build.run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "docker-compose.yml", composeFiles)
+
+	_, err = os.Stat(filepath.Join(dir, compose.BuildOverrideFile))
+	assert.True(t, os.IsNotExist(err), "no override without build sections")
+}
+
+func TestBuildLogPath(t *testing.T) {
+	assert.Equal(t, filepath.Join("/data", "logs", "myapp", "build-abc1234.log"), buildLogPath("/data", "myapp", "abc1234"))
+}
@@ -4,33 +4,73 @@ package orchestrator
 import (
 	"context"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"time"
 
 	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/envschema"
+	"github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
 	"github.com/jayteealao/otterstack/internal/lock"
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/metrics"
+	"github.com/jayteealao/otterstack/internal/oplog"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/tracing"
 	"github.com/jayteealao/otterstack/internal/traefik"
 	"github.com/jayteealao/otterstack/internal/validate"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DeployOptions contains options for a deployment.
 type DeployOptions struct {
 	GitRef    string
-	Timeout   time.Duration
+	SHA       string        // Deploy this commit without fetching or resolving GitRef, which is still recorded
+	Target    target.Target // Docker engine to deploy to; the zero value is the local engine
+	Timeout   time.Duration // Overrides the configured deploy timeout when non-zero
 	SkipPull  bool
+	Rebuild   bool // Build images even if they already exist for the commit
 	DataDir   string
 	OnStatus  func(msg string) // Callback for status messages
 	OnVerbose func(msg string) // Callback for verbose messages
+	Stdout    io.Writer        // Docker and hook output; defaults to os.Stdout
+	Stderr    io.Writer        // Docker and hook error output; defaults to os.Stderr
+
+	MaxConcurrent int           // Deployments of any project that may run at once on this machine; zero for no limit
+	Resources     ResourceCheck // Free disk and memory required before images are pulled
+
+	TriggerSource string // state.TriggerCLI, TriggerWebhook or TriggerSchedule; defaults to CLI
+	TriggeredBy   string // Unix user, API token name or webhook sender
 }
 
 // DeployResult contains the result of a deployment.
 type DeployResult struct {
 	Deployment *state.Deployment
 	ShortSHA   string
+	Config     *projectconfig.Config // Effective configuration used for the deployment
+	LogPath    string                // Full output of the deployment, empty if it could not be recorded
+}
+
+// DeployError is returned by Deploy when a deployment fails after its
+// record was created, so callers can report the record and why it failed.
+type DeployError struct {
+	DeploymentID string
+	Validation   *validate.ValidationResult // Set when env vars failed validation
+	Err          error
+}
+
+func (e *DeployError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeployError) Unwrap() error {
+	return e.Err
 }
 
 // Deployer orchestrates deployments.
@@ -48,7 +88,44 @@ func NewDeployer(store state.StateStore, gitMgr git.GitOperations) *Deployer {
 }
 
 // Deploy performs a deployment for the given project.
-func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts DeployOptions) (*DeployResult, error) {
+//
+// Hooks declared in otterstack.yaml run at fixed points: pre_up before compose up,
+// post_up_healthy once the new containers are healthy, post_switch after traffic
+// has moved but before the previous deployment stops, and on_failure whenever
+// the deployment fails. A failing blocking hook stops the new containers so the
+// previous deployment keeps serving.
+//
+// Status messages and all docker and hook output are also written to a
+// per-deployment log file recorded in operation_logs.
+func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts DeployOptions) (_ *DeployResult, retErr error) {
+	ctx, span := tracing.Tracer().Start(ctx, "deploy", trace.WithAttributes(
+		tracing.AttrProject.String(project.Name),
+		tracing.AttrHost.String(opts.Target.String())))
+	defer tracing.EndErr(span, &retErr)
+	ctx = tracing.WithAttributes(ctx, tracing.AttrProject.String(project.Name))
+	ctx = logging.WithFields(ctx,
+		logging.String("project", project.Name),
+		logging.String("project_id", project.ID),
+		logging.String("host", opts.Target.String()))
+
+	started := time.Now()
+	logging.FromContext(ctx).Info("starting deployment",
+		logging.String("git_ref", opts.GitRef),
+		logging.String("trigger", opts.TriggerSource))
+	defer func() {
+		// ctx has gained the commit, deployment and phase by now
+		log := logging.FromContext(ctx)
+		outcome := metrics.DeploySucceeded
+		if retErr != nil {
+			outcome = metrics.DeployFailed
+			log.Error("deployment failed", logging.Duration("duration", time.Since(started)), logging.Error(retErr))
+		} else {
+			log.Info("deployment succeeded", logging.Duration("duration", time.Since(started)))
+		}
+		metrics.DeploymentsTotal.WithLabelValues(project.Name, outcome).Inc()
+		metrics.DeploymentDuration.WithLabelValues(project.Name, outcome).Observe(time.Since(started).Seconds())
+	}()
+
 	// 1. ACQUIRE FILE LOCK (prevents concurrent deployments)
 	lockMgr, err := lock.NewManager(opts.DataDir)
 	if err != nil {
@@ -70,18 +147,80 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 	if onVerbose == nil {
 		onVerbose = func(msg string) {}
 	}
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	stderr := opts.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	// Wait for a deploy slot if deployments running at once on this machine are limited
+	if opts.MaxConcurrent > 0 {
+		slot, err := lockMgr.TryAcquireSlot(deploySlots, opts.MaxConcurrent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire deploy slot: %w", err)
+		}
+		if slot == nil {
+			onStatus(fmt.Sprintf("Waiting for a deploy slot: %d deployments running (PIDs %s), the configured maximum",
+				opts.MaxConcurrent, formatPIDs(lockMgr.SlotHolders(deploySlots, opts.MaxConcurrent))))
+			slot, err = lockMgr.AcquireSlot(ctx, deploySlots, opts.MaxConcurrent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to acquire deploy slot: %w", err)
+			}
+		}
+		defer slot.Release()
+	}
+
+	// Record the full output of the deployment
+	rec, err := oplog.Start(ctx, d.store, opts.DataDir, project, oplog.OperationDeploy)
+	if err != nil {
+		onVerbose(fmt.Sprintf("Warning: deployment output will not be recorded: %v", err))
+	}
+	statusFn, verboseFn := onStatus, onVerbose
+	defer func() {
+		if err := rec.Finish(context.WithoutCancel(ctx), retErr); err != nil {
+			verboseFn(fmt.Sprintf("Warning: failed to record deployment outcome: %v", err))
+		}
+		if retErr != nil && rec != nil {
+			statusFn(fmt.Sprintf("Full deployment output: %s", rec.Path()))
+		}
+	}()
+	onStatus = func(msg string) {
+		statusFn(msg)
+		rec.Line(msg)
+		logging.FromContext(ctx).Info(msg)
+	}
+	onVerbose = func(msg string) {
+		verboseFn(msg)
+		rec.Line(msg)
+		logging.FromContext(ctx).Debug(msg)
+	}
+	stdout, stderr = rec.Tee(stdout), rec.Tee(stderr)
+
+	// Every docker command below runs against the target engine
+	ctx = target.With(ctx, opts.Target)
+
+	timer := newPhaseTimer(ctx)
+	defer func() {
+		timer.fail(retErr)
+		timer.stop()
+	}()
 
 	// Fetch latest changes for remote repos
-	if project.RepoType == "remote" {
+	if project.RepoType == "remote" && opts.SHA == "" {
 		onStatus("Fetching latest changes...")
+		ctx = timer.start(PhaseFetch)
 		if err := d.gitMgr.Fetch(ctx); err != nil {
 			return nil, fmt.Errorf("failed to fetch: %w", err)
 		}
+		ctx = timer.stop()
 	}
 
 	// Resolve git reference
 	gitRef := opts.GitRef
-	if gitRef == "" {
+	if gitRef == "" && opts.SHA == "" {
 		defaultBranch, err := d.gitMgr.GetDefaultBranch(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get default branch: %w", err)
@@ -90,32 +229,96 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 		onStatus(fmt.Sprintf("Using default branch: %s", gitRef))
 	}
 
-	fullSHA, err := d.gitMgr.ResolveRef(ctx, gitRef)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve ref %q: %w", gitRef, err)
+	fullSHA := opts.SHA
+	if fullSHA == "" {
+		fullSHA, err = d.gitMgr.ResolveRef(ctx, gitRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve ref %q: %w", gitRef, err)
+		}
 	}
 
 	shortSHA := git.ShortSHA(fullSHA)
-	onStatus(fmt.Sprintf("Deploying %s (%s -> %s)", project.Name, gitRef, shortSHA))
+	span.SetAttributes(tracing.AttrSHA.String(fullSHA), tracing.AttrRef.String(gitRef))
+	ctx = tracing.WithAttributes(ctx, tracing.AttrSHA.String(fullSHA))
+	ctx = logging.WithFields(ctx, logging.String("git_sha", fullSHA), logging.String("git_ref", gitRef))
+	if opts.Target.IsLocal() {
+		onStatus(fmt.Sprintf("Deploying %s (%s -> %s)", project.Name, gitRef, shortSHA))
+	} else {
+		onStatus(fmt.Sprintf("Deploying %s (%s -> %s) to %s (%s)", project.Name, gitRef, shortSHA, opts.Target, opts.Target.Endpoint()))
+	}
+
+	// Record what was running on this host before this deployment
+	var previous *state.Deployment
+	var previousSHA string
+	active, err := d.store.ListActiveDeployments(ctx, project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active deployments: %w", err)
+	}
+	for _, a := range active {
+		if a.Host == opts.Target.Name {
+			previous = a
+			previousSHA = a.GitSHA
+		}
+	}
+
+	// Deploying a commit that went live on this host before is a redeploy of that deployment
+	kind, parentID := state.KindDeploy, ""
+	if earlier := d.liveDeployment(ctx, project.ID, fullSHA, opts.Target.Name); earlier != nil {
+		kind, parentID = state.KindRedeploy, earlier.ID
+	}
 
 	// Create deployment record
 	worktreePath := git.GetWorktreePath(opts.DataDir, project.Name, fullSHA)
 	deployment := &state.Deployment{
-		ProjectID:    project.ID,
-		GitSHA:       fullSHA,
-		GitRef:       gitRef,
-		WorktreePath: worktreePath,
-		Status:       "deploying",
+		ProjectID:     project.ID,
+		GitSHA:        fullSHA,
+		GitRef:        gitRef,
+		WorktreePath:  worktreePath,
+		Status:        "deploying",
+		TriggerSource: opts.TriggerSource,
+		TriggeredBy:   opts.TriggeredBy,
+		PreviousSHA:   previousSHA,
+
+		Kind:               kind,
+		ParentDeploymentID: parentID,
+
+		Host: opts.Target.Name,
 	}
 
 	if err := d.store.CreateDeployment(ctx, deployment); err != nil {
 		return nil, fmt.Errorf("failed to create deployment record: %w", err)
 	}
+	if err := rec.SetDeployment(ctx, deployment.ID); err != nil {
+		onVerbose(fmt.Sprintf("Warning: failed to link deployment log: %v", err))
+	}
+	span.SetAttributes(tracing.AttrDeploymentID.String(deployment.ID))
+	ctx = logging.WithFields(ctx, logging.String("deployment_id", deployment.ID))
+	timer.ctx = ctx
+
+	var failedValidation *validate.ValidationResult
+	defer func() {
+		if retErr != nil {
+			retErr = &DeployError{DeploymentID: deployment.ID, Validation: failedValidation, Err: retErr}
+		}
+	}()
+
+	// Keep phase durations for failed deployments too, to see where time went
+	defer func() {
+		timer.fail(retErr)
+		if err := d.store.UpdateDeploymentPhases(context.WithoutCancel(ctx), deployment.ID, timer.result()); err != nil {
+			onVerbose(fmt.Sprintf("Warning: failed to record phase durations: %v", err))
+		}
+	}()
 
 	// Set up cleanup on failure
 	success := false
+	var hooks *hookRunner
 	defer func() {
 		if !success {
+			if hooks != nil && retErr != nil {
+				// Run on_failure hooks even if the deployment was cancelled
+				hooks.run(context.WithoutCancel(ctx), projectconfig.PhaseOnFailure, "OTTERSTACK_ERROR="+retErr.Error())
+			}
 			errMsg := "deployment interrupted"
 			d.store.UpdateDeploymentStatus(ctx, deployment.ID, "failed", &errMsg)
 		}
@@ -123,6 +326,7 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 
 	// Create worktree
 	onVerbose(fmt.Sprintf("Creating worktree at %s...", worktreePath))
+	ctx = timer.start(PhaseWorktree)
 	if _, err := os.Stat(worktreePath); err == nil {
 		onVerbose("Worktree already exists, reusing...")
 	} else {
@@ -130,10 +334,25 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 			return nil, fmt.Errorf("failed to create worktree: %w", err)
 		}
 	}
+	ctx = timer.stop()
+
+	// Load deploy settings versioned with this commit and merge them with the stored project settings
+	configFile, err := projectconfig.Load(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+	if configFile != nil {
+		onVerbose(fmt.Sprintf("Using %s from %s", projectconfig.FileName, shortSHA))
+	}
+	cfg := projectconfig.Resolve(project, configFile)
+	if opts.Timeout > 0 {
+		cfg.SetDeployTimeout(opts.Timeout)
+	}
 
 	// Initialize compose manager
 	composeProjectName := compose.GenerateProjectName(project.Name, shortSHA)
-	composeMgr := compose.NewManager(worktreePath, project.ComposeFile, composeProjectName)
+	composeMgr := compose.NewManager(worktreePath, cfg.ComposeFile, composeProjectName)
+	composeMgr.SetOutputStreams(stdout, stderr)
 
 	// Write env file BEFORE any docker compose operations
 	// This ensures env vars are available for validation and pulling
@@ -142,6 +361,13 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 		return nil, fmt.Errorf("failed to get env vars: %w", err)
 	}
 
+	// Fill in defaults declared by this commit's env schema
+	schema, err := envschema.Load(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+	envVars = schema.ApplyDefaults(envVars)
+
 	envFilePath, err := writeEnvFile(opts.DataDir, project.Name, envVars)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write env file: %w", err)
@@ -150,20 +376,55 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 		onVerbose(fmt.Sprintf("Using env file: %s", envFilePath))
 	}
 
+	hooks = &hookRunner{
+		hooks:       cfg.Hooks,
+		composeMgr:  composeMgr,
+		envFilePath: envFilePath,
+		workDir:     worktreePath,
+		env: []string{
+			"OTTERSTACK_PROJECT=" + project.Name,
+			"OTTERSTACK_SHA=" + fullSHA,
+			"OTTERSTACK_SHORT_SHA=" + shortSHA,
+			"OTTERSTACK_REF=" + gitRef,
+			"OTTERSTACK_COMPOSE_PROJECT=" + composeProjectName,
+			"OTTERSTACK_HOST=" + opts.Target.String(),
+		},
+		hostEnv:  envPairs(envVars),
+		stdout:   stdout,
+		stderr:   stderr,
+		onStatus: onStatus,
+	}
+
+	// stopNewContainers tears down the new compose project so the previous deployment keeps serving.
+	// Uses the parent context (not deployCtx which may have timed out) and gives it 60 seconds.
+	stopNewContainers := func() {
+		if stopErr := compose.StopProjectByName(ctx, composeProjectName, 60*time.Second); stopErr != nil {
+			// Log the full error but continue with deployment failure
+			onStatus(fmt.Sprintf("ERROR: Failed to stop new containers: %v", stopErr))
+			onStatus("Manual cleanup required: docker compose -p " + composeProjectName + " down --timeout 0")
+		} else {
+			onStatus("Successfully stopped new containers.")
+		}
+	}
+
 	// PRE-DEPLOYMENT ENV VALIDATION GATE
 	// Check that all required environment variables are present before starting deployment
 	onVerbose("Validating environment variables...")
-	composePath := filepath.Join(worktreePath, project.ComposeFile)
+	composePath := filepath.Join(worktreePath, cfg.ComposeFile)
 	validation, err := validate.ValidateEnvVars(composePath, envVars)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate env vars: %w", err)
 	}
 
-	// If required variables are missing, abort deployment with clear error message
-	if !validation.AllPresent {
+	// If required variables are missing or violate the schema, abort deployment with clear error message
+	if !validation.Passed() {
 		errorMsg := validate.FormatValidationError(validation, project.Name)
 		onStatus(errorMsg)
-		return nil, fmt.Errorf("missing required environment variables (see above for details)")
+		failedValidation = validation
+		if !validation.AllPresent {
+			return nil, fmt.Errorf("%w (see above for details)", errors.ErrMissingEnvVars)
+		}
+		return nil, fmt.Errorf("%w: values violate %s (see above for details)", errors.ErrInvalidEnvVars, envschema.FileName)
 	}
 
 	// If optional variables are missing, show warning but continue
@@ -180,7 +441,7 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 
 	// Check if Traefik is available (only if routing is enabled)
 	var traefikAvailable bool
-	if project.TraefikRoutingEnabled {
+	if cfg.TraefikRouting {
 		onVerbose("Checking for Traefik...")
 		traefikAvailable, _ = traefik.IsRunning(ctx)
 		if !traefikAvailable {
@@ -190,12 +451,50 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 		}
 	}
 
+	// Pulls and builds need free disk and memory on the machine running the engine
+	if opts.Resources.enabled() && opts.Target.IsLocal() {
+		onVerbose("Checking free disk space and memory...")
+		if err := waitForResources(ctx, opts.Resources, opts.DataDir, onStatus); err != nil {
+			return nil, err
+		}
+	}
+
 	// Pull images if not skipped (with env file for variable substitution)
 	if !opts.SkipPull {
 		onStatus("Pulling images...")
+		ctx = timer.start(PhasePull)
 		if err := composeMgr.PullWithEnv(ctx, envFilePath); err != nil {
 			onVerbose(fmt.Sprintf("Warning: pull failed (continuing): %v", err))
 		}
+		ctx = timer.stop()
+	}
+
+	// Build images from source before any containers start
+	build := &buildPhase{
+		worktreePath: worktreePath,
+		cfg:          cfg,
+		projectName:  project.Name,
+		composeName:  composeProjectName,
+		shortSHA:     shortSHA,
+		envFilePath:  envFilePath,
+		envVars:      envVars,
+		logPath:      buildLogPath(opts.DataDir, project.Name, shortSHA),
+		rebuild:      opts.Rebuild,
+		stdout:       stdout,
+		stderr:       stderr,
+		onStatus:     onStatus,
+		onVerbose:    onVerbose,
+	}
+	ctx = timer.start(PhaseBuild)
+	composeFiles, err := build.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = timer.stop()
+	if composeFiles != cfg.ComposeFile {
+		composeMgr = compose.NewManager(worktreePath, composeFiles, composeProjectName)
+		composeMgr.SetOutputStreams(stdout, stderr)
+		hooks.composeMgr = composeMgr
 	}
 
 	// Check context before starting services
@@ -203,48 +502,79 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 		return nil, fmt.Errorf("deployment cancelled: %w", ctx.Err())
 	}
 
+	// Snapshot volumes before pre_up hooks or the new containers can change them
+	snapshots := &snapshotPhase{
+		store:       d.store,
+		cfg:         cfg.Snapshots,
+		composeMgr:  composeMgr,
+		envFilePath: envFilePath,
+		dir:         SnapshotDir(opts.DataDir, project.Name, deployment.ID),
+		projectID:   project.ID,
+		deployment:  deployment.ID,
+		onStatus:    onStatus,
+		onVerbose:   onVerbose,
+	}
+	if len(cfg.Snapshots.Volumes) > 0 {
+		ctx = timer.start(PhaseSnapshot)
+		if err := snapshots.run(ctx); err != nil {
+			return nil, err
+		}
+		ctx = timer.stop()
+	}
+
+	// Run pre_up hooks (e.g. database migrations) against the new images
+	ctx = timer.start(PhaseUp)
+	if err := hooks.run(ctx, projectconfig.PhasePreUp); err != nil {
+		stopNewContainers()
+		return nil, err
+	}
+
 	// Start services with timeout
 	onStatus("Starting services...")
-	deployCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	deployCtx, cancel := context.WithTimeout(ctx, cfg.DeployTimeout)
 	defer cancel()
 
 	if err := composeMgr.Up(deployCtx, envFilePath); err != nil {
 		// Get container logs to help debug the failure
-		onStatus("Deployment failed. Fetching container logs...")
-		logs, logErr := composeMgr.Logs(ctx, "", 50) // Last 50 lines from all services
-		if logErr == nil && logs != "" {
-			onStatus("Container logs (last 50 lines):")
-			onStatus(logs)
+		if cfg.FailureLogLines > 0 {
+			onStatus("Deployment failed. Fetching container logs...")
+			logs, logErr := composeMgr.Logs(ctx, "", cfg.FailureLogLines)
+			if logErr == nil && logs != "" {
+				onStatus(fmt.Sprintf("Container logs (last %d lines):", cfg.FailureLogLines))
+				onStatus(logs)
+			}
 		}
 		return nil, fmt.Errorf("failed to start services: %w", err)
 	}
 
 	// Health check NEW containers (BEFORE applying Traefik labels)
 	// This is critical: we only route traffic to healthy containers
-	if project.TraefikRoutingEnabled && traefikAvailable {
+	ctx = timer.start(PhaseHealth)
+	if cfg.TraefikRouting && traefikAvailable {
 		onStatus("Waiting for containers to be healthy...")
-		if err := traefik.WaitForHealthy(deployCtx, composeProjectName, traefik.DefaultHealthTimeout); err != nil {
+		healthCtx := trace.ContextWithSpan(deployCtx, trace.SpanFromContext(ctx))
+		if err := traefik.WaitForHealthyEvery(healthCtx, composeProjectName, cfg.HealthTimeout, cfg.HealthInterval); err != nil {
 			// UNHEALTHY: Stop new containers, keep old running
 			onStatus("Health check failed. Rolling back...")
-			// Use parent context for cleanup (not deployCtx which may have timed out)
-			// Give it 60 seconds to force-stop all containers
-			if stopErr := compose.StopProjectByName(ctx, composeProjectName, 60*time.Second); stopErr != nil {
-				// Log the full error but continue with deployment failure
-				onStatus(fmt.Sprintf("ERROR: Failed to stop unhealthy containers: %v", stopErr))
-				onStatus("Manual cleanup required: docker compose -p " + composeProjectName + " down --timeout 0")
-			} else {
-				onStatus("Successfully stopped unhealthy containers.")
-			}
+			stopNewContainers()
 			errMsg := err.Error()
 			d.store.UpdateDeploymentStatus(ctx, deployment.ID, "failed", &errMsg)
-			return nil, fmt.Errorf("health check failed: %w (deployment rolled back, old containers still serving)", err)
+			return nil, fmt.Errorf("%w: %w (deployment rolled back, old containers still serving)", errors.ErrHealthCheckFailed, err)
 		}
 		onStatus("Containers are healthy.")
 	}
 
+	// Run post_up_healthy hooks (e.g. smoke tests) before traffic is switched
+	if err := hooks.run(ctx, projectconfig.PhasePostUpHealthy); err != nil {
+		onStatus("Hook failed. Rolling back...")
+		stopNewContainers()
+		return nil, err
+	}
+
 	// Generate and apply Traefik override file with priority labels
 	// This happens AFTER health check, so traffic only switches if containers are healthy
-	if project.TraefikRoutingEnabled && traefikAvailable {
+	ctx = timer.start(PhaseSwitch)
+	if cfg.TraefikRouting && traefikAvailable {
 		onStatus("Applying Traefik priority labels...")
 		priority := time.Now().UnixMilli()
 		overridePath, err := traefik.GenerateOverride(worktreePath, priority)
@@ -254,28 +584,38 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 
 		// Apply override file - this triggers Traefik to route traffic to new containers
 		// Compose will merge the override with the base compose file
-		overrideComposeMgr := compose.NewManager(worktreePath, project.ComposeFile+","+filepath.Base(overridePath), composeProjectName)
+		overrideComposeMgr := compose.NewManager(worktreePath, composeFiles+","+filepath.Base(overridePath), composeProjectName)
+		overrideComposeMgr.SetOutputStreams(stdout, stderr)
 		if err := overrideComposeMgr.Up(ctx, envFilePath); err != nil {
 			return nil, fmt.Errorf("failed to apply Traefik labels: %w", err)
 		}
 		onVerbose(fmt.Sprintf("Applied priority: %d (new deployment gets traffic)", priority))
 	}
 
+	// Run post_switch hooks while the previous deployment can still take traffic back
+	if err := hooks.run(ctx, projectconfig.PhasePostSwitch); err != nil {
+		onStatus("Hook failed. Rolling back...")
+		stopNewContainers()
+		return nil, err
+	}
+
 	// Deactivate previous deployments
 	if err := d.store.DeactivatePreviousDeployments(ctx, project.ID, deployment.ID); err != nil {
 		onVerbose(fmt.Sprintf("Warning: failed to deactivate previous deployments: %v", err))
 	}
 
-	// Stop previous deployment's containers
-	previousDeployment, err := d.store.GetPreviousDeployment(ctx, project.ID)
-	if err == nil && previousDeployment != nil {
-		oldProjectName := compose.GenerateProjectName(project.Name, git.ShortSHA(previousDeployment.GitSHA))
-		onVerbose(fmt.Sprintf("Stopping previous deployment %s...", git.ShortSHA(previousDeployment.GitSHA)))
-		if err := compose.StopProjectByName(ctx, oldProjectName, 30*time.Second); err != nil {
+	// Stop the containers of the deployment this one replaced. A redeploy of the
+	// running commit shares its compose project, so there is nothing to stop.
+	if previous != nil && previous.GitSHA != fullSHA {
+		oldProjectName := compose.GenerateProjectName(project.Name, git.ShortSHA(previous.GitSHA))
+		onVerbose(fmt.Sprintf("Stopping previous deployment %s...", git.ShortSHA(previous.GitSHA)))
+		if err := compose.StopProjectByName(ctx, oldProjectName, cfg.StopTimeout); err != nil {
 			onVerbose(fmt.Sprintf("Warning: failed to stop previous deployment: %v", err))
 		}
 	}
 
+	ctx = timer.stop()
+
 	// Mark deployment as active
 	if err := d.store.UpdateDeploymentStatus(ctx, deployment.ID, "active", nil); err != nil {
 		return nil, fmt.Errorf("failed to update deployment status: %w", err)
@@ -286,10 +626,12 @@ func (d *Deployer) Deploy(ctx context.Context, project *state.Project, opts Depl
 	return &DeployResult{
 		Deployment: deployment,
 		ShortSHA:   shortSHA,
+		Config:     cfg,
+		LogPath:    rec.Path(),
 	}, nil
 }
 
-// CleanupOldWorktrees removes worktrees beyond the retention limit.
+// CleanupOldWorktrees removes worktrees and volume snapshots beyond the retention limit.
 func (d *Deployer) CleanupOldWorktrees(ctx context.Context, project *state.Project, dataDir string, onVerbose func(string)) error {
 	if onVerbose == nil {
 		onVerbose = func(msg string) {}
@@ -304,14 +646,35 @@ func (d *Deployer) CleanupOldWorktrees(ctx context.Context, project *state.Proje
 		return nil
 	}
 
+	// Hosts and redeploys share the worktree of a commit, so keep it while
+	// any retained or running deployment uses it
+	keep := make(map[string]bool)
+	for i, dep := range deployments {
+		if i < project.WorktreeRetention || dep.Status == "active" || dep.Status == "deploying" {
+			keep[dep.WorktreePath] = true
+		}
+	}
+
 	for i := project.WorktreeRetention; i < len(deployments); i++ {
 		dep := deployments[i]
-		if dep.WorktreePath == "" {
+		if dep.Status == "active" || dep.Status == "deploying" {
 			continue
 		}
-		if dep.Status == "active" || dep.Status == "deploying" {
+
+		snapshots, err := d.store.ListVolumeSnapshots(ctx, dep.ID)
+		if err != nil {
+			onVerbose(fmt.Sprintf("Warning: failed to list volume snapshots of %s: %v", dep.ID, err))
+		} else if len(snapshots) > 0 {
+			onVerbose(fmt.Sprintf("Removing volume snapshots of deployment %s", dep.ID))
+			if err := removeSnapshots(ctx, d.store, SnapshotDir(dataDir, project.Name, dep.ID), dep.ID); err != nil {
+				onVerbose(fmt.Sprintf("Warning: %v", err))
+			}
+		}
+
+		if dep.WorktreePath == "" || keep[dep.WorktreePath] {
 			continue
 		}
+		keep[dep.WorktreePath] = true // remove each worktree once
 
 		onVerbose(fmt.Sprintf("Removing old worktree: %s", dep.WorktreePath))
 		if err := d.gitMgr.RemoveWorktree(ctx, dep.WorktreePath); err != nil {
@@ -360,3 +723,54 @@ func writeEnvFile(dataDir, projectName string, vars map[string]string) (string,
 
 	return envPath, nil
 }
+
+// envPairs converts a variable map into sorted KEY=value pairs.
+func envPairs(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+vars[k])
+	}
+	return pairs
+}
+
+// liveDeployment returns the deployment that last built a commit on a host
+// and went live, or nil if the commit never ran there.
+func (d *Deployer) liveDeployment(ctx context.Context, projectID, sha, host string) *state.Deployment {
+	earlier, err := d.store.GetDeploymentBySHA(ctx, projectID, sha)
+	if err != nil {
+		return nil
+	}
+	if earlier.Host == host {
+		if wasLive(earlier) {
+			return earlier
+		}
+		return nil
+	}
+
+	// The latest record of the commit is from another host, e.g. earlier in a rolling deploy
+	deployments, err := d.store.ListDeployments(ctx, projectID, math.MaxInt32)
+	if err != nil {
+		return nil
+	}
+	for _, dep := range deployments {
+		if dep.GitSHA == sha && dep.Host == host && dep.Kind != state.KindRollback && wasLive(dep) {
+			return dep
+		}
+	}
+	return nil
+}
+
+// wasLive reports whether a deployment reached the active state at some point.
+func wasLive(d *state.Deployment) bool {
+	switch d.Status {
+	case "active", "inactive", "rolled_back":
+		return true
+	}
+	return false
+}
@@ -3,14 +3,22 @@ package orchestrator
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/metrics"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,6 +42,8 @@ type mockStore struct {
 	statusUpdates            []statusUpdate
 	deactivateCalls          []deactivateCall
 	previousDeploymentResult *state.Deployment
+	operationLogs            []*state.OperationLog
+	volumeSnapshots          []*state.VolumeSnapshot
 }
 
 type statusUpdate struct {
@@ -129,6 +139,20 @@ func (m *mockStore) GetActiveDeployment(ctx context.Context, projectID string) (
 	return nil, errors.New("no active deployment")
 }
 
+func (m *mockStore) GetHost(ctx context.Context, name string) (*state.Host, error) {
+	return nil, errors.New("host not found")
+}
+
+func (m *mockStore) ListActiveDeployments(ctx context.Context, projectID string) ([]*state.Deployment, error) {
+	var result []*state.Deployment
+	for _, d := range m.deployments {
+		if d.ProjectID == projectID && d.Status == "active" {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
 func (m *mockStore) ListDeployments(ctx context.Context, projectID string, limit int) ([]*state.Deployment, error) {
 	if m.listDeploymentsErr != nil {
 		return nil, m.listDeploymentsErr
@@ -200,6 +224,122 @@ func (m *mockStore) GetInterruptedDeployments(ctx context.Context) ([]*state.Dep
 	return result, nil
 }
 
+func (m *mockStore) UpdateDeploymentPhases(ctx context.Context, id string, phases []state.DeploymentPhase) error {
+	if d, ok := m.deployments[id]; ok {
+		d.Phases = phases
+	}
+	return nil
+}
+
+func (m *mockStore) GetDeploymentByIDPrefix(ctx context.Context, prefix string) (*state.Deployment, error) {
+	var found *state.Deployment
+	for id, d := range m.deployments {
+		if strings.HasPrefix(id, prefix) {
+			if found != nil {
+				return nil, errors.New("ambiguous deployment ID prefix")
+			}
+			found = d
+		}
+	}
+	if found == nil {
+		return nil, apperrors.ErrDeploymentNotFound
+	}
+	return found, nil
+}
+
+func (m *mockStore) CreateOperationLog(ctx context.Context, l *state.OperationLog) error {
+	if l.ID == "" {
+		l.ID = fmt.Sprintf("op-%d", len(m.operationLogs)+1)
+	}
+	if l.Status == "" {
+		l.Status = "running"
+	}
+	m.operationLogs = append(m.operationLogs, l)
+	return nil
+}
+
+func (m *mockStore) SetOperationLogDeployment(ctx context.Context, id, deploymentID string) error {
+	for _, l := range m.operationLogs {
+		if l.ID == id {
+			l.DeploymentID = deploymentID
+		}
+	}
+	return nil
+}
+
+func (m *mockStore) FinishOperationLog(ctx context.Context, id, status string) error {
+	for _, l := range m.operationLogs {
+		if l.ID == id {
+			now := time.Now()
+			l.Status = status
+			l.FinishedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *mockStore) GetOperationLog(ctx context.Context, id string) (*state.OperationLog, error) {
+	for _, l := range m.operationLogs {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+	return nil, apperrors.ErrOperationLogNotFound
+}
+
+func (m *mockStore) ListDeploymentOperationLogs(ctx context.Context, deploymentID string) ([]*state.OperationLog, error) {
+	var result []*state.OperationLog
+	for _, l := range m.operationLogs {
+		if l.DeploymentID == deploymentID {
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockStore) ListOperationLogs(ctx context.Context, projectID string, limit int) ([]*state.OperationLog, error) {
+	var result []*state.OperationLog
+	for i := len(m.operationLogs) - 1; i >= 0 && len(result) < limit; i-- {
+		if m.operationLogs[i].ProjectID == projectID {
+			result = append(result, m.operationLogs[i])
+		}
+	}
+	return result, nil
+}
+
+func (m *mockStore) CreateVolumeSnapshot(ctx context.Context, v *state.VolumeSnapshot) error {
+	if v.ID == "" {
+		v.ID = fmt.Sprintf("snapshot-%d", len(m.volumeSnapshots)+1)
+	}
+	m.volumeSnapshots = append(m.volumeSnapshots, v)
+	return nil
+}
+
+func (m *mockStore) ListVolumeSnapshots(ctx context.Context, deploymentID string) ([]*state.VolumeSnapshot, error) {
+	var result []*state.VolumeSnapshot
+	for _, v := range m.volumeSnapshots {
+		if v.DeploymentID == deploymentID {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockStore) GetRollbackSnapshots(ctx context.Context, projectID, gitSHA string) ([]*state.VolumeSnapshot, error) {
+	return nil, apperrors.ErrNoVolumeSnapshot
+}
+
+func (m *mockStore) DeleteVolumeSnapshots(ctx context.Context, deploymentID string) error {
+	var kept []*state.VolumeSnapshot
+	for _, v := range m.volumeSnapshots {
+		if v.DeploymentID != deploymentID {
+			kept = append(kept, v)
+		}
+	}
+	m.volumeSnapshots = kept
+	return nil
+}
+
 func (m *mockStore) SetEnvVars(ctx context.Context, projectID string, vars map[string]string) error {
 	return nil
 }
@@ -263,6 +403,16 @@ func (m *mockGit) ResolveRef(ctx context.Context, ref string) (string, error) {
 	return m.resolvedSHA, nil
 }
 
+func (m *mockGit) ResolveBranch(ctx context.Context, branch string) (string, error) {
+	return m.ResolveRef(ctx, branch)
+}
+
+func (m *mockGit) ListBranches(ctx context.Context) ([]string, error) {
+	return []string{m.defaultBranch}, nil
+}
+
+func (m *mockGit) ListTags(ctx context.Context) ([]string, error) { return nil, nil }
+
 func (m *mockGit) CreateWorktree(ctx context.Context, worktreePath, commit string) error {
 	if m.worktreeErr != nil {
 		return m.worktreeErr
@@ -308,6 +458,12 @@ func (m *mockGit) CommitExists(ctx context.Context, commit string) bool {
 	return commit == m.resolvedSHA
 }
 
+func (m *mockGit) Log(ctx context.Context, from, to string) ([]git.Commit, error) { return nil, nil }
+
+func (m *mockGit) Diff(ctx context.Context, from, to string, paths ...string) (string, error) {
+	return "", nil
+}
+
 // --- Test Helpers ---
 
 func setupTestDeployer(t *testing.T) (*Deployer, *mockStore, *mockGit, string, func()) {
@@ -643,6 +799,56 @@ func TestDeployer_Deploy_StatusUpdates(t *testing.T) {
 		_ = gitMgr // silence unused
 	})
 
+	t.Run("returns the failed deployment", func(t *testing.T) {
+		deployer, store, _, tmpDir, cleanup := setupTestDeployer(t)
+		defer cleanup()
+
+		project := createTestProject("proj-status-2", "status-error-test", "local")
+		project.RepoPath = filepath.Join(tmpDir, "repo")
+
+		_, err := deployer.Deploy(context.Background(), project, DeployOptions{
+			GitRef:   "v1.0.0",
+			DataDir:  tmpDir,
+			SkipPull: true,
+			OnStatus: func(string) {},
+		})
+		require.Error(t, err)
+
+		var deployErr *DeployError
+		require.ErrorAs(t, err, &deployErr)
+		require.Len(t, store.createdDeployments, 1)
+		assert.Equal(t, store.createdDeployments[0].ID, deployErr.DeploymentID)
+		assert.Nil(t, deployErr.Validation)
+	})
+
+	t.Run("returns missing env vars", func(t *testing.T) {
+		deployer, _, gitMgr, tmpDir, cleanup := setupTestDeployer(t)
+		defer cleanup()
+
+		project := createTestProject("proj-status-3", "status-env-test", "local")
+		project.RepoPath = filepath.Join(tmpDir, "repo")
+
+		worktreePath := git.GetWorktreePath(tmpDir, project.Name, gitMgr.resolvedSHA)
+		require.NoError(t, os.MkdirAll(worktreePath, 0755))
+		compose := "services:\n  web:\n    image: nginx\n    environment:\n      DB_PASSWORD: ${DB_PASSWORD:?set the password}\n"
+		require.NoError(t, os.WriteFile(filepath.Join(worktreePath, "compose.yaml"), []byte(compose), 0644))
+
+		_, err := deployer.Deploy(context.Background(), project, DeployOptions{
+			GitRef:   "v1.0.0",
+			DataDir:  tmpDir,
+			SkipPull: true,
+			OnStatus: func(string) {},
+		})
+		require.Error(t, err)
+		assert.ErrorIs(t, err, apperrors.ErrMissingEnvVars)
+
+		var deployErr *DeployError
+		require.ErrorAs(t, err, &deployErr)
+		require.NotNil(t, deployErr.Validation)
+		require.Len(t, deployErr.Validation.Missing, 1)
+		assert.Equal(t, "DB_PASSWORD", deployErr.Validation.Missing[0].Name)
+	})
+
 	t.Run("calls OnStatus and OnVerbose callbacks", func(t *testing.T) {
 		deployer, _, gitMgr, tmpDir, cleanup := setupTestDeployer(t)
 		defer cleanup()
@@ -689,6 +895,218 @@ func TestDeployer_Deploy_StatusUpdates(t *testing.T) {
 	})
 }
 
+func TestDeployer_Deploy_OperationLog(t *testing.T) {
+	deployer, store, _, tmpDir, cleanup := setupTestDeployer(t)
+	defer cleanup()
+
+	project := createTestProject("proj-oplog-1", "oplog-test", "local")
+	project.RepoPath = filepath.Join(tmpDir, "repo")
+
+	var statusMessages []string
+	_, deployErr := deployer.Deploy(context.Background(), project, DeployOptions{
+		GitRef:   "v1.0.0",
+		DataDir:  tmpDir,
+		SkipPull: true,
+		OnStatus: func(msg string) {
+			statusMessages = append(statusMessages, msg)
+		},
+	})
+	require.Error(t, deployErr)
+
+	require.Len(t, store.operationLogs, 1)
+	entry := store.operationLogs[0]
+	assert.Equal(t, "deploy", entry.Operation)
+	assert.Equal(t, "failed", entry.Status)
+	require.Len(t, store.createdDeployments, 1)
+	assert.Equal(t, store.createdDeployments[0].ID, entry.DeploymentID)
+	assert.Equal(t, filepath.Join(tmpDir, "logs", "oplog-test"), filepath.Dir(entry.LogPath))
+
+	data, err := os.ReadFile(entry.LogPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Deploying oplog-test")
+	assert.Contains(t, string(data), "Error: "+deployErr.Error())
+
+	assert.Contains(t, statusMessages, "Full deployment output: "+entry.LogPath)
+}
+
+func TestDeployer_Deploy_Metrics(t *testing.T) {
+	deployer, _, _, tmpDir, cleanup := setupTestDeployer(t)
+	defer cleanup()
+
+	project := createTestProject("proj-metrics-1", "metrics-test", "local")
+	project.RepoPath = filepath.Join(tmpDir, "repo")
+
+	for i := 0; i < 2; i++ {
+		_, err := deployer.Deploy(context.Background(), project, DeployOptions{
+			GitRef:   "v1.0.0",
+			DataDir:  tmpDir,
+			SkipPull: true,
+		})
+		require.Error(t, err)
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.DeploymentsTotal.WithLabelValues("metrics-test", metrics.DeployFailed)))
+	var duration dto.Metric
+	require.NoError(t, metrics.DeploymentDuration.WithLabelValues("metrics-test", metrics.DeployFailed).(prometheus.Metric).Write(&duration))
+	assert.EqualValues(t, 2, duration.GetHistogram().GetSampleCount())
+	assert.Zero(t, testutil.ToFloat64(metrics.DeploymentsTotal.WithLabelValues("metrics-test", metrics.DeploySucceeded)))
+}
+
+func TestDeployer_Deploy_Metadata(t *testing.T) {
+	deployer, store, _, tmpDir, cleanup := setupTestDeployer(t)
+	defer cleanup()
+
+	project := createTestProject("proj-meta-1", "meta-test", "remote")
+	project.RepoPath = filepath.Join(tmpDir, "repo")
+	store.deployments["previous"] = &state.Deployment{
+		ID:        "previous",
+		ProjectID: project.ID,
+		GitSHA:    "0123456789abcdef",
+		Status:    "active",
+	}
+
+	_, err := deployer.Deploy(context.Background(), project, DeployOptions{
+		GitRef:        "v1.0.0",
+		DataDir:       tmpDir,
+		SkipPull:      true,
+		OnStatus:      func(string) {},
+		TriggerSource: state.TriggerWebhook,
+		TriggeredBy:   "github:octocat",
+	})
+	require.Error(t, err)
+
+	require.Len(t, store.createdDeployments, 1)
+	d := store.createdDeployments[0]
+	assert.Equal(t, state.TriggerWebhook, d.TriggerSource)
+	assert.Equal(t, "github:octocat", d.TriggeredBy)
+	assert.Equal(t, "0123456789abcdef", d.PreviousSHA)
+
+	// Phases completed before the failure are kept
+	var names []string
+	for _, p := range d.Phases {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{PhaseFetch, PhaseWorktree}, names)
+}
+
+func TestDeployer_Deploy_Lineage(t *testing.T) {
+	tests := []struct {
+		name         string
+		earlier      *state.Deployment
+		wantKind     string
+		wantParentID string
+	}{
+		{
+			name:     "new commit is a deploy",
+			wantKind: state.KindDeploy,
+		},
+		{
+			name:         "commit that went live before is a redeploy",
+			earlier:      &state.Deployment{ID: "earlier", Status: "inactive"},
+			wantKind:     state.KindRedeploy,
+			wantParentID: "earlier",
+		},
+		{
+			name:     "commit that only failed before is a deploy",
+			earlier:  &state.Deployment{ID: "earlier", Status: "failed"},
+			wantKind: state.KindDeploy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployer, store, gitMgr, tmpDir, cleanup := setupTestDeployer(t)
+			defer cleanup()
+
+			project := createTestProject("proj-lineage-1", "lineage-test", "local")
+			project.RepoPath = filepath.Join(tmpDir, "repo")
+			if tt.earlier != nil {
+				tt.earlier.ProjectID = project.ID
+				tt.earlier.GitSHA = gitMgr.resolvedSHA
+				store.deployments[tt.earlier.ID] = tt.earlier
+			}
+
+			_, err := deployer.Deploy(context.Background(), project, DeployOptions{
+				GitRef:   "main",
+				DataDir:  tmpDir,
+				SkipPull: true,
+				OnStatus: func(string) {},
+			})
+			require.Error(t, err)
+
+			require.Len(t, store.createdDeployments, 1)
+			d := store.createdDeployments[0]
+			assert.Equal(t, tt.wantKind, d.Kind)
+			assert.Equal(t, tt.wantParentID, d.ParentDeploymentID)
+		})
+	}
+}
+
+func TestDeployer_Deploy_Host(t *testing.T) {
+	web1 := target.Target{Name: "web1", DockerHost: "ssh://deploy@web1"}
+
+	tests := []struct {
+		name         string
+		existing     []*state.Deployment
+		wantPrevious string
+		wantKind     string
+	}{
+		{
+			name: "first deploy to a host ignores other hosts",
+			existing: []*state.Deployment{
+				{ID: "local", GitSHA: "0123456789abcdef", Status: "active"},
+			},
+			wantKind: state.KindDeploy,
+		},
+		{
+			name: "previous deployment comes from the same host",
+			existing: []*state.Deployment{
+				{ID: "local", GitSHA: "0123456789abcdef", Status: "active"},
+				{ID: "remote", GitSHA: "fedcba9876543210", Status: "active", Host: "web1"},
+			},
+			wantPrevious: "fedcba9876543210",
+			wantKind:     state.KindDeploy,
+		},
+		{
+			name: "commit live on another host is a deploy here",
+			existing: []*state.Deployment{
+				{ID: "local", GitSHA: "abc123def456789012345678901234567890abcd", Status: "active"},
+			},
+			wantKind: state.KindDeploy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployer, store, _, tmpDir, cleanup := setupTestDeployer(t)
+			defer cleanup()
+
+			project := createTestProject("proj-host-1", "host-test", "local")
+			project.RepoPath = filepath.Join(tmpDir, "repo")
+			for _, d := range tt.existing {
+				d.ProjectID = project.ID
+				store.deployments[d.ID] = d
+			}
+
+			_, err := deployer.Deploy(context.Background(), project, DeployOptions{
+				SHA:      "abc123def456789012345678901234567890abcd",
+				DataDir:  tmpDir,
+				SkipPull: true,
+				OnStatus: func(string) {},
+				Target:   web1,
+			})
+			require.Error(t, err)
+
+			require.Len(t, store.createdDeployments, 1)
+			d := store.createdDeployments[0]
+			assert.Equal(t, "web1", d.Host)
+			assert.Equal(t, tt.wantPrevious, d.PreviousSHA)
+			assert.Equal(t, tt.wantKind, d.Kind)
+			assert.Equal(t, "abc123def456789012345678901234567890abcd", d.GitSHA)
+		})
+	}
+}
+
 func TestDeployer_CleanupOldWorktrees(t *testing.T) {
 	tests := []struct {
 		name           string
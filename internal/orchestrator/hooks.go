@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hookRunner executes the hooks declared in otterstack.yaml for one deployment.
+type hookRunner struct {
+	hooks       projectconfig.Hooks
+	composeMgr  compose.ComposeOperations
+	envFilePath string
+	workDir     string
+	env         []string // OTTERSTACK_* variables describing the deployment
+	hostEnv     []string // Project variables, exported to host hooks only
+	stdout      io.Writer
+	stderr      io.Writer
+	onStatus    func(string)
+}
+
+// run executes the hooks of a phase in order.
+// A failing blocking hook stops the phase and returns an error wrapping
+// errors.ErrHookFailed; non-blocking failures are reported and skipped.
+func (r *hookRunner) run(ctx context.Context, phase string, extraEnv ...string) error {
+	for _, hook := range r.hooks.ForPhase(phase) {
+		r.onStatus(fmt.Sprintf("Running %s hook %q...", phase, hook.DisplayName()))
+
+		err := r.runHook(ctx, phase, hook, extraEnv)
+		if err == nil {
+			continue
+		}
+		if hook.IsBlocking(phase) {
+			return fmt.Errorf("%w: %s hook %q: %v", errors.ErrHookFailed, phase, hook.DisplayName(), err)
+		}
+		r.onStatus(fmt.Sprintf("Warning: %s hook %q failed (continuing): %v", phase, hook.DisplayName(), err))
+	}
+	return nil
+}
+
+// runHook executes a single hook with its own timeout. The hook is traced,
+// and receives the span's trace context in TRACEPARENT.
+func (r *hookRunner) runHook(ctx context.Context, phase string, hook projectconfig.Hook, extraEnv []string) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "hook", trace.WithAttributes(
+		tracing.AttrPhase.String(phase),
+		tracing.AttrHook.String(hook.DisplayName())))
+	defer tracing.EndErr(span, &err)
+
+	timeout := hook.TimeoutOrDefault()
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	env := append([]string{"OTTERSTACK_HOOK_PHASE=" + phase}, r.env...)
+	env = append(env, extraEnv...)
+
+	if hook.Service != "" {
+		env = append(env, tracing.Environ(hookCtx)...)
+		return r.composeMgr.Run(hookCtx, r.envFilePath, hook.Service, hook.Command, env)
+	}
+
+	cmd := exec.CommandContext(hookCtx, hook.Command[0], hook.Command[1:]...)
+	cmd.Dir = r.workDir
+	cmd.Env = append(append(target.Environ(hookCtx), r.hostEnv...), env...)
+	cmd.Stdout = r.stdout
+	cmd.Stderr = r.stderr
+
+	if err := cmd.Run(); err != nil {
+		if hookCtx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	apperrors "github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// mockCompose records one-off container runs for hook tests.
+type mockCompose struct {
+	compose.ComposeOperations
+	runs   []mockRun
+	runErr error
+}
+
+type mockRun struct {
+	service string
+	command []string
+	env     []string
+}
+
+func (m *mockCompose) Run(ctx context.Context, envFilePath, service string, command, env []string) error {
+	m.runs = append(m.runs, mockRun{service: service, command: command, env: env})
+	return m.runErr
+}
+
+func newTestHookRunner(t *testing.T, hooks projectconfig.Hooks) (*hookRunner, *mockCompose, *[]string) {
+	var messages []string
+	composeMgr := &mockCompose{}
+	return &hookRunner{
+		hooks:      hooks,
+		composeMgr: composeMgr,
+		workDir:    t.TempDir(),
+		env:        []string{"OTTERSTACK_PROJECT=myapp"},
+		hostEnv:    []string{"DATABASE_URL=postgres://db"},
+		onStatus:   func(msg string) { messages = append(messages, msg) },
+	}, composeMgr, &messages
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestHookRunner_HostCommand(t *testing.T) {
+	runner, _, _ := newTestHookRunner(t, projectconfig.Hooks{
+		PostSwitch: []projectconfig.Hook{
+			{Name: "record", Command: projectconfig.Command{"sh", "-c", `echo "$OTTERSTACK_HOOK_PHASE $OTTERSTACK_PROJECT $DATABASE_URL $EXTRA" > out.txt`}},
+		},
+	})
+
+	err := runner.run(context.Background(), projectconfig.PhasePostSwitch, "EXTRA=1")
+	require.NoError(t, err)
+
+	out, err := os.ReadFile(filepath.Join(runner.workDir, "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "post_switch myapp postgres://db 1\n", string(out))
+}
+
+func TestHookRunner_ServiceCommand(t *testing.T) {
+	runner, composeMgr, _ := newTestHookRunner(t, projectconfig.Hooks{
+		PreUp: []projectconfig.Hook{
+			{Name: "migrate", Service: "web", Command: projectconfig.Command{"./manage.py", "migrate"}},
+		},
+	})
+
+	require.NoError(t, runner.run(context.Background(), projectconfig.PhasePreUp))
+
+	require.Len(t, composeMgr.runs, 1)
+	assert.Equal(t, "web", composeMgr.runs[0].service)
+	assert.Equal(t, []string{"./manage.py", "migrate"}, composeMgr.runs[0].command)
+	assert.Contains(t, composeMgr.runs[0].env, "OTTERSTACK_HOOK_PHASE=pre_up")
+	assert.Contains(t, composeMgr.runs[0].env, "OTTERSTACK_PROJECT=myapp")
+	assert.NotContains(t, composeMgr.runs[0].env, "DATABASE_URL=postgres://db", "project vars reach containers through the env file")
+}
+
+func TestHookRunner_TraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(tracing.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	runner, composeMgr, _ := newTestHookRunner(t, projectconfig.Hooks{
+		PostSwitch: []projectconfig.Hook{
+			{Name: "record", Command: projectconfig.Command{"sh", "-c", `echo "$TRACEPARENT" > out.txt`}},
+			{Name: "notify", Service: "web", Command: projectconfig.Command{"true"}},
+		},
+	})
+	require.NoError(t, runner.run(context.Background(), projectconfig.PhasePostSwitch))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	traceparent := func(s sdktrace.ReadOnlySpan) string {
+		return "00-" + s.SpanContext().TraceID().String() + "-" + s.SpanContext().SpanID().String() + "-01"
+	}
+
+	out, err := os.ReadFile(filepath.Join(runner.workDir, "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, traceparent(spans[0])+"\n", string(out), "host hooks continue the hook span")
+
+	require.Len(t, composeMgr.runs, 1)
+	assert.Contains(t, composeMgr.runs[0].env, "TRACEPARENT="+traceparent(spans[1]), "service hooks continue the hook span")
+	assert.Equal(t, "hook", spans[1].Name())
+	assert.Contains(t, spans[1].Attributes(), tracing.AttrHook.String("notify"))
+}
+
+func TestHookRunner_Failures(t *testing.T) {
+	t.Run("blocking failure stops the phase", func(t *testing.T) {
+		runner, composeMgr, _ := newTestHookRunner(t, projectconfig.Hooks{
+			PreUp: []projectconfig.Hook{
+				{Name: "fails", Command: projectconfig.Command{"false"}},
+				{Name: "never runs", Service: "web", Command: projectconfig.Command{"true"}},
+			},
+		})
+
+		err := runner.run(context.Background(), projectconfig.PhasePreUp)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, apperrors.ErrHookFailed))
+		assert.Contains(t, err.Error(), `pre_up hook "fails"`)
+		assert.Empty(t, composeMgr.runs)
+	})
+
+	t.Run("non-blocking failure continues", func(t *testing.T) {
+		runner, composeMgr, messages := newTestHookRunner(t, projectconfig.Hooks{
+			PostUpHealthy: []projectconfig.Hook{
+				{Name: "optional", Command: projectconfig.Command{"false"}, Blocking: boolPtr(false)},
+				{Name: "smoke", Service: "web", Command: projectconfig.Command{"true"}},
+			},
+		})
+
+		require.NoError(t, runner.run(context.Background(), projectconfig.PhasePostUpHealthy))
+		assert.Len(t, composeMgr.runs, 1)
+		assert.Contains(t, (*messages)[1], `Warning: post_up_healthy hook "optional" failed`)
+	})
+
+	t.Run("on_failure hooks never block", func(t *testing.T) {
+		runner, composeMgr, _ := newTestHookRunner(t, projectconfig.Hooks{
+			OnFailure: []projectconfig.Hook{{Service: "web", Command: projectconfig.Command{"notify"}}},
+		})
+		composeMgr.runErr = errors.New("exit status 1")
+
+		assert.NoError(t, runner.run(context.Background(), projectconfig.PhaseOnFailure))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		runner, _, _ := newTestHookRunner(t, projectconfig.Hooks{
+			PreUp: []projectconfig.Hook{
+				{Name: "slow", Command: projectconfig.Command{"sleep", "5"}, Timeout: projectconfig.Duration(50 * time.Millisecond)},
+			},
+		})
+
+		err := runner.run(context.Background(), projectconfig.PhasePreUp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out after 50ms")
+	})
+}
+
+func TestEnvPairs(t *testing.T) {
+	assert.Equal(t, []string{"A=1", "B=two"}, envPairs(map[string]string{"B": "two", "A": "1"}))
+	assert.Empty(t, envPairs(nil))
+}
@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Deployment phases whose durations are recorded on the deployment.
+const (
+	PhaseFetch    = "fetch"
+	PhaseWorktree = "worktree"
+	PhasePull     = "pull"
+	PhaseBuild    = "build"
+	PhaseSnapshot = "snapshot" // volume snapshots before pre_up hooks
+	PhaseUp       = "up"       // pre_up hooks and compose up
+	PhaseHealth   = "health"   // health probe and post_up_healthy hooks
+	PhaseSwitch   = "switch"   // Traefik switch, post_switch hooks and stopping the previous deployment
+)
+
+// phaseTimer measures the phases of one deployment. Time between phases,
+// such as validation, is not attributed to any phase.
+//
+// Each phase is also traced as a child span of ctx, so the work done during
+// a phase nests under it when run with the context start returns. That
+// context's logger carries the phase field.
+type phaseTimer struct {
+	now     func() time.Time
+	ctx     context.Context // Parent of the phase spans
+	phases  []state.DeploymentPhase
+	current string
+	started time.Time
+	span    trace.Span
+}
+
+func newPhaseTimer(ctx context.Context) *phaseTimer {
+	return &phaseTimer{now: time.Now, ctx: ctx}
+}
+
+// start ends the running phase, if any, and begins a new one. It returns
+// the context to run the phase with.
+func (t *phaseTimer) start(name string) context.Context {
+	t.stop()
+	t.current = name
+	t.started = t.now()
+	ctx, span := tracing.Tracer().Start(t.parent(), "deploy."+name, trace.WithAttributes(tracing.AttrPhase.String(name)))
+	t.span = span
+	ctx = logging.WithFields(ctx, logging.String("phase", name))
+	logging.FromContext(ctx).Debug("phase started")
+	return ctx
+}
+
+// stop ends the running phase and returns the context outside of phases.
+func (t *phaseTimer) stop() context.Context {
+	if t.current == "" {
+		return t.parent()
+	}
+	phase := state.DeploymentPhase{
+		Name:     t.current,
+		Duration: t.now().Sub(t.started),
+	}
+	t.phases = append(t.phases, phase)
+	logging.FromContext(t.parent()).Debug("phase finished",
+		logging.String("phase", phase.Name), logging.Duration("duration", phase.Duration))
+	t.span.End()
+	t.span = nil
+	t.current = ""
+	return t.parent()
+}
+
+// fail records err against the running phase, if any.
+func (t *phaseTimer) fail(err error) {
+	if t.current != "" {
+		tracing.RecordError(t.span, err)
+	}
+}
+
+// result ends the running phase and returns all measured phases in order.
+func (t *phaseTimer) result() []state.DeploymentPhase {
+	t.stop()
+	return t.phases
+}
+
+func (t *phaseTimer) parent() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestPhaseTimer(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	timer := &phaseTimer{now: func() time.Time { return clock }}
+
+	timer.start(PhasePull)
+	clock = clock.Add(10 * time.Second)
+	timer.stop()
+
+	// Time between phases is not attributed
+	clock = clock.Add(time.Minute)
+
+	timer.start(PhaseUp)
+	clock = clock.Add(3 * time.Second)
+	timer.start(PhaseHealth) // starting a phase ends the previous one
+	clock = clock.Add(2 * time.Second)
+
+	assert.Equal(t, []state.DeploymentPhase{
+		{Name: PhasePull, Duration: 10 * time.Second},
+		{Name: PhaseUp, Duration: 3 * time.Second},
+		{Name: PhaseHealth, Duration: 2 * time.Second},
+	}, timer.result())
+
+	// result is idempotent once no phase is running
+	assert.Len(t, timer.result(), 3)
+}
+
+func TestPhaseTimer_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(tracing.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	ctx, deploy := tracing.Tracer().Start(context.Background(), "deploy")
+	timer := newPhaseTimer(ctx)
+
+	pullCtx := timer.start(PhasePull)
+	_, pull := tracing.Tracer().Start(pullCtx, "compose.pull_with_env")
+	pull.End()
+	assert.Equal(t, ctx, timer.stop(), "stop returns the context outside of phases")
+
+	timer.start(PhaseUp)
+	timer.fail(errors.New("container exited"))
+	timer.result()
+	deploy.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	require.Len(t, spans, 4)
+
+	assert.Equal(t, spans["deploy"].SpanContext().SpanID(), spans["deploy.pull"].Parent().SpanID())
+	assert.Equal(t, spans["deploy.pull"].SpanContext().SpanID(), spans["compose.pull_with_env"].Parent().SpanID())
+	assert.Equal(t, spans["deploy"].SpanContext().SpanID(), spans["deploy.up"].Parent().SpanID())
+	assert.Equal(t, []attribute.KeyValue{tracing.AttrPhase.String(PhaseUp)}, spans["deploy.up"].Attributes())
+	assert.Equal(t, codes.Unset, spans["deploy.pull"].Status().Code)
+	assert.Equal(t, codes.Error, spans["deploy.up"].Status().Code)
+}
+
+func TestPhaseTimer_Logs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, slog.LevelDebug)
+	require.NoError(t, err)
+	ctx := logging.WithLogger(context.Background(), logger.With(logging.String("deployment_id", "d1")))
+	timer := newPhaseTimer(ctx)
+
+	buildCtx := timer.start(PhaseBuild)
+	logging.FromContext(buildCtx).Info("Building images...")
+	timer.stop()
+	logging.FromContext(timer.parent()).Info("Validating compose file...")
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		records = append(records, rec)
+	}
+	require.Len(t, records, 4)
+
+	assert.Equal(t, "phase started", records[0]["msg"])
+	assert.Equal(t, "Building images...", records[1]["msg"])
+	assert.Equal(t, PhaseBuild, records[1]["phase"])
+	assert.Equal(t, "d1", records[1]["deployment_id"])
+	assert.Equal(t, "phase finished", records[2]["msg"])
+	assert.Equal(t, PhaseBuild, records[2]["phase"])
+	assert.NotContains(t, records[3], "phase", "logs between phases have no phase")
+}
@@ -0,0 +1,162 @@
+package orchestrator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+)
+
+// deploySlots is the host-wide counting lock that limits how many
+// deployments run at once.
+const deploySlots = "deploys"
+
+// ResourceCheck is the free disk space and memory a deployment to the local
+// engine needs before it pulls and builds images. Zero minimums are not
+// checked.
+type ResourceCheck struct {
+	MinFreeDisk   uint64        // Bytes free on the filesystem holding DiskPath
+	DiskPath      string        // Defaults to the data directory
+	MinFreeMemory uint64        // Bytes of memory available without swapping
+	Wait          time.Duration // How long to wait for resources before failing; zero fails at once
+}
+
+// enabled reports whether the check has any minimum to enforce.
+func (c ResourceCheck) enabled() bool {
+	return c.MinFreeDisk > 0 || c.MinFreeMemory > 0
+}
+
+// Resources are measured through these so tests can replace them. Each
+// reports false if the resource cannot be measured on this platform.
+var (
+	freeDiskSpace   = statfsFree
+	availableMemory = meminfoAvailable
+)
+
+// resourcePollInterval is how often a deployment waiting for resources
+// measures them again.
+var resourcePollInterval = 5 * time.Second
+
+// shortfall describes the first resource below its minimum, or returns an
+// empty string if there is enough of both.
+func (c ResourceCheck) shortfall(dataDir string) (string, error) {
+	if c.MinFreeDisk > 0 {
+		path := c.DiskPath
+		if path == "" {
+			path = dataDir
+		}
+		free, ok, err := freeDiskSpace(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to measure free disk space on %s: %w", path, err)
+		}
+		if ok && free < c.MinFreeDisk {
+			return fmt.Sprintf("%s free on %s, %s required", formatBytes(free), path, formatBytes(c.MinFreeDisk)), nil
+		}
+	}
+
+	if c.MinFreeMemory > 0 {
+		free, ok, err := availableMemory()
+		if err != nil {
+			return "", fmt.Errorf("failed to measure available memory: %w", err)
+		}
+		if ok && free < c.MinFreeMemory {
+			return fmt.Sprintf("%s of memory available, %s required", formatBytes(free), formatBytes(c.MinFreeMemory)), nil
+		}
+	}
+	return "", nil
+}
+
+// waitForResources returns once the host has the free disk and memory c
+// requires. It fails with ErrInsufficientResources if they are still short
+// after c.Wait.
+func waitForResources(ctx context.Context, c ResourceCheck, dataDir string, onStatus func(string)) error {
+	deadline := time.Now().Add(c.Wait)
+	waiting := false
+	for {
+		short, err := c.shortfall(dataDir)
+		if err != nil {
+			return err
+		}
+		if short == "" {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %s", errors.ErrInsufficientResources, short)
+		}
+		if !waiting {
+			onStatus(fmt.Sprintf("Waiting up to %s for resources: %s", c.Wait, short))
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(resourcePollInterval):
+		}
+	}
+}
+
+// meminfoAvailable reads MemAvailable from /proc/meminfo. Systems without
+// it are not measured.
+func meminfoAvailable() (uint64, bool, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	defer f.Close()
+	return parseMemAvailable(f)
+}
+
+// parseMemAvailable reads the MemAvailable line of /proc/meminfo, e.g.
+// "MemAvailable:    1534212 kB".
+func parseMemAvailable(r io.Reader) (uint64, bool, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid MemAvailable %q", fields[1])
+		}
+		return kb * 1024, true, nil
+	}
+	// Kernels before 3.14 do not report it
+	return 0, false, scanner.Err()
+}
+
+// formatBytes formats a size with a binary unit, e.g. "1.5 GiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatPIDs lists process IDs for a status message, e.g. "412, 977".
+func formatPIDs(pids []int) string {
+	if len(pids) == 0 {
+		return "unknown"
+	}
+	parts := make([]string, len(pids))
+	for i, pid := range pids {
+		parts[i] = strconv.Itoa(pid)
+	}
+	return strings.Join(parts, ", ")
+}
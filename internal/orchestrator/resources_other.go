@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package orchestrator
+
+// statfsFree does not measure free disk space on this platform.
+func statfsFree(path string) (uint64, bool, error) {
+	return 0, false, nil
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package orchestrator
+
+import "syscall"
+
+// statfsFree returns the bytes available to unprivileged users on the
+// filesystem holding path.
+func statfsFree(path string) (uint64, bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true, nil
+}
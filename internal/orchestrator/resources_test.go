@@ -0,0 +1,123 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/lock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResources replaces the resource measurements for the duration of a test.
+func fakeResources(t *testing.T, disk, memory func() uint64) {
+	t.Helper()
+	oldDisk, oldMemory, oldInterval := freeDiskSpace, availableMemory, resourcePollInterval
+	t.Cleanup(func() { freeDiskSpace, availableMemory, resourcePollInterval = oldDisk, oldMemory, oldInterval })
+
+	freeDiskSpace = func(string) (uint64, bool, error) { return disk(), true, nil }
+	availableMemory = func() (uint64, bool, error) { return memory(), true, nil }
+	resourcePollInterval = time.Millisecond
+}
+
+func TestResourceCheck(t *testing.T) {
+	const gib = 1 << 30
+
+	t.Run("enough", func(t *testing.T) {
+		fakeResources(t, func() uint64 { return 10 * gib }, func() uint64 { return 2 * gib })
+		c := ResourceCheck{MinFreeDisk: 5 * gib, MinFreeMemory: gib}
+
+		short, err := c.shortfall("/data")
+		require.NoError(t, err)
+		assert.Empty(t, short)
+	})
+
+	t.Run("disk short", func(t *testing.T) {
+		fakeResources(t, func() uint64 { return gib }, func() uint64 { return 2 * gib })
+		c := ResourceCheck{MinFreeDisk: 5 * gib, DiskPath: "/var/lib/docker"}
+
+		short, err := c.shortfall("/data")
+		require.NoError(t, err)
+		assert.Equal(t, "1.0 GiB free on /var/lib/docker, 5.0 GiB required", short)
+	})
+
+	t.Run("memory short fails at once without a wait", func(t *testing.T) {
+		fakeResources(t, func() uint64 { return 10 * gib }, func() uint64 { return 256 << 20 })
+		c := ResourceCheck{MinFreeMemory: gib}
+
+		var messages []string
+		err := waitForResources(context.Background(), c, "/data", func(msg string) { messages = append(messages, msg) })
+		assert.ErrorIs(t, err, errors.ErrInsufficientResources)
+		assert.Contains(t, err.Error(), "256.0 MiB of memory available, 1.0 GiB required")
+		assert.Empty(t, messages)
+	})
+
+	t.Run("waits until resources are free", func(t *testing.T) {
+		free := uint64(gib)
+		fakeResources(t, func() uint64 {
+			free += gib
+			return free
+		}, func() uint64 { return gib })
+		c := ResourceCheck{MinFreeDisk: 4 * gib, Wait: time.Minute}
+
+		var messages []string
+		err := waitForResources(context.Background(), c, "/data", func(msg string) { messages = append(messages, msg) })
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.True(t, strings.HasPrefix(messages[0], "Waiting up to 1m0s for resources"), messages[0])
+	})
+
+	t.Run("unmeasured resources pass", func(t *testing.T) {
+		fakeResources(t, func() uint64 { return 0 }, func() uint64 { return 0 })
+		availableMemory = func() (uint64, bool, error) { return 0, false, nil }
+
+		short, err := ResourceCheck{MinFreeMemory: gib}.shortfall("/data")
+		require.NoError(t, err)
+		assert.Empty(t, short)
+	})
+}
+
+func TestParseMemAvailable(t *testing.T) {
+	meminfo := "MemTotal:        4028440 kB\nMemFree:          171452 kB\nMemAvailable:    1534212 kB\nBuffers:           93284 kB\n"
+	available, ok, err := parseMemAvailable(strings.NewReader(meminfo))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1534212*1024), available)
+
+	_, ok, err = parseMemAvailable(strings.NewReader("MemTotal:        4028440 kB\n"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDeployer_Deploy_Slots(t *testing.T) {
+	deployer, _, _, tmpDir, cleanup := setupTestDeployer(t)
+	defer cleanup()
+
+	// Another deployment holds the only slot
+	lockMgr, err := lock.NewManager(tmpDir)
+	require.NoError(t, err)
+	slot, err := lockMgr.TryAcquireSlot(deploySlots, 1)
+	require.NoError(t, err)
+	require.NotNil(t, slot)
+	defer slot.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var messages []string
+	project := createTestProject("proj-slots", "slots-test", "local")
+	_, err = deployer.Deploy(ctx, project, DeployOptions{
+		GitRef:        "v1.0.0",
+		DataDir:       tmpDir,
+		MaxConcurrent: 1,
+		OnStatus:      func(msg string) { messages = append(messages, msg) },
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "failed to acquire deploy slot")
+	require.NotEmpty(t, messages)
+	assert.Contains(t, messages[0], "Waiting for a deploy slot")
+}
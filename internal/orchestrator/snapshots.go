@@ -0,0 +1,216 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/volume"
+)
+
+// SnapshotDir returns the directory holding the volume snapshots taken before a deployment.
+func SnapshotDir(dataDir, projectName, deploymentID string) string {
+	return filepath.Join(dataDir, "snapshots", projectName, deploymentID)
+}
+
+// snapshotPhase copies the volumes listed in otterstack.yaml before pre_up
+// hooks and compose up can change them.
+type snapshotPhase struct {
+	store       state.StateStore
+	cfg         projectconfig.Snapshots
+	composeMgr  *compose.Manager
+	envFilePath string
+	dir         string
+	projectID   string
+	deployment  string
+	onStatus    func(string)
+	onVerbose   func(string)
+}
+
+// run takes the snapshots. Volumes that do not exist yet have nothing to
+// protect and are skipped. On failure no snapshot of the deployment is kept,
+// so a rollback never restores a partial set.
+func (p *snapshotPhase) run(ctx context.Context) (retErr error) {
+	if len(p.cfg.Volumes) == 0 {
+		return nil
+	}
+
+	names, err := p.composeMgr.VolumeNames(ctx, p.envFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve volumes to snapshot: %w", err)
+	}
+
+	defer func() {
+		if retErr != nil {
+			removeSnapshots(context.WithoutCancel(ctx), p.store, p.dir, p.deployment)
+		}
+	}()
+
+	for _, key := range p.cfg.Volumes {
+		name, ok := names[key]
+		if !ok {
+			return fmt.Errorf("snapshots.volumes: compose file declares no volume %q", key)
+		}
+
+		exists, err := volume.Exists(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			p.onVerbose(fmt.Sprintf("Volume %s does not exist yet, nothing to snapshot", name))
+			continue
+		}
+
+		p.onStatus(fmt.Sprintf("Snapshotting volume %s...", name))
+		snapshot, err := p.snapshot(ctx, name)
+		if err != nil {
+			return err
+		}
+		snapshot.ComposeVolume = key
+		if err := p.store.CreateVolumeSnapshot(ctx, snapshot); err != nil {
+			os.Remove(snapshot.Path)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// snapshot exports one volume, pausing the containers using it if configured.
+func (p *snapshotPhase) snapshot(ctx context.Context, name string) (_ *state.VolumeSnapshot, retErr error) {
+	if p.cfg.Pause {
+		resume, err := volume.Pause(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := resume(context.WithoutCancel(ctx)); err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+	}
+
+	if err := os.MkdirAll(p.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	path := filepath.Join(p.dir, name+".tar")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, h)}
+	if err := volume.Export(ctx, name, counter); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return &state.VolumeSnapshot{
+		ProjectID:      p.projectID,
+		DeploymentID:   p.deployment,
+		VolumeName:     name,
+		ComposeProject: p.composeMgr.ProjectName(),
+		Path:           path,
+		SizeBytes:      counter.n,
+		SHA256:         hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// RestoreSnapshots replaces the contents of each snapshotted volume with its
+// snapshot. Every snapshot is checked before any volume is touched, and no
+// volume may be in use by a running container.
+func RestoreSnapshots(ctx context.Context, snapshots []*state.VolumeSnapshot, onStatus func(string)) error {
+	if err := VerifySnapshots(snapshots); err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		inUse, err := volume.InUse(ctx, s.VolumeName)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return fmt.Errorf("volume %s is used by a running container", s.VolumeName)
+		}
+	}
+
+	for _, s := range snapshots {
+		onStatus(fmt.Sprintf("Restoring volume %s...", s.VolumeName))
+		f, err := os.Open(s.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		err = volume.Import(ctx, volume.Volume{
+			Name:           s.VolumeName,
+			ComposeProject: s.ComposeProject,
+			ComposeVolume:  s.ComposeVolume,
+		}, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifySnapshots checks that every snapshot file is present and matches its
+// recorded size and checksum.
+func VerifySnapshots(snapshots []*state.VolumeSnapshot) error {
+	for _, s := range snapshots {
+		if err := verifySnapshot(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifySnapshot(s *state.VolumeSnapshot) error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return fmt.Errorf("snapshot of volume %s is missing: %w", s.VolumeName, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot of volume %s: %w", s.VolumeName, err)
+	}
+	if n != s.SizeBytes || hex.EncodeToString(h.Sum(nil)) != s.SHA256 {
+		return fmt.Errorf("snapshot of volume %s does not match its checksum", s.VolumeName)
+	}
+	return nil
+}
+
+// removeSnapshots deletes the snapshot files and records of a deployment.
+func removeSnapshots(ctx context.Context, store state.StateStore, dir, deploymentID string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove snapshots: %w", err)
+	}
+	return store.DeleteVolumeSnapshots(ctx, deploymentID)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
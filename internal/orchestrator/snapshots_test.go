@@ -0,0 +1,90 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestSnapshot writes a snapshot file for a deployment and records it in store.
+func writeTestSnapshot(t *testing.T, store *mockStore, dataDir, projectName, deploymentID string) *state.VolumeSnapshot {
+	t.Helper()
+
+	dir := SnapshotDir(dataDir, projectName, deploymentID)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	content := []byte("volume contents of " + deploymentID)
+	path := filepath.Join(dir, "data.tar")
+	require.NoError(t, os.WriteFile(path, content, 0600))
+
+	sum := sha256.Sum256(content)
+	snapshot := &state.VolumeSnapshot{
+		DeploymentID: deploymentID,
+		VolumeName:   projectName + "_data",
+		Path:         path,
+		SizeBytes:    int64(len(content)),
+		SHA256:       hex.EncodeToString(sum[:]),
+	}
+	require.NoError(t, store.CreateVolumeSnapshot(context.Background(), snapshot))
+	return snapshot
+}
+
+func TestVerifySnapshots(t *testing.T) {
+	_, store, _, tmpDir, cleanup := setupTestDeployer(t)
+	defer cleanup()
+
+	snapshot := writeTestSnapshot(t, store, tmpDir, "myapp", "d1")
+	require.NoError(t, VerifySnapshots([]*state.VolumeSnapshot{snapshot}))
+
+	t.Run("modified file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(snapshot.Path, []byte("tampered"), 0600))
+		err := VerifySnapshots([]*state.VolumeSnapshot{snapshot})
+		assert.ErrorContains(t, err, "does not match its checksum")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		require.NoError(t, os.Remove(snapshot.Path))
+		err := VerifySnapshots([]*state.VolumeSnapshot{snapshot})
+		assert.ErrorContains(t, err, "is missing")
+	})
+}
+
+func TestDeployer_CleanupOldWorktrees_Snapshots(t *testing.T) {
+	deployer, store, _, tmpDir, cleanup := setupTestDeployer(t)
+	defer cleanup()
+
+	project := createTestProject("proj-snapshots", "snapshots-test", "local")
+	project.WorktreeRetention = 1
+
+	for _, d := range []*state.Deployment{
+		{ID: "d1", GitSHA: "sha1", WorktreePath: "/path/1", Status: "inactive"},
+		{ID: "d2", GitSHA: "sha2", WorktreePath: "", Status: "inactive"},
+		{ID: "d3", GitSHA: "sha3", WorktreePath: "/path/3", Status: "active"},
+	} {
+		d.ProjectID = project.ID
+		store.deployments[d.ID] = d
+		writeTestSnapshot(t, store, tmpDir, project.Name, d.ID)
+	}
+
+	require.NoError(t, deployer.CleanupOldWorktrees(context.Background(), project, tmpDir, nil))
+
+	// d1 is within retention and d3 is active; d2 has no worktree but its snapshots still go
+	for id, kept := range map[string]bool{"d1": true, "d2": false, "d3": true} {
+		snapshots, err := store.ListVolumeSnapshots(context.Background(), id)
+		require.NoError(t, err)
+		_, statErr := os.Stat(SnapshotDir(tmpDir, project.Name, id))
+		if kept {
+			assert.Len(t, snapshots, 1, id)
+			assert.NoError(t, statErr, id)
+		} else {
+			assert.Empty(t, snapshots, id)
+			assert.True(t, os.IsNotExist(statErr), id)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Package projectconfig loads the declarative otterstack.yaml shipped in a
+// repository and merges it with the per-project settings stored in SQLite.
+//
+// The file is read from the worktree of the commit being deployed, so deploy
+// behaviour is versioned with the code:
+//
+//	compose_file: compose.prod.yml
+//	worktree_retention: 5
+//	traefik_routing: true
+//	timeouts:
+//	  build: 20m
+//	  deploy: 10m
+//	  stop: 45s
+//	health:
+//	  timeout: 3m
+//	  interval: 5s
+//	failure_log_lines: 100
+//	build:
+//	  args: [NODE_ENV, SENTRY_RELEASE]
+//	hooks:
+//	  pre_up:
+//	    - name: migrate
+//	      service: web
+//	      command: ["./manage.py", "migrate"]
+//	      timeout: 10m
+//	  post_up_healthy:
+//	    - name: smoke
+//	      command: curl -fsS http://localhost:8080/health
+//	      timeout: 30s
+//	snapshots:
+//	  volumes: [db-data]
+//	  pause: true
+//
+// Precedence, lowest to highest: built-in defaults, stored project settings,
+// otterstack.yaml, explicit command line flags.
+package projectconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/state"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the config location relative to the repository root.
+const FileName = "otterstack.yaml"
+
+// Built-in defaults for settings that are not stored per project.
+const (
+	DefaultBuildTimeout    = 30 * time.Minute
+	DefaultDeployTimeout   = 5 * time.Minute
+	DefaultStopTimeout     = 30 * time.Second
+	DefaultHealthTimeout   = 5 * time.Minute
+	DefaultHealthInterval  = 2 * time.Second
+	DefaultFailureLogLines = 50
+)
+
+var (
+	argNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// volumeKeyPattern matches the keys of the compose file's top-level volumes section.
+	volumeKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// Sources of an effective setting, from lowest to highest precedence.
+const (
+	SourceDefault = "default"
+	SourceProject = "project"
+	SourceFile    = FileName
+	SourceFlag    = "flag"
+)
+
+// Duration is a time.Duration that unmarshals from strings such as "90s" or "5m".
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	if parsed <= 0 {
+		return fmt.Errorf("duration %q must be positive", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// File is the otterstack.yaml document. Unset fields leave the stored or
+// default value in place.
+type File struct {
+	ComposeFile       string    `yaml:"compose_file"`
+	WorktreeRetention *int      `yaml:"worktree_retention"`
+	TraefikRouting    *bool     `yaml:"traefik_routing"`
+	Timeouts          Timeouts  `yaml:"timeouts"`
+	Health            Health    `yaml:"health"`
+	FailureLogLines   *int      `yaml:"failure_log_lines"`
+	Build             Build     `yaml:"build"`
+	Hooks             Hooks     `yaml:"hooks"`
+	Snapshots         Snapshots `yaml:"snapshots"`
+}
+
+// Timeouts configures how long deploy steps may take.
+type Timeouts struct {
+	Build  Duration `yaml:"build"`  // docker compose build
+	Deploy Duration `yaml:"deploy"` // docker compose up
+	Stop   Duration `yaml:"stop"`   // stopping the previous deployment
+}
+
+// Build configures the image build phase for services with a build section.
+type Build struct {
+	// Args names project environment variables passed to the build as --build-arg.
+	Args []string `yaml:"args"`
+}
+
+// Snapshots configures the named volumes copied before each deployment
+// starts its containers, so rollback --restore-volumes can undo data changes
+// such as migrations run by pre_up hooks.
+type Snapshots struct {
+	// Volumes names keys of the compose file's top-level volumes section.
+	Volumes []string `yaml:"volumes"`
+	// Pause freezes the containers using a volume while it is copied, for a
+	// consistent copy of databases at the cost of a short pause in service.
+	Pause bool `yaml:"pause"`
+}
+
+// Health configures the container health probe run before traffic is switched.
+type Health struct {
+	Timeout  Duration `yaml:"timeout"`
+	Interval Duration `yaml:"interval"`
+}
+
+// Config is the effective configuration for a deployment.
+type Config struct {
+	ComposeFile       string
+	WorktreeRetention int
+	TraefikRouting    bool
+	BuildTimeout      time.Duration
+	DeployTimeout     time.Duration
+	StopTimeout       time.Duration
+	HealthTimeout     time.Duration
+	HealthInterval    time.Duration
+	FailureLogLines   int
+	BuildArgs         []string
+	Hooks             Hooks
+	Snapshots         Snapshots
+
+	// Sources maps each setting key (as spelled in otterstack.yaml) to where its value came from.
+	Sources map[string]string
+}
+
+// Keys lists the setting keys in display order.
+var Keys = []string{
+	"compose_file",
+	"worktree_retention",
+	"traefik_routing",
+	"timeouts.build",
+	"timeouts.deploy",
+	"timeouts.stop",
+	"health.timeout",
+	"health.interval",
+	"failure_log_lines",
+	"build.args",
+	"hooks",
+	"snapshots",
+}
+
+// Load reads otterstack.yaml from a repository directory.
+// Returns nil and no error when the repository does not ship one.
+func Load(dir string) (*File, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+
+	file, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	return file, nil
+}
+
+// Parse decodes and checks an otterstack.yaml document.
+// Unknown keys are rejected so typos do not silently fall back to defaults.
+func Parse(data []byte) (*File, error) {
+	file := &File{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if file.ComposeFile != "" {
+		if strings.Contains(file.ComposeFile, "..") ||
+			strings.Contains(file.ComposeFile, "/") ||
+			strings.Contains(file.ComposeFile, "\\") {
+			return nil, fmt.Errorf("compose_file cannot contain path separators or parent directory references")
+		}
+	}
+	if file.WorktreeRetention != nil && *file.WorktreeRetention < 0 {
+		return nil, fmt.Errorf("worktree_retention cannot be negative")
+	}
+	if file.FailureLogLines != nil && *file.FailureLogLines < 0 {
+		return nil, fmt.Errorf("failure_log_lines cannot be negative")
+	}
+	for _, arg := range file.Build.Args {
+		if !argNamePattern.MatchString(arg) {
+			return nil, fmt.Errorf("build.args: invalid variable name %q", arg)
+		}
+	}
+	if err := file.Hooks.validate(); err != nil {
+		return nil, err
+	}
+	for _, name := range file.Snapshots.Volumes {
+		if !volumeKeyPattern.MatchString(name) {
+			return nil, fmt.Errorf("snapshots.volumes: invalid volume name %q", name)
+		}
+	}
+
+	return file, nil
+}
+
+// Resolve merges the built-in defaults, the stored project settings and the
+// repository file. file may be nil.
+func Resolve(project *state.Project, file *File) *Config {
+	cfg := &Config{
+		ComposeFile:       project.ComposeFile,
+		WorktreeRetention: project.WorktreeRetention,
+		TraefikRouting:    project.TraefikRoutingEnabled,
+		BuildTimeout:      DefaultBuildTimeout,
+		DeployTimeout:     DefaultDeployTimeout,
+		StopTimeout:       DefaultStopTimeout,
+		HealthTimeout:     DefaultHealthTimeout,
+		HealthInterval:    DefaultHealthInterval,
+		FailureLogLines:   DefaultFailureLogLines,
+		Sources: map[string]string{
+			"compose_file":       SourceProject,
+			"worktree_retention": SourceProject,
+			"traefik_routing":    SourceProject,
+			"timeouts.build":     SourceDefault,
+			"timeouts.deploy":    SourceDefault,
+			"timeouts.stop":      SourceDefault,
+			"health.timeout":     SourceDefault,
+			"health.interval":    SourceDefault,
+			"failure_log_lines":  SourceDefault,
+			"build.args":         SourceDefault,
+			"hooks":              SourceDefault,
+			"snapshots":          SourceDefault,
+		},
+	}
+
+	if file == nil {
+		return cfg
+	}
+
+	if file.ComposeFile != "" {
+		cfg.ComposeFile = file.ComposeFile
+		cfg.Sources["compose_file"] = SourceFile
+	}
+	if file.WorktreeRetention != nil {
+		cfg.WorktreeRetention = *file.WorktreeRetention
+		cfg.Sources["worktree_retention"] = SourceFile
+	}
+	if file.TraefikRouting != nil {
+		cfg.TraefikRouting = *file.TraefikRouting
+		cfg.Sources["traefik_routing"] = SourceFile
+	}
+	if file.Timeouts.Build > 0 {
+		cfg.BuildTimeout = time.Duration(file.Timeouts.Build)
+		cfg.Sources["timeouts.build"] = SourceFile
+	}
+	if file.Timeouts.Deploy > 0 {
+		cfg.DeployTimeout = time.Duration(file.Timeouts.Deploy)
+		cfg.Sources["timeouts.deploy"] = SourceFile
+	}
+	if file.Timeouts.Stop > 0 {
+		cfg.StopTimeout = time.Duration(file.Timeouts.Stop)
+		cfg.Sources["timeouts.stop"] = SourceFile
+	}
+	if file.Health.Timeout > 0 {
+		cfg.HealthTimeout = time.Duration(file.Health.Timeout)
+		cfg.Sources["health.timeout"] = SourceFile
+	}
+	if file.Health.Interval > 0 {
+		cfg.HealthInterval = time.Duration(file.Health.Interval)
+		cfg.Sources["health.interval"] = SourceFile
+	}
+	if file.FailureLogLines != nil {
+		cfg.FailureLogLines = *file.FailureLogLines
+		cfg.Sources["failure_log_lines"] = SourceFile
+	}
+	if len(file.Build.Args) > 0 {
+		cfg.BuildArgs = file.Build.Args
+		cfg.Sources["build.args"] = SourceFile
+	}
+	if !file.Hooks.Empty() {
+		cfg.Hooks = file.Hooks
+		cfg.Sources["hooks"] = SourceFile
+	}
+	if len(file.Snapshots.Volumes) > 0 {
+		cfg.Snapshots = file.Snapshots
+		cfg.Sources["snapshots"] = SourceFile
+	}
+
+	return cfg
+}
+
+// SetDeployTimeout overrides the deploy timeout from an explicit command line flag.
+func (c *Config) SetDeployTimeout(timeout time.Duration) {
+	c.DeployTimeout = timeout
+	c.Sources["timeouts.deploy"] = SourceFlag
+}
+
+// Value returns the display value of a setting key.
+func (c *Config) Value(key string) string {
+	switch key {
+	case "compose_file":
+		return c.ComposeFile
+	case "worktree_retention":
+		return fmt.Sprintf("%d", c.WorktreeRetention)
+	case "traefik_routing":
+		return fmt.Sprintf("%t", c.TraefikRouting)
+	case "timeouts.build":
+		return c.BuildTimeout.String()
+	case "timeouts.deploy":
+		return c.DeployTimeout.String()
+	case "timeouts.stop":
+		return c.StopTimeout.String()
+	case "health.timeout":
+		return c.HealthTimeout.String()
+	case "health.interval":
+		return c.HealthInterval.String()
+	case "failure_log_lines":
+		return fmt.Sprintf("%d", c.FailureLogLines)
+	case "build.args":
+		if len(c.BuildArgs) == 0 {
+			return "none"
+		}
+		return strings.Join(c.BuildArgs, ", ")
+	case "hooks":
+		return c.Hooks.String()
+	case "snapshots":
+		if len(c.Snapshots.Volumes) == 0 {
+			return "none"
+		}
+		value := strings.Join(c.Snapshots.Volumes, ", ")
+		if c.Snapshots.Pause {
+			value += " (paused)"
+		}
+		return value
+	}
+	return ""
+}
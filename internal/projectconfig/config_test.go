@@ -0,0 +1,145 @@
+package projectconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testProject() *state.Project {
+	return &state.Project{
+		Name:                  "myapp",
+		ComposeFile:           "docker-compose.yml",
+		WorktreeRetention:     3,
+		TraefikRoutingEnabled: false,
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		file, err := Load(t.TempDir())
+		require.NoError(t, err)
+		assert.Nil(t, file)
+	})
+
+	t.Run("file in repository", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte(`
+compose_file: compose.prod.yml
+worktree_retention: 5
+traefik_routing: true
+timeouts:
+  deploy: 10m
+health:
+  interval: 5s
+`), 0644))
+
+		file, err := Load(dir)
+		require.NoError(t, err)
+		require.NotNil(t, file)
+		assert.Equal(t, "compose.prod.yml", file.ComposeFile)
+		assert.Equal(t, 5, *file.WorktreeRetention)
+		assert.True(t, *file.TraefikRouting)
+		assert.Equal(t, Duration(10*time.Minute), file.Timeouts.Deploy)
+		assert.Equal(t, Duration(5*time.Second), file.Health.Interval)
+	})
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"unknown key", "compose: docker-compose.yml\n"},
+		{"bad duration", "timeouts:\n  deploy: soon\n"},
+		{"negative duration", "health:\n  timeout: -5s\n"},
+		{"compose file with path", "compose_file: ../other/compose.yml\n"},
+		{"negative retention", "worktree_retention: -1\n"},
+		{"bad snapshot volume", "snapshots:\n  volumes: [\"../data\"]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Run("without file uses project settings and defaults", func(t *testing.T) {
+		cfg := Resolve(testProject(), nil)
+
+		assert.Equal(t, "docker-compose.yml", cfg.ComposeFile)
+		assert.Equal(t, 3, cfg.WorktreeRetention)
+		assert.False(t, cfg.TraefikRouting)
+		assert.Equal(t, DefaultDeployTimeout, cfg.DeployTimeout)
+		assert.Equal(t, DefaultHealthInterval, cfg.HealthInterval)
+		assert.Equal(t, DefaultFailureLogLines, cfg.FailureLogLines)
+		assert.Equal(t, SourceProject, cfg.Sources["compose_file"])
+		assert.Equal(t, SourceDefault, cfg.Sources["timeouts.deploy"])
+	})
+
+	t.Run("file overrides project settings", func(t *testing.T) {
+		file, err := Parse([]byte(`
+compose_file: compose.prod.yml
+traefik_routing: true
+worktree_retention: 0
+failure_log_lines: 0
+timeouts:
+  stop: 45s
+`))
+		require.NoError(t, err)
+
+		cfg := Resolve(testProject(), file)
+
+		assert.Equal(t, "compose.prod.yml", cfg.ComposeFile)
+		assert.True(t, cfg.TraefikRouting)
+		assert.Equal(t, 0, cfg.WorktreeRetention, "explicit zero is honoured")
+		assert.Equal(t, 0, cfg.FailureLogLines)
+		assert.Equal(t, 45*time.Second, cfg.StopTimeout)
+		assert.Equal(t, DefaultDeployTimeout, cfg.DeployTimeout)
+		assert.Equal(t, SourceFile, cfg.Sources["compose_file"])
+		assert.Equal(t, SourceFile, cfg.Sources["timeouts.stop"])
+		assert.Equal(t, SourceDefault, cfg.Sources["timeouts.deploy"])
+	})
+
+	t.Run("flag overrides file", func(t *testing.T) {
+		file, err := Parse([]byte("timeouts:\n  deploy: 10m\n"))
+		require.NoError(t, err)
+
+		cfg := Resolve(testProject(), file)
+		cfg.SetDeployTimeout(time.Minute)
+
+		assert.Equal(t, time.Minute, cfg.DeployTimeout)
+		assert.Equal(t, SourceFlag, cfg.Sources["timeouts.deploy"])
+	})
+
+	t.Run("snapshots", func(t *testing.T) {
+		file, err := Parse([]byte("snapshots:\n  volumes: [db-data, app-files]\n  pause: true\n"))
+		require.NoError(t, err)
+
+		cfg := Resolve(testProject(), file)
+
+		assert.Equal(t, []string{"db-data", "app-files"}, cfg.Snapshots.Volumes)
+		assert.True(t, cfg.Snapshots.Pause)
+		assert.Equal(t, SourceFile, cfg.Sources["snapshots"])
+		assert.Equal(t, "db-data, app-files (paused)", cfg.Value("snapshots"))
+	})
+}
+
+func TestConfig_Value(t *testing.T) {
+	cfg := Resolve(testProject(), nil)
+
+	for _, key := range Keys {
+		assert.NotEmpty(t, cfg.Value(key), key)
+		assert.NotEmpty(t, cfg.Sources[key], key)
+	}
+	assert.Equal(t, "5m0s", cfg.Value("timeouts.deploy"))
+	assert.Equal(t, "false", cfg.Value("traefik_routing"))
+}
@@ -0,0 +1,149 @@
+package projectconfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Hook phases, in the order they run during a deployment.
+const (
+	PhasePreUp         = "pre_up"          // after images are available, before compose up
+	PhasePostUpHealthy = "post_up_healthy" // after containers are healthy, before traffic is switched
+	PhasePostSwitch    = "post_switch"     // after traffic is switched, before the previous deployment stops
+	PhaseOnFailure     = "on_failure"      // after any failure; never blocking
+)
+
+// DefaultHookTimeout bounds a hook that does not set its own timeout.
+const DefaultHookTimeout = 5 * time.Minute
+
+// Hooks lists the commands to run at each deploy phase.
+type Hooks struct {
+	PreUp         []Hook `yaml:"pre_up"`
+	PostUpHealthy []Hook `yaml:"post_up_healthy"`
+	PostSwitch    []Hook `yaml:"post_switch"`
+	OnFailure     []Hook `yaml:"on_failure"`
+}
+
+// Hook is a single command run at a deploy phase.
+//
+// With Service set the command runs in a one-off container of that service
+// (docker compose run --rm) against the new compose project; otherwise it runs
+// on the host from the worktree directory.
+type Hook struct {
+	Name     string   `yaml:"name"`
+	Service  string   `yaml:"service"`
+	Command  Command  `yaml:"command"`
+	Timeout  Duration `yaml:"timeout"`
+	Blocking *bool    `yaml:"blocking"`
+}
+
+// Command is a hook command. A string is run through sh -c; a list is executed as is.
+type Command []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Command) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var s string
+		if err := value.Decode(&s); err != nil {
+			return err
+		}
+		*c = Command{"sh", "-c", s}
+		return nil
+	}
+
+	var args []string
+	if err := value.Decode(&args); err != nil {
+		return err
+	}
+	*c = args
+	return nil
+}
+
+// ForPhase returns the hooks declared for a phase.
+func (h Hooks) ForPhase(phase string) []Hook {
+	switch phase {
+	case PhasePreUp:
+		return h.PreUp
+	case PhasePostUpHealthy:
+		return h.PostUpHealthy
+	case PhasePostSwitch:
+		return h.PostSwitch
+	case PhaseOnFailure:
+		return h.OnFailure
+	}
+	return nil
+}
+
+// Empty reports whether no hooks are declared.
+func (h Hooks) Empty() bool {
+	return len(h.PreUp)+len(h.PostUpHealthy)+len(h.PostSwitch)+len(h.OnFailure) == 0
+}
+
+// String summarises the declared hooks by phase.
+func (h Hooks) String() string {
+	var parts []string
+	for _, phase := range []string{PhasePreUp, PhasePostUpHealthy, PhasePostSwitch, PhaseOnFailure} {
+		hooks := h.ForPhase(phase)
+		if len(hooks) == 0 {
+			continue
+		}
+		names := make([]string, len(hooks))
+		for i, hook := range hooks {
+			names[i] = hook.DisplayName()
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", phase, strings.Join(names, ", ")))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// validate checks every hook in every phase.
+func (h Hooks) validate() error {
+	for _, phase := range []string{PhasePreUp, PhasePostUpHealthy, PhasePostSwitch, PhaseOnFailure} {
+		for i, hook := range h.ForPhase(phase) {
+			if len(hook.Command) == 0 || strings.TrimSpace(hook.Command[len(hook.Command)-1]) == "" {
+				return fmt.Errorf("hooks.%s[%d]: command is required", phase, i)
+			}
+			if phase == PhaseOnFailure && hook.Blocking != nil && *hook.Blocking {
+				return fmt.Errorf("hooks.%s[%d]: on_failure hooks cannot be blocking", phase, i)
+			}
+		}
+	}
+	return nil
+}
+
+// DisplayName returns the hook name, or its command when unnamed.
+func (h Hook) DisplayName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	if len(h.Command) == 3 && h.Command[0] == "sh" && h.Command[1] == "-c" {
+		return h.Command[2]
+	}
+	return strings.Join(h.Command, " ")
+}
+
+// IsBlocking reports whether a failure of the hook aborts the deployment.
+// Hooks block by default, except on_failure hooks which never do.
+func (h Hook) IsBlocking(phase string) bool {
+	if phase == PhaseOnFailure {
+		return false
+	}
+	if h.Blocking != nil {
+		return *h.Blocking
+	}
+	return true
+}
+
+// TimeoutOrDefault returns the hook timeout, or DefaultHookTimeout when unset.
+func (h Hook) TimeoutOrDefault() time.Duration {
+	if h.Timeout > 0 {
+		return time.Duration(h.Timeout)
+	}
+	return DefaultHookTimeout
+}
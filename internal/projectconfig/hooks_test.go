@@ -0,0 +1,73 @@
+package projectconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Hooks(t *testing.T) {
+	file, err := Parse([]byte(`
+hooks:
+  pre_up:
+    - name: migrate
+      service: web
+      command: ["./manage.py", "migrate"]
+      timeout: 10m
+  post_up_healthy:
+    - command: curl -fsS http://localhost:8080/health
+      blocking: false
+  on_failure:
+    - command: ./notify-failure.sh
+`))
+	require.NoError(t, err)
+
+	hooks := file.Hooks
+	require.Len(t, hooks.PreUp, 1)
+	migrate := hooks.PreUp[0]
+	assert.Equal(t, "migrate", migrate.DisplayName())
+	assert.Equal(t, "web", migrate.Service)
+	assert.Equal(t, Command{"./manage.py", "migrate"}, migrate.Command)
+	assert.Equal(t, 10*time.Minute, migrate.TimeoutOrDefault())
+	assert.True(t, migrate.IsBlocking(PhasePreUp))
+
+	require.Len(t, hooks.PostUpHealthy, 1)
+	smoke := hooks.PostUpHealthy[0]
+	assert.Equal(t, Command{"sh", "-c", "curl -fsS http://localhost:8080/health"}, smoke.Command)
+	assert.Equal(t, "curl -fsS http://localhost:8080/health", smoke.DisplayName())
+	assert.Equal(t, DefaultHookTimeout, smoke.TimeoutOrDefault())
+	assert.False(t, smoke.IsBlocking(PhasePostUpHealthy))
+
+	require.Len(t, hooks.OnFailure, 1)
+	assert.False(t, hooks.OnFailure[0].IsBlocking(PhaseOnFailure))
+
+	assert.Equal(t, "pre_up: migrate; post_up_healthy: curl -fsS http://localhost:8080/health; on_failure: ./notify-failure.sh", hooks.String())
+	assert.Equal(t, SourceFile, Resolve(testProject(), file).Sources["hooks"])
+}
+
+func TestParse_HooksInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"missing command", "hooks:\n  pre_up:\n    - name: migrate\n"},
+		{"empty command", "hooks:\n  pre_up:\n    - command: ''\n"},
+		{"blocking on_failure", "hooks:\n  on_failure:\n    - command: x\n      blocking: true\n"},
+		{"unknown phase", "hooks:\n  post_up:\n    - command: x\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHooks_Empty(t *testing.T) {
+	assert.True(t, Hooks{}.Empty())
+	assert.Equal(t, "none", Hooks{}.String())
+	assert.False(t, Hooks{PostSwitch: []Hook{{Command: Command{"true"}}}}.Empty())
+}
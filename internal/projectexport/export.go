@@ -0,0 +1,315 @@
+// Package projectexport reads and writes project export files, which move a
+// project to another OtterStack host. An export is a JSON document holding
+// the project's registration and settings, its environment variables and its
+// deployment history:
+//
+//	{
+//	  "kind": "otterstack-project",
+//	  "format_version": 1,
+//	  "project": {"name": "myapp", "repo_type": "remote", ...},
+//	  "env": {"vars": {"DATABASE_URL": "..."}},
+//	  "deployments": [{"id": "...", "git_sha": "...", ...}]
+//	}
+//
+// With a passphrase the environment variables are sealed with AES-256-GCM
+// under a key derived with PBKDF2-SHA256, and "env" holds only the ciphertext.
+// Paths on the exporting host, such as worktrees and operation logs, are not
+// carried over.
+package projectexport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/state"
+)
+
+// Kind identifies a project export file.
+const Kind = "otterstack-project"
+
+// FormatVersion is the export layout written by this version of OtterStack.
+const FormatVersion = 1
+
+// Key derivation settings for sealed environment variables.
+const (
+	kdfName       = "pbkdf2-sha256"
+	kdfIterations = 600000
+	saltSize      = 16
+)
+
+// File is a project export.
+type File struct {
+	Kind              string       `json:"kind"`
+	FormatVersion     int          `json:"format_version"`
+	ExportedAt        time.Time    `json:"exported_at"`
+	OtterStackVersion string       `json:"otterstack_version"`
+	Project           Project      `json:"project"`
+	Env               Env          `json:"env"`
+	Deployments       []Deployment `json:"deployments"`
+}
+
+// Project is the registration and settings of the exported project.
+type Project struct {
+	Name              string `json:"name"`
+	RepoType          string `json:"repo_type"`
+	RepoURL           string `json:"repo_url,omitempty"`
+	RepoPath          string `json:"repo_path,omitempty"` // only meaningful for local repositories
+	ComposeFile       string `json:"compose_file"`
+	WorktreeRetention int    `json:"worktree_retention"`
+	TraefikRouting    bool   `json:"traefik_routing"`
+	Status            string `json:"status"`
+}
+
+// Env holds the environment variables, either in plain text or sealed.
+type Env struct {
+	Vars   map[string]string `json:"vars,omitempty"`
+	Sealed *Sealed           `json:"sealed,omitempty"`
+}
+
+// Sealed is an encrypted JSON object of environment variables.
+type Sealed struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Deployment is a deployment history record.
+type Deployment struct {
+	ID                  string                  `json:"id"`
+	GitSHA              string                  `json:"git_sha"`
+	GitRef              string                  `json:"git_ref,omitempty"`
+	Status              string                  `json:"status"`
+	ErrorMessage        string                  `json:"error_message,omitempty"`
+	StartedAt           time.Time               `json:"started_at"`
+	FinishedAt          *time.Time              `json:"finished_at,omitempty"`
+	TriggerSource       string                  `json:"trigger_source,omitempty"`
+	TriggeredBy         string                  `json:"triggered_by,omitempty"`
+	PreviousSHA         string                  `json:"previous_sha,omitempty"`
+	Phases              []state.DeploymentPhase `json:"phases,omitempty"`
+	Kind                string                  `json:"kind"`
+	ParentDeploymentID  string                  `json:"parent_deployment_id,omitempty"`
+	RevertsDeploymentID string                  `json:"reverts_deployment_id,omitempty"`
+}
+
+// New builds an export of a project. With a non-empty passphrase the
+// environment variables are sealed.
+func New(project *state.Project, vars map[string]string, deployments []*state.Deployment, passphrase string) (*File, error) {
+	f := &File{
+		Kind:          Kind,
+		FormatVersion: FormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Project: Project{
+			Name:              project.Name,
+			RepoType:          project.RepoType,
+			RepoURL:           project.RepoURL,
+			ComposeFile:       project.ComposeFile,
+			WorktreeRetention: project.WorktreeRetention,
+			TraefikRouting:    project.TraefikRoutingEnabled,
+			Status:            project.Status,
+		},
+	}
+	if project.RepoType != "remote" {
+		f.Project.RepoPath = project.RepoPath
+	}
+
+	if len(vars) > 0 {
+		if passphrase == "" {
+			f.Env.Vars = vars
+		} else {
+			sealed, err := seal(vars, passphrase)
+			if err != nil {
+				return nil, err
+			}
+			f.Env.Sealed = sealed
+		}
+	}
+
+	for _, d := range deployments {
+		f.Deployments = append(f.Deployments, Deployment{
+			ID:                  d.ID,
+			GitSHA:              d.GitSHA,
+			GitRef:              d.GitRef,
+			Status:              d.Status,
+			ErrorMessage:        d.ErrorMessage,
+			StartedAt:           d.StartedAt,
+			FinishedAt:          d.FinishedAt,
+			TriggerSource:       d.TriggerSource,
+			TriggeredBy:         d.TriggeredBy,
+			PreviousSHA:         d.PreviousSHA,
+			Phases:              d.Phases,
+			Kind:                d.Kind,
+			ParentDeploymentID:  d.ParentDeploymentID,
+			RevertsDeploymentID: d.RevertsDeploymentID,
+		})
+	}
+	// Oldest first, so records are imported after the ones they link to
+	sort.SliceStable(f.Deployments, func(i, j int) bool {
+		return f.Deployments[i].StartedAt.Before(f.Deployments[j].StartedAt)
+	})
+
+	return f, nil
+}
+
+// Write encodes the export as indented JSON.
+func (f *File) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// Read decodes and checks a project export.
+func Read(r io.Reader) (*File, error) {
+	var f File
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("not a project export: %w", err)
+	}
+	if f.Kind != Kind {
+		return nil, fmt.Errorf("not a project export: kind is %q, expected %q", f.Kind, Kind)
+	}
+	if f.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("export format %d is newer than this otterstack supports (%d); upgrade otterstack", f.FormatVersion, FormatVersion)
+	}
+	if f.Project.Name == "" || f.Project.RepoType == "" {
+		return nil, fmt.Errorf("project export is missing the project name or repository type")
+	}
+	return &f, nil
+}
+
+// Sealed reports whether the environment variables need a passphrase.
+func (f *File) Sealed() bool {
+	return f.Env.Sealed != nil
+}
+
+// EnvVars returns the environment variables, unsealing them with passphrase
+// if needed. Returns ErrInvalidPassphrase if the passphrase is wrong.
+func (f *File) EnvVars(passphrase string) (map[string]string, error) {
+	if f.Env.Sealed == nil {
+		return f.Env.Vars, nil
+	}
+	return open(f.Env.Sealed, passphrase)
+}
+
+// StateDeployments converts the history into records for a project on this
+// host. Records get new IDs, with lineage links rewritten to match, so an
+// export can be imported next to the project it came from. Worktree paths
+// are set by worktreePath; nothing runs on this host yet, so the active
+// deployment becomes inactive and unfinished ones interrupted.
+func (f *File) StateDeployments(projectID string, worktreePath func(sha string) string) []*state.Deployment {
+	ids := make(map[string]string, len(f.Deployments))
+	for _, d := range f.Deployments {
+		ids[d.ID] = uuid.New().String()
+	}
+
+	deployments := make([]*state.Deployment, 0, len(f.Deployments))
+	for _, d := range f.Deployments {
+		status := d.Status
+		switch status {
+		case "active":
+			status = "inactive"
+		case "deploying":
+			status = "interrupted"
+		}
+
+		deployments = append(deployments, &state.Deployment{
+			ID:                  ids[d.ID],
+			ProjectID:           projectID,
+			GitSHA:              d.GitSHA,
+			GitRef:              d.GitRef,
+			WorktreePath:        worktreePath(d.GitSHA),
+			Status:              status,
+			ErrorMessage:        d.ErrorMessage,
+			StartedAt:           d.StartedAt,
+			FinishedAt:          d.FinishedAt,
+			TriggerSource:       d.TriggerSource,
+			TriggeredBy:         d.TriggeredBy,
+			PreviousSHA:         d.PreviousSHA,
+			Phases:              d.Phases,
+			Kind:                d.Kind,
+			ParentDeploymentID:  ids[d.ParentDeploymentID],
+			RevertsDeploymentID: ids[d.RevertsDeploymentID],
+		})
+	}
+	return deployments
+}
+
+// seal encrypts environment variables with a key derived from passphrase.
+func seal(vars map[string]string, passphrase string) (*Sealed, error) {
+	plaintext, err := json.Marshal(vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode environment variables: %w", err)
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	gcm, err := newGCM(passphrase, salt, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &Sealed{
+		KDF:        kdfName,
+		Iterations: kdfIterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, []byte(Kind)),
+	}, nil
+}
+
+// open decrypts sealed environment variables.
+func open(s *Sealed, passphrase string) (map[string]string, error) {
+	if s.KDF != kdfName || s.Iterations <= 0 {
+		return nil, fmt.Errorf("unsupported key derivation %q", s.KDF)
+	}
+
+	gcm, err := newGCM(passphrase, s.Salt, s.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid sealed environment variables")
+	}
+	plaintext, err := gcm.Open(nil, s.Nonce, s.Ciphertext, []byte(Kind))
+	if err != nil {
+		// GCM cannot tell a wrong key from tampering; a wrong passphrase is far more likely
+		return nil, errors.ErrInvalidPassphrase
+	}
+
+	var vars map[string]string
+	if err := json.Unmarshal(plaintext, &vars); err != nil {
+		return nil, fmt.Errorf("failed to decode environment variables: %w", err)
+	}
+	return vars, nil
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
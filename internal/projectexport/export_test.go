@@ -0,0 +1,127 @@
+package projectexport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testProject() *state.Project {
+	return &state.Project{
+		ID:                    "proj-1",
+		Name:                  "myapp",
+		RepoType:              "remote",
+		RepoURL:               "https://github.com/test/myapp.git",
+		RepoPath:              "/var/lib/otterstack/repos/myapp",
+		ComposeFile:           "compose.yaml",
+		WorktreeRetention:     3,
+		TraefikRoutingEnabled: true,
+		Status:                "ready",
+	}
+}
+
+func testDeployments() []*state.Deployment {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	// Newest first, as ListDeployments returns them
+	return []*state.Deployment{
+		{ID: "d3", GitSHA: "aaa111", Status: "active", StartedAt: start.Add(2 * time.Hour), Kind: state.KindRollback, ParentDeploymentID: "d1", RevertsDeploymentID: "d2"},
+		{ID: "d2", GitSHA: "bbb222", Status: "rolled_back", StartedAt: start.Add(time.Hour), Kind: state.KindDeploy},
+		{ID: "d1", GitSHA: "aaa111", Status: "inactive", StartedAt: start, Kind: state.KindDeploy},
+	}
+}
+
+func roundTrip(t *testing.T, f *File) *File {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+	got, err := Read(&buf)
+	require.NoError(t, err)
+	return got
+}
+
+func TestExport_PlainRoundTrip(t *testing.T) {
+	vars := map[string]string{"DATABASE_URL": "postgres://db", "API_KEY": "secret"}
+	f, err := New(testProject(), vars, testDeployments(), "")
+	require.NoError(t, err)
+
+	got := roundTrip(t, f)
+	assert.False(t, got.Sealed())
+	assert.Equal(t, "myapp", got.Project.Name)
+	assert.True(t, got.Project.TraefikRouting)
+	assert.Empty(t, got.Project.RepoPath, "remote repo paths are host specific")
+
+	gotVars, err := got.EnvVars("")
+	require.NoError(t, err)
+	assert.Equal(t, vars, gotVars)
+
+	require.Len(t, got.Deployments, 3)
+	assert.Equal(t, "d1", got.Deployments[0].ID, "deployments are ordered oldest first")
+	assert.Equal(t, "d3", got.Deployments[2].ID)
+}
+
+func TestExport_SealedRoundTrip(t *testing.T) {
+	vars := map[string]string{"API_KEY": "secret"}
+	f, err := New(testProject(), vars, nil, "correct horse")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+	assert.NotContains(t, buf.String(), "secret")
+
+	got, err := Read(&buf)
+	require.NoError(t, err)
+	assert.True(t, got.Sealed())
+
+	gotVars, err := got.EnvVars("correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, vars, gotVars)
+
+	_, err = got.EnvVars("wrong horse")
+	assert.ErrorIs(t, err, errors.ErrInvalidPassphrase)
+}
+
+func TestRead_Rejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"not json", "backup.tar.zst"},
+		{"wrong kind", `{"kind": "something-else", "format_version": 1, "project": {"name": "a", "repo_type": "local"}}`},
+		{"newer format", `{"kind": "otterstack-project", "format_version": 99, "project": {"name": "a", "repo_type": "local"}}`},
+		{"missing project", `{"kind": "otterstack-project", "format_version": 1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestStateDeployments(t *testing.T) {
+	f, err := New(testProject(), nil, testDeployments(), "")
+	require.NoError(t, err)
+
+	got := f.StateDeployments("proj-2", func(sha string) string { return "/worktrees/" + sha })
+	require.Len(t, got, 3)
+
+	byOldID := map[string]*state.Deployment{}
+	for i, d := range f.Deployments {
+		byOldID[d.ID] = got[i]
+		assert.NotEqual(t, d.ID, got[i].ID, "records get new IDs")
+		assert.Equal(t, "proj-2", got[i].ProjectID)
+		assert.Equal(t, "/worktrees/"+d.GitSHA, got[i].WorktreePath)
+	}
+
+	rollback := byOldID["d3"]
+	assert.Equal(t, "inactive", rollback.Status, "nothing is active on the new host")
+	assert.Equal(t, byOldID["d1"].ID, rollback.ParentDeploymentID)
+	assert.Equal(t, byOldID["d2"].ID, rollback.RevertsDeploymentID)
+	assert.Empty(t, byOldID["d1"].ParentDeploymentID)
+}
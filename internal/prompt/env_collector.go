@@ -7,12 +7,14 @@ import (
 
 	"github.com/charmbracelet/huh"
 	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/envschema"
 )
 
 // CollectMissingVars interactively prompts the user for missing environment variables.
-// It detects variable types and applies appropriate validation.
-// Returns a map of variable names to values entered by the user.
-func CollectMissingVars(missing []compose.EnvVarReference) (map[string]string, error) {
+// Variables declared in the repository's env schema use the declared type,
+// description, enum and secret flag; others fall back to type detection.
+// schema may be nil. Returns a map of variable names to values entered by the user.
+func CollectMissingVars(missing []compose.EnvVarReference, schema *envschema.Schema) (map[string]string, error) {
 	if len(missing) == 0 {
 		return map[string]string{}, nil
 	}
@@ -22,7 +24,7 @@ func CollectMissingVars(missing []compose.EnvVarReference) (map[string]string, e
 	// Group variables by required vs optional for better UX
 	var required, optional []compose.EnvVarReference
 	for _, v := range missing {
-		if v.IsRequired || !v.HasDefault {
+		if v.NeedsValue() {
 			required = append(required, v)
 		} else {
 			optional = append(optional, v)
@@ -35,7 +37,7 @@ func CollectMissingVars(missing []compose.EnvVarReference) (map[string]string, e
 		fmt.Println(strings.Repeat("─", 50))
 
 		for _, v := range required {
-			value, err := collectSingleVar(v)
+			value, err := collectSingleVar(v, schema)
 			if err != nil {
 				return nil, err
 			}
@@ -49,7 +51,7 @@ func CollectMissingVars(missing []compose.EnvVarReference) (map[string]string, e
 		fmt.Println(strings.Repeat("─", 50))
 
 		for _, v := range optional {
-			value, err := collectSingleVar(v)
+			value, err := collectSingleVar(v, schema)
 			if err != nil {
 				return nil, err
 			}
@@ -68,9 +70,14 @@ func CollectMissingVars(missing []compose.EnvVarReference) (map[string]string, e
 }
 
 // collectSingleVar prompts for a single variable with type detection and validation.
-func collectSingleVar(v compose.EnvVarReference) (string, error) {
-	// Detect variable type from name and default value
+func collectSingleVar(v compose.EnvVarReference, schema *envschema.Schema) (string, error) {
+	decl, declared := schema.Lookup(v.Name)
+
+	// Prefer the declared type, otherwise detect it from name and default value
 	varType := DetectType(v.Name, v.DefaultValue)
+	if declared {
+		varType = VarType(decl.Type)
+	}
 
 	// Build title with context
 	title := v.Name
@@ -78,21 +85,10 @@ func collectSingleVar(v compose.EnvVarReference) (string, error) {
 		title += fmt.Sprintf(" (used by: %s)", strings.Join(v.Locations, ", "))
 	}
 
-	// Add type hint to description
-	var description string
-	if v.HasDefault {
-		description = fmt.Sprintf("Default: %s", v.DefaultValue)
-	} else if v.IsRequired && v.ErrorMessage != "" {
-		description = v.ErrorMessage
-	}
+	description := describeVar(v, decl, varType)
 
-	// Add type hint
-	if varType != TypeString {
-		if description != "" {
-			description += fmt.Sprintf(" | Type: %s", varType)
-		} else {
-			description = fmt.Sprintf("Type: %s", varType)
-		}
+	if declared && len(decl.Enum) > 0 {
+		return collectEnumVar(title, description, decl.Enum, v.DefaultValue)
 	}
 
 	// For boolean types, use a confirm prompt instead of text input
@@ -101,7 +97,72 @@ func collectSingleVar(v compose.EnvVarReference) (string, error) {
 	}
 
 	// For other types, use text input with validation
-	return collectTextVar(title, description, varType, v)
+	return collectTextVar(title, description, varType, v, decl)
+}
+
+// describeVar builds the prompt description from the schema declaration, the
+// compose default or error message, and the variable type.
+func describeVar(v compose.EnvVarReference, decl *envschema.Variable, varType VarType) string {
+	var parts []string
+	if decl != nil && decl.Description != "" {
+		parts = append(parts, decl.Description)
+	}
+
+	if v.HasDefault && v.DefaultValue != "" {
+		parts = append(parts, fmt.Sprintf("Default: %s", v.DefaultValue))
+	} else if v.IsRequired && v.ErrorMessage != "" && (decl == nil || v.ErrorMessage != decl.Description) {
+		parts = append(parts, v.ErrorMessage)
+	}
+
+	// Add type hint
+	if varType != TypeString {
+		parts = append(parts, fmt.Sprintf("Type: %s", varType))
+	}
+
+	return strings.Join(parts, " | ")
+}
+
+// inputValidator combines the required check with type and schema constraints.
+// Returns nil when the input needs no validation.
+func inputValidator(varType VarType, required bool, decl *envschema.Variable) func(string) error {
+	typeCheck := ValidateByType(varType)
+	if decl != nil {
+		typeCheck = decl.Check
+	}
+	if typeCheck == nil && !required {
+		return nil
+	}
+
+	return func(s string) error {
+		if required && strings.TrimSpace(s) == "" {
+			return fmt.Errorf("this field is required")
+		}
+		if typeCheck != nil {
+			return typeCheck(s)
+		}
+		return nil
+	}
+}
+
+// collectEnumVar prompts for a variable restricted to a fixed set of values.
+func collectEnumVar(title, description string, options []string, defaultValue string) (string, error) {
+	value := defaultValue
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(title).
+				Description(description).
+				Options(huh.NewOptions(options...)...).
+				Value(&value),
+		),
+	)
+
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+
+	return value, nil
 }
 
 // collectBooleanVar prompts for a boolean variable using a confirm dialog.
@@ -141,7 +202,8 @@ func collectBooleanVar(title, description, defaultValue string) (string, error)
 }
 
 // collectTextVar prompts for a text variable with type-specific validation.
-func collectTextVar(title, description string, varType VarType, v compose.EnvVarReference) (string, error) {
+// Secret variables are read without echoing the input.
+func collectTextVar(title, description string, varType VarType, v compose.EnvVarReference, decl *envschema.Variable) (string, error) {
 	var value string
 
 	input := huh.NewInput().
@@ -150,20 +212,13 @@ func collectTextVar(title, description string, varType VarType, v compose.EnvVar
 		Value(&value).
 		Placeholder(GetPlaceholder(varType, v.DefaultValue))
 
-	// Add validation based on type
-	validator := ValidateByType(varType)
-	if validator != nil {
-		input.Validate(validator)
+	if decl != nil && decl.Secret {
+		input.EchoMode(huh.EchoModePassword)
 	}
 
-	// For required variables without defaults, ensure non-empty
-	if (v.IsRequired || !v.HasDefault) && varType == TypeString {
-		input.Validate(func(s string) error {
-			if strings.TrimSpace(s) == "" {
-				return fmt.Errorf("this field is required")
-			}
-			return nil
-		})
+	// Required variables without defaults must be non-empty
+	if validator := inputValidator(varType, v.NeedsValue(), decl); validator != nil {
+		input.Validate(validator)
 	}
 
 	form := huh.NewForm(
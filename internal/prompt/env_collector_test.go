@@ -3,7 +3,10 @@ package prompt
 import (
 	"testing"
 
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/envschema"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestDetectType tests type detection from variable names
@@ -558,3 +561,61 @@ func TestValidateByType(t *testing.T) {
 		})
 	}
 }
+
+func TestInputValidator(t *testing.T) {
+	schema, err := envschema.Parse([]byte(`
+variables:
+  LOG_LEVEL:
+    enum: [info, debug]
+  API_PORT:
+    type: port
+`))
+	require.NoError(t, err)
+	logLevel, _ := schema.Lookup("LOG_LEVEL")
+	apiPort, _ := schema.Lookup("API_PORT")
+
+	t.Run("optional string needs no validation", func(t *testing.T) {
+		assert.Nil(t, inputValidator(TypeString, false, nil))
+	})
+
+	t.Run("required applies to typed inputs", func(t *testing.T) {
+		validator := inputValidator(TypeURL, true, nil)
+		require.NotNil(t, validator)
+		assert.EqualError(t, validator(""), "this field is required")
+		assert.Error(t, validator("not a url"))
+		assert.NoError(t, validator("https://example.com"))
+	})
+
+	t.Run("schema constraints replace detected type", func(t *testing.T) {
+		validator := inputValidator(TypeString, false, logLevel)
+		require.NotNil(t, validator)
+		assert.NoError(t, validator(""))
+		assert.NoError(t, validator("debug"))
+		assert.Error(t, validator("trace"))
+
+		validator = inputValidator(VarType(apiPort.Type), true, apiPort)
+		assert.Error(t, validator("0"))
+		assert.NoError(t, validator("8080"))
+	})
+}
+
+func TestDescribeVar(t *testing.T) {
+	schema, err := envschema.Parse([]byte(`
+variables:
+  API_URL:
+    type: url
+    required: true
+    description: Public API endpoint
+`))
+	require.NoError(t, err)
+	decl, _ := schema.Lookup("API_URL")
+
+	refs := schema.Apply([]compose.EnvVarReference{{Name: "API_URL"}})
+	assert.Equal(t, "Public API endpoint | Type: url", describeVar(refs[0], decl, TypeURL))
+
+	ref := compose.EnvVarReference{Name: "PORT", HasDefault: true, DefaultValue: "3000"}
+	assert.Equal(t, "Default: 3000 | Type: port", describeVar(ref, nil, TypePort))
+
+	ref = compose.EnvVarReference{Name: "TOKEN", IsRequired: true, ErrorMessage: "token needed"}
+	assert.Equal(t, "token needed", describeVar(ref, nil, TypeString))
+}
@@ -0,0 +1,51 @@
+package prompt
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+)
+
+// MinPassphraseLength is the shortest passphrase accepted for new encryption.
+const MinPassphraseLength = 8
+
+// Passphrase prompts for a passphrase without echoing it. With confirm set,
+// as when choosing a new passphrase, it must be at least MinPassphraseLength
+// characters and is asked for twice.
+func Passphrase(title string, confirm bool) (string, error) {
+	var passphrase, repeated string
+
+	input := huh.NewInput().
+		Title(title).
+		EchoMode(huh.EchoModePassword).
+		Value(&passphrase).
+		Validate(func(s string) error {
+			if s == "" {
+				return fmt.Errorf("passphrase is required")
+			}
+			if confirm && len(s) < MinPassphraseLength {
+				return fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+			}
+			return nil
+		})
+
+	fields := []huh.Field{input}
+	if confirm {
+		fields = append(fields, huh.NewInput().
+			Title("Repeat passphrase").
+			EchoMode(huh.EchoModePassword).
+			Value(&repeated).
+			Validate(func(s string) error {
+				if s != passphrase {
+					return fmt.Errorf("passphrases do not match")
+				}
+				return nil
+			}))
+	}
+
+	if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+		return "", err
+	}
+
+	return passphrase, nil
+}
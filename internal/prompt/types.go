@@ -2,12 +2,13 @@
 package prompt
 
 import (
-	"fmt"
 	"net/mail"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/jayteealao/otterstack/internal/envschema"
 )
 
 // VarType represents the detected type of an environment variable.
@@ -85,83 +86,26 @@ func ValidateByType(varType VarType) func(string) error {
 }
 
 // Validation functions
+// Each accepts an empty value so the user may press enter to skip.
 
 func validateURL(value string) error {
-	if value == "" {
-		return nil // Empty is valid (user may press enter to skip)
-	}
-
-	u, err := url.ParseRequestURI(value)
-	if err != nil {
-		return fmt.Errorf("invalid URL format")
-	}
-
-	// Check that it has a scheme (http, https, etc.)
-	if u.Scheme == "" {
-		return fmt.Errorf("URL must include a scheme (e.g., https://)")
-	}
-
-	return nil
+	return envschema.CheckType(envschema.TypeURL, value)
 }
 
 func validateEmail(value string) error {
-	if value == "" {
-		return nil // Empty is valid
-	}
-
-	_, err := mail.ParseAddress(value)
-	if err != nil {
-		return fmt.Errorf("invalid email format")
-	}
-
-	return nil
+	return envschema.CheckType(envschema.TypeEmail, value)
 }
 
 func validatePort(value string) error {
-	if value == "" {
-		return nil // Empty is valid
-	}
-
-	port, err := strconv.Atoi(value)
-	if err != nil {
-		return fmt.Errorf("port must be a number")
-	}
-
-	if port < 1 || port > 65535 {
-		return fmt.Errorf("port must be between 1 and 65535")
-	}
-
-	return nil
+	return envschema.CheckType(envschema.TypePort, value)
 }
 
 func validateInteger(value string) error {
-	if value == "" {
-		return nil // Empty is valid
-	}
-
-	_, err := strconv.Atoi(value)
-	if err != nil {
-		return fmt.Errorf("must be a valid integer")
-	}
-
-	return nil
+	return envschema.CheckType(envschema.TypeInteger, value)
 }
 
 func validateBoolean(value string) error {
-	if value == "" {
-		return nil // Empty is valid
-	}
-
-	lower := strings.ToLower(strings.TrimSpace(value))
-	validValues := []string{"true", "false", "yes", "no", "1", "0", "t", "f", "y", "n"}
-
-	for _, valid := range validValues {
-		if lower == valid {
-			return nil
-		}
-	}
-
-	return fmt.Errorf("must be true/false, yes/no, or 1/0")
+	return envschema.CheckType(envschema.TypeBoolean, value)
 }
 
 // Type checking functions (used in detection)
@@ -0,0 +1,105 @@
+// Package schedule decides when a project that follows a branch or tag
+// pattern is checked for a new commit, and which commit it should run.
+// The watch process runs the checks and deploys through the orchestrator.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/state"
+)
+
+// MinInterval is the shortest time allowed between two checks of a schedule.
+const MinInterval = time.Minute
+
+// Spec is a parsed deploy schedule.
+type Spec struct {
+	*state.Schedule
+	Windows  []Window
+	Location *time.Location
+}
+
+// Parse validates a stored schedule and parses its windows and time zone.
+func Parse(sc *state.Schedule) (*Spec, error) {
+	if (sc.Branch == "") == (sc.TagPattern == "") {
+		return nil, fmt.Errorf("schedule must follow either a branch or a tag pattern")
+	}
+	if sc.TagPattern != "" {
+		if err := ValidatePattern(sc.TagPattern); err != nil {
+			return nil, fmt.Errorf("invalid tag pattern %q: %w", sc.TagPattern, err)
+		}
+	}
+	if sc.Interval < MinInterval {
+		return nil, fmt.Errorf("interval must be at least %v", MinInterval)
+	}
+
+	windows, err := ParseWindows(sc.Windows)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.Local
+	if sc.Timezone != "" {
+		if loc, err = time.LoadLocation(sc.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", sc.Timezone, err)
+		}
+	}
+
+	return &Spec{Schedule: sc, Windows: windows, Location: loc}, nil
+}
+
+// Tracks describes what the schedule follows, e.g. "branch main" or "tag v*".
+func (s *Spec) Tracks() string {
+	if s.Branch != "" {
+		return "branch " + s.Branch
+	}
+	return "tag " + s.TagPattern
+}
+
+// Open reports whether automatic deployments may start at t. A schedule
+// without windows is always open.
+func (s *Spec) Open(t time.Time) bool {
+	if len(s.Windows) == 0 {
+		return true
+	}
+	t = t.In(s.Location)
+	for _, w := range s.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Due reports whether the schedule should be checked at now: its interval has
+// passed since the last check and a maintenance window is open.
+func (s *Spec) Due(now time.Time) bool {
+	if s.LastCheckedAt != nil && now.Sub(*s.LastCheckedAt) < s.Interval {
+		return false
+	}
+	return s.Open(now)
+}
+
+// Resolve returns the ref the schedule currently points at and its commit.
+// The repository should be fetched first.
+func (s *Spec) Resolve(ctx context.Context, gitMgr git.GitOperations) (ref, sha string, err error) {
+	if s.Branch != "" {
+		sha, err = gitMgr.ResolveBranch(ctx, s.Branch)
+		return s.Branch, sha, err
+	}
+
+	tags, err := gitMgr.ListTags(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	tag, ok := LatestTag(tags, s.TagPattern)
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", errors.ErrNoMatchingTag, s.TagPattern)
+	}
+	sha, err = gitMgr.ResolveRef(ctx, "refs/tags/"+tag)
+	return tag, sha, err
+}
@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		sc      state.Schedule
+		wantErr bool
+	}{
+		{name: "branch", sc: state.Schedule{Branch: "main", Interval: 5 * time.Minute}},
+		{name: "tag with windows", sc: state.Schedule{TagPattern: "v*", Interval: time.Hour, Windows: "Mon-Fri 02:00-05:00", Timezone: "UTC"}},
+		{name: "neither", sc: state.Schedule{Interval: time.Hour}, wantErr: true},
+		{name: "both", sc: state.Schedule{Branch: "main", TagPattern: "v*", Interval: time.Hour}, wantErr: true},
+		{name: "too frequent", sc: state.Schedule{Branch: "main", Interval: 10 * time.Second}, wantErr: true},
+		{name: "bad pattern", sc: state.Schedule{TagPattern: "v[", Interval: time.Hour}, wantErr: true},
+		{name: "bad window", sc: state.Schedule{Branch: "main", Interval: time.Hour, Windows: "nightly"}, wantErr: true},
+		{name: "bad time zone", sc: state.Schedule{Branch: "main", Interval: time.Hour, Timezone: "Mars/Olympus"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(&tt.sc)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSpec_Due(t *testing.T) {
+	now := at(time.Tuesday, 3, 0)
+	recent := now.Add(-2 * time.Minute)
+	earlier := now.Add(-10 * time.Minute)
+
+	spec, err := Parse(&state.Schedule{Branch: "main", Interval: 5 * time.Minute, Timezone: "UTC"})
+	require.NoError(t, err)
+	assert.True(t, spec.Due(now), "never checked")
+
+	spec.LastCheckedAt = &recent
+	assert.False(t, spec.Due(now), "interval has not passed")
+
+	spec.LastCheckedAt = &earlier
+	assert.True(t, spec.Due(now))
+
+	spec.Windows, err = ParseWindows("Sat,Sun 00:00-24:00")
+	require.NoError(t, err)
+	assert.False(t, spec.Due(now), "outside the maintenance window")
+	assert.True(t, spec.Due(at(time.Saturday, 12, 0)))
+}
+
+func TestSpec_Resolve(t *testing.T) {
+	repo := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	commit := func(msg string) string {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "file.txt"), []byte(msg), 0644))
+		run("add", ".")
+		run("commit", "-m", msg)
+		sha, err := git.NewManager(repo).GetCurrentCommit(context.Background())
+		require.NoError(t, err)
+		return sha
+	}
+
+	run("init", "-b", "main")
+	run("config", "user.email", "test@test.com")
+	run("config", "user.name", "Test")
+	first := commit("first")
+	run("tag", "-a", "v1.0.0", "-m", "1.0.0")
+	second := commit("second")
+	run("tag", "v1.1.0")
+	run("tag", "v2.0.0-beta.1")
+
+	ctx := context.Background()
+	gitMgr := git.NewManager(repo)
+
+	t.Run("branch", func(t *testing.T) {
+		spec, err := Parse(&state.Schedule{Branch: "main", Interval: time.Minute})
+		require.NoError(t, err)
+		ref, sha, err := spec.Resolve(ctx, gitMgr)
+		require.NoError(t, err)
+		assert.Equal(t, "main", ref)
+		assert.Equal(t, second, sha)
+	})
+
+	t.Run("highest tag", func(t *testing.T) {
+		spec, err := Parse(&state.Schedule{TagPattern: "v*", Interval: time.Minute})
+		require.NoError(t, err)
+		ref, sha, err := spec.Resolve(ctx, gitMgr)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.1.0", ref)
+		assert.Equal(t, second, sha)
+	})
+
+	t.Run("annotated tag resolves to its commit", func(t *testing.T) {
+		spec, err := Parse(&state.Schedule{TagPattern: "v1.0.*", Interval: time.Minute})
+		require.NoError(t, err)
+		_, sha, err := spec.Resolve(ctx, gitMgr)
+		require.NoError(t, err)
+		assert.Equal(t, first, sha)
+	})
+
+	t.Run("no matching tag", func(t *testing.T) {
+		spec, err := Parse(&state.Schedule{TagPattern: "release-*", Interval: time.Minute})
+		require.NoError(t, err)
+		_, _, err = spec.Resolve(ctx, gitMgr)
+		assert.ErrorIs(t, err, errors.ErrNoMatchingTag)
+	})
+}
@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version. Build metadata is ignored.
+type version struct {
+	major, minor, patch int
+	pre                 string
+}
+
+// parseVersion parses a tag such as "v1.2.3", "1.2.3-rc.1" or
+// "release-1.2.3": everything before the first digit is treated as a prefix.
+func parseVersion(tag string) (version, bool) {
+	i := strings.IndexAny(tag, "0123456789")
+	if i < 0 {
+		return version{}, false
+	}
+	s := tag[i:]
+	if plus := strings.IndexByte(s, '+'); plus >= 0 {
+		s = s[:plus]
+	}
+	var v version
+	s, v.pre, _ = strings.Cut(s, "-")
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, false
+	}
+	nums := make([]int, 3)
+	for j, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (len(p) > 1 && p[0] == '0') {
+			return version{}, false
+		}
+		nums[j] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+	return v, true
+}
+
+// less reports whether v is a lower release than o.
+func (v version) less(o version) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	return v.patch < o.patch
+}
+
+// LatestTag returns the tag with the highest semantic version among those
+// matching the glob pattern. Pre-release tags are skipped.
+func LatestTag(tags []string, pattern string) (string, bool) {
+	var best string
+	var bestVersion version
+	for _, tag := range tags {
+		if ok, _ := path.Match(pattern, tag); !ok {
+			continue
+		}
+		v, ok := parseVersion(tag)
+		if !ok || v.pre != "" {
+			continue
+		}
+		if best == "" || bestVersion.less(v) {
+			best, bestVersion = tag, v
+		}
+	}
+	return best, best != ""
+}
+
+// ValidatePattern checks that a tag pattern is a valid glob.
+func ValidatePattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		pattern string
+		want    string
+	}{
+		{
+			name:    "highest version, not the newest name",
+			tags:    []string{"v1.9.0", "v1.10.0", "v1.2.3"},
+			pattern: "v*",
+			want:    "v1.10.0",
+		},
+		{
+			name:    "pre-releases are skipped",
+			tags:    []string{"v1.0.0", "v1.1.0-rc.1"},
+			pattern: "v*",
+			want:    "v1.0.0",
+		},
+		{
+			name:    "pattern narrows the tags",
+			tags:    []string{"v1.4.2", "v2.0.0", "v1.5.0"},
+			pattern: "v1.*",
+			want:    "v1.5.0",
+		},
+		{
+			name:    "prefixed tags",
+			tags:    []string{"release-1.0.0", "release-1.0.1+build.7", "v9.9.9"},
+			pattern: "release-*",
+			want:    "release-1.0.1+build.7",
+		},
+		{
+			name:    "non-semver tags are ignored",
+			tags:    []string{"latest", "v1.2", "v01.2.3", "nightly-2026"},
+			pattern: "*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LatestTag(tt.tags, tt.pattern)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want != "", ok)
+		})
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, ValidatePattern("v*"))
+	assert.Error(t, ValidatePattern("v[1-"))
+}
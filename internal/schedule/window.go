@@ -0,0 +1,159 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a recurring weekly period in which automatic deployments may start,
+// such as "Mon-Fri 02:00-05:00". A window whose end is not after its start runs
+// past midnight and belongs to the day it starts on: "Fri 22:00-02:00" includes
+// Saturday 01:00.
+type Window struct {
+	Days  [7]bool       // indexed by time.Weekday
+	Start time.Duration // offset from midnight
+	End   time.Duration // offset from midnight, up to 24h
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWindow parses "[days] HH:MM-HH:MM". Days are a comma-separated list of
+// day names or ranges such as "Mon-Fri,Sun"; without them the window applies
+// every day.
+func ParseWindow(spec string) (Window, error) {
+	var w Window
+	fields := strings.Fields(spec)
+	var days, hours string
+	switch len(fields) {
+	case 1:
+		days, hours = "*", fields[0]
+	case 2:
+		days, hours = fields[0], fields[1]
+	default:
+		return w, fmt.Errorf("invalid window %q: expected [days] HH:MM-HH:MM", spec)
+	}
+
+	if err := w.parseDays(days); err != nil {
+		return w, fmt.Errorf("invalid window %q: %w", spec, err)
+	}
+
+	start, end, ok := strings.Cut(hours, "-")
+	if !ok {
+		return w, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", spec)
+	}
+	var err error
+	if w.Start, err = parseClock(start); err != nil || w.Start == 24*time.Hour {
+		return w, fmt.Errorf("invalid window %q: bad start time %q", spec, start)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, fmt.Errorf("invalid window %q: bad end time %q", spec, end)
+	}
+	if w.Start == w.End {
+		return w, fmt.Errorf("invalid window %q: start and end are equal", spec)
+	}
+	return w, nil
+}
+
+func (w *Window) parseDays(days string) error {
+	if days == "*" || strings.EqualFold(days, "daily") {
+		for i := range w.Days {
+			w.Days[i] = true
+		}
+		return nil
+	}
+
+	for _, part := range strings.Split(days, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok := dayNames[strings.ToLower(first)]
+		if !ok {
+			return fmt.Errorf("unknown day %q", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = dayNames[strings.ToLower(last)]; !ok {
+				return fmt.Errorf("unknown day %q", last)
+			}
+		}
+		// Ranges may wrap around the week, e.g. Sat-Mon
+		for d := from; ; d = (d + 1) % 7 {
+			w.Days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses HH:MM into an offset from midnight. 24:00 is accepted.
+func parseClock(s string) (time.Duration, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window, in t's location.
+func (w Window) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+
+	if w.Start < w.End {
+		return w.Days[day] && offset >= w.Start && offset < w.End
+	}
+	// Past midnight: the evening of a listed day or the morning after one
+	yesterday := (day + 6) % 7
+	return (w.Days[day] && offset >= w.Start) || (w.Days[yesterday] && offset < w.End)
+}
+
+// String formats the window the way ParseWindow reads it.
+func (w Window) String() string {
+	var names []string
+	all := true
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if w.Days[d] {
+			names = append(names, d.String()[:3])
+		} else {
+			all = false
+		}
+	}
+	hours := formatClock(w.Start) + "-" + formatClock(w.End)
+	if all {
+		return hours
+	}
+	return strings.Join(names, ",") + " " + hours
+}
+
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// ParseWindows parses maintenance windows separated by ";".
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, err := ParseWindow(part)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
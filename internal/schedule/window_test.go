@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// at returns a time in UTC on the given weekday of the week of 2026-10-18 (a Sunday).
+func at(day time.Weekday, hour, minute int) time.Time {
+	return time.Date(2026, 10, 18+int(day), hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "Mon-Fri 02:00-05:00", want: "Mon,Tue,Wed,Thu,Fri 02:00-05:00"},
+		{spec: "sat,sun 00:00-24:00", want: "Sun,Sat 00:00-24:00"},
+		{spec: "22:00-06:00", want: "22:00-06:00"},
+		{spec: "daily 01:30-02:00", want: "01:30-02:00"},
+		{spec: "Sat-Mon 10:00-11:00", want: "Sun,Mon,Sat 10:00-11:00"},
+		{spec: "Mon 24:00-01:00", wantErr: true},
+		{spec: "Mon 02:00-02:00", wantErr: true},
+		{spec: "Mon 2:00-05:00", wantErr: true},
+		{spec: "Mon 02:00-05:60", wantErr: true},
+		{spec: "Someday 02:00-05:00", wantErr: true},
+		{spec: "Mon 02:00", wantErr: true},
+		{spec: "Mon Tue 02:00-03:00", wantErr: true},
+		{spec: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			w, err := ParseWindow(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, w.String())
+		})
+	}
+}
+
+func TestWindow_Contains(t *testing.T) {
+	weekdays, err := ParseWindow("Mon-Fri 02:00-05:00")
+	require.NoError(t, err)
+	overnight, err := ParseWindow("Fri 22:00-02:00")
+	require.NoError(t, err)
+
+	assert.True(t, weekdays.Contains(at(time.Monday, 2, 0)), "start is inclusive")
+	assert.True(t, weekdays.Contains(at(time.Friday, 4, 59)))
+	assert.False(t, weekdays.Contains(at(time.Friday, 5, 0)), "end is exclusive")
+	assert.False(t, weekdays.Contains(at(time.Saturday, 3, 0)))
+
+	assert.True(t, overnight.Contains(at(time.Friday, 23, 0)))
+	assert.True(t, overnight.Contains(at(time.Saturday, 1, 59)), "runs past midnight")
+	assert.False(t, overnight.Contains(at(time.Saturday, 2, 0)))
+	assert.False(t, overnight.Contains(at(time.Friday, 1, 0)), "belongs to the day it starts on")
+}
+
+func TestParseWindows(t *testing.T) {
+	windows, err := ParseWindows("Mon-Fri 02:00-05:00; Sat,Sun 00:00-24:00;")
+	require.NoError(t, err)
+	assert.Len(t, windows, 2)
+
+	windows, err = ParseWindows("")
+	require.NoError(t, err)
+	assert.Empty(t, windows)
+
+	_, err = ParseWindows("Mon-Fri 02:00-05:00; bogus")
+	assert.Error(t, err)
+}
@@ -0,0 +1,332 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/mattn/go-sqlite3"
+)
+
+// DatabaseFile is the name of the database file in the data directory.
+const DatabaseFile = "otterstack.db"
+
+// Backup writes a consistent copy of the database to dest using the SQLite
+// online backup API. Changes still in the write-ahead log are included, and
+// other processes can keep using the database while the copy is made.
+func (s *Store) Backup(ctx context.Context, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup destination %s already exists", dest)
+	}
+
+	destDB, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("failed to create backup database: %w", err)
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup database: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer srcConn.Close()
+
+	err = destConn.Raw(func(destRaw any) error {
+		return srcConn.Raw(func(srcRaw any) error {
+			bk, err := destRaw.(*sqlite3.SQLiteConn).Backup("main", srcRaw.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := bk.Step(-1); err != nil {
+				bk.Finish()
+				return err
+			}
+			return bk.Finish()
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration.
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// ImportProject copies a project with its deployments, operation logs, host
+// assignments, deploy schedule and volume snapshots from another database at
+// the same schema version, such as one extracted from a backup and migrated.
+// Hosts the project deploys to are added unless a host with the same name
+// exists. Databases at another schema version are refused. An existing
+// project with the same name is replaced only if replace is set.
+func (s *Store) ImportProject(ctx context.Context, srcPath, name string, replace bool) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS src`, srcPath); err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), `DETACH DATABASE src`)
+
+	// Rows are copied with SELECT *, which relies on both tables having the
+	// same columns in the same order
+	var srcVersion, version int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM src.schema_migrations`).Scan(&srcVersion); err != nil {
+		return fmt.Errorf("failed to read schema version of %s: %w", srcPath, err)
+	}
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM main.schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	switch {
+	case srcVersion > version:
+		return fmt.Errorf("%w: %s is at schema version %d, this database at %d; upgrade otterstack",
+			errors.ErrSchemaTooNew, srcPath, srcVersion, version)
+	case srcVersion < version:
+		return fmt.Errorf("%s is at schema version %d, this database at %d; migrate it before importing",
+			srcPath, srcVersion, version)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id string
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM src.projects WHERE name = ?`, name).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.ErrProjectNotFound
+		}
+		return fmt.Errorf("failed to read project: %w", err)
+	}
+
+	var existing string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM main.projects WHERE name = ? OR id = ?`, name, id).Scan(&existing)
+	switch {
+	case err == nil && !replace:
+		return errors.ErrProjectExists
+	case err == nil:
+		// Its deployments, logs, host assignments, schedule and snapshots go with it
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main.projects WHERE name = ? OR id = ?`, name, id); err != nil {
+			return fmt.Errorf("failed to remove existing project: %w", err)
+		}
+	case err != sql.ErrNoRows:
+		return fmt.Errorf("failed to check existing project: %w", err)
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO main.projects SELECT * FROM src.projects WHERE id = ?`,
+		`INSERT INTO main.deployments SELECT * FROM src.deployments WHERE project_id = ?`,
+		`INSERT INTO main.operation_logs SELECT * FROM src.operation_logs WHERE project_id = ?`,
+		`INSERT INTO main.volume_snapshots SELECT * FROM src.volume_snapshots WHERE project_id = ?`,
+		`INSERT INTO main.deploy_schedules SELECT * FROM src.deploy_schedules WHERE project_id = ?`,
+		// Hosts are shared between projects, so only the missing ones are added
+		`INSERT INTO main.hosts SELECT * FROM src.hosts
+			WHERE id IN (SELECT host_id FROM src.project_hosts WHERE project_id = ?)
+			AND id NOT IN (SELECT id FROM main.hosts)
+			AND name NOT IN (SELECT name FROM main.hosts)`,
+		// A host is matched by name when this database knows it under another id
+		`INSERT INTO main.project_hosts (project_id, host_id, position)
+			SELECT ph.project_id, h.id, ph.position
+			FROM src.project_hosts ph
+			JOIN src.hosts sh ON sh.id = ph.host_id
+			JOIN main.hosts h ON h.name = sh.name
+			WHERE ph.project_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			return fmt.Errorf("failed to import project %s: %w", name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+	return nil
+}
+
+// ImportDeployments inserts deployment records with their original
+// timestamps, such as history carried over from another host. Records must be
+// ordered oldest first so lineage links point at rows already inserted.
+func (s *Store) ImportDeployments(ctx context.Context, deployments []*Deployment) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO deployments (id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		                         trigger_source, triggered_by, previous_sha, phase_durations,
+		                         kind, parent_deployment_id, reverts_deployment_id, host)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for _, d := range deployments {
+		if d.TriggerSource == "" {
+			d.TriggerSource = TriggerCLI
+		}
+		if d.Kind == "" {
+			d.Kind = KindDeploy
+		}
+		phases, err := marshalPhases(d.Phases)
+		if err != nil {
+			return err
+		}
+		// Match the CURRENT_TIMESTAMP format so ordering by started_at stays correct
+		var finishedAt sql.NullString
+		if d.FinishedAt != nil {
+			finishedAt = sql.NullString{String: d.FinishedAt.UTC().Format(time.DateTime), Valid: true}
+		}
+
+		_, err = tx.ExecContext(ctx, query,
+			d.ID, d.ProjectID, d.GitSHA, nullString(d.GitRef),
+			nullString(d.WorktreePath), d.Status, nullString(d.ErrorMessage),
+			d.StartedAt.UTC().Format(time.DateTime), finishedAt,
+			d.TriggerSource, nullString(d.TriggeredBy), nullString(d.PreviousSHA), phases,
+			d.Kind, nullString(d.ParentDeploymentID), nullString(d.RevertsDeploymentID), nullString(d.Host),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to import deployment %s: %w", d.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+	return nil
+}
+
+// UpdateProjectRepoPath changes where a project's repository is checked out.
+func (s *Store) UpdateProjectRepoPath(ctx context.Context, name, repoPath string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE projects SET repo_path = ? WHERE name = ?`, repoPath, name)
+	if err != nil {
+		return fmt.Errorf("failed to update project repo path: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.ErrProjectNotFound
+	}
+
+	return nil
+}
+
+// ReplaceDatabase swaps the database in dataDir for the file at src. The
+// new database is checked first: one from a newer otterstack is rejected with
+// ErrSchemaTooNew and the current database left alone. The current database,
+// if any, is then copied to "<database>.<suffix>" and the path of the copy
+// returned. No Store may be open on dataDir.
+func ReplaceDatabase(dataDir, src, suffix string) (string, error) {
+	dbPath := filepath.Join(dataDir, DatabaseFile)
+	if err := os.MkdirAll(dataDir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// Stage the new database next to the current one so the rename is atomic
+	staging, err := os.MkdirTemp(dataDir, ".restoring-")
+	if err != nil {
+		return "", fmt.Errorf("failed to stage database: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	staged := filepath.Join(staging, DatabaseFile)
+	if err := copyFileSync(src, staged); err != nil {
+		return "", err
+	}
+	if err := checkSchema(staging); err != nil {
+		return "", err
+	}
+
+	var previous string
+	if _, err := os.Stat(dbPath); err == nil {
+		// Copy through the backup API so changes in the write-ahead log are kept
+		store, err := Open(dataDir)
+		if err != nil {
+			return "", err
+		}
+		previous = dbPath + "." + suffix
+		err = store.Backup(context.Background(), previous)
+		store.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, name := range []string{dbPath + "-wal", dbPath + "-shm"} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	if err := os.Rename(staged, dbPath); err != nil {
+		return "", fmt.Errorf("failed to replace database: %w", err)
+	}
+
+	return previous, nil
+}
+
+// copyFileSync copies src to a new file at dest and flushes it to disk.
+func copyFileSync(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+	return out.Close()
+}
+
+// checkSchema opens the database in dataDir and fails if this otterstack
+// cannot migrate it, because it is from a newer version or an applied
+// migration was modified.
+func checkSchema(dataDir string) error {
+	store, err := Open(dataDir)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if _, err := store.PendingMigrations(context.Background()); err != nil {
+		return fmt.Errorf("cannot restore database: %w", err)
+	}
+	return nil
+}
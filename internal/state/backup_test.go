@@ -0,0 +1,353 @@
+package state
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createBackupFixture adds a project with one deployment to store.
+func createBackupFixture(t *testing.T, store *Store, name string) *Project {
+	t.Helper()
+	ctx := context.Background()
+
+	p := &Project{
+		Name:              name,
+		RepoType:          "remote",
+		RepoURL:           "https://github.com/test/" + name + ".git",
+		RepoPath:          "/var/lib/otterstack/repos/" + name,
+		ComposeFile:       "compose.yaml",
+		WorktreeRetention: 3,
+		Status:            "ready",
+	}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	d := &Deployment{ProjectID: p.ID, GitSHA: "abc123def456", GitRef: "main", Status: "active"}
+	require.NoError(t, store.CreateDeployment(ctx, d))
+	return p
+}
+
+func TestStore_Backup(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p := createBackupFixture(t, store, "backup-app")
+
+	dest := filepath.Join(t.TempDir(), DatabaseFile)
+	require.NoError(t, store.Backup(ctx, dest))
+
+	t.Run("copy contains committed data", func(t *testing.T) {
+		copied, err := Open(filepath.Dir(dest))
+		require.NoError(t, err)
+		defer copied.Close()
+
+		got, err := copied.GetProject(ctx, "backup-app")
+		require.NoError(t, err)
+		assert.Equal(t, p.ID, got.ID)
+
+		deployments, err := copied.ListDeployments(ctx, p.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, deployments, 1)
+
+		version, err := copied.SchemaVersion(ctx)
+		require.NoError(t, err)
+		want, err := store.SchemaVersion(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, version)
+	})
+
+	t.Run("refuses to overwrite", func(t *testing.T) {
+		assert.Error(t, store.Backup(ctx, dest))
+	})
+}
+
+func TestStore_ImportProject(t *testing.T) {
+	ctx := context.Background()
+
+	src, cleanupSrc := setupTestStore(t)
+	defer cleanupSrc()
+	p := createBackupFixture(t, src, "import-app")
+	createBackupFixture(t, src, "other-app")
+
+	srcPath := filepath.Join(t.TempDir(), DatabaseFile)
+	require.NoError(t, src.Backup(ctx, srcPath))
+
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	t.Run("imports project and deployments", func(t *testing.T) {
+		require.NoError(t, store.ImportProject(ctx, srcPath, "import-app", false))
+
+		got, err := store.GetProject(ctx, "import-app")
+		require.NoError(t, err)
+		assert.Equal(t, p.ID, got.ID)
+
+		deployments, err := store.ListDeployments(ctx, p.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, deployments, 1)
+
+		_, err = store.GetProject(ctx, "other-app")
+		assert.ErrorIs(t, err, errors.ErrProjectNotFound)
+	})
+
+	t.Run("existing project requires replace", func(t *testing.T) {
+		err := store.ImportProject(ctx, srcPath, "import-app", false)
+		assert.ErrorIs(t, err, errors.ErrProjectExists)
+
+		require.NoError(t, store.ImportProject(ctx, srcPath, "import-app", true))
+		deployments, err := store.ListDeployments(ctx, p.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, deployments, 1)
+	})
+
+	t.Run("missing project", func(t *testing.T) {
+		err := store.ImportProject(ctx, srcPath, "missing-app", false)
+		assert.ErrorIs(t, err, errors.ErrProjectNotFound)
+	})
+
+	t.Run("refuses another schema version", func(t *testing.T) {
+		version, err := store.SchemaVersion(ctx)
+		require.NoError(t, err)
+
+		newer, cleanupNewer := setupTestStore(t)
+		defer cleanupNewer()
+		createBackupFixture(t, newer, "newer-app")
+		_, err = newer.db.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (999, 'future', 'abc')`)
+		require.NoError(t, err)
+		newerPath := filepath.Join(t.TempDir(), DatabaseFile)
+		require.NoError(t, newer.Backup(ctx, newerPath))
+
+		err = store.ImportProject(ctx, newerPath, "newer-app", false)
+		assert.ErrorIs(t, err, errors.ErrSchemaTooNew)
+
+		older, cleanupOlder := setupTestStore(t)
+		defer cleanupOlder()
+		createBackupFixture(t, older, "older-app")
+		_, err = older.db.Exec(`DELETE FROM schema_migrations WHERE version = ?`, version)
+		require.NoError(t, err)
+		olderPath := filepath.Join(t.TempDir(), DatabaseFile)
+		require.NoError(t, older.Backup(ctx, olderPath))
+
+		err = store.ImportProject(ctx, olderPath, "older-app", false)
+		assert.ErrorContains(t, err, "migrate it before importing")
+
+		_, err = store.GetProject(ctx, "newer-app")
+		assert.ErrorIs(t, err, errors.ErrProjectNotFound)
+		_, err = store.GetProject(ctx, "older-app")
+		assert.ErrorIs(t, err, errors.ErrProjectNotFound)
+	})
+}
+
+func TestStore_ImportProject_HostsScheduleSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	src, cleanupSrc := setupTestStore(t)
+	defer cleanupSrc()
+	p := createBackupFixture(t, src, "remote-app")
+	require.NoError(t, src.CreateHost(ctx, &Host{Name: "web1", DockerHost: "ssh://deploy@web1"}))
+	require.NoError(t, src.CreateHost(ctx, &Host{Name: "web2", DockerHost: "ssh://deploy@web2"}))
+	require.NoError(t, src.SetProjectHosts(ctx, p.ID, []string{"web2", "web1"}))
+	require.NoError(t, src.SetSchedule(ctx, &Schedule{ProjectID: p.ID, Branch: "main", Interval: 5 * time.Minute}))
+	deployments, err := src.ListDeployments(ctx, p.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, deployments, 1)
+	require.NoError(t, src.CreateVolumeSnapshot(ctx, &VolumeSnapshot{
+		ProjectID: p.ID, DeploymentID: deployments[0].ID, VolumeName: "remote-app_data",
+		Path: "/var/lib/otterstack/snapshots/data.tar", SizeBytes: 1024, SHA256: "abc",
+	}))
+
+	srcPath := filepath.Join(t.TempDir(), DatabaseFile)
+	require.NoError(t, src.Backup(ctx, srcPath))
+
+	assertImported := func(t *testing.T, store *Store) {
+		t.Helper()
+		hosts, err := store.ListProjectHosts(ctx, p.ID)
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Equal(t, "web2", hosts[0].Name)
+		assert.Equal(t, "web1", hosts[1].Name)
+		assert.Equal(t, "ssh://deploy@web1", hosts[1].DockerHost)
+
+		schedule, err := store.GetSchedule(ctx, p.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "main", schedule.Branch)
+		assert.Equal(t, 5*time.Minute, schedule.Interval)
+
+		snapshots, err := store.ListVolumeSnapshots(ctx, deployments[0].ID)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, "remote-app_data", snapshots[0].VolumeName)
+	}
+
+	t.Run("into an empty database", func(t *testing.T) {
+		store, cleanup := setupTestStore(t)
+		defer cleanup()
+
+		require.NoError(t, store.ImportProject(ctx, srcPath, "remote-app", false))
+		assertImported(t, store)
+	})
+
+	t.Run("replacing the project keeps existing hosts", func(t *testing.T) {
+		store, cleanup := setupTestStore(t)
+		defer cleanup()
+		require.NoError(t, store.ImportProject(ctx, srcPath, "remote-app", false))
+
+		// web1 was removed and added again under a new id
+		other := createBackupFixture(t, store, "other-app")
+		require.NoError(t, store.SetProjectHosts(ctx, p.ID, []string{"web2"}))
+		require.NoError(t, store.DeleteHost(ctx, "web1"))
+		require.NoError(t, store.CreateHost(ctx, &Host{Name: "web1", DockerHost: "ssh://deploy@web1"}))
+		require.NoError(t, store.SetProjectHosts(ctx, other.ID, []string{"web1"}))
+
+		require.NoError(t, store.ImportProject(ctx, srcPath, "remote-app", true))
+		assertImported(t, store)
+
+		hosts, err := store.ListProjectHosts(ctx, other.ID)
+		require.NoError(t, err)
+		require.Len(t, hosts, 1)
+		assert.Equal(t, "web1", hosts[0].Name)
+	})
+}
+
+func TestStore_UpdateProjectRepoPath(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	createBackupFixture(t, store, "move-app")
+
+	require.NoError(t, store.UpdateProjectRepoPath(ctx, "move-app", "/srv/repos/move-app"))
+	got, err := store.GetProject(ctx, "move-app")
+	require.NoError(t, err)
+	assert.Equal(t, "/srv/repos/move-app", got.RepoPath)
+
+	err = store.UpdateProjectRepoPath(ctx, "missing-app", "/srv/repos/missing-app")
+	assert.ErrorIs(t, err, errors.ErrProjectNotFound)
+}
+
+func TestReplaceDatabase(t *testing.T) {
+	ctx := context.Background()
+
+	src, cleanupSrc := setupTestStore(t)
+	defer cleanupSrc()
+	createBackupFixture(t, src, "restored-app")
+	srcPath := filepath.Join(t.TempDir(), DatabaseFile)
+	require.NoError(t, src.Backup(ctx, srcPath))
+
+	dataDir := t.TempDir()
+	current, err := New(dataDir)
+	require.NoError(t, err)
+	createBackupFixture(t, current, "current-app")
+	require.NoError(t, current.Close())
+
+	previous, err := ReplaceDatabase(dataDir, srcPath, "pre-restore-test")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataDir, DatabaseFile+".pre-restore-test"), previous)
+
+	restored, err := New(dataDir)
+	require.NoError(t, err)
+	defer restored.Close()
+	_, err = restored.GetProject(ctx, "restored-app")
+	assert.NoError(t, err)
+	_, err = restored.GetProject(ctx, "current-app")
+	assert.ErrorIs(t, err, errors.ErrProjectNotFound)
+
+	// The previous database is kept intact
+	savedDir := t.TempDir()
+	data, err := os.ReadFile(previous)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(savedDir, DatabaseFile), data, 0600))
+	saved, err := Open(savedDir)
+	require.NoError(t, err)
+	defer saved.Close()
+	_, err = saved.GetProject(ctx, "current-app")
+	assert.NoError(t, err)
+
+	// Nothing is left behind in the data directory
+	entries, err := os.ReadDir(dataDir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		assert.NotContains(t, e.Name(), "restoring")
+	}
+}
+
+func TestReplaceDatabase_SchemaTooNew(t *testing.T) {
+	ctx := context.Background()
+
+	src, cleanupSrc := setupTestStore(t)
+	defer cleanupSrc()
+	_, err := src.db.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (999, 'future', 'abc')`)
+	require.NoError(t, err)
+	srcPath := filepath.Join(t.TempDir(), DatabaseFile)
+	require.NoError(t, src.Backup(ctx, srcPath))
+
+	dataDir := t.TempDir()
+	current, err := New(dataDir)
+	require.NoError(t, err)
+	createBackupFixture(t, current, "current-app")
+	require.NoError(t, current.Close())
+
+	previous, err := ReplaceDatabase(dataDir, srcPath, "pre-restore-test")
+	assert.ErrorIs(t, err, errors.ErrSchemaTooNew)
+	assert.Empty(t, previous)
+
+	// The current database is untouched and no copy was made
+	_, err = os.Stat(filepath.Join(dataDir, DatabaseFile+".pre-restore-test"))
+	assert.True(t, os.IsNotExist(err))
+	kept, err := New(dataDir)
+	require.NoError(t, err)
+	defer kept.Close()
+	_, err = kept.GetProject(ctx, "current-app")
+	assert.NoError(t, err)
+}
+
+func TestStore_ImportDeployments(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p := createBackupFixture(t, store, "history-app")
+
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	finished := start.Add(time.Minute)
+	imported := []*Deployment{
+		{ID: "imported-1", ProjectID: p.ID, GitSHA: "1111111", Status: "inactive", StartedAt: start, FinishedAt: &finished},
+		{ID: "imported-2", ProjectID: p.ID, GitSHA: "2222222", Status: "inactive", StartedAt: start.Add(time.Hour),
+			Kind: KindRollback, ParentDeploymentID: "imported-1"},
+	}
+	require.NoError(t, store.ImportDeployments(ctx, imported))
+
+	got, err := store.GetDeployment(ctx, "imported-1")
+	require.NoError(t, err)
+	assert.True(t, start.Equal(got.StartedAt))
+	require.NotNil(t, got.FinishedAt)
+	assert.True(t, finished.Equal(*got.FinishedAt))
+	assert.Equal(t, TriggerCLI, got.TriggerSource)
+	assert.Equal(t, KindDeploy, got.Kind)
+
+	// Imported history sorts before the fixture's deployment, which started now
+	deployments, err := store.ListDeployments(ctx, p.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deployments, 3)
+	assert.Equal(t, "imported-2", deployments[1].ID)
+	assert.Equal(t, "imported-1", deployments[1].ParentDeploymentID)
+	assert.Equal(t, "imported-1", deployments[2].ID)
+
+	t.Run("failed import inserts nothing", func(t *testing.T) {
+		err := store.ImportDeployments(ctx, []*Deployment{
+			{ID: "imported-3", ProjectID: p.ID, GitSHA: "3333333", Status: "inactive", StartedAt: start},
+			{ID: "imported-1", ProjectID: p.ID, GitSHA: "1111111", Status: "inactive", StartedAt: start},
+		})
+		assert.Error(t, err)
+
+		_, err = store.GetDeployment(ctx, "imported-3")
+		assert.ErrorIs(t, err, errors.ErrDeploymentNotFound)
+	})
+}
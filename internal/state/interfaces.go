@@ -19,12 +19,32 @@ type StateStore interface {
 	CreateDeployment(ctx context.Context, d *Deployment) error
 	GetDeployment(ctx context.Context, id string) (*Deployment, error)
 	GetActiveDeployment(ctx context.Context, projectID string) (*Deployment, error)
+	ListActiveDeployments(ctx context.Context, projectID string) ([]*Deployment, error)
 	ListDeployments(ctx context.Context, projectID string, limit int) ([]*Deployment, error)
 	UpdateDeploymentStatus(ctx context.Context, id, status string, errorMsg *string) error
+	UpdateDeploymentPhases(ctx context.Context, id string, phases []DeploymentPhase) error
 	DeactivatePreviousDeployments(ctx context.Context, projectID, currentDeploymentID string) error
 	GetPreviousDeployment(ctx context.Context, projectID string) (*Deployment, error)
 	GetDeploymentBySHA(ctx context.Context, projectID, sha string) (*Deployment, error)
 	GetInterruptedDeployments(ctx context.Context) ([]*Deployment, error)
+	GetDeploymentByIDPrefix(ctx context.Context, prefix string) (*Deployment, error)
+
+	// Operation log operations
+	CreateOperationLog(ctx context.Context, l *OperationLog) error
+	SetOperationLogDeployment(ctx context.Context, id, deploymentID string) error
+	FinishOperationLog(ctx context.Context, id, status string) error
+	GetOperationLog(ctx context.Context, id string) (*OperationLog, error)
+	ListDeploymentOperationLogs(ctx context.Context, deploymentID string) ([]*OperationLog, error)
+	ListOperationLogs(ctx context.Context, projectID string, limit int) ([]*OperationLog, error)
+
+	// Volume snapshot operations
+	CreateVolumeSnapshot(ctx context.Context, v *VolumeSnapshot) error
+	ListVolumeSnapshots(ctx context.Context, deploymentID string) ([]*VolumeSnapshot, error)
+	GetRollbackSnapshots(ctx context.Context, projectID, gitSHA string) ([]*VolumeSnapshot, error)
+	DeleteVolumeSnapshots(ctx context.Context, deploymentID string) error
+
+	// Host operations
+	GetHost(ctx context.Context, name string) (*Host, error)
 
 	// Environment variable operations
 	SetEnvVars(ctx context.Context, projectID string, vars map[string]string) error
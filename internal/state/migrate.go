@@ -0,0 +1,322 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+)
+
+// migrationFiles holds the numbered schema migrations. Files contain schema
+// statements only: the runner wraps each one in a transaction and records it
+// in schema_migrations, so a failed migration leaves no partial changes.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFilePattern matches migration file names such as 004_operation_logs.sql.
+var migrationFilePattern = regexp.MustCompile(`^(\d{3})_([a-z0-9_]+)\.sql$`)
+
+// Migration is a numbered schema change embedded in the binary.
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	Checksum string // hex SHA-256 of the file
+}
+
+// ID returns the migration file name without extension, e.g. "004_operation_logs".
+func (m Migration) ID() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus describes a migration known to the binary or recorded in the database.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // the embedded file no longer matches the checksum recorded when it was applied
+	Unknown   bool // recorded in the database but not embedded: applied by a newer otterstack
+}
+
+// appliedMigration is a row of schema_migrations.
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string // empty for migrations applied before checksums were recorded
+	appliedAt *time.Time
+}
+
+// loadMigrations returns the embedded migrations in version order.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		sum := sha256.Sum256(data)
+
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     match[2],
+			SQL:      string(data),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %s is out of sequence: expected version %d", m.ID(), i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrate applies pending migrations in order, one transaction each, and
+// returns the migrations it applied. It refuses to run against a schema
+// written by a newer otterstack or one whose applied migrations were changed.
+func (s *Store) Migrate(ctx context.Context) ([]Migration, error) {
+	pending, err := s.PendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, m := range pending {
+		if err := s.applyMigration(ctx, m); err != nil {
+			return pending[:i], err
+		}
+	}
+
+	return pending, nil
+}
+
+// PendingMigrations returns the migrations Migrate would apply, without changing the database.
+func (s *Store) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	known, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := known[len(known)-1].Version
+	byVersion := make(map[int]Migration, len(known))
+	for _, m := range known {
+		byVersion[m.Version] = m
+	}
+	for _, a := range applied {
+		m, ok := byVersion[a.version]
+		if !ok {
+			return nil, fmt.Errorf("%w: database has migration %d, this otterstack knows up to %d; upgrade otterstack",
+				errors.ErrSchemaTooNew, a.version, latest)
+		}
+		if a.checksum != "" && a.checksum != m.Checksum {
+			return nil, fmt.Errorf("%w: %s", errors.ErrMigrationModified, m.ID())
+		}
+	}
+
+	var pending []Migration
+	for _, m := range known {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// MigrationStatus lists every embedded migration, and any applied migration
+// the binary does not know, in version order.
+func (s *Store) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	known, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(known))
+	for _, m := range known {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = a.appliedAt
+			st.Modified = a.checksum != "" && a.checksum != m.Checksum
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, st)
+	}
+	for _, a := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version:   a.version,
+			Name:      a.name,
+			Applied:   true,
+			AppliedAt: a.appliedAt,
+			Unknown:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// appliedMigrations reads schema_migrations without modifying it. Databases
+// created before checksums were recorded have no name or checksum columns.
+func (s *Store) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	columns, err := s.tableColumns(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]appliedMigration)
+	if len(columns) == 0 {
+		return applied, nil
+	}
+
+	query := `SELECT version, applied_at, '', '' FROM schema_migrations`
+	if columns["checksum"] {
+		query = `SELECT version, applied_at, COALESCE(name, ''), COALESCE(checksum, '') FROM schema_migrations`
+	}
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a appliedMigration
+		var appliedAt sql.NullTime
+		if err := rows.Scan(&a.version, &appliedAt, &a.name, &a.checksum); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+		}
+		if appliedAt.Valid {
+			a.appliedAt = &appliedAt.Time
+		}
+		applied[a.version] = a
+	}
+
+	return applied, rows.Err()
+}
+
+// ensureMigrationTable creates schema_migrations, adds the name and checksum
+// columns to tables created by older versions and records the checksums of
+// migrations applied before checksums existed.
+func (s *Store) ensureMigrationTable(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    version INTEGER PRIMARY KEY,
+		    applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	columns, err := s.tableColumns(ctx, "schema_migrations")
+	if err != nil {
+		return err
+	}
+	for _, column := range []string{"name", "checksum"} {
+		if columns[column] {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE schema_migrations ADD COLUMN `+column+` TEXT`); err != nil {
+			return fmt.Errorf("failed to add schema_migrations.%s: %w", column, err)
+		}
+	}
+
+	known, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range known {
+		if _, err := s.db.ExecContext(ctx,
+			`UPDATE schema_migrations SET name = ?, checksum = ? WHERE version = ? AND checksum IS NULL`,
+			m.Name, m.Checksum, m.Version,
+		); err != nil {
+			return fmt.Errorf("failed to record checksum of migration %s: %w", m.ID(), err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a migration and records it in a single transaction.
+func (s *Store) applyMigration(ctx context.Context, m Migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %s: %w", m.ID(), err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("migration %s failed: %w", m.ID(), err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`,
+		m.Version, m.Name, m.Checksum,
+	); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.ID(), err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.ID(), err)
+	}
+	return nil
+}
+
+// tableColumns returns the column names of a table, or none if it does not exist.
+func (s *Store) tableColumns(ctx context.Context, table string) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,172 @@
+package state
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transactionStatement matches statements that would nest inside the runner's transaction.
+var transactionStatement = regexp.MustCompile(`(?im)^\s*(BEGIN( TRANSACTION)?|COMMIT|END TRANSACTION)\s*;`)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.Len(t, m.Checksum, 64)
+
+		// The runner owns transactions and version bookkeeping
+		assert.NotRegexp(t, transactionStatement, m.SQL, m.ID())
+		assert.NotContains(t, m.SQL, "INSERT INTO schema_migrations", m.ID())
+		assert.NotContains(t, m.SQL, "INSERT OR IGNORE INTO schema_migrations", m.ID())
+	}
+	assert.Equal(t, "001_initial", migrations[0].ID())
+}
+
+func TestStore_Migrate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fresh database is fully migrated", func(t *testing.T) {
+		store, cleanup := setupTestStore(t)
+		defer cleanup()
+
+		pending, err := store.PendingMigrations(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		statuses, err := store.MigrationStatus(ctx)
+		require.NoError(t, err)
+		for _, st := range statuses {
+			assert.True(t, st.Applied, "migration %d", st.Version)
+			assert.False(t, st.Modified)
+			assert.False(t, st.Unknown)
+			assert.NotNil(t, st.AppliedAt)
+		}
+
+		applied, err := store.Migrate(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, applied, "second run should be a no-op")
+	})
+
+	t.Run("dry run does not touch the database", func(t *testing.T) {
+		store := openTestStore(t)
+
+		pending, err := store.PendingMigrations(ctx)
+		require.NoError(t, err)
+		known, err := loadMigrations()
+		require.NoError(t, err)
+		assert.Len(t, pending, len(known))
+
+		columns, err := store.tableColumns(ctx, "schema_migrations")
+		require.NoError(t, err)
+		assert.Empty(t, columns)
+	})
+
+	t.Run("upgrades databases migrated by the old runner", func(t *testing.T) {
+		store := openTestStore(t)
+
+		known, err := loadMigrations()
+		require.NoError(t, err)
+
+		// The old runner created schema_migrations without name or checksum
+		for _, m := range known[:3] {
+			_, err := store.db.Exec(m.SQL)
+			require.NoError(t, err)
+			_, err = store.db.Exec(`INSERT OR IGNORE INTO schema_migrations (version) VALUES (?)`, m.Version)
+			require.NoError(t, err)
+		}
+
+		pending, err := store.PendingMigrations(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, known[3:], pending)
+
+		applied, err := store.Migrate(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, known[3:], applied)
+
+		var missing int
+		require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE checksum IS NULL`).Scan(&missing))
+		assert.Zero(t, missing, "checksums of old migrations should be backfilled")
+	})
+
+	t.Run("refuses a schema from a newer otterstack", func(t *testing.T) {
+		store, cleanup := setupTestStore(t)
+		defer cleanup()
+
+		_, err := store.db.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (999, 'future', 'abc')`)
+		require.NoError(t, err)
+
+		_, err = store.Migrate(ctx)
+		assert.ErrorIs(t, err, errors.ErrSchemaTooNew)
+
+		_, err = New(store.DataDir())
+		assert.ErrorIs(t, err, errors.ErrSchemaTooNew)
+
+		statuses, err := store.MigrationStatus(ctx)
+		require.NoError(t, err)
+		last := statuses[len(statuses)-1]
+		assert.Equal(t, 999, last.Version)
+		assert.True(t, last.Unknown)
+	})
+
+	t.Run("refuses modified migrations", func(t *testing.T) {
+		store, cleanup := setupTestStore(t)
+		defer cleanup()
+
+		_, err := store.db.Exec(`UPDATE schema_migrations SET checksum = 'changed' WHERE version = 2`)
+		require.NoError(t, err)
+
+		_, err = store.Migrate(ctx)
+		assert.ErrorIs(t, err, errors.ErrMigrationModified)
+
+		statuses, err := store.MigrationStatus(ctx)
+		require.NoError(t, err)
+		assert.True(t, statuses[1].Modified)
+	})
+
+	t.Run("failed migration leaves no partial changes", func(t *testing.T) {
+		store, cleanup := setupTestStore(t)
+		defer cleanup()
+
+		err := store.applyMigration(ctx, Migration{
+			Version: 99,
+			Name:    "broken",
+			SQL:     "CREATE TABLE half_applied (id TEXT);\nALTER TABLE missing_table ADD COLUMN x TEXT;",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "099_broken")
+
+		columns, err := store.tableColumns(ctx, "half_applied")
+		require.NoError(t, err)
+		assert.Empty(t, columns)
+
+		var count int
+		require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version = 99`).Scan(&count))
+		assert.Zero(t, count)
+	})
+}
+
+// openTestStore opens an empty database without running migrations.
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "otterstack-test-*")
+	require.NoError(t, err)
+
+	store, err := Open(tmpDir)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		store.Close()
+		os.RemoveAll(tmpDir)
+	})
+	return store
+}
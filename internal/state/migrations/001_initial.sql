@@ -65,6 +65,3 @@ CREATE TRIGGER IF NOT EXISTS update_projects_timestamp
 BEGIN
     UPDATE projects SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
 END;
-
--- Insert initial migration version
-INSERT OR IGNORE INTO schema_migrations (version) VALUES (1);
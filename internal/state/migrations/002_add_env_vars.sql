@@ -2,5 +2,3 @@
 -- Stores environment variables as JSON blob
 
 ALTER TABLE projects ADD COLUMN env_vars TEXT DEFAULT '{}';
-
-INSERT OR IGNORE INTO schema_migrations (version) VALUES (2);
@@ -0,0 +1,6 @@
+-- Look up operation logs by deployment
+-- Migration: 004_operation_logs_deployment_index
+-- Created: 2026-10-18
+
+-- Index used by "otterstack logs <deployment>"
+CREATE INDEX IF NOT EXISTS idx_operation_logs_deployment ON operation_logs(deployment_id, started_at);
@@ -0,0 +1,15 @@
+-- Record who triggered a deployment, from where, and how long each phase took
+-- Migration: 005_deployment_metadata
+-- Created: 2026-10-18
+
+-- How the deployment was started: cli, webhook, schedule or rollback
+ALTER TABLE deployments ADD COLUMN trigger_source TEXT NOT NULL DEFAULT 'cli';
+
+-- Unix user, API token name or webhook sender that started the deployment
+ALTER TABLE deployments ADD COLUMN triggered_by TEXT;
+
+-- SHA that was active when the deployment started
+ALTER TABLE deployments ADD COLUMN previous_sha TEXT;
+
+-- JSON array of {"name": ..., "duration": <nanoseconds>} in execution order
+ALTER TABLE deployments ADD COLUMN phase_durations TEXT;
@@ -0,0 +1,19 @@
+-- Link rollbacks and redeploys to the deployments they came from
+-- Migration: 006_deployment_lineage
+-- Created: 2026-10-18
+
+-- deploy: a new commit; redeploy: a commit deployed before; rollback: created by otterstack rollback
+ALTER TABLE deployments ADD COLUMN kind TEXT NOT NULL DEFAULT 'deploy'
+    CHECK (kind IN ('deploy', 'rollback', 'redeploy'));
+
+-- Deployment whose commit and worktree a rollback or redeploy reuses
+ALTER TABLE deployments ADD COLUMN parent_deployment_id TEXT REFERENCES deployments(id) ON DELETE SET NULL;
+
+-- Deployment a rollback undid
+ALTER TABLE deployments ADD COLUMN reverts_deployment_id TEXT REFERENCES deployments(id) ON DELETE SET NULL;
+
+-- Rollbacks written before this migration are recognisable by their trigger source
+UPDATE deployments SET kind = 'rollback' WHERE trigger_source = 'rollback';
+
+CREATE INDEX IF NOT EXISTS idx_deployments_parent ON deployments(parent_deployment_id);
+CREATE INDEX IF NOT EXISTS idx_deployments_reverts ON deployments(reverts_deployment_id);
@@ -0,0 +1,21 @@
+-- Copies of named volumes taken before a deployment starts its containers
+-- Migration: 007_volume_snapshots
+-- Created: 2026-10-18
+
+CREATE TABLE IF NOT EXISTS volume_snapshots (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,  -- deployment about to start when the snapshot was taken
+    volume_name TEXT NOT NULL,
+    compose_project TEXT,
+    compose_volume TEXT,
+    path TEXT NOT NULL,           -- tar archive of the volume contents
+    size_bytes INTEGER NOT NULL DEFAULT 0,
+    sha256 TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE,
+    UNIQUE (deployment_id, volume_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_volume_snapshots_project ON volume_snapshots(project_id);
@@ -0,0 +1,29 @@
+-- Remote Docker hosts that projects deploy to, and the host of each deployment
+-- Migration: 008_hosts
+-- Created: 2026-10-18
+
+-- A Docker engine reached over SSH (docker_host = ssh://...) or a docker context
+CREATE TABLE IF NOT EXISTS hosts (
+    id TEXT PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    docker_host TEXT,
+    docker_context TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((docker_host IS NULL) != (docker_context IS NULL))
+);
+
+-- Hosts a project deploys to, in rolling order; none means the local engine
+CREATE TABLE IF NOT EXISTS project_hosts (
+    project_id TEXT NOT NULL,
+    host_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (project_id, host_id),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (host_id) REFERENCES hosts(id) ON DELETE RESTRICT
+);
+
+-- Name of the host the deployment ran on, NULL for the local engine.
+-- Kept as a name so history survives removing the host.
+ALTER TABLE deployments ADD COLUMN host TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_deployments_host ON deployments(project_id, host, status);
@@ -0,0 +1,21 @@
+-- Automatic deployments of a project when its branch or newest tag moves
+-- Migration: 009_deploy_schedules
+-- Created: 2026-10-18
+
+-- One schedule per project. It follows a branch or the highest semver tag
+-- matching a glob, checking every interval_seconds within the maintenance
+-- windows (empty for any time).
+CREATE TABLE IF NOT EXISTS deploy_schedules (
+    project_id TEXT PRIMARY KEY,
+    branch TEXT,
+    tag_pattern TEXT,
+    interval_seconds INTEGER NOT NULL CHECK (interval_seconds > 0),
+    windows TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT '',
+    last_checked_at DATETIME,
+    last_sha TEXT,
+    last_error TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((branch IS NULL) != (tag_pattern IS NULL)),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
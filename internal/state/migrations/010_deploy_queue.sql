@@ -0,0 +1,27 @@
+-- Deploy requests waiting for a project's running deploy to finish
+-- Migration: 010_deploy_queue
+-- Created: 2026-10-18
+
+-- A deploy request is pending until it runs, or until a newer request for the
+-- same project and hosts supersedes it. pid is the otterstack process that
+-- waits to run it, so a request left behind by a killed process can be
+-- recognised.
+CREATE TABLE IF NOT EXISTS deploy_queue (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL,
+    git_ref TEXT,
+    hosts TEXT NOT NULL DEFAULT '',
+    trigger_source TEXT NOT NULL DEFAULT 'cli',
+    triggered_by TEXT,
+    pid INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'superseded', 'cancelled')),
+    superseded_by TEXT,
+    error TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME,
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_deploy_queue_project_status ON deploy_queue(project_id, status);
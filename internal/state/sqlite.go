@@ -4,28 +4,20 @@ package state
 import (
 	"context"
 	"database/sql"
-	_ "embed"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jayteealao/otterstack/internal/errors"
+	"github.com/jayteealao/otterstack/internal/target"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-//go:embed migrations/001_initial.sql
-var initialMigration string
-
-//go:embed migrations/002_add_env_vars.sql
-var envVarsMigration string
-
-//go:embed migrations/003_add_traefik_routing.sql
-var traefikRoutingMigration string
-
 // Store provides state management for OtterStack using SQLite.
 type Store struct {
 	db      *sql.DB
@@ -58,17 +50,151 @@ type Deployment struct {
 	ErrorMessage string
 	StartedAt    time.Time
 	FinishedAt   *time.Time
+
+	TriggerSource string            // "cli", "webhook", "schedule" or "rollback"
+	TriggeredBy   string            // unix user, API token name or webhook sender
+	PreviousSHA   string            // SHA that was active when the deployment started
+	Phases        []DeploymentPhase // in execution order
+
+	Kind                string // "deploy", "redeploy" or "rollback"
+	ParentDeploymentID  string // deployment whose commit a rollback or redeploy reuses
+	RevertsDeploymentID string // deployment a rollback undid
+
+	Host string // name of the remote host deployed to, empty for the local engine
+}
+
+// Deployment kinds.
+const (
+	KindDeploy   = "deploy"
+	KindRedeploy = "redeploy"
+	KindRollback = "rollback"
+)
+
+// Trigger sources recorded on deployments.
+const (
+	TriggerCLI      = "cli"
+	TriggerWebhook  = "webhook"
+	TriggerSchedule = "schedule"
+	TriggerRollback = "rollback"
+)
+
+// DeploymentPhase records how long one step of a deployment took.
+type DeploymentPhase struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// OperationLog records where the full output of a deploy, rollback or cleanup operation was written.
+type OperationLog struct {
+	ID           string
+	ProjectID    string
+	DeploymentID string // empty for operations not tied to a deployment
+	Operation    string // "deploy", "rollback" or "cleanup"
+	Status       string // "running", "success" or "failed"
+	LogPath      string
+	StartedAt    time.Time
+	FinishedAt   *time.Time
+}
+
+// Host is a remote Docker engine that projects can deploy to. Exactly one
+// of DockerHost and DockerContext is set.
+type Host struct {
+	ID            string
+	Name          string
+	DockerHost    string // e.g. ssh://deploy@web1.example.com
+	DockerContext string // docker context on this machine
+	CreatedAt     time.Time
+}
+
+// Target returns the Docker engine the host is reached through.
+func (h *Host) Target() target.Target {
+	return target.Target{Name: h.Name, DockerHost: h.DockerHost, DockerContext: h.DockerContext}
+}
+
+// Schedule makes a project deploy automatically when a branch, or the newest
+// tag matching a pattern, moves. Exactly one of Branch and TagPattern is set.
+type Schedule struct {
+	ProjectID     string
+	Branch        string
+	TagPattern    string // glob such as "v*"; the highest semver tag matching it is deployed
+	Interval      time.Duration
+	Windows       string // maintenance windows separated by ";", empty for any time
+	Timezone      string // IANA zone of the windows, empty for the server's local time
+	LastCheckedAt *time.Time
+	LastSHA       string // commit the tracked ref pointed at on the last check
+	LastError     string // why the last check or deployment failed
+	CreatedAt     time.Time
 }
 
-// New creates a new Store with the given data directory.
+// QueuedDeploy is a deploy request waiting for the project's running deploy
+// to finish. Only the newest pending request for a project and set of hosts
+// runs; older ones are superseded.
+type QueuedDeploy struct {
+	ID            string
+	ProjectID     string
+	GitRef        string   // empty for the default branch
+	Hosts         []string // hosts selected with --host, empty for all of the project's hosts
+	TriggerSource string
+	TriggeredBy   string
+	PID           int // otterstack process waiting to run the request
+	Status        string
+	SupersededBy  string // newer request that replaced this one
+	Error         string
+	CreatedAt     time.Time
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
+}
+
+// Queued deploy statuses.
+const (
+	QueuePending    = "pending"
+	QueueRunning    = "running"
+	QueueSucceeded  = "succeeded"
+	QueueFailed     = "failed"
+	QueueSuperseded = "superseded"
+	QueueCancelled  = "cancelled"
+)
+
+// VolumeSnapshot is a copy of a named volume taken before a deployment started its containers.
+type VolumeSnapshot struct {
+	ID             string
+	ProjectID      string
+	DeploymentID   string // deployment about to start when the snapshot was taken
+	VolumeName     string
+	ComposeProject string
+	ComposeVolume  string // key under "volumes:" in the compose file
+	Path           string // tar archive of the volume contents
+	SizeBytes      int64
+	SHA256         string
+	CreatedAt      time.Time
+}
+
+// New creates a new Store with the given data directory and applies pending migrations.
 // The database file will be created at <dataDir>/otterstack.db.
 func New(dataDir string) (*Store, error) {
+	store, err := Open(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run migrations
+	if _, err := store.Migrate(context.Background()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return store, nil
+}
+
+// Open opens the database in the given data directory without applying
+// migrations, so the schema can be inspected first. Use New for everything else.
+func Open(dataDir string) (*Store, error) {
 	// Ensure data directory exists
 	if err := os.MkdirAll(dataDir, 0750); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	dbPath := filepath.Join(dataDir, "otterstack.db")
+	dbPath := filepath.Join(dataDir, DatabaseFile)
 	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=ON")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -79,18 +205,10 @@ func New(dataDir string) (*Store, error) {
 	db.SetMaxIdleConns(1)
 	db.SetConnMaxLifetime(time.Hour)
 
-	store := &Store{
+	return &Store{
 		db:      db,
 		dataDir: dataDir,
-	}
-
-	// Run migrations
-	if err := store.migrate(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	return store, nil
+	}, nil
 }
 
 // Close closes the database connection.
@@ -103,43 +221,6 @@ func (s *Store) DataDir() string {
 	return s.dataDir
 }
 
-// migrate runs database migrations.
-func (s *Store) migrate() error {
-	// Check current schema version
-	var version int
-	err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
-	if err != nil {
-		// Table doesn't exist yet, run initial migration
-		if _, err := s.db.Exec(initialMigration); err != nil {
-			return fmt.Errorf("failed to run initial migration: %w", err)
-		}
-		version = 1
-	}
-
-	// Run migrations based on current version
-	if version < 1 {
-		if _, err := s.db.Exec(initialMigration); err != nil {
-			return fmt.Errorf("failed to run initial migration: %w", err)
-		}
-		version = 1
-	}
-
-	if version < 2 {
-		if _, err := s.db.Exec(envVarsMigration); err != nil {
-			return fmt.Errorf("failed to run env vars migration: %w", err)
-		}
-		version = 2
-	}
-
-	if version < 3 {
-		if _, err := s.db.Exec(traefikRoutingMigration); err != nil {
-			return fmt.Errorf("failed to run traefik routing migration: %w", err)
-		}
-	}
-
-	return nil
-}
-
 // --- Project Operations ---
 
 // CreateProject creates a new project.
@@ -329,14 +410,30 @@ func (s *Store) CreateDeployment(ctx context.Context, d *Deployment) error {
 		d.ID = uuid.New().String()
 	}
 
+	if d.TriggerSource == "" {
+		d.TriggerSource = TriggerCLI
+	}
+	if d.Kind == "" {
+		d.Kind = KindDeploy
+	}
+
+	phases, err := marshalPhases(d.Phases)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO deployments (id, project_id, git_sha, git_ref, worktree_path, status, error_message)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO deployments (id, project_id, git_sha, git_ref, worktree_path, status, error_message,
+		                         trigger_source, triggered_by, previous_sha, phase_durations,
+		                         kind, parent_deployment_id, reverts_deployment_id, host)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := s.db.ExecContext(ctx, query,
+	_, err = s.db.ExecContext(ctx, query,
 		d.ID, d.ProjectID, d.GitSHA, nullString(d.GitRef),
 		nullString(d.WorktreePath), d.Status, nullString(d.ErrorMessage),
+		d.TriggerSource, nullString(d.TriggeredBy), nullString(d.PreviousSHA), phases,
+		d.Kind, nullString(d.ParentDeploymentID), nullString(d.RevertsDeploymentID), nullString(d.Host),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create deployment: %w", err)
@@ -348,17 +445,13 @@ func (s *Store) CreateDeployment(ctx context.Context, d *Deployment) error {
 // GetDeployment retrieves a deployment by ID.
 func (s *Store) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
 	query := `
-		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
 		FROM deployments WHERE id = ?
 	`
 
-	var d Deployment
-	var gitRef, worktreePath, errorMessage sql.NullString
-	var finishedAt sql.NullTime
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&d.ID, &d.ProjectID, &d.GitSHA, &gitRef, &worktreePath,
-		&d.Status, &errorMessage, &d.StartedAt, &finishedAt,
-	)
+	d, err := scanDeployment(s.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.ErrDeploymentNotFound
@@ -366,31 +459,20 @@ func (s *Store) GetDeployment(ctx context.Context, id string) (*Deployment, erro
 		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
 
-	d.GitRef = gitRef.String
-	d.WorktreePath = worktreePath.String
-	d.ErrorMessage = errorMessage.String
-	if finishedAt.Valid {
-		d.FinishedAt = &finishedAt.Time
-	}
-
-	return &d, nil
+	return d, nil
 }
 
 // GetActiveDeployment returns the currently active deployment for a project.
 func (s *Store) GetActiveDeployment(ctx context.Context, projectID string) (*Deployment, error) {
 	query := `
-		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
 		FROM deployments WHERE project_id = ? AND status = 'active'
 		ORDER BY started_at DESC LIMIT 1
 	`
 
-	var d Deployment
-	var gitRef, worktreePath, errorMessage sql.NullString
-	var finishedAt sql.NullTime
-	err := s.db.QueryRowContext(ctx, query, projectID).Scan(
-		&d.ID, &d.ProjectID, &d.GitSHA, &gitRef, &worktreePath,
-		&d.Status, &errorMessage, &d.StartedAt, &finishedAt,
-	)
+	d, err := scanDeployment(s.db.QueryRowContext(ctx, query, projectID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.ErrNoActiveDeployment
@@ -398,20 +480,50 @@ func (s *Store) GetActiveDeployment(ctx context.Context, projectID string) (*Dep
 		return nil, fmt.Errorf("failed to get active deployment: %w", err)
 	}
 
-	d.GitRef = gitRef.String
-	d.WorktreePath = worktreePath.String
-	d.ErrorMessage = errorMessage.String
-	if finishedAt.Valid {
-		d.FinishedAt = &finishedAt.Time
+	return d, nil
+}
+
+// ListActiveDeployments returns the active deployment of a project on each
+// host it runs on, ordered by host name with the local engine first.
+func (s *Store) ListActiveDeployments(ctx context.Context, projectID string) ([]*Deployment, error) {
+	query := `
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
+		FROM deployments WHERE project_id = ? AND status = 'active'
+		ORDER BY host IS NOT NULL, host, started_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active deployments: %w", err)
 	}
+	defer rows.Close()
 
-	return &d, nil
+	var deployments []*Deployment
+	seen := make(map[string]bool)
+	for rows.Next() {
+		d, err := scanDeployment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deployment: %w", err)
+		}
+		// Only the newest if an interrupted switch left two active on a host
+		if seen[d.Host] {
+			continue
+		}
+		seen[d.Host] = true
+		deployments = append(deployments, d)
+	}
+
+	return deployments, rows.Err()
 }
 
 // ListDeployments returns deployments for a project, ordered by most recent first.
 func (s *Store) ListDeployments(ctx context.Context, projectID string, limit int) ([]*Deployment, error) {
 	query := `
-		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
 		FROM deployments WHERE project_id = ?
 		ORDER BY started_at DESC LIMIT ?
 	`
@@ -424,27 +536,72 @@ func (s *Store) ListDeployments(ctx context.Context, projectID string, limit int
 
 	var deployments []*Deployment
 	for rows.Next() {
-		var d Deployment
-		var gitRef, worktreePath, errorMessage sql.NullString
-		var finishedAt sql.NullTime
-		if err := rows.Scan(
-			&d.ID, &d.ProjectID, &d.GitSHA, &gitRef, &worktreePath,
-			&d.Status, &errorMessage, &d.StartedAt, &finishedAt,
-		); err != nil {
+		d, err := scanDeployment(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan deployment: %w", err)
 		}
-		d.GitRef = gitRef.String
-		d.WorktreePath = worktreePath.String
-		d.ErrorMessage = errorMessage.String
-		if finishedAt.Valid {
-			d.FinishedAt = &finishedAt.Time
-		}
-		deployments = append(deployments, &d)
+		deployments = append(deployments, d)
 	}
 
 	return deployments, rows.Err()
 }
 
+// DeploymentCount is the number of deployments of a project with a status.
+type DeploymentCount struct {
+	ProjectID string
+	Status    string
+	Count     int
+}
+
+// CountDeploymentsByStatus counts the deployments of every project by status.
+func (s *Store) CountDeploymentsByStatus(ctx context.Context) ([]DeploymentCount, error) {
+	query := `
+		SELECT project_id, status, COUNT(*) FROM deployments
+		GROUP BY project_id, status
+		ORDER BY project_id, status
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count deployments: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []DeploymentCount
+	for rows.Next() {
+		var c DeploymentCount
+		if err := rows.Scan(&c.ProjectID, &c.Status, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetLastSuccessfulDeployment returns the deployment of a project that went
+// live and finished most recently, whether or not it is still active.
+func (s *Store) GetLastSuccessfulDeployment(ctx context.Context, projectID string) (*Deployment, error) {
+	query := `
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
+		FROM deployments
+		WHERE project_id = ? AND status IN ('active', 'inactive', 'rolled_back') AND finished_at IS NOT NULL
+		ORDER BY finished_at DESC, rowid DESC LIMIT 1
+	`
+
+	d, err := scanDeployment(s.db.QueryRowContext(ctx, query, projectID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrDeploymentNotFound
+		}
+		return nil, fmt.Errorf("failed to get last successful deployment: %w", err)
+	}
+
+	return d, nil
+}
+
 // UpdateDeploymentStatus updates a deployment's status and optionally sets error message and finished time.
 func (s *Store) UpdateDeploymentStatus(ctx context.Context, id, status string, errorMsg *string) error {
 	var query string
@@ -475,33 +632,72 @@ func (s *Store) UpdateDeploymentStatus(ctx context.Context, id, status string, e
 	return nil
 }
 
-// DeactivatePreviousDeployments marks all previous active deployments for a project as inactive.
+// UpdateDeploymentPhases records the phase durations of a deployment.
+func (s *Store) UpdateDeploymentPhases(ctx context.Context, id string, phases []DeploymentPhase) error {
+	data, err := marshalPhases(phases)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.ExecContext(ctx, `UPDATE deployments SET phase_durations = ? WHERE id = ?`, data, id)
+	if err != nil {
+		return fmt.Errorf("failed to update deployment phases: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.ErrDeploymentNotFound
+	}
+
+	return nil
+}
+
+// DeactivatePreviousDeployments marks the other active deployments of a
+// project on the same host as the current deployment as inactive.
 func (s *Store) DeactivatePreviousDeployments(ctx context.Context, projectID, currentDeploymentID string) error {
 	query := `
 		UPDATE deployments
 		SET status = 'inactive', finished_at = CURRENT_TIMESTAMP
 		WHERE project_id = ? AND status = 'active' AND id != ?
+		  AND host IS (SELECT host FROM deployments WHERE id = ?)
 	`
-	_, err := s.db.ExecContext(ctx, query, projectID, currentDeploymentID)
+	_, err := s.db.ExecContext(ctx, query, projectID, currentDeploymentID, currentDeploymentID)
 	return err
 }
 
-// GetPreviousDeployment returns the previous successful deployment (for rollback).
+// GetPreviousDeployment returns the deployment a rollback of the active
+// deployment should restore: the one that was live before it. Rollback
+// records on either side are traced back to the deployment they restored, so
+// rolling back twice in a row keeps moving back through history instead of
+// returning to the deployment that was just undone.
 func (s *Store) GetPreviousDeployment(ctx context.Context, projectID string) (*Deployment, error) {
+	current, err := s.GetActiveDeployment(ctx, projectID)
+	if err != nil {
+		if err == errors.ErrNoActiveDeployment {
+			return nil, errors.ErrNoPreviousDeployment
+		}
+		return nil, err
+	}
+
+	origin, err := s.rollbackOrigin(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
 		FROM deployments
-		WHERE project_id = ? AND status IN ('active', 'inactive', 'rolled_back')
-		ORDER BY started_at DESC LIMIT 1 OFFSET 1
+		WHERE project_id = ? AND status IN ('active', 'inactive') AND git_sha != ?
+		  AND (started_at, rowid) < (SELECT started_at, rowid FROM deployments WHERE id = ?)
+		ORDER BY started_at DESC, rowid DESC LIMIT 1
 	`
 
-	var d Deployment
-	var gitRef, worktreePath, errorMessage sql.NullString
-	var finishedAt sql.NullTime
-	err := s.db.QueryRowContext(ctx, query, projectID).Scan(
-		&d.ID, &d.ProjectID, &d.GitSHA, &gitRef, &worktreePath,
-		&d.Status, &errorMessage, &d.StartedAt, &finishedAt,
-	)
+	d, err := scanDeployment(s.db.QueryRowContext(ctx, query, projectID, origin.GitSHA, origin.ID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.ErrNoPreviousDeployment
@@ -509,33 +705,43 @@ func (s *Store) GetPreviousDeployment(ctx context.Context, projectID string) (*D
 		return nil, fmt.Errorf("failed to get previous deployment: %w", err)
 	}
 
-	d.GitRef = gitRef.String
-	d.WorktreePath = worktreePath.String
-	d.ErrorMessage = errorMessage.String
-	if finishedAt.Valid {
-		d.FinishedAt = &finishedAt.Time
-	}
+	return s.rollbackOrigin(ctx, d)
+}
 
-	return &d, nil
+// maxLineageDepth bounds walks along parent links in case of a cycle.
+const maxLineageDepth = 100
+
+// rollbackOrigin follows the parent links of rollback records back to the
+// deployment whose commit they restored.
+func (s *Store) rollbackOrigin(ctx context.Context, d *Deployment) (*Deployment, error) {
+	for i := 0; i < maxLineageDepth && d.Kind == KindRollback && d.ParentDeploymentID != ""; i++ {
+		parent, err := s.GetDeployment(ctx, d.ParentDeploymentID)
+		if err != nil {
+			if err == errors.ErrDeploymentNotFound {
+				return d, nil
+			}
+			return nil, err
+		}
+		d = parent
+	}
+	return d, nil
 }
 
 // GetDeploymentBySHA returns a deployment by its git SHA (full or short).
+// A commit can have several deployment records; the most recent one that
+// built it is preferred over rollbacks that later reused it.
 func (s *Store) GetDeploymentBySHA(ctx context.Context, projectID, sha string) (*Deployment, error) {
 	// Support both full and short SHA by using LIKE with prefix
 	query := `
-		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
 		FROM deployments
 		WHERE project_id = ? AND git_sha LIKE ?
-		ORDER BY started_at DESC LIMIT 1
+		ORDER BY kind = 'rollback', started_at DESC, rowid DESC LIMIT 1
 	`
 
-	var d Deployment
-	var gitRef, worktreePath, errorMessage sql.NullString
-	var finishedAt sql.NullTime
-	err := s.db.QueryRowContext(ctx, query, projectID, sha+"%").Scan(
-		&d.ID, &d.ProjectID, &d.GitSHA, &gitRef, &worktreePath,
-		&d.Status, &errorMessage, &d.StartedAt, &finishedAt,
-	)
+	d, err := scanDeployment(s.db.QueryRowContext(ctx, query, projectID, sha+"%"))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.ErrDeploymentNotFound
@@ -543,20 +749,15 @@ func (s *Store) GetDeploymentBySHA(ctx context.Context, projectID, sha string) (
 		return nil, fmt.Errorf("failed to get deployment by SHA: %w", err)
 	}
 
-	d.GitRef = gitRef.String
-	d.WorktreePath = worktreePath.String
-	d.ErrorMessage = errorMessage.String
-	if finishedAt.Valid {
-		d.FinishedAt = &finishedAt.Time
-	}
-
-	return &d, nil
+	return d, nil
 }
 
 // GetInterruptedDeployments returns all deployments with status 'interrupted' or 'deploying'.
 func (s *Store) GetInterruptedDeployments(ctx context.Context) ([]*Deployment, error) {
 	query := `
-		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at
+		SELECT id, project_id, git_sha, git_ref, worktree_path, status, error_message, started_at, finished_at,
+		       trigger_source, triggered_by, previous_sha, phase_durations,
+		       kind, parent_deployment_id, reverts_deployment_id, host
 		FROM deployments WHERE status IN ('interrupted', 'deploying')
 		ORDER BY started_at DESC
 	`
@@ -569,76 +770,312 @@ func (s *Store) GetInterruptedDeployments(ctx context.Context) ([]*Deployment, e
 
 	var deployments []*Deployment
 	for rows.Next() {
-		var d Deployment
-		var gitRef, worktreePath, errorMessage sql.NullString
-		var finishedAt sql.NullTime
-		if err := rows.Scan(
-			&d.ID, &d.ProjectID, &d.GitSHA, &gitRef, &worktreePath,
-			&d.Status, &errorMessage, &d.StartedAt, &finishedAt,
-		); err != nil {
+		d, err := scanDeployment(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan deployment: %w", err)
 		}
-		d.GitRef = gitRef.String
-		d.WorktreePath = worktreePath.String
-		d.ErrorMessage = errorMessage.String
-		if finishedAt.Valid {
-			d.FinishedAt = &finishedAt.Time
-		}
-		deployments = append(deployments, &d)
+		deployments = append(deployments, d)
 	}
 
 	return deployments, rows.Err()
 }
 
-// --- Environment Variable Operations ---
+// GetDeploymentByIDPrefix retrieves a deployment by its full ID or a unique prefix of it.
+func (s *Store) GetDeploymentByIDPrefix(ctx context.Context, prefix string) (*Deployment, error) {
+	query := `
+		SELECT id FROM deployments
+		WHERE substr(id, 1, ?) = ?
+		ORDER BY started_at DESC LIMIT 2
+	`
 
-// SetEnvVars sets environment variables for a project (merges with existing).
-func (s *Store) SetEnvVars(ctx context.Context, projectID string, vars map[string]string) error {
-	// Get existing vars
-	existing, err := s.GetEnvVars(ctx, projectID)
+	rows, err := s.db.QueryContext(ctx, query, len(prefix), prefix)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to find deployment: %w", err)
 	}
+	defer rows.Close()
 
-	// Merge new vars into existing
-	for k, v := range vars {
-		existing[k] = v
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// Serialize and update
-	data, err := json.Marshal(existing)
-	if err != nil {
-		return fmt.Errorf("failed to marshal env vars: %w", err)
+	switch len(ids) {
+	case 0:
+		return nil, errors.ErrDeploymentNotFound
+	case 1:
+		return s.GetDeployment(ctx, ids[0])
 	}
+	return nil, fmt.Errorf("deployment ID prefix %q is ambiguous", prefix)
+}
 
-	result, err := s.db.ExecContext(ctx, `UPDATE projects SET env_vars = ? WHERE id = ?`, string(data), projectID)
-	if err != nil {
-		return fmt.Errorf("failed to update env vars: %w", err)
+// --- Operation Log Operations ---
+
+// CreateOperationLog records the start of an operation.
+func (s *Store) CreateOperationLog(ctx context.Context, l *OperationLog) error {
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+	}
+	if l.Status == "" {
+		l.Status = "running"
 	}
 
-	rows, err := result.RowsAffected()
+	query := `
+		INSERT INTO operation_logs (id, project_id, deployment_id, operation, status, log_path)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		l.ID, l.ProjectID, nullString(l.DeploymentID), l.Operation, l.Status, nullString(l.LogPath),
+	)
 	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return errors.ErrProjectNotFound
+		return fmt.Errorf("failed to create operation log: %w", err)
 	}
 
 	return nil
 }
 
-// GetEnvVars returns environment variables for a project.
-func (s *Store) GetEnvVars(ctx context.Context, projectID string) (map[string]string, error) {
-	var envJSON sql.NullString
-	err := s.db.QueryRowContext(ctx, `SELECT env_vars FROM projects WHERE id = ?`, projectID).Scan(&envJSON)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.ErrProjectNotFound
-		}
-		return nil, fmt.Errorf("failed to get env vars: %w", err)
+// SetOperationLogDeployment links an operation log to the deployment it produced.
+func (s *Store) SetOperationLogDeployment(ctx context.Context, id, deploymentID string) error {
+	query := `UPDATE operation_logs SET deployment_id = ? WHERE id = ?`
+
+	if _, err := s.db.ExecContext(ctx, query, deploymentID, id); err != nil {
+		return fmt.Errorf("failed to update operation log: %w", err)
 	}
 
-	// Handle NULL or empty string
+	return nil
+}
+
+// FinishOperationLog sets the final status of an operation and its finished time.
+func (s *Store) FinishOperationLog(ctx context.Context, id, status string) error {
+	query := `UPDATE operation_logs SET status = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ?`
+
+	if _, err := s.db.ExecContext(ctx, query, status, id); err != nil {
+		return fmt.Errorf("failed to finish operation log: %w", err)
+	}
+
+	return nil
+}
+
+// GetOperationLog retrieves an operation log by ID.
+func (s *Store) GetOperationLog(ctx context.Context, id string) (*OperationLog, error) {
+	query := `
+		SELECT id, project_id, deployment_id, operation, status, log_path, started_at, finished_at
+		FROM operation_logs WHERE id = ?
+	`
+
+	logs, err := s.queryOperationLogs(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, errors.ErrOperationLogNotFound
+	}
+
+	return logs[0], nil
+}
+
+// ListDeploymentOperationLogs returns the operations recorded for a deployment, oldest first.
+func (s *Store) ListDeploymentOperationLogs(ctx context.Context, deploymentID string) ([]*OperationLog, error) {
+	query := `
+		SELECT id, project_id, deployment_id, operation, status, log_path, started_at, finished_at
+		FROM operation_logs WHERE deployment_id = ?
+		ORDER BY started_at ASC
+	`
+
+	return s.queryOperationLogs(ctx, query, deploymentID)
+}
+
+// ListOperationLogs returns the operations recorded for a project, ordered by most recent first.
+func (s *Store) ListOperationLogs(ctx context.Context, projectID string, limit int) ([]*OperationLog, error) {
+	query := `
+		SELECT id, project_id, deployment_id, operation, status, log_path, started_at, finished_at
+		FROM operation_logs WHERE project_id = ?
+		ORDER BY started_at DESC LIMIT ?
+	`
+
+	return s.queryOperationLogs(ctx, query, projectID, limit)
+}
+
+func (s *Store) queryOperationLogs(ctx context.Context, query string, args ...interface{}) ([]*OperationLog, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list operation logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*OperationLog
+	for rows.Next() {
+		var l OperationLog
+		var deploymentID, logPath sql.NullString
+		var finishedAt sql.NullTime
+		if err := rows.Scan(
+			&l.ID, &l.ProjectID, &deploymentID, &l.Operation, &l.Status,
+			&logPath, &l.StartedAt, &finishedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan operation log: %w", err)
+		}
+		l.DeploymentID = deploymentID.String
+		l.LogPath = logPath.String
+		if finishedAt.Valid {
+			l.FinishedAt = &finishedAt.Time
+		}
+		logs = append(logs, &l)
+	}
+
+	return logs, rows.Err()
+}
+
+// --- Volume Snapshot Operations ---
+
+// CreateVolumeSnapshot records a volume snapshot.
+func (s *Store) CreateVolumeSnapshot(ctx context.Context, v *VolumeSnapshot) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+
+	query := `
+		INSERT INTO volume_snapshots (id, project_id, deployment_id, volume_name, compose_project, compose_volume, path, size_bytes, sha256)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		v.ID, v.ProjectID, v.DeploymentID, v.VolumeName,
+		nullString(v.ComposeProject), nullString(v.ComposeVolume), v.Path, v.SizeBytes, v.SHA256,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create volume snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// ListVolumeSnapshots returns the volume snapshots taken before a deployment, ordered by volume name.
+func (s *Store) ListVolumeSnapshots(ctx context.Context, deploymentID string) ([]*VolumeSnapshot, error) {
+	query := `
+		SELECT id, project_id, deployment_id, volume_name, compose_project, compose_volume, path, size_bytes, sha256, created_at
+		FROM volume_snapshots WHERE deployment_id = ?
+		ORDER BY volume_name
+	`
+
+	return s.queryVolumeSnapshots(ctx, query, deploymentID)
+}
+
+// GetRollbackSnapshots returns the volume snapshots to restore when rolling
+// back to a commit: those of the first deployment that snapshotted after the
+// commit was last live, i.e. the data as the commit left it.
+// Returns ErrNoVolumeSnapshot if there are none.
+func (s *Store) GetRollbackSnapshots(ctx context.Context, projectID, gitSHA string) ([]*VolumeSnapshot, error) {
+	query := `
+		SELECT s.deployment_id
+		FROM volume_snapshots s JOIN deployments d ON d.id = s.deployment_id
+		WHERE s.project_id = ?
+		  AND (d.started_at, d.rowid) > (
+		      SELECT started_at, rowid FROM deployments
+		      WHERE project_id = ? AND git_sha = ? AND status IN ('active', 'inactive', 'rolled_back')
+		      ORDER BY started_at DESC, rowid DESC LIMIT 1)
+		ORDER BY d.started_at, d.rowid LIMIT 1
+	`
+
+	var deploymentID string
+	if err := s.db.QueryRowContext(ctx, query, projectID, projectID, gitSHA).Scan(&deploymentID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrNoVolumeSnapshot
+		}
+		return nil, fmt.Errorf("failed to find volume snapshots: %w", err)
+	}
+
+	return s.ListVolumeSnapshots(ctx, deploymentID)
+}
+
+// DeleteVolumeSnapshots removes the snapshot records of a deployment.
+func (s *Store) DeleteVolumeSnapshots(ctx context.Context, deploymentID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM volume_snapshots WHERE deployment_id = ?`, deploymentID); err != nil {
+		return fmt.Errorf("failed to delete volume snapshots: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) queryVolumeSnapshots(ctx context.Context, query string, args ...interface{}) ([]*VolumeSnapshot, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volume snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*VolumeSnapshot
+	for rows.Next() {
+		var v VolumeSnapshot
+		var composeProject, composeVolume sql.NullString
+		if err := rows.Scan(
+			&v.ID, &v.ProjectID, &v.DeploymentID, &v.VolumeName, &composeProject, &composeVolume,
+			&v.Path, &v.SizeBytes, &v.SHA256, &v.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan volume snapshot: %w", err)
+		}
+		v.ComposeProject = composeProject.String
+		v.ComposeVolume = composeVolume.String
+		snapshots = append(snapshots, &v)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// --- Environment Variable Operations ---
+
+// SetEnvVars sets environment variables for a project (merges with existing).
+func (s *Store) SetEnvVars(ctx context.Context, projectID string, vars map[string]string) error {
+	// Get existing vars
+	existing, err := s.GetEnvVars(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	// Merge new vars into existing
+	for k, v := range vars {
+		existing[k] = v
+	}
+
+	// Serialize and update
+	data, err := json.Marshal(existing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal env vars: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `UPDATE projects SET env_vars = ? WHERE id = ?`, string(data), projectID)
+	if err != nil {
+		return fmt.Errorf("failed to update env vars: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.ErrProjectNotFound
+	}
+
+	return nil
+}
+
+// GetEnvVars returns environment variables for a project.
+func (s *Store) GetEnvVars(ctx context.Context, projectID string) (map[string]string, error) {
+	var envJSON sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT env_vars FROM projects WHERE id = ?`, projectID).Scan(&envJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("failed to get env vars: %w", err)
+	}
+
+	// Handle NULL or empty string
 	if !envJSON.Valid || envJSON.String == "" {
 		return make(map[string]string), nil
 	}
@@ -677,8 +1114,491 @@ func (s *Store) DeleteEnvVar(ctx context.Context, projectID, key string) error {
 	return nil
 }
 
+// --- Host Operations ---
+
+// CreateHost registers a remote host.
+func (s *Store) CreateHost(ctx context.Context, h *Host) error {
+	if h.ID == "" {
+		h.ID = uuid.New().String()
+	}
+
+	query := `INSERT INTO hosts (id, name, docker_host, docker_context) VALUES (?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx, query, h.ID, h.Name, nullString(h.DockerHost), nullString(h.DockerContext))
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.ErrHostExists
+		}
+		return fmt.Errorf("failed to create host: %w", err)
+	}
+
+	return nil
+}
+
+// GetHost retrieves a host by name.
+func (s *Store) GetHost(ctx context.Context, name string) (*Host, error) {
+	hosts, err := s.queryHosts(ctx, `
+		SELECT id, name, docker_host, docker_context, created_at
+		FROM hosts WHERE name = ?
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, errors.ErrHostNotFound
+	}
+	return hosts[0], nil
+}
+
+// ListHosts returns all registered hosts ordered by name.
+func (s *Store) ListHosts(ctx context.Context) ([]*Host, error) {
+	return s.queryHosts(ctx, `
+		SELECT id, name, docker_host, docker_context, created_at
+		FROM hosts ORDER BY name
+	`)
+}
+
+// DeleteHost removes a host. Returns ErrHostInUse while a project deploys to it.
+func (s *Store) DeleteHost(ctx context.Context, name string) error {
+	host, err := s.GetHost(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	projects, err := s.HostProjects(ctx, host.ID)
+	if err != nil {
+		return err
+	}
+	if len(projects) > 0 {
+		return fmt.Errorf("%w: %s", errors.ErrHostInUse, strings.Join(projects, ", "))
+	}
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM hosts WHERE id = ?`, host.ID); err != nil {
+		return fmt.Errorf("failed to delete host: %w", err)
+	}
+	return nil
+}
+
+// HostProjects returns the names of the projects deploying to a host.
+func (s *Store) HostProjects(ctx context.Context, hostID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT p.name FROM project_hosts ph JOIN projects p ON p.id = ph.project_id
+		WHERE ph.host_id = ? ORDER BY p.name
+	`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list host projects: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan project name: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SetProjectHosts replaces the hosts a project deploys to, in rolling order.
+// An empty list deploys the project to the local engine.
+func (s *Store) SetProjectHosts(ctx context.Context, projectID string, hostNames []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM project_hosts WHERE project_id = ?`, projectID); err != nil {
+		return fmt.Errorf("failed to clear project hosts: %w", err)
+	}
+
+	for i, name := range hostNames {
+		var hostID string
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM hosts WHERE name = ?`, name).Scan(&hostID); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: %s", errors.ErrHostNotFound, name)
+			}
+			return fmt.Errorf("failed to look up host: %w", err)
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO project_hosts (project_id, host_id, position) VALUES (?, ?, ?)`,
+			projectID, hostID, i)
+		if err != nil {
+			if isUniqueConstraintError(err) {
+				return fmt.Errorf("host %s is listed twice", name)
+			}
+			return fmt.Errorf("failed to add project host: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit project hosts: %w", err)
+	}
+	return nil
+}
+
+// ListProjectHosts returns the hosts a project deploys to, in rolling order.
+// An empty result means the project deploys to the local engine.
+func (s *Store) ListProjectHosts(ctx context.Context, projectID string) ([]*Host, error) {
+	return s.queryHosts(ctx, `
+		SELECT h.id, h.name, h.docker_host, h.docker_context, h.created_at
+		FROM project_hosts ph JOIN hosts h ON h.id = ph.host_id
+		WHERE ph.project_id = ? ORDER BY ph.position
+	`, projectID)
+}
+
+// DeploymentTarget returns the Docker engine a deployment ran on.
+func DeploymentTarget(ctx context.Context, store StateStore, d *Deployment) (target.Target, error) {
+	if d.Host == "" {
+		return target.Local, nil
+	}
+	h, err := store.GetHost(ctx, d.Host)
+	if err != nil {
+		return target.Target{}, fmt.Errorf("deployment ran on host %s: %w", d.Host, err)
+	}
+	return h.Target(), nil
+}
+
+func (s *Store) queryHosts(ctx context.Context, query string, args ...interface{}) ([]*Host, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hosts: %w", err)
+	}
+	defer rows.Close()
+
+	var hosts []*Host
+	for rows.Next() {
+		var h Host
+		var dockerHost, dockerContext sql.NullString
+		if err := rows.Scan(&h.ID, &h.Name, &dockerHost, &dockerContext, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan host: %w", err)
+		}
+		h.DockerHost = dockerHost.String
+		h.DockerContext = dockerContext.String
+		hosts = append(hosts, &h)
+	}
+	return hosts, rows.Err()
+}
+
+// --- Schedule Operations ---
+
+// SetSchedule creates or replaces a project's deploy schedule. Replacing a
+// schedule forgets the results of earlier checks.
+func (s *Store) SetSchedule(ctx context.Context, sc *Schedule) error {
+	query := `
+		INSERT OR REPLACE INTO deploy_schedules (project_id, branch, tag_pattern, interval_seconds, windows, timezone)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.db.ExecContext(ctx, query,
+		sc.ProjectID, nullString(sc.Branch), nullString(sc.TagPattern),
+		int64(sc.Interval/time.Second), sc.Windows, sc.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to set schedule: %w", err)
+	}
+	return nil
+}
+
+// GetSchedule returns a project's deploy schedule.
+func (s *Store) GetSchedule(ctx context.Context, projectID string) (*Schedule, error) {
+	schedules, err := s.querySchedules(ctx, `
+		SELECT project_id, branch, tag_pattern, interval_seconds, windows, timezone,
+		       last_checked_at, last_sha, last_error, created_at
+		FROM deploy_schedules WHERE project_id = ?
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, errors.ErrScheduleNotFound
+	}
+	return schedules[0], nil
+}
+
+// ListSchedules returns the deploy schedules of all projects.
+func (s *Store) ListSchedules(ctx context.Context) ([]*Schedule, error) {
+	return s.querySchedules(ctx, `
+		SELECT project_id, branch, tag_pattern, interval_seconds, windows, timezone,
+		       last_checked_at, last_sha, last_error, created_at
+		FROM deploy_schedules ORDER BY created_at
+	`)
+}
+
+// DeleteSchedule removes a project's deploy schedule.
+func (s *Store) DeleteSchedule(ctx context.Context, projectID string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM deploy_schedules WHERE project_id = ?`, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.ErrScheduleNotFound
+	}
+	return nil
+}
+
+// RecordScheduleCheck stores the outcome of a schedule check: the commit the
+// tracked ref pointed at, if it was resolved, and the error, if any.
+func (s *Store) RecordScheduleCheck(ctx context.Context, projectID, sha, checkErr string) error {
+	query := `
+		UPDATE deploy_schedules
+		SET last_checked_at = CURRENT_TIMESTAMP, last_sha = COALESCE(?, last_sha), last_error = ?
+		WHERE project_id = ?
+	`
+	_, err := s.db.ExecContext(ctx, query, nullString(sha), nullString(checkErr), projectID)
+	if err != nil {
+		return fmt.Errorf("failed to record schedule check: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) querySchedules(ctx context.Context, query string, args ...interface{}) ([]*Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*Schedule
+	for rows.Next() {
+		var sc Schedule
+		var branch, tagPattern, lastSHA, lastError sql.NullString
+		var intervalSeconds int64
+		var lastCheckedAt sql.NullTime
+		if err := rows.Scan(&sc.ProjectID, &branch, &tagPattern, &intervalSeconds, &sc.Windows, &sc.Timezone,
+			&lastCheckedAt, &lastSHA, &lastError, &sc.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		sc.Branch = branch.String
+		sc.TagPattern = tagPattern.String
+		sc.Interval = time.Duration(intervalSeconds) * time.Second
+		sc.LastSHA = lastSHA.String
+		sc.LastError = lastError.String
+		if lastCheckedAt.Valid {
+			sc.LastCheckedAt = &lastCheckedAt.Time
+		}
+		schedules = append(schedules, &sc)
+	}
+	return schedules, rows.Err()
+}
+
+// --- Deploy Queue Operations ---
+
+// queuedDeployColumns is the column list read by scanQueuedDeploy.
+const queuedDeployColumns = `id, project_id, git_ref, hosts, trigger_source, triggered_by, pid, status,
+		       superseded_by, error, created_at, started_at, finished_at`
+
+// EnqueueDeploy adds a pending deploy request. Pending requests of the same
+// project and hosts are superseded by it.
+func (s *Store) EnqueueDeploy(ctx context.Context, q *QueuedDeploy) error {
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	if q.TriggerSource == "" {
+		q.TriggerSource = TriggerCLI
+	}
+	// The same hosts in another order or repeated are the same set
+	hosts := strings.Join(slices.Compact(slices.Sorted(slices.Values(q.Hosts))), ",")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE deploy_queue
+		SET status = ?, superseded_by = ?, finished_at = CURRENT_TIMESTAMP
+		WHERE project_id = ? AND hosts = ? AND status = ?
+	`, QueueSuperseded, q.ID, q.ProjectID, hosts, QueuePending)
+	if err != nil {
+		return fmt.Errorf("failed to supersede queued deploys: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO deploy_queue (id, project_id, git_ref, hosts, trigger_source, triggered_by, pid, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, q.ID, q.ProjectID, nullString(q.GitRef), hosts, q.TriggerSource, nullString(q.TriggeredBy), q.PID, QueuePending)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue deploy: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	q.Status = QueuePending
+	return nil
+}
+
+// GetQueuedDeploy returns a deploy request by ID.
+func (s *Store) GetQueuedDeploy(ctx context.Context, id string) (*QueuedDeploy, error) {
+	queued, err := s.queryQueuedDeploys(ctx, `SELECT `+queuedDeployColumns+` FROM deploy_queue WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(queued) == 0 {
+		return nil, errors.ErrQueuedDeployNotFound
+	}
+	return queued[0], nil
+}
+
+// ListQueuedDeploys returns the pending and running deploy requests of a
+// project, or of all projects if projectID is empty, oldest first.
+func (s *Store) ListQueuedDeploys(ctx context.Context, projectID string) ([]*QueuedDeploy, error) {
+	return s.queryQueuedDeploys(ctx, `
+		SELECT `+queuedDeployColumns+`
+		FROM deploy_queue
+		WHERE status IN (?, ?) AND (? = '' OR project_id = ?)
+		ORDER BY created_at, rowid
+	`, QueuePending, QueueRunning, projectID, projectID)
+}
+
+// StartQueuedDeploy marks a pending request as running if it is the oldest
+// pending request of the project and no other request of the project is
+// running. It reports whether the request started.
+func (s *Store) StartQueuedDeploy(ctx context.Context, id string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE deploy_queue
+		SET status = ?, started_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ? AND NOT EXISTS (
+			SELECT 1 FROM deploy_queue running
+			WHERE running.project_id = deploy_queue.project_id AND running.status = ?
+		) AND id = (
+			SELECT oldest.id FROM deploy_queue oldest
+			WHERE oldest.project_id = deploy_queue.project_id AND oldest.status = ?
+			ORDER BY oldest.created_at, oldest.rowid LIMIT 1
+		)
+	`, QueueRunning, id, QueuePending, QueueRunning, QueuePending)
+	if err != nil {
+		return false, fmt.Errorf("failed to start queued deploy: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// FinishQueuedDeploy records the final status of a pending or running request.
+func (s *Store) FinishQueuedDeploy(ctx context.Context, id, status, errMsg string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE deploy_queue
+		SET status = ?, error = ?, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN (?, ?)
+	`, status, nullString(errMsg), id, QueuePending, QueueRunning)
+	if err != nil {
+		return fmt.Errorf("failed to finish queued deploy: %w", err)
+	}
+	return nil
+}
+
+// CancelQueuedDeploy cancels a pending request. Running requests cannot be
+// cancelled.
+func (s *Store) CancelQueuedDeploy(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE deploy_queue
+		SET status = ?, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`, QueueCancelled, id, QueuePending)
+	if err != nil {
+		return fmt.Errorf("failed to cancel queued deploy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	q, err := s.GetQueuedDeploy(ctx, id)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("queued deploy %s is %s, only pending deploys can be cancelled", q.ID, q.Status)
+}
+
+func (s *Store) queryQueuedDeploys(ctx context.Context, query string, args ...interface{}) ([]*QueuedDeploy, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queued deploys: %w", err)
+	}
+	defer rows.Close()
+
+	var queued []*QueuedDeploy
+	for rows.Next() {
+		var q QueuedDeploy
+		var gitRef, triggeredBy, supersededBy, errMsg sql.NullString
+		var hosts string
+		var startedAt, finishedAt sql.NullTime
+		if err := rows.Scan(&q.ID, &q.ProjectID, &gitRef, &hosts, &q.TriggerSource, &triggeredBy, &q.PID, &q.Status,
+			&supersededBy, &errMsg, &q.CreatedAt, &startedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan queued deploy: %w", err)
+		}
+		q.GitRef = gitRef.String
+		if hosts != "" {
+			q.Hosts = strings.Split(hosts, ",")
+		}
+		q.TriggeredBy = triggeredBy.String
+		q.SupersededBy = supersededBy.String
+		q.Error = errMsg.String
+		if startedAt.Valid {
+			q.StartedAt = &startedAt.Time
+		}
+		if finishedAt.Valid {
+			q.FinishedAt = &finishedAt.Time
+		}
+		queued = append(queued, &q)
+	}
+	return queued, rows.Err()
+}
+
 // --- Helper Functions ---
 
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDeployment reads a deployment selected with the full deployments column list.
+func scanDeployment(row rowScanner) (*Deployment, error) {
+	var d Deployment
+	var gitRef, worktreePath, errorMessage sql.NullString
+	var triggeredBy, previousSHA, phases sql.NullString
+	var parentID, revertsID, host sql.NullString
+	var finishedAt sql.NullTime
+	if err := row.Scan(
+		&d.ID, &d.ProjectID, &d.GitSHA, &gitRef, &worktreePath,
+		&d.Status, &errorMessage, &d.StartedAt, &finishedAt,
+		&d.TriggerSource, &triggeredBy, &previousSHA, &phases,
+		&d.Kind, &parentID, &revertsID, &host,
+	); err != nil {
+		return nil, err
+	}
+
+	d.GitRef = gitRef.String
+	d.WorktreePath = worktreePath.String
+	d.ErrorMessage = errorMessage.String
+	d.TriggeredBy = triggeredBy.String
+	d.PreviousSHA = previousSHA.String
+	d.ParentDeploymentID = parentID.String
+	d.RevertsDeploymentID = revertsID.String
+	d.Host = host.String
+	if finishedAt.Valid {
+		d.FinishedAt = &finishedAt.Time
+	}
+	if phases.String != "" {
+		if err := json.Unmarshal([]byte(phases.String), &d.Phases); err != nil {
+			return nil, fmt.Errorf("failed to decode phase durations: %w", err)
+		}
+	}
+
+	return &d, nil
+}
+
+func marshalPhases(phases []DeploymentPhase) (sql.NullString, error) {
+	if len(phases) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(phases)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to encode phase durations: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
 func nullString(s string) sql.NullString {
 	if s == "" {
 		return sql.NullString{}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/jayteealao/otterstack/internal/errors"
 	"github.com/stretchr/testify/assert"
@@ -267,3 +268,703 @@ func TestStore_RemoteProject(t *testing.T) {
 		assert.Equal(t, "cloning", got.Status)
 	})
 }
+
+func TestStore_OperationLogs(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{
+		Name:              "logged-app",
+		RepoType:          "local",
+		RepoPath:          "/srv/logged-app",
+		ComposeFile:       "compose.yaml",
+		WorktreeRetention: 3,
+		Status:            "ready",
+	}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	d := &Deployment{ProjectID: p.ID, GitSHA: "abc1234567890", Status: "deploying"}
+	require.NoError(t, store.CreateDeployment(ctx, d))
+
+	t.Run("create, link and finish", func(t *testing.T) {
+		l := &OperationLog{ProjectID: p.ID, Operation: "deploy", LogPath: "/data/logs/logged-app/deploy.log"}
+		require.NoError(t, store.CreateOperationLog(ctx, l))
+		assert.NotEmpty(t, l.ID)
+
+		got, err := store.GetOperationLog(ctx, l.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "running", got.Status)
+		assert.Empty(t, got.DeploymentID)
+		assert.Nil(t, got.FinishedAt)
+
+		require.NoError(t, store.SetOperationLogDeployment(ctx, l.ID, d.ID))
+		require.NoError(t, store.FinishOperationLog(ctx, l.ID, "success"))
+
+		logs, err := store.ListDeploymentOperationLogs(ctx, d.ID)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "success", logs[0].Status)
+		assert.Equal(t, "/data/logs/logged-app/deploy.log", logs[0].LogPath)
+		assert.NotNil(t, logs[0].FinishedAt)
+	})
+
+	t.Run("list by project", func(t *testing.T) {
+		require.NoError(t, store.CreateOperationLog(ctx, &OperationLog{ProjectID: p.ID, Operation: "cleanup"}))
+
+		logs, err := store.ListOperationLogs(ctx, p.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, logs, 2)
+	})
+
+	t.Run("get missing operation log", func(t *testing.T) {
+		_, err := store.GetOperationLog(ctx, "does-not-exist")
+		assert.ErrorIs(t, err, errors.ErrOperationLogNotFound)
+	})
+
+	t.Run("rejects unknown operations", func(t *testing.T) {
+		err := store.CreateOperationLog(ctx, &OperationLog{ProjectID: p.ID, Operation: "explode"})
+		assert.Error(t, err)
+	})
+}
+
+func TestStore_GetDeploymentByIDPrefix(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{Name: "prefix-app", RepoType: "local", RepoPath: "/srv/prefix-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	first := &Deployment{ID: "aaaa1111-0000", ProjectID: p.ID, GitSHA: "abc1234", Status: "active"}
+	second := &Deployment{ID: "aaaa2222-0000", ProjectID: p.ID, GitSHA: "def5678", Status: "failed"}
+	require.NoError(t, store.CreateDeployment(ctx, first))
+	require.NoError(t, store.CreateDeployment(ctx, second))
+
+	got, err := store.GetDeploymentByIDPrefix(ctx, "aaaa1")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, got.ID)
+
+	got, err = store.GetDeploymentByIDPrefix(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, got.ID)
+
+	_, err = store.GetDeploymentByIDPrefix(ctx, "aaaa")
+	assert.ErrorContains(t, err, "ambiguous")
+
+	_, err = store.GetDeploymentByIDPrefix(ctx, "%")
+	assert.ErrorIs(t, err, errors.ErrDeploymentNotFound)
+}
+
+func TestStore_DeploymentMetadata(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{Name: "meta-app", RepoType: "local", RepoPath: "/srv/meta-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	t.Run("trigger source defaults to cli", func(t *testing.T) {
+		d := &Deployment{ProjectID: p.ID, GitSHA: "aaa1111", Status: "deploying"}
+		require.NoError(t, store.CreateDeployment(ctx, d))
+
+		got, err := store.GetDeployment(ctx, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, TriggerCLI, got.TriggerSource)
+		assert.Empty(t, got.TriggeredBy)
+		assert.Empty(t, got.PreviousSHA)
+		assert.Nil(t, got.Phases)
+	})
+
+	t.Run("round-trips trigger, previous SHA and phases", func(t *testing.T) {
+		d := &Deployment{
+			ProjectID:     p.ID,
+			GitSHA:        "bbb2222",
+			Status:        "deploying",
+			TriggerSource: TriggerWebhook,
+			TriggeredBy:   "github:octocat",
+			PreviousSHA:   "aaa1111",
+		}
+		require.NoError(t, store.CreateDeployment(ctx, d))
+
+		phases := []DeploymentPhase{
+			{Name: "pull", Duration: 12 * time.Second},
+			{Name: "up", Duration: 1500 * time.Millisecond},
+		}
+		require.NoError(t, store.UpdateDeploymentPhases(ctx, d.ID, phases))
+
+		got, err := store.GetDeploymentBySHA(ctx, p.ID, "bbb2222")
+		require.NoError(t, err)
+		assert.Equal(t, TriggerWebhook, got.TriggerSource)
+		assert.Equal(t, "github:octocat", got.TriggeredBy)
+		assert.Equal(t, "aaa1111", got.PreviousSHA)
+		assert.Equal(t, phases, got.Phases)
+
+		list, err := store.ListDeployments(ctx, p.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+	})
+
+	t.Run("update phases of missing deployment", func(t *testing.T) {
+		err := store.UpdateDeploymentPhases(ctx, "missing", nil)
+		assert.ErrorIs(t, err, errors.ErrDeploymentNotFound)
+	})
+}
+
+func TestStore_DeploymentLineage(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{Name: "lineage-app", RepoType: "local", RepoPath: "/srv/lineage-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	// deploy creates a live deployment and deactivates the one before it
+	deploy := func(d *Deployment) *Deployment {
+		t.Helper()
+		d.ProjectID = p.ID
+		d.Status = "active"
+		require.NoError(t, store.CreateDeployment(ctx, d))
+		require.NoError(t, store.DeactivatePreviousDeployments(ctx, p.ID, d.ID))
+		return d
+	}
+	// rollback mirrors "otterstack rollback": the current deployment is marked
+	// rolled_back and a rollback record reuses the target's commit
+	rollback := func(target *Deployment) *Deployment {
+		t.Helper()
+		current, err := store.GetActiveDeployment(ctx, p.ID)
+		require.NoError(t, err)
+		require.NoError(t, store.UpdateDeploymentStatus(ctx, current.ID, "rolled_back", nil))
+		return deploy(&Deployment{
+			GitSHA:              target.GitSHA,
+			Kind:                KindRollback,
+			ParentDeploymentID:  target.ID,
+			RevertsDeploymentID: current.ID,
+		})
+	}
+
+	_, err := store.GetPreviousDeployment(ctx, p.ID)
+	assert.ErrorIs(t, err, errors.ErrNoPreviousDeployment)
+
+	a := deploy(&Deployment{GitSHA: "aaa1111"})
+	b := deploy(&Deployment{GitSHA: "bbb2222"})
+	c := deploy(&Deployment{GitSHA: "ccc3333"})
+
+	t.Run("kind defaults to deploy", func(t *testing.T) {
+		got, err := store.GetDeployment(ctx, a.ID)
+		require.NoError(t, err)
+		assert.Equal(t, KindDeploy, got.Kind)
+		assert.Empty(t, got.ParentDeploymentID)
+		assert.Empty(t, got.RevertsDeploymentID)
+	})
+
+	t.Run("previous of a deploy is the one before it", func(t *testing.T) {
+		prev, err := store.GetPreviousDeployment(ctx, p.ID)
+		require.NoError(t, err)
+		assert.Equal(t, b.ID, prev.ID)
+	})
+
+	r1 := rollback(b)
+
+	t.Run("rollback records link to both deployments", func(t *testing.T) {
+		got, err := store.GetDeployment(ctx, r1.ID)
+		require.NoError(t, err)
+		assert.Equal(t, KindRollback, got.Kind)
+		assert.Equal(t, b.ID, got.ParentDeploymentID)
+		assert.Equal(t, c.ID, got.RevertsDeploymentID)
+	})
+
+	t.Run("rolling back twice moves further back", func(t *testing.T) {
+		prev, err := store.GetPreviousDeployment(ctx, p.ID)
+		require.NoError(t, err)
+		assert.Equal(t, a.ID, prev.ID, "should skip the rollback record and the reverted deployment")
+
+		rollback(prev)
+		_, err = store.GetPreviousDeployment(ctx, p.ID)
+		assert.ErrorIs(t, err, errors.ErrNoPreviousDeployment)
+	})
+
+	t.Run("by SHA prefers the deployment that built the commit", func(t *testing.T) {
+		got, err := store.GetDeploymentBySHA(ctx, p.ID, "bbb")
+		require.NoError(t, err)
+		assert.Equal(t, b.ID, got.ID)
+	})
+
+	t.Run("previous of a new deploy after rollbacks", func(t *testing.T) {
+		deploy(&Deployment{GitSHA: "ddd4444"})
+		prev, err := store.GetPreviousDeployment(ctx, p.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "aaa1111", prev.GitSHA)
+	})
+
+	t.Run("rejects unknown kinds", func(t *testing.T) {
+		err := store.CreateDeployment(ctx, &Deployment{ProjectID: p.ID, GitSHA: "eee5555", Status: "deploying", Kind: "bogus"})
+		assert.Error(t, err)
+	})
+}
+
+func TestStore_VolumeSnapshots(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{Name: "snapshot-app", RepoType: "local", RepoPath: "/srv/snapshot-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	deploy := func(sha, kind string) *Deployment {
+		t.Helper()
+		d := &Deployment{ProjectID: p.ID, GitSHA: sha, Kind: kind, Status: "active"}
+		require.NoError(t, store.CreateDeployment(ctx, d))
+		require.NoError(t, store.DeactivatePreviousDeployments(ctx, p.ID, d.ID))
+		return d
+	}
+	snapshot := func(d *Deployment, volume string) {
+		t.Helper()
+		require.NoError(t, store.CreateVolumeSnapshot(ctx, &VolumeSnapshot{
+			ProjectID:     p.ID,
+			DeploymentID:  d.ID,
+			VolumeName:    volume,
+			ComposeVolume: "data",
+			Path:          "/var/lib/otterstack/snapshots/" + d.ID + "/" + volume + ".tar",
+			SizeBytes:     10240,
+			SHA256:        "ab12",
+		}))
+	}
+
+	a := deploy("aaa1111", KindDeploy)
+	b := deploy("bbb2222", KindDeploy)
+	snapshot(b, "app_db")
+	snapshot(b, "app_cache")
+	c := deploy("ccc3333", KindDeploy)
+	snapshot(c, "app_db")
+
+	t.Run("list and fields", func(t *testing.T) {
+		snapshots, err := store.ListVolumeSnapshots(ctx, b.ID)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 2)
+		assert.Equal(t, "app_cache", snapshots[0].VolumeName)
+		assert.Equal(t, "data", snapshots[0].ComposeVolume)
+		assert.Equal(t, int64(10240), snapshots[0].SizeBytes)
+		assert.False(t, snapshots[0].CreatedAt.IsZero())
+	})
+
+	t.Run("rollback restores the first snapshots after the target was live", func(t *testing.T) {
+		snapshots, err := store.GetRollbackSnapshots(ctx, p.ID, b.GitSHA)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, c.ID, snapshots[0].DeploymentID)
+
+		snapshots, err = store.GetRollbackSnapshots(ctx, p.ID, a.GitSHA)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 2)
+		assert.Equal(t, b.ID, snapshots[0].DeploymentID)
+	})
+
+	t.Run("a rollback makes its commit live again", func(t *testing.T) {
+		require.NoError(t, store.UpdateDeploymentStatus(ctx, c.ID, "rolled_back", nil))
+		deploy(a.GitSHA, KindRollback)
+		d := deploy("ddd4444", KindDeploy)
+		snapshot(d, "app_db")
+
+		snapshots, err := store.GetRollbackSnapshots(ctx, p.ID, a.GitSHA)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, d.ID, snapshots[0].DeploymentID)
+	})
+
+	t.Run("no snapshot", func(t *testing.T) {
+		_, err := store.GetRollbackSnapshots(ctx, p.ID, "ddd4444")
+		assert.ErrorIs(t, err, errors.ErrNoVolumeSnapshot)
+
+		_, err = store.GetRollbackSnapshots(ctx, p.ID, "fff9999")
+		assert.ErrorIs(t, err, errors.ErrNoVolumeSnapshot)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.DeleteVolumeSnapshots(ctx, b.ID))
+		snapshots, err := store.ListVolumeSnapshots(ctx, b.ID)
+		require.NoError(t, err)
+		assert.Empty(t, snapshots)
+	})
+}
+
+func TestStore_Hosts(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	web1 := &Host{Name: "web1", DockerHost: "ssh://deploy@web1"}
+	web2 := &Host{Name: "web2", DockerContext: "web2"}
+	require.NoError(t, store.CreateHost(ctx, web1))
+	require.NoError(t, store.CreateHost(ctx, web2))
+	assert.NotEmpty(t, web1.ID)
+
+	t.Run("duplicate name", func(t *testing.T) {
+		err := store.CreateHost(ctx, &Host{Name: "web1", DockerHost: "ssh://other"})
+		assert.ErrorIs(t, err, errors.ErrHostExists)
+	})
+
+	t.Run("needs exactly one endpoint", func(t *testing.T) {
+		assert.Error(t, store.CreateHost(ctx, &Host{Name: "none"}))
+		assert.Error(t, store.CreateHost(ctx, &Host{Name: "both", DockerHost: "ssh://x", DockerContext: "x"}))
+	})
+
+	t.Run("get and list", func(t *testing.T) {
+		got, err := store.GetHost(ctx, "web2")
+		require.NoError(t, err)
+		assert.Equal(t, "web2", got.Target().DockerContext)
+		assert.Equal(t, "web2", got.Target().Name)
+
+		_, err = store.GetHost(ctx, "missing")
+		assert.ErrorIs(t, err, errors.ErrHostNotFound)
+
+		hosts, err := store.ListHosts(ctx)
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Equal(t, "web1", hosts[0].Name)
+	})
+
+	p := &Project{Name: "hosted-app", RepoType: "local", RepoPath: "/srv/hosted-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	t.Run("project hosts keep their order", func(t *testing.T) {
+		require.NoError(t, store.SetProjectHosts(ctx, p.ID, []string{"web2", "web1"}))
+		hosts, err := store.ListProjectHosts(ctx, p.ID)
+		require.NoError(t, err)
+		require.Len(t, hosts, 2)
+		assert.Equal(t, "web2", hosts[0].Name)
+		assert.Equal(t, "web1", hosts[1].Name)
+
+		projects, err := store.HostProjects(ctx, web1.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"hosted-app"}, projects)
+	})
+
+	t.Run("set project hosts is all or nothing", func(t *testing.T) {
+		err := store.SetProjectHosts(ctx, p.ID, []string{"web1", "missing"})
+		assert.ErrorIs(t, err, errors.ErrHostNotFound)
+		assert.Error(t, store.SetProjectHosts(ctx, p.ID, []string{"web1", "web1"}))
+
+		hosts, err := store.ListProjectHosts(ctx, p.ID)
+		require.NoError(t, err)
+		assert.Len(t, hosts, 2, "failed updates should keep the previous hosts")
+	})
+
+	t.Run("hosts in use cannot be deleted", func(t *testing.T) {
+		err := store.DeleteHost(ctx, "web1")
+		assert.ErrorIs(t, err, errors.ErrHostInUse)
+		assert.Contains(t, err.Error(), "hosted-app")
+
+		require.NoError(t, store.SetProjectHosts(ctx, p.ID, []string{"web2"}))
+		require.NoError(t, store.DeleteHost(ctx, "web1"))
+		assert.ErrorIs(t, store.DeleteHost(ctx, "web1"), errors.ErrHostNotFound)
+	})
+
+	t.Run("no hosts means local", func(t *testing.T) {
+		require.NoError(t, store.SetProjectHosts(ctx, p.ID, nil))
+		hosts, err := store.ListProjectHosts(ctx, p.ID)
+		require.NoError(t, err)
+		assert.Empty(t, hosts)
+	})
+}
+
+func TestStore_ActiveDeploymentsPerHost(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.CreateHost(ctx, &Host{Name: "web1", DockerHost: "ssh://deploy@web1"}))
+
+	p := &Project{Name: "rolling-app", RepoType: "local", RepoPath: "/srv/rolling-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	deploy := func(sha, host string) *Deployment {
+		t.Helper()
+		d := &Deployment{ProjectID: p.ID, GitSHA: sha, Status: "active", Host: host}
+		require.NoError(t, store.CreateDeployment(ctx, d))
+		require.NoError(t, store.DeactivatePreviousDeployments(ctx, p.ID, d.ID))
+		return d
+	}
+
+	local := deploy("aaa1111", "")
+	remote := deploy("aaa1111", "web1")
+
+	active, err := store.ListActiveDeployments(ctx, p.ID)
+	require.NoError(t, err)
+	require.Len(t, active, 2, "deploying to one host should leave the other host active")
+	assert.Equal(t, local.ID, active[0].ID, "local deployment should come first")
+	assert.Equal(t, remote.ID, active[1].ID)
+	assert.Equal(t, "web1", active[1].Host)
+
+	newer := deploy("bbb2222", "web1")
+	active, err = store.ListActiveDeployments(ctx, p.ID)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	assert.Equal(t, local.ID, active[0].ID)
+	assert.Equal(t, newer.ID, active[1].ID)
+
+	old, err := store.GetDeployment(ctx, remote.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "inactive", old.Status)
+
+	t.Run("deployment target", func(t *testing.T) {
+		tgt, err := DeploymentTarget(ctx, store, local)
+		require.NoError(t, err)
+		assert.True(t, tgt.IsLocal())
+
+		tgt, err = DeploymentTarget(ctx, store, newer)
+		require.NoError(t, err)
+		assert.Equal(t, "ssh://deploy@web1", tgt.DockerHost)
+	})
+}
+
+func TestStore_DeploymentCounts(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{Name: "counted-app", RepoType: "local", RepoPath: "/srv/counted-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	_, err := store.GetLastSuccessfulDeployment(ctx, p.ID)
+	assert.ErrorIs(t, err, errors.ErrDeploymentNotFound)
+
+	var deployments []*Deployment
+	for _, status := range []string{"active", "failed", "failed", "deploying"} {
+		d := &Deployment{ProjectID: p.ID, GitSHA: "aaa1111", Status: "deploying"}
+		require.NoError(t, store.CreateDeployment(ctx, d))
+		if status != "deploying" {
+			require.NoError(t, store.UpdateDeploymentStatus(ctx, d.ID, status, nil))
+		}
+		deployments = append(deployments, d)
+	}
+
+	counts, err := store.CountDeploymentsByStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []DeploymentCount{
+		{ProjectID: p.ID, Status: "active", Count: 1},
+		{ProjectID: p.ID, Status: "deploying", Count: 1},
+		{ProjectID: p.ID, Status: "failed", Count: 2},
+	}, counts)
+
+	last, err := store.GetLastSuccessfulDeployment(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, deployments[0].ID, last.ID, "failed and unfinished deployments are not successful")
+
+	// A deployment that was live stays the last success once replaced
+	require.NoError(t, store.UpdateDeploymentStatus(ctx, deployments[0].ID, "inactive", nil))
+	last, err = store.GetLastSuccessfulDeployment(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, deployments[0].ID, last.ID)
+}
+
+func TestStore_Schedules(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{Name: "scheduled-app", RepoType: "remote", RepoPath: "/srv/scheduled-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	_, err := store.GetSchedule(ctx, p.ID)
+	assert.ErrorIs(t, err, errors.ErrScheduleNotFound)
+
+	require.NoError(t, store.SetSchedule(ctx, &Schedule{
+		ProjectID: p.ID,
+		Branch:    "main",
+		Interval:  5 * time.Minute,
+		Windows:   "Mon-Fri 02:00-05:00",
+		Timezone:  "UTC",
+	}))
+
+	got, err := store.GetSchedule(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "main", got.Branch)
+	assert.Empty(t, got.TagPattern)
+	assert.Equal(t, 5*time.Minute, got.Interval)
+	assert.Equal(t, "Mon-Fri 02:00-05:00", got.Windows)
+	assert.Nil(t, got.LastCheckedAt)
+
+	t.Run("record checks", func(t *testing.T) {
+		require.NoError(t, store.RecordScheduleCheck(ctx, p.ID, "aaa1111", ""))
+		require.NoError(t, store.RecordScheduleCheck(ctx, p.ID, "", "fetch failed"))
+
+		got, err := store.GetSchedule(ctx, p.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got.LastCheckedAt)
+		assert.Equal(t, "aaa1111", got.LastSHA, "a failed check should keep the last commit")
+		assert.Equal(t, "fetch failed", got.LastError)
+	})
+
+	t.Run("replacing starts over", func(t *testing.T) {
+		require.NoError(t, store.SetSchedule(ctx, &Schedule{ProjectID: p.ID, TagPattern: "v*", Interval: time.Hour}))
+
+		schedules, err := store.ListSchedules(ctx)
+		require.NoError(t, err)
+		require.Len(t, schedules, 1)
+		assert.Equal(t, "v*", schedules[0].TagPattern)
+		assert.Empty(t, schedules[0].Branch)
+		assert.Empty(t, schedules[0].LastSHA)
+	})
+
+	t.Run("follows exactly one ref", func(t *testing.T) {
+		err := store.SetSchedule(ctx, &Schedule{ProjectID: p.ID, Branch: "main", TagPattern: "v*", Interval: time.Hour})
+		assert.Error(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.DeleteSchedule(ctx, p.ID))
+		assert.ErrorIs(t, store.DeleteSchedule(ctx, p.ID), errors.ErrScheduleNotFound)
+	})
+
+	t.Run("removed with the project", func(t *testing.T) {
+		require.NoError(t, store.SetSchedule(ctx, &Schedule{ProjectID: p.ID, Branch: "main", Interval: time.Hour}))
+		require.NoError(t, store.DeleteProject(ctx, p.Name))
+
+		schedules, err := store.ListSchedules(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, schedules)
+	})
+}
+
+func TestStore_DeployQueue(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	p := &Project{Name: "queued-app", RepoType: "remote", RepoPath: "/srv/queued-app", ComposeFile: "compose.yaml", Status: "ready"}
+	require.NoError(t, store.CreateProject(ctx, p))
+
+	first := &QueuedDeploy{ProjectID: p.ID, GitRef: "v1.0.0", TriggerSource: TriggerWebhook, TriggeredBy: "ci", PID: 100}
+	require.NoError(t, store.EnqueueDeploy(ctx, first))
+	assert.NotEmpty(t, first.ID)
+
+	got, err := store.GetQueuedDeploy(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, QueuePending, got.Status)
+	assert.Equal(t, "v1.0.0", got.GitRef)
+	assert.Equal(t, TriggerWebhook, got.TriggerSource)
+	assert.Equal(t, "ci", got.TriggeredBy)
+	assert.Equal(t, 100, got.PID)
+	assert.Empty(t, got.Hosts)
+	assert.Nil(t, got.StartedAt)
+
+	_, err = store.GetQueuedDeploy(ctx, "missing")
+	assert.ErrorIs(t, err, errors.ErrQueuedDeployNotFound)
+
+	t.Run("only one request runs at a time", func(t *testing.T) {
+		started, err := store.StartQueuedDeploy(ctx, first.ID)
+		require.NoError(t, err)
+		assert.True(t, started)
+
+		second := &QueuedDeploy{ProjectID: p.ID, GitRef: "v1.1.0", PID: 101}
+		require.NoError(t, store.EnqueueDeploy(ctx, second))
+		started, err = store.StartQueuedDeploy(ctx, second.ID)
+		require.NoError(t, err)
+		assert.False(t, started, "the first request is still running")
+
+		queued, err := store.ListQueuedDeploys(ctx, p.ID)
+		require.NoError(t, err)
+		require.Len(t, queued, 2)
+		assert.Equal(t, QueueRunning, queued[0].Status)
+		assert.NotNil(t, queued[0].StartedAt)
+		assert.Equal(t, QueuePending, queued[1].Status)
+
+		require.NoError(t, store.FinishQueuedDeploy(ctx, first.ID, QueueSucceeded, ""))
+		started, err = store.StartQueuedDeploy(ctx, second.ID)
+		require.NoError(t, err)
+		assert.True(t, started)
+		require.NoError(t, store.FinishQueuedDeploy(ctx, second.ID, QueueFailed, "health check failed"))
+
+		got, err := store.GetQueuedDeploy(ctx, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, QueueFailed, got.Status)
+		assert.Equal(t, "health check failed", got.Error)
+		assert.NotNil(t, got.FinishedAt)
+	})
+
+	t.Run("newer requests supersede pending ones", func(t *testing.T) {
+		older := &QueuedDeploy{ProjectID: p.ID, GitRef: "main", PID: 200}
+		require.NoError(t, store.EnqueueDeploy(ctx, older))
+		onHost := &QueuedDeploy{ProjectID: p.ID, GitRef: "main", Hosts: []string{"web1", "web2"}, PID: 201}
+		require.NoError(t, store.EnqueueDeploy(ctx, onHost))
+		newer := &QueuedDeploy{ProjectID: p.ID, GitRef: "v2.0.0", PID: 202}
+		require.NoError(t, store.EnqueueDeploy(ctx, newer))
+
+		got, err := store.GetQueuedDeploy(ctx, older.ID)
+		require.NoError(t, err)
+		assert.Equal(t, QueueSuperseded, got.Status)
+		assert.Equal(t, newer.ID, got.SupersededBy)
+
+		queued, err := store.ListQueuedDeploys(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, queued, 2, "requests for other hosts are kept")
+		assert.Equal(t, []string{"web1", "web2"}, queued[0].Hosts)
+		assert.Equal(t, newer.ID, queued[1].ID)
+
+		// Hosts are compared as a set
+		sameHosts := &QueuedDeploy{ProjectID: p.ID, GitRef: "v2.0.0", Hosts: []string{"web2", "web1", "web2"}, PID: 203}
+		require.NoError(t, store.EnqueueDeploy(ctx, sameHosts))
+		got, err = store.GetQueuedDeploy(ctx, onHost.ID)
+		require.NoError(t, err)
+		assert.Equal(t, QueueSuperseded, got.Status)
+		assert.Equal(t, sameHosts.ID, got.SupersededBy)
+
+		got, err = store.GetQueuedDeploy(ctx, sameHosts.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web1", "web2"}, got.Hosts)
+	})
+
+	t.Run("pending requests start oldest first", func(t *testing.T) {
+		queued, err := store.ListQueuedDeploys(ctx, p.ID)
+		require.NoError(t, err)
+		require.Len(t, queued, 2)
+		older, newer := queued[0], queued[1]
+
+		started, err := store.StartQueuedDeploy(ctx, newer.ID)
+		require.NoError(t, err)
+		assert.False(t, started, "an older request is pending")
+
+		started, err = store.StartQueuedDeploy(ctx, older.ID)
+		require.NoError(t, err)
+		assert.True(t, started)
+		require.NoError(t, store.FinishQueuedDeploy(ctx, older.ID, QueueSucceeded, ""))
+
+		started, err = store.StartQueuedDeploy(ctx, newer.ID)
+		require.NoError(t, err)
+		assert.True(t, started)
+		require.NoError(t, store.FinishQueuedDeploy(ctx, newer.ID, QueueSucceeded, ""))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		pending := &QueuedDeploy{ProjectID: p.ID, GitRef: "v3.0.0", PID: 300}
+		require.NoError(t, store.EnqueueDeploy(ctx, pending))
+		require.NoError(t, store.CancelQueuedDeploy(ctx, pending.ID))
+
+		got, err := store.GetQueuedDeploy(ctx, pending.ID)
+		require.NoError(t, err)
+		assert.Equal(t, QueueCancelled, got.Status)
+
+		err = store.CancelQueuedDeploy(ctx, pending.ID)
+		assert.ErrorContains(t, err, "only pending deploys can be cancelled")
+		assert.ErrorIs(t, store.CancelQueuedDeploy(ctx, "missing"), errors.ErrQueuedDeployNotFound)
+	})
+
+	t.Run("removed with the project", func(t *testing.T) {
+		require.NoError(t, store.DeleteProject(ctx, p.Name))
+
+		queued, err := store.ListQueuedDeploys(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, queued)
+	})
+}
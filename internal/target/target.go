@@ -0,0 +1,182 @@
+// Package target selects the Docker engine that docker commands run against.
+// Deployments run on the local engine unless a registered host is chosen, in
+// which case the docker CLI reaches that host's engine over SSH
+// (DOCKER_HOST=ssh://...) or through a docker context. Git checkouts, env
+// files and state stay on the machine running otterstack.
+//
+// The target travels in the context so package-level helpers such as
+// volume.Export or traefik.IsRunning reach the same engine as the compose
+// manager without extra parameters.
+package target
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/tracing"
+)
+
+// Target is a Docker engine deployments can run on. The zero value is the
+// local engine.
+type Target struct {
+	Name          string // registered host name, empty for the local engine
+	DockerHost    string // e.g. ssh://deploy@web1.example.com
+	DockerContext string // name of a docker context on this machine
+}
+
+// Local is the Docker engine of the machine running otterstack.
+var Local = Target{}
+
+// LocalName is how the local engine is shown to users.
+const LocalName = "local"
+
+// IsLocal reports whether the target is the local engine.
+func (t Target) IsLocal() bool {
+	return t.DockerHost == "" && t.DockerContext == ""
+}
+
+// String returns the host name, or "local" for the local engine.
+func (t Target) String() string {
+	if t.Name == "" {
+		return LocalName
+	}
+	return t.Name
+}
+
+// Endpoint describes how the engine is reached, for display.
+func (t Target) Endpoint() string {
+	switch {
+	case t.DockerHost != "":
+		return t.DockerHost
+	case t.DockerContext != "":
+		return "context " + t.DockerContext
+	}
+	return "local engine"
+}
+
+// Env returns the variables that point the docker CLI at the target. Each
+// sets one variable and clears the other, so a DOCKER_HOST or DOCKER_CONTEXT
+// inherited from the shell cannot redirect it. Returns nil for the local engine.
+func (t Target) Env() []string {
+	switch {
+	case t.DockerHost != "":
+		return []string{"DOCKER_HOST=" + t.DockerHost, "DOCKER_CONTEXT="}
+	case t.DockerContext != "":
+		return []string{"DOCKER_CONTEXT=" + t.DockerContext, "DOCKER_HOST="}
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// With returns a context whose docker commands run against t.
+func With(ctx context.Context, t Target) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// From returns the target carried by ctx, or Local.
+func From(ctx context.Context) Target {
+	if t, ok := ctx.Value(contextKey{}).(Target); ok {
+		return t
+	}
+	return Local
+}
+
+// Environ returns the process environment with the target of ctx applied,
+// for commands that set their own environment. It includes the trace context
+// of ctx, so docker compose and hooks continue the trace.
+func Environ(ctx context.Context) []string {
+	return append(append(os.Environ(), From(ctx).Env()...), tracing.Environ(ctx)...)
+}
+
+// Docker returns a docker command that runs against the target of ctx.
+func Docker(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	if !From(ctx).IsLocal() || len(tracing.Environ(ctx)) > 0 {
+		cmd.Env = Environ(ctx)
+	}
+	return cmd
+}
+
+// checkTimeout bounds how long Check waits for an SSH connection and the engine.
+const checkTimeout = 30 * time.Second
+
+// Check connects to the target's engine and returns its version.
+func Check(ctx context.Context, t Target) (string, error) {
+	ctx, cancel := context.WithTimeout(With(ctx, t), checkTimeout)
+	defer cancel()
+
+	output, err := Docker(ctx, "version", "--format", "{{.Server.Version}}").CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("cannot reach docker on %s: timed out after %v", t, checkTimeout)
+		}
+		return "", fmt.Errorf("cannot reach docker on %s: %s", t, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+var (
+	// sshUserPattern and sshHostPattern keep SSH addresses free of characters
+	// ssh would read as options or the shell as syntax.
+	sshUserPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+	sshHostPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.:-]*$`)
+
+	// contextPattern matches docker context names.
+	contextPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.+-]*$`)
+)
+
+// ParseSSH normalizes an SSH address such as "deploy@web1", "web1:2222" or
+// "ssh://deploy@web1:2222" into a DOCKER_HOST value.
+func ParseSSH(addr string) (string, error) {
+	if addr == "" {
+		return "", fmt.Errorf("SSH address is required")
+	}
+	raw := addr
+	if !strings.HasPrefix(raw, "ssh://") {
+		if strings.Contains(raw, "://") {
+			return "", fmt.Errorf("invalid SSH address %q: only ssh:// is supported", addr)
+		}
+		raw = "ssh://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSH address %q: %w", addr, err)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid SSH address %q: must be [user@]host[:port]", addr)
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return "", fmt.Errorf("invalid SSH address %q: passwords are not supported, use an SSH key", addr)
+	}
+	if u.User != nil && !sshUserPattern.MatchString(u.User.Username()) {
+		return "", fmt.Errorf("invalid SSH user in %q", addr)
+	}
+	if !sshHostPattern.MatchString(u.Hostname()) {
+		return "", fmt.Errorf("invalid SSH host in %q", addr)
+	}
+	if port := u.Port(); port != "" {
+		var n int
+		if _, err := fmt.Sscanf(port, "%d", &n); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("invalid SSH port in %q", addr)
+		}
+	}
+
+	u.Path = ""
+	return u.String(), nil
+}
+
+// ValidateContext checks a docker context name.
+func ValidateContext(name string) error {
+	if !contextPattern.MatchString(name) {
+		return fmt.Errorf("invalid docker context name %q", name)
+	}
+	return nil
+}
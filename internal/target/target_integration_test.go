@@ -0,0 +1,60 @@
+//go:build integration
+// +build integration
+
+package target
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sshTarget returns the SSH host the remote tests run against, taken from
+// OTTERSTACK_TEST_SSH_HOST. Any host with sshd and a docker engine works; a
+// docker:dind container with sshd installed and the test key authorized is
+// a stand-in for a real server.
+func sshTarget(t *testing.T) Target {
+	t.Helper()
+	addr := os.Getenv("OTTERSTACK_TEST_SSH_HOST")
+	if addr == "" {
+		t.Skip("OTTERSTACK_TEST_SSH_HOST not set, skipping remote host test")
+	}
+	dockerHost, err := ParseSSH(addr)
+	require.NoError(t, err)
+	return Target{Name: "test-remote", DockerHost: dockerHost}
+}
+
+// TestRealSSHCheck connects to the remote engine over SSH
+func TestRealSSHCheck(t *testing.T) {
+	remote := sshTarget(t)
+
+	version, err := Check(context.Background(), remote)
+	require.NoError(t, err)
+	assert.NotEmpty(t, version)
+}
+
+// TestRealSSHIsolation checks that containers started on the remote engine
+// are not visible to the local one
+func TestRealSSHIsolation(t *testing.T) {
+	remote := sshTarget(t)
+	ctx := With(context.Background(), remote)
+
+	name := "otterstack-target-test"
+	_ = Docker(ctx, "rm", "-f", name).Run()
+	out, err := Docker(ctx, "run", "-d", "--name", name, "alpine:latest", "sleep", "60").CombinedOutput()
+	require.NoError(t, err, string(out))
+	defer Docker(ctx, "rm", "-f", name).Run()
+
+	out, err = Docker(ctx, "ps", "--filter", "name="+name, "--format", "{{.Names}}").Output()
+	require.NoError(t, err)
+	assert.Equal(t, name, strings.TrimSpace(string(out)))
+
+	out, err = Docker(context.Background(), "ps", "--filter", "name="+name, "--format", "{{.Names}}").Output()
+	if err == nil {
+		assert.Empty(t, strings.TrimSpace(string(out)), "remote container should not run on the local engine")
+	}
+}
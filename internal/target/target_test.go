@@ -0,0 +1,105 @@
+package target
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseSSH(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{addr: "web1", want: "ssh://web1"},
+		{addr: "deploy@web1.example.com", want: "ssh://deploy@web1.example.com"},
+		{addr: "deploy@10.0.0.12:2222", want: "ssh://deploy@10.0.0.12:2222"},
+		{addr: "ssh://deploy@web1:22", want: "ssh://deploy@web1:22"},
+		{addr: "ssh://deploy@web1/", want: "ssh://deploy@web1"},
+		{addr: "", wantErr: true},
+		{addr: "tcp://web1:2375", wantErr: true},
+		{addr: "deploy:secret@web1", wantErr: true},
+		{addr: "web1/path", wantErr: true},
+		{addr: "web1:0", wantErr: true},
+		{addr: "web1:70000", wantErr: true},
+		{addr: "-oProxyCommand=x", wantErr: true},
+		{addr: "-x@web1", wantErr: true},
+		{addr: "web1;rm", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got, err := ParseSSH(tt.addr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateContext(t *testing.T) {
+	assert.NoError(t, ValidateContext("staging"))
+	assert.NoError(t, ValidateContext("prod-eu.1"))
+	assert.Error(t, ValidateContext(""))
+	assert.Error(t, ValidateContext("-bad"))
+	assert.Error(t, ValidateContext("has space"))
+}
+
+func TestTarget(t *testing.T) {
+	ssh := Target{Name: "web1", DockerHost: "ssh://deploy@web1"}
+	dctx := Target{Name: "web2", DockerContext: "web2"}
+
+	t.Run("local", func(t *testing.T) {
+		assert.True(t, Local.IsLocal())
+		assert.Equal(t, LocalName, Local.String())
+		assert.Nil(t, Local.Env())
+	})
+
+	t.Run("env overrides the other variable", func(t *testing.T) {
+		assert.False(t, ssh.IsLocal())
+		assert.Equal(t, "web1", ssh.String())
+		assert.Equal(t, []string{"DOCKER_HOST=ssh://deploy@web1", "DOCKER_CONTEXT="}, ssh.Env())
+		assert.Equal(t, []string{"DOCKER_CONTEXT=web2", "DOCKER_HOST="}, dctx.Env())
+		assert.Equal(t, "context web2", dctx.Endpoint())
+	})
+
+	t.Run("context", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Equal(t, Local, From(ctx))
+		assert.Equal(t, ssh, From(With(ctx, ssh)))
+	})
+
+	t.Run("docker command", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Nil(t, Docker(ctx, "ps").Env, "local commands should inherit the environment")
+
+		cmd := Docker(With(ctx, ssh), "ps")
+		assert.Contains(t, cmd.Env, "DOCKER_HOST=ssh://deploy@web1")
+		assert.Equal(t, []string{"docker", "ps"}, cmd.Args)
+	})
+
+	t.Run("trace context", func(t *testing.T) {
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x01, 15: 0xff},
+			SpanID:     trace.SpanID{0x0a},
+			TraceFlags: trace.FlagsSampled,
+		})
+		ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+		cmd := Docker(ctx, "compose", "up")
+		assert.Contains(t, cmd.Env, "TRACEPARENT=00-010000000000000000000000000000ff-0a00000000000000-01",
+			"local commands get the trace context too")
+	})
+}
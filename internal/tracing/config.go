@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// DefaultServiceName is the service name spans are reported under unless
+// OTEL_SERVICE_NAME or OTEL_RESOURCE_ATTRIBUTES set another.
+const DefaultServiceName = "otterstack"
+
+// OTLP protocols, as named by OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+)
+
+// Config selects where spans are exported. Tracing is off when neither
+// File nor Protocol is set.
+type Config struct {
+	File     string // Path to append JSON spans to, or "-" for stdout
+	Protocol string // OTLP protocol; empty when no OTLP endpoint is configured
+}
+
+// ConfigFromEnv reads the standard OpenTelemetry exporter variables. OTLP
+// export is on when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or
+// OTEL_EXPORTER_OTLP_ENDPOINT is set, over the protocol named by
+// OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL
+// (http/protobuf by default). OTEL_SDK_DISABLED=true or
+// OTEL_TRACES_EXPORTER=none turn it off. The exporters read the endpoint,
+// headers, timeout and TLS settings from the environment themselves.
+func ConfigFromEnv(getenv func(string) string) Config {
+	var cfg Config
+	if strings.EqualFold(getenv("OTEL_SDK_DISABLED"), "true") || getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return cfg
+	}
+	if getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" && getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return cfg
+	}
+
+	cfg.Protocol = getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if cfg.Protocol == "" {
+		cfg.Protocol = getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolHTTPProtobuf
+	}
+	return cfg
+}
+
+// Enabled reports whether the config exports spans anywhere.
+func (c Config) Enabled() bool {
+	return c.File != "" || c.Protocol != ""
+}
+
+// Setup installs a tracer provider exporting spans as configured, batched
+// and sent at least every interval, and the W3C trace context propagator.
+// It returns the function that flushes the remaining spans and removes the
+// provider. Export errors are passed to onError, if set. Setup does nothing
+// when c is not Enabled.
+func Setup(ctx context.Context, c Config, serviceVersion string, interval time.Duration, onError func(error)) (func(context.Context) error, error) {
+	if !c.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var (
+		opts    []sdktrace.TracerProviderOption
+		closers []io.Closer
+	)
+	closeAll := func() error {
+		var errs []error
+		for _, c := range closers {
+			errs = append(errs, c.Close())
+		}
+		return errors.Join(errs...)
+	}
+
+	if c.File != "" {
+		var w io.Writer = os.Stdout
+		if c.File != "-" {
+			f, err := os.OpenFile(c.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open trace file: %w", err)
+			}
+			w = f
+			closers = append(closers, f)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to create trace file exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(interval)))
+	}
+
+	if c.Protocol != "" {
+		exporter, err := newOTLPExporter(ctx, c.Protocol)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(interval)))
+	}
+
+	res, err := newResource(ctx, serviceVersion)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	opts = append(opts, sdktrace.WithResource(res))
+
+	if onError != nil {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(onError))
+	}
+	tp := NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		err := tp.Shutdown(ctx)
+		return errors.Join(err, closeAll())
+	}, nil
+}
+
+// newOTLPExporter creates the OTLP exporter for protocol. It reads its
+// endpoint and options from the OTEL_EXPORTER_OTLP_* variables.
+func newOTLPExporter(ctx context.Context, protocol string) (sdktrace.SpanExporter, error) {
+	switch protocol {
+	case ProtocolGRPC:
+		return otlptracegrpc.New(ctx)
+	case ProtocolHTTPProtobuf:
+		return otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q (must be %s or %s)", protocol, ProtocolGRPC, ProtocolHTTPProtobuf)
+	}
+}
+
+// newResource describes this process with the semantic convention resource
+// attributes: service, host, OS, process and SDK. OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES override the defaults.
+func newResource(ctx context.Context, serviceVersion string) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName(DefaultServiceName),
+			semconv.ServiceVersion(serviceVersion),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOSType(),
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect trace resource: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,273 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Config
+	}{
+		{
+			name: "nothing set",
+			want: Config{},
+		},
+		{
+			name: "base endpoint defaults to http/protobuf",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318"},
+			want: Config{Protocol: ProtocolHTTPProtobuf},
+		},
+		{
+			name: "traces endpoint with base protocol",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector:4317",
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+			},
+			want: Config{Protocol: ProtocolGRPC},
+		},
+		{
+			name: "traces protocol overrides base protocol",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://collector:4318",
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+				"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": "http/protobuf",
+			},
+			want: Config{Protocol: ProtocolHTTPProtobuf},
+		},
+		{
+			name: "protocol without endpoint",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			want: Config{},
+		},
+		{
+			name: "exporter disabled",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_TRACES_EXPORTER":        "none",
+			},
+			want: Config{},
+		},
+		{
+			name: "SDK disabled",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_SDK_DISABLED":           "TRUE",
+			},
+			want: Config{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ConfigFromEnv(func(key string) string { return tt.env[key] })
+			assert.Equal(t, tt.want, cfg)
+		})
+	}
+}
+
+// setup runs Setup for the test and removes the provider it installs.
+func setup(t *testing.T, c Config) func(context.Context) error {
+	t.Helper()
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "")
+	shutdown, err := Setup(context.Background(), c, "test", time.Hour, func(err error) { t.Errorf("export failed: %v", err) })
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+	return shutdown
+}
+
+func TestSetup_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown := setup(t, Config{File: path})
+
+	ctx, span := Tracer().Start(context.Background(), "deploy")
+	_, child := Tracer().Start(ctx, "git.fetch")
+	child.End()
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	type fileSpan struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Resource    []struct {
+			Key   string
+			Value struct{ Value interface{} }
+		}
+	}
+	var spans []fileSpan
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s fileSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &s), "one JSON span per line")
+		spans = append(spans, s)
+	}
+	require.Len(t, spans, 2)
+	assert.Equal(t, "git.fetch", spans[0].Name)
+	assert.Equal(t, "deploy", spans[1].Name)
+	assert.Equal(t, spans[0].SpanContext.TraceID, spans[1].SpanContext.TraceID)
+
+	resource := make(map[string]interface{})
+	for _, kv := range spans[1].Resource {
+		resource[kv.Key] = kv.Value.Value
+	}
+	assert.Equal(t, "otterstack", resource["service.name"])
+	assert.Equal(t, "test", resource["service.version"])
+	assert.Contains(t, resource, "host.name")
+	assert.Contains(t, resource, "process.pid")
+	assert.Equal(t, "opentelemetry", resource["telemetry.sdk.name"])
+
+	// The provider is removed on shutdown
+	_, span = Tracer().Start(context.Background(), "after")
+	assert.False(t, span.IsRecording())
+}
+
+// resourceAttr returns a string resource attribute of an OTLP request.
+func resourceAttr(req *coltracepb.ExportTraceServiceRequest, key string) string {
+	for _, rs := range req.ResourceSpans {
+		for _, kv := range rs.Resource.Attributes {
+			if kv.Key == key {
+				return kv.Value.GetStringValue()
+			}
+		}
+	}
+	return ""
+}
+
+// spanNames lists the span names of an OTLP request.
+func spanNames(req *coltracepb.ExportTraceServiceRequest) []string {
+	var names []string
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				names = append(names, s.Name)
+			}
+		}
+	}
+	return names
+}
+
+func TestSetup_OTLPHTTP(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		req    coltracepb.ExportTraceServiceRequest
+		header http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		header = r.Header
+		assert.NoError(t, proto.Unmarshal(body, &req))
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Bearer%20token")
+	shutdown := setup(t, ConfigFromEnv(os.Getenv))
+
+	_, span := Tracer().Start(context.Background(), "deploy", trace.WithAttributes(AttrProject.String("myapp")))
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "application/x-protobuf", header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+	assert.Equal(t, []string{"deploy"}, spanNames(&req))
+	assert.Equal(t, "otterstack", resourceAttr(&req, "service.name"))
+	assert.Equal(t, ScopeName, req.ResourceSpans[0].ScopeSpans[0].Scope.Name)
+	assert.Contains(t, req.ResourceSpans[0].ScopeSpans[0].Spans[0].Attributes,
+		&commonpb.KeyValue{Key: string(AttrProject), Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "myapp"}}})
+}
+
+// traceCollector is an OTLP gRPC trace service recording what it receives.
+type traceCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	mu       sync.Mutex
+	requests []*coltracepb.ExportTraceServiceRequest
+	metadata metadata.MD
+}
+
+func (c *traceCollector) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	c.metadata, _ = metadata.FromIncomingContext(ctx)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func TestSetup_OTLPGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	collector := &traceCollector{}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, collector)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://"+listener.Addr().String())
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=abc")
+	shutdown := setup(t, ConfigFromEnv(os.Getenv))
+
+	_, span := Tracer().Start(context.Background(), "deploy")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	require.Len(t, collector.requests, 1)
+	assert.Equal(t, []string{"deploy"}, spanNames(collector.requests[0]))
+	assert.Equal(t, "otterstack", resourceAttr(collector.requests[0], "service.name"))
+	assert.Equal(t, []string{"abc"}, collector.metadata.Get("x-api-key"))
+}
+
+func TestSetup_UnsupportedProtocol(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Protocol: "http/json"}, "test", time.Hour, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported OTLP protocol "http/json"`)
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{}, "test", time.Hour, nil)
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, span := Tracer().Start(context.Background(), "deploy")
+	assert.False(t, span.IsRecording())
+}
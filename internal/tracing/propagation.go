@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Environ returns the trace context of ctx as environment variables for a
+// child process, e.g. "TRACEPARENT=00-…-01", following the W3C Trace Context
+// and Baggage headers upper-cased. It returns nothing when ctx holds no
+// span or tracing is off.
+func Environ(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	keys := carrier.Keys()
+	sort.Strings(keys)
+	var env []string
+	for _, key := range keys {
+		env = append(env, strings.ToUpper(key)+"="+carrier.Get(key))
+	}
+	return env
+}
+
+// ContextFromEnviron returns ctx with the trace context read from the
+// TRACEPARENT, TRACESTATE and BAGGAGE variables of getenv, so a command run
+// by another traced process continues its trace.
+func ContextFromEnviron(ctx context.Context, getenv func(string) string) context.Context {
+	propagator := otel.GetTextMapPropagator()
+	carrier := propagation.MapCarrier{}
+	for _, field := range propagator.Fields() {
+		if v := getenv(strings.ToUpper(field)); v != "" {
+			carrier.Set(field, v)
+		}
+	}
+	return propagator.Extract(ctx, carrier)
+}
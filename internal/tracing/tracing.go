@@ -0,0 +1,89 @@
+// Package tracing sets up the OpenTelemetry SDK for otterstack and holds the
+// conventions its instrumented packages share.
+//
+// Instrumented code starts spans with Tracer, which is a no-op until Setup
+// installs a tracer provider, so tracing costs next to nothing when it is off.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of otterstack's spans.
+const ScopeName = "github.com/jayteealao/otterstack"
+
+// Attribute keys shared by the instrumented packages.
+const (
+	AttrProject        = attribute.Key("otterstack.project")
+	AttrSHA            = attribute.Key("otterstack.git.sha")
+	AttrRef            = attribute.Key("otterstack.git.ref")
+	AttrPhase          = attribute.Key("otterstack.phase")
+	AttrHost           = attribute.Key("otterstack.host")
+	AttrDeploymentID   = attribute.Key("otterstack.deployment.id")
+	AttrComposeProject = attribute.Key("otterstack.compose.project")
+	AttrRepo           = attribute.Key("otterstack.git.repo")
+	AttrNotifier       = attribute.Key("otterstack.notifier")
+	AttrEvent          = attribute.Key("otterstack.event")
+	AttrHook           = attribute.Key("otterstack.hook")
+)
+
+// Tracer returns the tracer spans are started with. It is looked up on every
+// call so it follows the tracer provider installed last.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+type attrsKey struct{}
+
+// WithAttributes returns a context whose spans, and those of its children,
+// carry attrs in addition to their own. The attributes are added by the
+// tracer provider from NewTracerProvider.
+func WithAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	inherited, _ := ctx.Value(attrsKey{}).([]attribute.KeyValue)
+	return context.WithValue(ctx, attrsKey{}, append(append([]attribute.KeyValue(nil), inherited...), attrs...))
+}
+
+// contextAttributes is a span processor that adds the attributes set with
+// WithAttributes on the parent context to each span it starts.
+type contextAttributes struct{}
+
+func (contextAttributes) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if attrs, _ := parent.Value(attrsKey{}).([]attribute.KeyValue); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+func (contextAttributes) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (contextAttributes) Shutdown(context.Context) error   { return nil }
+func (contextAttributes) ForceFlush(context.Context) error { return nil }
+
+// NewTracerProvider returns an SDK tracer provider that adds the attributes
+// of WithAttributes to spans, configured further by opts.
+func NewTracerProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append([]sdktrace.TracerProviderOption{sdktrace.WithSpanProcessor(contextAttributes{})}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// RecordError marks span as failed with err. A nil err is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// EndErr records *errp, if set, and ends span. It is meant to be deferred
+// with a pointer to a named error result.
+func EndErr(span trace.Span, errp *error) {
+	if errp != nil {
+		RecordError(span, *errp)
+	}
+	span.End()
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// record installs a tracer provider recording spans in memory for the test.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+	return recorder
+}
+
+func byName(recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, s := range recorder.Ended() {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) interface{} {
+	for _, a := range s.Attributes() {
+		if a.Key == key {
+			return a.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+func TestTracer_Disabled(t *testing.T) {
+	otel.SetTracerProvider(noop.NewTracerProvider())
+
+	_, span := Tracer().Start(context.Background(), "deploy")
+	assert.False(t, span.IsRecording())
+	assert.False(t, span.SpanContext().IsValid())
+
+	err := errors.New("failed")
+	EndErr(span, &err)
+	assert.Empty(t, Environ(trace.ContextWithSpan(context.Background(), span)))
+}
+
+func TestTracer_FollowsProvider(t *testing.T) {
+	first := record(t)
+	_, span := Tracer().Start(context.Background(), "a")
+	span.End()
+
+	// Spans started after another provider is installed go to it
+	second := record(t)
+	_, span = Tracer().Start(context.Background(), "b")
+	span.End()
+
+	assert.NotNil(t, byName(first, "a"))
+	assert.Nil(t, byName(first, "b"))
+	assert.NotNil(t, byName(second, "b"))
+}
+
+func TestWithAttributes(t *testing.T) {
+	recorder := record(t)
+
+	ctx := WithAttributes(context.Background(), AttrProject.String("myapp"))
+	ctx, root := Tracer().Start(ctx, "deploy", trace.WithAttributes(AttrHost.String("local")))
+	ctx = WithAttributes(ctx, AttrSHA.String("abc123"))
+	_, child := Tracer().Start(ctx, "git.fetch")
+	child.End()
+	root.End()
+
+	require.Len(t, recorder.Ended(), 2)
+	deploy, fetch := byName(recorder, "deploy"), byName(recorder, "git.fetch")
+	assert.Equal(t, deploy.SpanContext().TraceID(), fetch.SpanContext().TraceID())
+	assert.Equal(t, deploy.SpanContext().SpanID(), fetch.Parent().SpanID())
+
+	assert.Equal(t, "myapp", attr(deploy, AttrProject))
+	assert.Equal(t, "local", attr(deploy, AttrHost))
+	assert.Nil(t, attr(deploy, AttrSHA), "attributes added later only reach new spans")
+	assert.Equal(t, "myapp", attr(fetch, AttrProject), "context attributes are inherited")
+	assert.Equal(t, "abc123", attr(fetch, AttrSHA))
+}
+
+func TestEndErr(t *testing.T) {
+	recorder := record(t)
+
+	fn := func(fail bool) (err error) {
+		_, span := Tracer().Start(context.Background(), "compose.up")
+		defer EndErr(span, &err)
+		if fail {
+			return errors.New("container exited")
+		}
+		return nil
+	}
+	require.NoError(t, fn(false))
+	require.Error(t, fn(true))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	ok, failed := spans[0], spans[1]
+	assert.Equal(t, codes.Unset, ok.Status().Code)
+	assert.Empty(t, ok.Events())
+
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Equal(t, "container exited", failed.Status().Description)
+	require.Len(t, failed.Events(), 1)
+	assert.Equal(t, "exception", failed.Events()[0].Name)
+}
+
+func TestEnviron(t *testing.T) {
+	record(t)
+
+	ctx, span := Tracer().Start(context.Background(), "hook")
+	defer span.End()
+	sc := span.SpanContext()
+
+	env := Environ(ctx)
+	assert.Equal(t, []string{"TRACEPARENT=00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"}, env)
+
+	// A child process continues the trace
+	getenv := func(key string) string {
+		if key == "TRACEPARENT" {
+			return env[0][len("TRACEPARENT="):]
+		}
+		return ""
+	}
+	remote := trace.SpanContextFromContext(ContextFromEnviron(context.Background(), getenv))
+	assert.True(t, remote.IsRemote())
+	assert.Equal(t, sc.TraceID(), remote.TraceID())
+	assert.Equal(t, sc.SpanID(), remote.SpanID())
+
+	assert.Empty(t, Environ(context.Background()))
+	assert.False(t, trace.SpanContextFromContext(ContextFromEnviron(context.Background(), func(string) string { return "" })).IsValid())
+}
+
+func TestNewTracerProvider_Batches(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := NewTracerProvider(sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(time.Hour)))
+
+	_, span := tp.Tracer(ScopeName).Start(WithAttributes(context.Background(), AttrProject.String("myapp")), "deploy")
+	span.End()
+	assert.Empty(t, exporter.GetSpans(), "spans are batched")
+
+	require.NoError(t, tp.ForceFlush(context.Background()))
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes, AttrProject.String("myapp"))
+	require.NoError(t, tp.Shutdown(context.Background()))
+}
@@ -3,8 +3,9 @@ package traefik
 
 import (
 	"context"
-	"os/exec"
 	"strings"
+
+	"github.com/jayteealao/otterstack/internal/target"
 )
 
 // IsRunning checks if a Traefik container is currently running.
@@ -12,7 +13,7 @@ import (
 // Does not return an error if Traefik is not found - degraded mode is supported.
 func IsRunning(ctx context.Context) (bool, error) {
 	// Check for Traefik container
-	cmd := exec.CommandContext(ctx, "docker", "ps",
+	cmd := target.Docker(ctx, "ps",
 		"--filter", "name=traefik",
 		"--filter", "status=running",
 		"--format", "{{.Names}}")
@@ -3,9 +3,13 @@ package traefik
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/jayteealao/otterstack/internal/logging"
+	"github.com/jayteealao/otterstack/internal/target"
+	"github.com/jayteealao/otterstack/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -18,24 +22,47 @@ const (
 // WaitForHealthy waits for all containers in a compose project to become healthy.
 // It polls the container health status at regular intervals until the timeout is reached.
 func WaitForHealthy(ctx context.Context, composeProject string, timeout time.Duration) error {
+	return WaitForHealthyEvery(ctx, composeProject, timeout, healthCheckInterval)
+}
+
+// WaitForHealthyEvery is like WaitForHealthy but polls at the given interval.
+func WaitForHealthyEvery(ctx context.Context, composeProject string, timeout, interval time.Duration) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "traefik.wait_for_healthy",
+		trace.WithAttributes(tracing.AttrComposeProject.String(composeProject)))
+	defer tracing.EndErr(span, &err)
+
+	if interval <= 0 {
+		interval = healthCheckInterval
+	}
+
+	log := logging.FromContext(ctx).With(
+		logging.String("compose_project", composeProject),
+		logging.Duration("timeout", timeout))
+	log.Info("waiting for containers to be healthy")
+	start := time.Now()
+
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	ticker := time.NewTicker(healthCheckInterval)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
+			log.Error("health check timed out", logging.Duration("duration", time.Since(start)))
 			return fmt.Errorf("health check timeout after %v", timeout)
 		case <-ticker.C:
 			healthy, err := checkHealth(ctx, composeProject)
 			if err != nil {
+				log.Error("health check failed", logging.Error(err))
 				return fmt.Errorf("health check failed: %w", err)
 			}
 			if healthy {
+				log.Info("containers are healthy", logging.Duration("duration", time.Since(start)))
 				return nil
 			}
+			log.Debug("containers not healthy yet")
 		}
 	}
 }
@@ -44,7 +71,7 @@ func WaitForHealthy(ctx context.Context, composeProject string, timeout time.Dur
 // Returns true if all containers are healthy or running (for containers without healthchecks), false otherwise.
 func checkHealth(ctx context.Context, composeProject string) (bool, error) {
 	// Get container status and health
-	cmd := exec.CommandContext(ctx, "docker", "compose",
+	cmd := target.Docker(ctx, "compose",
 		"-p", composeProject,
 		"ps", "--format", "{{.Name}}\t{{.Status}}\t{{.Health}}")
 
@@ -0,0 +1,437 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/state"
+)
+
+// Actions performs the operations that can be started from a project's
+// detail view. The monitor command implements it with the same code as the
+// deploy and rollback commands, so the dashboard takes the project lock and
+// records operation logs like the CLI does.
+type Actions interface {
+	// Refs lists the branches and tags a project can be deployed at.
+	Refs(ctx context.Context, project *state.Project) ([]string, error)
+
+	// Deploy deploys a project at a ref, writing progress to out.
+	Deploy(ctx context.Context, project *state.Project, ref string, out io.Writer) error
+
+	// Rollback returns a project to an earlier deployment, writing progress to out.
+	Rollback(ctx context.Context, project *state.Project, to *state.Deployment, out io.Writer) error
+
+	// Restart restarts one service of a deployment, writing progress to out.
+	Restart(ctx context.Context, project *state.Project, deployment *state.Deployment, service string, out io.Writer) error
+}
+
+// stream carries the output of a background operation to the UI one line at
+// a time, followed by its result.
+type stream struct {
+	ch   chan tea.Msg
+	quit chan struct{}
+	once sync.Once
+}
+
+type streamLineMsg struct {
+	src  *stream
+	line string
+}
+
+type streamDoneMsg struct {
+	src *stream
+	err error
+}
+
+// startStream runs fn in the background. The returned command delivers its
+// first message; call next after each one to receive the rest.
+func startStream(fn func(w io.Writer) error) (*stream, tea.Cmd) {
+	s := &stream{ch: make(chan tea.Msg, 64), quit: make(chan struct{})}
+	go func() {
+		w := &lineWriter{fn: func(line string) { s.send(streamLineMsg{src: s, line: line}) }}
+		err := fn(w)
+		w.Flush()
+		s.send(streamDoneMsg{src: s, err: err})
+	}()
+	return s, s.next()
+}
+
+func (s *stream) send(msg tea.Msg) {
+	select {
+	case s.ch <- msg:
+	case <-s.quit:
+	}
+}
+
+// next waits for the next line or the result.
+func (s *stream) next() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case msg := <-s.ch:
+			return msg
+		case <-s.quit:
+			return nil
+		}
+	}
+}
+
+// stop discards the rest of the output so the operation never blocks on it.
+func (s *stream) stop() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+// lineWriter calls fn for each line written to it.
+type lineWriter struct {
+	mu  sync.Mutex
+	fn  func(line string)
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits a trailing line that was not terminated by a newline.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(line []byte) {
+	// Progress output redraws lines with carriage returns; keep the last state
+	text := string(line)
+	if i := strings.LastIndexByte(strings.TrimRight(text, "\r"), '\r'); i >= 0 {
+		text = text[i+1:]
+	}
+	w.fn(strings.TrimRight(text, "\r"))
+}
+
+// task is an action started from the detail view. Its latest output lines
+// are shown below the services until another action replaces it.
+type task struct {
+	projectID string
+	title     string
+	lines     []string
+	done      bool
+	err       error
+	stream    *stream
+}
+
+// maxTaskLines is the number of output lines shown for a task.
+const maxTaskLines = 8
+
+func (t *task) addLine(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	t.lines = append(t.lines, line)
+	if len(t.lines) > maxTaskLines {
+		t.lines = t.lines[len(t.lines)-maxTaskLines:]
+	}
+}
+
+// confirmation is a yes/no prompt shown before an action starts.
+type confirmation struct {
+	prompt string
+	title  string // Title of the task started on yes
+	run    func(ctx context.Context, out io.Writer) error
+}
+
+// selected returns the project shown in the detail view.
+func (m *Model) selected() (ProjectInfo, bool) {
+	if m.selectedIndex >= len(m.projects) {
+		return ProjectInfo{}, false
+	}
+	return m.projects[m.selectedIndex], true
+}
+
+// handleDetailKey handles the service cursor and the action keys.
+func (m Model) handleDetailKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	info, ok := m.selected()
+	if !ok {
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, keys.Up):
+		if m.service > 0 {
+			m.service--
+		}
+
+	case key.Matches(msg, keys.Down):
+		if m.service < len(info.Services)-1 {
+			m.service++
+		}
+
+	case key.Matches(msg, keys.Deploy):
+		if m.canStart() {
+			m.notice = "Loading refs..."
+			return m, m.loadRefs(info.Project)
+		}
+
+	case key.Matches(msg, keys.Rollback):
+		if !m.canStart() {
+			break
+		}
+		if info.Deployment == nil {
+			m.notice = "No active deployment to roll back from"
+			break
+		}
+		m.notice = "Loading deployments..."
+		return m, m.loadCandidates(info.Project, info.Deployment)
+
+	case key.Matches(msg, keys.Restart):
+		if !m.canStart() {
+			break
+		}
+		service, ok := m.selectedService(info)
+		if !ok {
+			break
+		}
+		actions, project, deployment := m.actions, info.Project, info.Deployment
+		m.confirm = &confirmation{
+			prompt: fmt.Sprintf("Restart service %s of %s?", service, project.Name),
+			title:  fmt.Sprintf("Restarting %s", service),
+			run: func(ctx context.Context, out io.Writer) error {
+				return actions.Restart(ctx, project, deployment, service, out)
+			},
+		}
+
+	case key.Matches(msg, keys.History):
+		m.notice = "Loading history..."
+		return m, m.loadHistory(info.Project)
+
+	case key.Matches(msg, keys.Logs):
+		service, ok := m.selectedService(info)
+		if !ok {
+			break
+		}
+		composeProject := compose.GenerateProjectName(info.Project.Name, git.ShortSHA(info.Deployment.GitSHA))
+		width, height := m.logViewportSize()
+		var cmd tea.Cmd
+		m.logs, cmd = openLogs(m.ctx, info.Host, info.Project.Name, composeProject, service, width, height)
+		m.currentView = ViewLogs
+		return m, cmd
+	}
+	return m, nil
+}
+
+// canStart reports whether a new action may start, and says why not otherwise.
+func (m *Model) canStart() bool {
+	switch {
+	case m.actions == nil:
+		m.notice = "Actions are not available"
+		return false
+	case m.task != nil && !m.task.done:
+		m.notice = "Wait for the running action to finish"
+		return false
+	}
+	return true
+}
+
+// selectedService returns the compose service of the container under the cursor.
+func (m *Model) selectedService(info ProjectInfo) (string, bool) {
+	if info.Deployment == nil || m.service >= len(info.Services) {
+		m.notice = "No running service selected"
+		return "", false
+	}
+	svc := info.Services[m.service]
+	if svc.Service == "" {
+		return svc.Name, true
+	}
+	return svc.Service, true
+}
+
+func (m Model) loadRefs(project *state.Project) tea.Cmd {
+	return func() tea.Msg {
+		refs, err := m.actions.Refs(m.ctx, project)
+		return refsMsg{project: project, refs: refs, err: err}
+	}
+}
+
+// openRefPicker lets the user choose the ref to deploy. A ref that is not
+// listed, such as a commit SHA, can be typed into the filter.
+func (m Model) openRefPicker(msg refsMsg) Model {
+	m.notice = ""
+	if msg.err != nil {
+		m.notice = fmt.Sprintf("Failed to list refs: %v", msg.err)
+		return m
+	}
+	if info, ok := m.selected(); !ok || info.Project.ID != msg.project.ID || m.currentView != ViewDetail {
+		return m // The user moved on while the refs loaded
+	}
+
+	items := make([]pickerItem, len(msg.refs))
+	for i, ref := range msg.refs {
+		items[i] = pickerItem{Label: ref, Value: ref}
+	}
+
+	actions, project := m.actions, msg.project
+	m.picker = newPicker(fmt.Sprintf("Deploy %s at:", project.Name), items, true)
+	m.onPick = func(item pickerItem) *confirmation {
+		ref := item.Value
+		return &confirmation{
+			prompt: fmt.Sprintf("Deploy %s at %s?", project.Name, ref),
+			title:  fmt.Sprintf("Deploying %s at %s", project.Name, ref),
+			run: func(ctx context.Context, out io.Writer) error {
+				return actions.Deploy(ctx, project, ref, out)
+			},
+		}
+	}
+	return m
+}
+
+// rollbackCandidates is the number of recent deployments searched for
+// rollback targets.
+const rollbackCandidates = 50
+
+func (m Model) loadCandidates(project *state.Project, current *state.Deployment) tea.Cmd {
+	return func() tea.Msg {
+		deployments, err := m.store.ListDeployments(m.ctx, project.ID, rollbackCandidates)
+		return candidatesMsg{project: project, current: current, deployments: deployments, err: err}
+	}
+}
+
+// openRollbackPicker lets the user choose an earlier deployment to return
+// to. Only commits that were live before are offered, newest first.
+func (m Model) openRollbackPicker(msg candidatesMsg) Model {
+	m.notice = ""
+	if msg.err != nil {
+		m.notice = fmt.Sprintf("Failed to list deployments: %v", msg.err)
+		return m
+	}
+	if info, ok := m.selected(); !ok || info.Project.ID != msg.project.ID || m.currentView != ViewDetail {
+		return m
+	}
+
+	seen := map[string]bool{msg.current.GitSHA: true}
+	byID := make(map[string]*state.Deployment)
+	var items []pickerItem
+	for _, d := range msg.deployments {
+		if !wasLive(d) || seen[d.GitSHA] {
+			continue
+		}
+		seen[d.GitSHA] = true
+		byID[d.ID] = d
+
+		label := git.ShortSHA(d.GitSHA)
+		if d.GitRef != "" {
+			label += " " + d.GitRef
+		}
+		items = append(items, pickerItem{
+			Label:  label,
+			Detail: d.StartedAt.Format("2006-01-02 15:04") + " " + d.Kind,
+			Value:  d.ID,
+		})
+	}
+	if len(items) == 0 {
+		m.notice = "No earlier deployment to roll back to"
+		return m
+	}
+
+	m.picker = newPicker(fmt.Sprintf("Roll %s back to:", msg.project.Name), items, false)
+	m.onPick = func(item pickerItem) *confirmation {
+		return m.rollbackPrompt(msg.project, msg.current, byID[item.Value])
+	}
+	return m
+}
+
+// wasLive reports whether a deployment once served traffic, so it can be
+// rolled back to.
+func wasLive(d *state.Deployment) bool {
+	return d.Status == "inactive" || d.Status == "rolled_back"
+}
+
+func (m *Model) rollbackPrompt(project *state.Project, current, to *state.Deployment) *confirmation {
+	actions := m.actions
+	return &confirmation{
+		prompt: fmt.Sprintf("Roll %s back from %s to %s?", project.Name, git.ShortSHA(current.GitSHA), git.ShortSHA(to.GitSHA)),
+		title:  fmt.Sprintf("Rolling %s back to %s", project.Name, git.ShortSHA(to.GitSHA)),
+		run: func(ctx context.Context, out io.Writer) error {
+			return actions.Rollback(ctx, project, to, out)
+		},
+	}
+}
+
+// startTask runs a confirmed action in the background.
+func (m *Model) startTask(conf *confirmation) tea.Cmd {
+	info, _ := m.selected()
+	t := &task{title: conf.title}
+	if info.Project != nil {
+		t.projectID = info.Project.ID
+	}
+
+	ctx := m.ctx
+	var cmd tea.Cmd
+	t.stream, cmd = startStream(func(w io.Writer) error { return conf.run(ctx, w) })
+	m.task = t
+	m.notice = ""
+	return tea.Batch(cmd, m.spinner.Tick)
+}
+
+// actionView shows the latest action on the project, then the open prompt
+// or picker and any notice.
+func (m *Model) actionView(project *state.Project) string {
+	var s string
+
+	if t := m.task; t != nil && t.projectID == project.ID {
+		switch {
+		case !t.done:
+			s += m.spinner.View() + " " + ValueStyle.Render(t.title) + "\n"
+		case t.err != nil:
+			s += StatusUnhealthy.Render("✗ "+t.title+" failed") + "\n"
+		default:
+			s += StatusHealthy.Render("✓ "+t.title+" done") + "\n"
+		}
+		for _, line := range t.lines {
+			s += HelpStyle.Padding(0).Render("  "+line) + "\n"
+		}
+		if t.err != nil {
+			s += ErrorStyle.Render(fmt.Sprintf("  Error: %v", t.err)) + "\n"
+		}
+		s += "\n"
+	}
+
+	switch {
+	case m.confirm != nil:
+		s += StatusStarting.Bold(true).Render(m.confirm.prompt+" [y/N]") + "\n"
+	case m.picker != nil:
+		s += m.picker.view()
+	}
+
+	if m.notice != "" {
+		s += HelpStyle.Padding(0).Render(m.notice) + "\n"
+	}
+	return s
+}
+
+// logViewportSize is the space left for log lines below the title and above the footer.
+func (m *Model) logViewportSize() (int, int) {
+	return max(m.width, 20), max(m.height-7, 5)
+}
@@ -0,0 +1,327 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeActions records the actions started from the model.
+type fakeActions struct {
+	mu       sync.Mutex
+	refs     []string
+	deployed string
+	rolledTo string
+	restart  string
+	err      error
+}
+
+func (f *fakeActions) Refs(ctx context.Context, project *state.Project) ([]string, error) {
+	return f.refs, nil
+}
+
+func (f *fakeActions) Deploy(ctx context.Context, project *state.Project, ref string, out io.Writer) error {
+	f.mu.Lock()
+	f.deployed = ref
+	f.mu.Unlock()
+	fmt.Fprintf(out, "Deploying %s\nBuilding...\n", ref)
+	return f.err
+}
+
+func (f *fakeActions) Rollback(ctx context.Context, project *state.Project, to *state.Deployment, out io.Writer) error {
+	f.mu.Lock()
+	f.rolledTo = to.GitSHA
+	f.mu.Unlock()
+	return f.err
+}
+
+func (f *fakeActions) Restart(ctx context.Context, project *state.Project, deployment *state.Deployment, service string, out io.Writer) error {
+	f.mu.Lock()
+	f.restart = service
+	f.mu.Unlock()
+	fmt.Fprint(out, "Restarted "+service)
+	return f.err
+}
+
+// detailModel returns a model showing the detail view of a running project.
+func detailModel(t *testing.T, actions Actions) Model {
+	t.Helper()
+	m := NewModel(context.Background(), nil, time.Second)
+	if actions != nil {
+		m = m.WithActions(actions)
+	}
+	m.projects = []ProjectInfo{{
+		Project:    &state.Project{ID: "p1", Name: "myapp", Status: "ready"},
+		Deployment: &state.Deployment{ID: "d3", GitSHA: "ccccccc3333333", Status: "active"},
+		Services: []compose.ServiceStatus{
+			{Name: "myapp-ccccccc-web-1", Service: "web", Status: "running"},
+			{Name: "myapp-ccccccc-worker-1", Service: "worker", Status: "running"},
+		},
+	}}
+	m.currentView = ViewDetail
+	return m
+}
+
+func press(t *testing.T, m Model, keys string) (Model, tea.Cmd) {
+	t.Helper()
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys)}
+	switch keys {
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	case "down":
+		msg = tea.KeyMsg{Type: tea.KeyDown}
+	}
+	updated, cmd := m.Update(msg)
+	return updated.(Model), cmd
+}
+
+// finishTask feeds the running task's output to the model until it is done.
+func finishTask(t *testing.T, m Model) Model {
+	t.Helper()
+	require.NotNil(t, m.task)
+	for !m.task.done {
+		updated, _ := m.Update(m.task.stream.next()())
+		m = updated.(Model)
+	}
+	return m
+}
+
+func TestModel_Deploy(t *testing.T) {
+	actions := &fakeActions{refs: []string{"main", "feature", "v1.1.0", "v1.0.0"}}
+	m := detailModel(t, actions)
+
+	// openPicker presses d and delivers the refs
+	openPicker := func(t *testing.T) Model {
+		m, cmd := press(t, m, "d")
+		require.NotNil(t, cmd)
+		assert.Equal(t, "Loading refs...", m.notice)
+
+		updated, _ := m.Update(cmd())
+		m = updated.(Model)
+		require.NotNil(t, m.picker, "ref picker should open")
+		assert.Len(t, m.picker.visible(), 4)
+		return m
+	}
+
+	t.Run("filter narrows the refs", func(t *testing.T) {
+		m := openPicker(t)
+		m, _ = press(t, m, "v1.1")
+		assert.Equal(t, []pickerItem{{Label: "v1.1.0", Value: "v1.1.0"}}, m.picker.visible())
+	})
+
+	t.Run("escape closes the picker", func(t *testing.T) {
+		m := openPicker(t)
+		m, _ = press(t, m, "esc")
+		assert.Nil(t, m.picker)
+		assert.Equal(t, ViewDetail, m.currentView)
+	})
+
+	t.Run("declining the prompt cancels", func(t *testing.T) {
+		m := openPicker(t)
+		m, _ = press(t, m, "enter")
+		require.NotNil(t, m.confirm)
+		m, cmd := press(t, m, "n")
+		assert.Nil(t, m.confirm)
+		assert.Nil(t, m.task)
+		assert.Nil(t, cmd)
+	})
+
+	t.Run("confirmed deploy shows progress", func(t *testing.T) {
+		m := openPicker(t)
+		m, _ = press(t, m, "down")
+		m, _ = press(t, m, "enter")
+		require.NotNil(t, m.confirm)
+		assert.Equal(t, "Deploy myapp at feature?", m.confirm.prompt)
+		assert.Contains(t, m.View(), "[y/N]")
+
+		m, cmd := press(t, m, "y")
+		require.NotNil(t, cmd)
+		require.NotNil(t, m.task)
+
+		m = finishTask(t, m)
+		assert.Equal(t, "feature", actions.deployed)
+		assert.NoError(t, m.task.err)
+		assert.Equal(t, []string{"Deploying feature", "Building..."}, m.task.lines)
+		assert.Contains(t, m.View(), "Deploying myapp at feature done")
+	})
+
+	t.Run("unlisted refs can be typed", func(t *testing.T) {
+		m := openPicker(t)
+		m, _ = press(t, m, "abc1234")
+		assert.Empty(t, m.picker.visible())
+		m, _ = press(t, m, "enter")
+		require.NotNil(t, m.confirm)
+		assert.Equal(t, "Deploy myapp at abc1234?", m.confirm.prompt)
+	})
+}
+
+func TestModel_Rollback(t *testing.T) {
+	actions := &fakeActions{}
+	m := detailModel(t, actions)
+	current := m.projects[0].Deployment
+
+	started := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	updated, _ := m.Update(candidatesMsg{
+		project: m.projects[0].Project,
+		current: current,
+		deployments: []*state.Deployment{
+			current,
+			{ID: "d2", GitSHA: "bbbbbbb2222222", GitRef: "main", Status: "failed", StartedAt: started},
+			{ID: "d1", GitSHA: "aaaaaaa1111111", GitRef: "main", Status: "inactive", Kind: "deploy", StartedAt: started},
+			{ID: "d0", GitSHA: "aaaaaaa1111111", GitRef: "main", Status: "rolled_back", Kind: "deploy", StartedAt: started},
+			{ID: "d-1", GitSHA: "ccccccc3333333", Status: "inactive", StartedAt: started},
+		},
+	})
+	m = updated.(Model)
+
+	require.NotNil(t, m.picker)
+	items := m.picker.visible()
+	require.Len(t, items, 1, "only earlier live commits are offered, once each")
+	assert.Equal(t, "d1", items[0].Value)
+	assert.Equal(t, "aaaaaaa main", items[0].Label)
+
+	m, _ = press(t, m, "enter")
+	require.NotNil(t, m.confirm)
+	assert.Equal(t, "Roll myapp back from ccccccc to aaaaaaa?", m.confirm.prompt)
+
+	m, _ = press(t, m, "y")
+	m = finishTask(t, m)
+	assert.Equal(t, "aaaaaaa1111111", actions.rolledTo)
+
+	t.Run("nothing to roll back to", func(t *testing.T) {
+		updated, _ := m.Update(candidatesMsg{project: m.projects[0].Project, current: current})
+		m := updated.(Model)
+		assert.Nil(t, m.picker)
+		assert.Equal(t, "No earlier deployment to roll back to", m.notice)
+	})
+}
+
+func TestModel_Restart(t *testing.T) {
+	actions := &fakeActions{err: fmt.Errorf("boom")}
+	m := detailModel(t, actions)
+
+	m, _ = press(t, m, "down")
+	assert.Equal(t, 1, m.service)
+	m, _ = press(t, m, "down")
+	assert.Equal(t, 1, m.service, "cursor stops at the last service")
+
+	m, _ = press(t, m, "R")
+	require.NotNil(t, m.confirm)
+	assert.Equal(t, "Restart service worker of myapp?", m.confirm.prompt)
+
+	m, _ = press(t, m, "y")
+
+	t.Run("quitting waits for the action", func(t *testing.T) {
+		m, cmd := press(t, m, "q")
+		assert.False(t, m.quitting)
+		assert.Nil(t, cmd)
+		assert.Contains(t, m.notice, "ctrl+c")
+	})
+
+	t.Run("no second action while one runs", func(t *testing.T) {
+		m, _ := press(t, m, "d")
+		assert.Equal(t, "Wait for the running action to finish", m.notice)
+	})
+
+	m = finishTask(t, m)
+	assert.Equal(t, "worker", actions.restart)
+	assert.EqualError(t, m.task.err, "boom")
+	assert.Equal(t, []string{"Restarted worker"}, m.task.lines, "unterminated output is flushed")
+	assert.Contains(t, m.View(), "Restarting worker failed")
+}
+
+func TestModel_WithoutActions(t *testing.T) {
+	m := detailModel(t, nil)
+
+	for _, k := range []string{"d", "b", "R"} {
+		m, cmd := press(t, m, k)
+		assert.Nil(t, cmd)
+		assert.Nil(t, m.confirm)
+		assert.Equal(t, "Actions are not available", m.notice)
+	}
+	assert.NotContains(t, m.View(), "[d] Deploy")
+}
+
+func TestModel_LogPane(t *testing.T) {
+	m := detailModel(t, nil)
+	m.height = 30
+	m.width = 80
+
+	l := &logPane{project: "myapp", service: "web", cancel: func() {}}
+	l.stream = &stream{ch: make(chan tea.Msg), quit: make(chan struct{})}
+	l.viewport.Width, l.viewport.Height = m.logViewportSize()
+	m.logs = l
+	m.currentView = ViewLogs
+
+	for i := range 50 {
+		updated, cmd := m.Update(streamLineMsg{src: l.stream, line: fmt.Sprintf("line %d", i)})
+		m = updated.(Model)
+		assert.NotNil(t, cmd, "more output is awaited")
+	}
+	assert.Len(t, m.logs.lines, 50)
+	assert.True(t, m.logs.viewport.AtBottom(), "new lines stay in view")
+	assert.Contains(t, m.View(), "line 49")
+	assert.Contains(t, m.View(), "following")
+
+	t.Run("stale output is ignored", func(t *testing.T) {
+		other := &stream{ch: make(chan tea.Msg), quit: make(chan struct{})}
+		updated, cmd := m.Update(streamLineMsg{src: other, line: "old"})
+		assert.Nil(t, cmd)
+		assert.Len(t, updated.(Model).logs.lines, 50)
+	})
+
+	m, _ = press(t, m, "esc")
+	assert.Nil(t, m.logs)
+	assert.Equal(t, ViewDetail, m.currentView)
+	select {
+	case <-l.stream.quit:
+	default:
+		t.Error("closing the pane should stop its stream")
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := &lineWriter{fn: func(line string) { lines = append(lines, line) }}
+
+	fmt.Fprint(w, "one\ntw")
+	fmt.Fprint(w, "o\r\nprogress 10%\rprogress 100%\nlast")
+	assert.Equal(t, []string{"one", "two", "progress 100%"}, lines)
+
+	w.Flush()
+	assert.Equal(t, []string{"one", "two", "progress 100%", "last"}, lines)
+}
+
+func TestPicker(t *testing.T) {
+	items := []pickerItem{{Label: "main", Value: "main"}, {Label: "Feature-X", Value: "feature-x"}}
+
+	p := newPicker("Pick:", items, false)
+	item, ok := p.selected()
+	require.True(t, ok)
+	assert.Equal(t, "main", item.Value)
+
+	p.update(tea.KeyMsg{Type: tea.KeyDown})
+	p.update(tea.KeyMsg{Type: tea.KeyDown})
+	item, _ = p.selected()
+	assert.Equal(t, "feature-x", item.Value, "cursor stops at the last item")
+
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("FEAT")})
+	assert.Equal(t, 0, p.cursor, "filtering resets the cursor")
+	assert.Len(t, p.visible(), 1, "filtering ignores case")
+
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("zzz")})
+	_, ok = p.selected()
+	assert.False(t, ok, "no custom choices unless enabled")
+	assert.Contains(t, p.view(), "No matches")
+}
@@ -0,0 +1,437 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jayteealao/otterstack/internal/git"
+	"github.com/jayteealao/otterstack/internal/projectconfig"
+	"github.com/jayteealao/otterstack/internal/state"
+)
+
+// historyLimit is the number of deployments listed in the history view.
+const historyLimit = 50
+
+// FormatDuration formats a duration in seconds below one minute and in minutes above.
+func FormatDuration(d time.Duration) string {
+	if d.Seconds() < 60 {
+		return fmt.Sprintf("%.0fs", d.Seconds())
+	}
+	return fmt.Sprintf("%.1fm", d.Minutes())
+}
+
+// FormatTrigger describes who started a deployment and how, e.g. "alice (cli)".
+func FormatTrigger(d *state.Deployment) string {
+	switch {
+	case d.TriggeredBy != "" && d.TriggerSource != "":
+		return fmt.Sprintf("%s (%s)", d.TriggeredBy, d.TriggerSource)
+	case d.TriggeredBy != "":
+		return d.TriggeredBy
+	case d.TriggerSource != "":
+		return d.TriggerSource
+	}
+	return "-"
+}
+
+// history lists the past deployments of a project, newest first. Two of
+// them can be marked to compare their commits and compose files.
+type history struct {
+	project     *state.Project
+	deployments []*state.Deployment
+	table       table.Model
+	marked      []string // IDs of up to two marked deployments, in marking order
+}
+
+type historyMsg struct {
+	project     *state.Project
+	deployments []*state.Deployment
+	err         error
+}
+
+func newHistory(project *state.Project, deployments []*state.Deployment, width, height int) *history {
+	t := table.New(
+		table.WithColumns([]table.Column{
+			{Title: " ", Width: 1},
+			{Title: "STARTED", Width: 16},
+			{Title: "COMMIT", Width: 7},
+			{Title: "REF", Width: 12},
+			{Title: "KIND", Width: 8},
+			{Title: "STATUS", Width: 13},
+			{Title: "DURATION", Width: 8},
+			{Title: "ERROR", Width: 30},
+		}),
+		table.WithFocused(true),
+	)
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(ColorMuted).
+		BorderBottom(true).
+		Bold(true)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("15")).
+		Background(ColorPrimary).
+		Bold(false)
+	t.SetStyles(s)
+
+	h := &history{project: project, deployments: deployments, table: t}
+	h.resize(width, height)
+	h.updateRows()
+	return h
+}
+
+func (h *history) resize(width, height int) {
+	h.table.SetWidth(max(width-4, 40))
+	h.table.SetHeight(max(height-14, 5))
+}
+
+// reload replaces the deployments, keeping marks on those still listed.
+func (h *history) reload(deployments []*state.Deployment) {
+	listed := make(map[string]bool, len(deployments))
+	for _, d := range deployments {
+		listed[d.ID] = true
+	}
+	var marked []string
+	for _, id := range h.marked {
+		if listed[id] {
+			marked = append(marked, id)
+		}
+	}
+	h.deployments, h.marked = deployments, marked
+	h.updateRows()
+}
+
+func (h *history) updateRows() {
+	rows := make([]table.Row, len(h.deployments))
+	for i, d := range h.deployments {
+		mark := ""
+		if h.isMarked(d.ID) {
+			mark = "*"
+		}
+		ref := d.GitRef
+		if ref == "" {
+			ref = "-"
+		}
+		duration := "-"
+		if d.FinishedAt != nil {
+			duration = FormatDuration(d.FinishedAt.Sub(d.StartedAt))
+		}
+		rows[i] = table.Row{
+			mark,
+			d.StartedAt.Format("2006-01-02 15:04"),
+			git.ShortSHA(d.GitSHA),
+			ref,
+			d.Kind,
+			GetStatusIcon(d.Status) + " " + d.Status,
+			duration,
+			firstLine(d.ErrorMessage),
+		}
+	}
+	h.table.SetRows(rows)
+}
+
+func (h *history) isMarked(id string) bool {
+	for _, m := range h.marked {
+		if m == id {
+			return true
+		}
+	}
+	return false
+}
+
+// selected returns the deployment under the cursor.
+func (h *history) selected() *state.Deployment {
+	i := h.table.Cursor()
+	if i < 0 || i >= len(h.deployments) {
+		return nil
+	}
+	return h.deployments[i]
+}
+
+// toggleMark marks or unmarks the deployment under the cursor. Marking a
+// third deployment drops the earliest mark.
+func (h *history) toggleMark() {
+	d := h.selected()
+	if d == nil {
+		return
+	}
+	if h.isMarked(d.ID) {
+		var marked []string
+		for _, id := range h.marked {
+			if id != d.ID {
+				marked = append(marked, id)
+			}
+		}
+		h.marked = marked
+	} else {
+		h.marked = append(h.marked, d.ID)
+		if len(h.marked) > 2 {
+			h.marked = h.marked[1:]
+		}
+	}
+	h.updateRows()
+}
+
+// pair returns the two marked deployments, older first.
+func (h *history) pair() (from, to *state.Deployment, ok bool) {
+	if len(h.marked) != 2 {
+		return nil, nil, false
+	}
+	for _, d := range h.deployments {
+		switch d.ID {
+		case h.marked[0], h.marked[1]:
+			// Deployments are listed newest first
+			if to == nil {
+				to = d
+			} else {
+				from = d
+			}
+		}
+	}
+	return from, to, from != nil && to != nil
+}
+
+func (h *history) view() string {
+	s := TitleStyle.Render(fmt.Sprintf("History: %s", h.project.Name)) + "\n\n"
+	if len(h.deployments) == 0 {
+		return s + HelpStyle.Padding(0).Render("No deployments yet") + "\n\n"
+	}
+	s += h.table.View() + "\n"
+
+	if d := h.selected(); d != nil {
+		s += LabelStyle.Render("Triggered:") + ValueStyle.Render(FormatTrigger(d)) + "\n"
+		if d.ErrorMessage != "" {
+			s += LabelStyle.Render("Error:") + ErrorStyle.Render(firstLine(d.ErrorMessage)) + "\n"
+		}
+	}
+	return s + "\n"
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+func (m Model) loadHistory(project *state.Project) tea.Cmd {
+	return func() tea.Msg {
+		deployments, err := m.store.ListDeployments(m.ctx, project.ID, historyLimit)
+		return historyMsg{project: project, deployments: deployments, err: err}
+	}
+}
+
+// openHistory shows the deployments of the project in the detail view, or
+// refreshes the history already shown.
+func (m Model) openHistory(msg historyMsg) Model {
+	m.notice = ""
+	if msg.err != nil {
+		m.notice = fmt.Sprintf("Failed to list deployments: %v", msg.err)
+		return m
+	}
+	if m.history != nil && m.history.project.ID == msg.project.ID {
+		m.history.reload(msg.deployments)
+		return m
+	}
+	if info, ok := m.selected(); !ok || info.Project.ID != msg.project.ID || m.currentView != ViewDetail {
+		return m
+	}
+	m.history = newHistory(msg.project, msg.deployments, m.width, m.height)
+	m.currentView = ViewHistory
+	return m
+}
+
+// handleHistoryKey marks deployments, compares the marked pair and rolls
+// back to the deployment under the cursor.
+func (m Model) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	h := m.history
+	switch {
+	case key.Matches(msg, keys.Back):
+		m.history = nil
+		m.currentView = ViewDetail
+		m.notice = ""
+
+	case key.Matches(msg, keys.Refresh):
+		return m, tea.Batch(m.loadProjects(), m.loadHistory(h.project))
+
+	case key.Matches(msg, keys.Mark):
+		h.toggleMark()
+
+	case key.Matches(msg, keys.Enter):
+		from, to, ok := h.pair()
+		if !ok {
+			m.notice = "Mark two deployments with space to compare them"
+			break
+		}
+		m.notice = "Loading changes..."
+		return m, loadDiff(m.ctx, h.project, from, to)
+
+	case key.Matches(msg, keys.Rollback):
+		if !m.canStart() {
+			break
+		}
+		info, _ := m.selected()
+		to := h.selected()
+		switch {
+		case to == nil:
+		case info.Deployment == nil:
+			m.notice = "No active deployment to roll back from"
+		case to.GitSHA == info.Deployment.GitSHA:
+			m.notice = fmt.Sprintf("%s is already running", git.ShortSHA(to.GitSHA))
+		case !wasLive(to):
+			m.notice = fmt.Sprintf("Only deployments that were live can be rolled back to (this one is %s)", to.Status)
+		default:
+			m.confirm = m.rollbackPrompt(h.project, info.Deployment, to)
+		}
+
+	default:
+		var cmd tea.Cmd
+		h.table, cmd = h.table.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m *Model) historyView() string {
+	s := m.history.view()
+	s += m.actionView(m.history.project)
+
+	footer := "[↑↓] Navigate  [Space] Mark  [Enter] Compare marked  [Esc] Back  [r] Refresh  [q] Quit"
+	switch {
+	case m.confirm != nil:
+		footer = "[y] Confirm  [any other key] Cancel"
+	case m.actions != nil:
+		footer = "[↑↓] Navigate  [Space] Mark  [Enter] Compare marked  [b] Rollback  [Esc] Back  [r] Refresh  [q] Quit"
+	}
+	return s + HelpStyle.Render(footer)
+}
+
+// diffView compares two deployments of a project: the commits between them
+// and the changes to its compose files and otterstack.yaml.
+type diffView struct {
+	from, to *state.Deployment
+	viewport viewport.Model
+}
+
+type diffMsg struct {
+	from, to *state.Deployment
+	added    []git.Commit // Commits in to but not in from
+	removed  []git.Commit // Commits in from but not in to, e.g. after a rollback
+	files    []string
+	diff     string
+	err      error
+}
+
+// diffFiles lists the files compared between deployments.
+func diffFiles(project *state.Project) []string {
+	var files []string
+	for _, f := range strings.Split(project.ComposeFile, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	return append(files, projectconfig.FileName)
+}
+
+func loadDiff(ctx context.Context, project *state.Project, from, to *state.Deployment) tea.Cmd {
+	return func() tea.Msg {
+		msg := diffMsg{from: from, to: to, files: diffFiles(project)}
+		if from.GitSHA == to.GitSHA {
+			return msg
+		}
+
+		gitMgr := git.NewManager(project.RepoPath)
+		if msg.added, msg.err = gitMgr.Log(ctx, from.GitSHA, to.GitSHA); msg.err != nil {
+			return msg
+		}
+		if msg.removed, msg.err = gitMgr.Log(ctx, to.GitSHA, from.GitSHA); msg.err != nil {
+			return msg
+		}
+		msg.diff, msg.err = gitMgr.Diff(ctx, from.GitSHA, to.GitSHA, msg.files...)
+		return msg
+	}
+}
+
+func newDiffView(msg diffMsg, width, height int) *diffView {
+	v := &diffView{from: msg.from, to: msg.to, viewport: viewport.New(width, height)}
+	v.viewport.SetContent(renderDiff(msg))
+	return v
+}
+
+var (
+	diffAdded   = lipgloss.NewStyle().Foreground(ColorSuccess)
+	diffRemoved = lipgloss.NewStyle().Foreground(ColorDanger)
+	diffHunk    = lipgloss.NewStyle().Foreground(ColorPrimary)
+)
+
+func renderDiff(msg diffMsg) string {
+	describe := func(d *state.Deployment) string {
+		s := git.ShortSHA(d.GitSHA)
+		if d.GitRef != "" {
+			s += " " + d.GitRef
+		}
+		return fmt.Sprintf("%s  %s  (%s, %s)", s, d.StartedAt.Format("2006-01-02 15:04"), d.Kind, d.Status)
+	}
+
+	var b strings.Builder
+	b.WriteString(LabelStyle.Render("From:") + describe(msg.from) + "\n")
+	b.WriteString(LabelStyle.Render("To:") + describe(msg.to) + "\n\n")
+
+	if msg.err != nil {
+		b.WriteString(ErrorStyle.Render(fmt.Sprintf("Error: %v", msg.err)) + "\n")
+		return b.String()
+	}
+	if msg.from.GitSHA == msg.to.GitSHA {
+		b.WriteString("Both deployments ran the same commit.\n")
+		return b.String()
+	}
+
+	writeCommits := func(title string, commits []git.Commit, style lipgloss.Style, sign string) {
+		if len(commits) == 0 {
+			return
+		}
+		b.WriteString(LabelStyle.Width(0).Render(fmt.Sprintf("%s (%d):", title, len(commits))) + "\n")
+		for _, c := range commits {
+			b.WriteString(fmt.Sprintf("  %s %s %s  %s\n",
+				style.Render(sign),
+				ValueStyle.Render(git.ShortSHA(c.SHA)),
+				c.Subject,
+				HelpStyle.Padding(0).Render(c.Author+", "+c.Date.Format("2006-01-02"))))
+		}
+		b.WriteString("\n")
+	}
+	writeCommits("Commits added", msg.added, diffAdded, "+")
+	writeCommits("Commits removed", msg.removed, diffRemoved, "-")
+
+	if strings.TrimSpace(msg.diff) == "" {
+		b.WriteString(fmt.Sprintf("No changes to %s\n", strings.Join(msg.files, ", ")))
+		return b.String()
+	}
+	b.WriteString(LabelStyle.Width(0).Render("Configuration changes:") + "\n")
+	for _, line := range strings.Split(strings.TrimRight(msg.diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "):
+			line = ValueStyle.Bold(true).Render(line)
+		case strings.HasPrefix(line, "+"):
+			line = diffAdded.Render(line)
+		case strings.HasPrefix(line, "-"):
+			line = diffRemoved.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			line = diffHunk.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func (v *diffView) view() string {
+	s := TitleStyle.Render(fmt.Sprintf("Compare %s → %s", git.ShortSHA(v.from.GitSHA), git.ShortSHA(v.to.GitSHA))) + "\n\n"
+	s += v.viewport.View() + "\n"
+	s += HelpStyle.Render("[↑↓/PgUp/PgDn] Scroll  [Esc] Back  [q] Quit")
+	return s
+}
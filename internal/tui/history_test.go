@@ -0,0 +1,192 @@
+package tui
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "0s", FormatDuration(200*time.Millisecond))
+	assert.Equal(t, "45s", FormatDuration(45*time.Second))
+	assert.Equal(t, "1.5m", FormatDuration(90*time.Second))
+}
+
+func TestFormatTrigger(t *testing.T) {
+	assert.Equal(t, "alice (cli)", FormatTrigger(&state.Deployment{TriggerSource: "cli", TriggeredBy: "alice"}))
+	assert.Equal(t, "rollback", FormatTrigger(&state.Deployment{TriggerSource: "rollback"}))
+	assert.Equal(t, "-", FormatTrigger(&state.Deployment{}))
+}
+
+// historyRepo creates a repository with two commits that change the compose
+// file and returns its path and the commits, oldest first.
+func historyRepo(t *testing.T) (string, []string) {
+	t.Helper()
+	repo := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.email=test@test.com", "-c", "user.name=Test"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return strings.TrimSpace(string(output))
+	}
+	run("init")
+
+	var shas []string
+	for i, image := range []string{"nginx:1", "nginx:2"} {
+		compose := "services:\n  web:\n    image: " + image + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "compose.yaml"), []byte(compose), 0644))
+		run("add", ".")
+		run("commit", "-m", []string{"Initial release", "Upgrade nginx"}[i])
+		shas = append(shas, run("rev-parse", "HEAD"))
+	}
+	return repo, shas
+}
+
+func TestModel_History(t *testing.T) {
+	repo, shas := historyRepo(t)
+	actions := &fakeActions{}
+	m := detailModel(t, actions)
+	project := m.projects[0].Project
+	project.RepoPath = repo
+	project.ComposeFile = "compose.yaml"
+	m.projects[0].Deployment.GitSHA = shas[1]
+	m.width, m.height = 160, 40
+
+	finished := time.Date(2026, 10, 2, 12, 1, 30, 0, time.UTC)
+	deployments := []*state.Deployment{
+		{ID: "d3", GitSHA: shas[1], GitRef: "main", Status: "active", Kind: "deploy",
+			StartedAt: time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC), FinishedAt: &finished, TriggeredBy: "alice", TriggerSource: "cli"},
+		{ID: "d2", GitSHA: "bbbbbbb2222222", GitRef: "main", Status: "failed", Kind: "deploy", ErrorMessage: "health check failed\ndetails",
+			StartedAt: time.Date(2026, 10, 1, 13, 0, 0, 0, time.UTC)},
+		{ID: "d1", GitSHA: shas[0], GitRef: "main", Status: "inactive", Kind: "deploy",
+			StartedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)},
+	}
+
+	m, _ = press(t, m, "h")
+	assert.Equal(t, "Loading history...", m.notice)
+
+	updated, _ := m.Update(historyMsg{project: project, deployments: deployments})
+	m = updated.(Model)
+	require.Equal(t, ViewHistory, m.currentView)
+	require.NotNil(t, m.history)
+
+	view := m.View()
+	assert.Contains(t, view, "History: myapp")
+	assert.Contains(t, view, "1.5m", "duration of finished deployments")
+	assert.Contains(t, view, "health check failed")
+	assert.NotContains(t, view, "details", "only the first line of errors")
+	assert.Contains(t, view, "alice (cli)")
+
+	t.Run("compare needs two marks", func(t *testing.T) {
+		m, cmd := press(t, m, "enter")
+		assert.Nil(t, cmd)
+		assert.Contains(t, m.notice, "Mark two deployments")
+	})
+
+	t.Run("rollback only to deployments that were live", func(t *testing.T) {
+		m, _ := press(t, m, "b")
+		assert.Contains(t, m.notice, "already running")
+
+		m, _ = press(t, m, "down")
+		m, _ = press(t, m, "b")
+		assert.Contains(t, m.notice, "were live")
+		assert.Nil(t, m.confirm)
+
+		m, _ = press(t, m, "down")
+		m, _ = press(t, m, "b")
+		require.NotNil(t, m.confirm)
+		assert.Contains(t, m.View(), "Roll myapp back from")
+
+		m, _ = press(t, m, "y")
+		m = finishTask(t, m)
+		assert.Equal(t, shas[0], actions.rolledTo)
+		assert.Equal(t, ViewHistory, m.currentView, "history stays open")
+	})
+
+	t.Run("marked deployments are compared oldest first", func(t *testing.T) {
+		hm := m
+		hm.history = newHistory(project, deployments, hm.width, hm.height)
+
+		hm, _ = press(t, hm, " ")
+		hm, _ = press(t, hm, "down")
+		hm, _ = press(t, hm, "down")
+		hm, _ = press(t, hm, " ")
+		assert.Equal(t, []string{"d3", "d1"}, hm.history.marked)
+
+		hm, cmd := press(t, hm, "enter")
+		require.NotNil(t, cmd)
+		updated, _ := hm.Update(cmd())
+		hm = updated.(Model)
+		require.Equal(t, ViewDiff, hm.currentView)
+
+		hm.diff.viewport.Height = 100
+		view := hm.View()
+		assert.Contains(t, view, "Compare "+shas[0][:7]+" → "+shas[1][:7])
+		assert.Contains(t, view, "Commits added (1)")
+		assert.Contains(t, view, "Upgrade nginx")
+		assert.Contains(t, view, "+    image: nginx:2")
+		assert.Contains(t, view, "-    image: nginx:1")
+		assert.NotContains(t, view, "Commits removed")
+
+		hm, _ = press(t, hm, "esc")
+		assert.Equal(t, ViewHistory, hm.currentView)
+		assert.Nil(t, hm.diff)
+	})
+
+	t.Run("escape returns to the detail view", func(t *testing.T) {
+		m, _ := press(t, m, "esc")
+		assert.Equal(t, ViewDetail, m.currentView)
+		assert.Nil(t, m.history)
+	})
+}
+
+func TestHistory_Marks(t *testing.T) {
+	deployments := []*state.Deployment{{ID: "c"}, {ID: "b"}, {ID: "a"}}
+	h := newHistory(&state.Project{Name: "myapp"}, deployments, 0, 0)
+
+	down := tea.KeyMsg{Type: tea.KeyDown}
+	for range 3 {
+		h.toggleMark()
+		h.table, _ = h.table.Update(down)
+	}
+	assert.Equal(t, []string{"b", "a"}, h.marked, "a third mark drops the earliest")
+
+	from, to, ok := h.pair()
+	require.True(t, ok)
+	assert.Equal(t, "a", from.ID)
+	assert.Equal(t, "b", to.ID)
+
+	h.toggleMark() // Cursor is on the last row
+	assert.Equal(t, []string{"b"}, h.marked, "marking again unmarks")
+
+	h.reload([]*state.Deployment{{ID: "d"}, {ID: "c"}})
+	assert.Empty(t, h.marked, "marks on deployments no longer listed are dropped")
+}
+
+func TestRenderDiff(t *testing.T) {
+	d := &state.Deployment{GitSHA: "abcdef1234", Kind: "deploy", Status: "inactive"}
+
+	t.Run("same commit", func(t *testing.T) {
+		out := renderDiff(diffMsg{from: d, to: d})
+		assert.Contains(t, out, "same commit")
+	})
+
+	t.Run("no configuration changes", func(t *testing.T) {
+		to := &state.Deployment{GitSHA: "1234567890", Kind: "rollback", Status: "active"}
+		out := renderDiff(diffMsg{from: d, to: to, files: []string{"compose.yaml", "otterstack.yaml"}})
+		assert.Contains(t, out, "No changes to compose.yaml, otterstack.yaml")
+	})
+
+	t.Run("diff files include otterstack.yaml", func(t *testing.T) {
+		files := diffFiles(&state.Project{ComposeFile: "compose.yaml, compose.prod.yaml"})
+		assert.Equal(t, []string{"compose.yaml", "compose.prod.yaml", "otterstack.yaml"}, files)
+	})
+}
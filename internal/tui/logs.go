@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/target"
+)
+
+const (
+	// logTail is the number of earlier lines shown when a log pane opens.
+	logTail = 200
+	// maxLogLines is the number of lines a log pane keeps for scrolling back.
+	maxLogLines = 5000
+)
+
+// logPane follows the logs of one service in a scrolling viewport. It stays
+// at the newest line unless scrolled up.
+type logPane struct {
+	project  string
+	service  string
+	viewport viewport.Model
+	lines    []string
+	stream   *stream
+	cancel   context.CancelFunc
+	ended    bool
+	err      error
+}
+
+// openLogs starts following a service's logs on the host its deployment runs on.
+func openLogs(ctx context.Context, host target.Target, project, composeProject, service string, width, height int) (*logPane, tea.Cmd) {
+	ctx, cancel := context.WithCancel(target.With(ctx, host))
+	opts := compose.LogOptions{Service: service, Follow: true, Tail: logTail}
+
+	l := &logPane{
+		project:  project,
+		service:  service,
+		viewport: viewport.New(width, height),
+		cancel:   cancel,
+	}
+	var cmd tea.Cmd
+	l.stream, cmd = startStream(func(w io.Writer) error {
+		return compose.StreamProjectLogs(ctx, composeProject, opts, w)
+	})
+	return l, cmd
+}
+
+func (l *logPane) addLine(line string) {
+	following := l.viewport.AtBottom()
+
+	l.lines = append(l.lines, line)
+	if len(l.lines) > maxLogLines {
+		l.lines = l.lines[len(l.lines)-maxLogLines:]
+	}
+	l.viewport.SetContent(strings.Join(l.lines, "\n"))
+
+	if following {
+		l.viewport.GotoBottom()
+	}
+}
+
+func (l *logPane) resize(width, height int) {
+	l.viewport.Width = width
+	l.viewport.Height = height
+}
+
+// close stops following the logs.
+func (l *logPane) close() {
+	l.cancel()
+	l.stream.stop()
+}
+
+func (l *logPane) view() string {
+	s := TitleStyle.Render(fmt.Sprintf("Logs: %s (%s)", l.service, l.project)) + "\n\n"
+	s += l.viewport.View() + "\n"
+
+	state := "following"
+	switch {
+	case l.err != nil:
+		s += ErrorStyle.Render(fmt.Sprintf("Error: %v", l.err)) + "\n"
+		state = "stopped"
+	case l.ended:
+		state = "ended"
+	case !l.viewport.AtBottom():
+		state = "paused"
+	}
+	s += HelpStyle.Render(fmt.Sprintf("[↑↓/PgUp/PgDn] Scroll  [Esc] Back  [q] Quit  |  %s", state))
+	return s
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -14,6 +15,7 @@ import (
 	apperrors "github.com/jayteealao/otterstack/internal/errors"
 	"github.com/jayteealao/otterstack/internal/git"
 	"github.com/jayteealao/otterstack/internal/state"
+	"github.com/jayteealao/otterstack/internal/target"
 )
 
 // View represents the current view.
@@ -22,6 +24,9 @@ type View int
 const (
 	ViewList View = iota
 	ViewDetail
+	ViewLogs
+	ViewHistory
+	ViewDiff
 )
 
 // ProjectInfo holds project information for display.
@@ -29,6 +34,8 @@ type ProjectInfo struct {
 	Project    *state.Project
 	Deployment *state.Deployment
 	Services   []compose.ServiceStatus
+	Stats      []compose.ContainerStats // Resource usage of the running containers
+	Host       target.Target            // Engine the deployment runs on
 	Error      error
 }
 
@@ -47,6 +54,20 @@ type Model struct {
 	lastRefresh   time.Time
 	err           error
 	quitting      bool
+
+	// Actions started from the detail view
+	actions Actions
+	service int                            // Service selected in the detail view
+	picker  *picker                        // Choice of ref or deployment being made
+	onPick  func(pickerItem) *confirmation // Prompt to show for the chosen item
+	confirm *confirmation                  // Prompt waiting for y or n
+	task    *task                          // Most recent action
+	logs    *logPane                       // Log pane shown in ViewLogs
+	history *history                       // Deployments shown in ViewHistory
+	diff    *diffView                      // Comparison shown in ViewDiff
+	usage   map[string]*usageHistory       // Recent resource usage by container
+	notice  string                         // One-line message in the detail view
+	spinner spinner.Model
 }
 
 // KeyMap defines the keybindings.
@@ -58,6 +79,14 @@ type KeyMap struct {
 	Refresh key.Binding
 	Quit    key.Binding
 	Help    key.Binding
+
+	Deploy   key.Binding
+	Rollback key.Binding
+	Restart  key.Binding
+	Logs     key.Binding
+	Confirm  key.Binding
+	History  key.Binding
+	Mark     key.Binding
 }
 
 var keys = KeyMap{
@@ -89,6 +118,34 @@ var keys = KeyMap{
 		key.WithKeys("?"),
 		key.WithHelp("?", "help"),
 	),
+	Deploy: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "deploy"),
+	),
+	Rollback: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "rollback"),
+	),
+	Restart: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "restart service"),
+	),
+	Logs: key.NewBinding(
+		key.WithKeys("l"),
+		key.WithHelp("l", "logs"),
+	),
+	Confirm: key.NewBinding(
+		key.WithKeys("y", "Y"),
+		key.WithHelp("y", "confirm"),
+	),
+	History: key.NewBinding(
+		key.WithKeys("h"),
+		key.WithHelp("h", "history"),
+	),
+	Mark: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "mark"),
+	),
 }
 
 // Messages
@@ -96,6 +153,19 @@ type tickMsg time.Time
 type refreshMsg []ProjectInfo
 type errMsg struct{ err error }
 
+type refsMsg struct {
+	project *state.Project
+	refs    []string
+	err     error
+}
+
+type candidatesMsg struct {
+	project     *state.Project
+	current     *state.Deployment
+	deployments []*state.Deployment
+	err         error
+}
+
 // NewModel creates a new TUI model.
 func NewModel(ctx context.Context, store *state.Store, refreshInterval time.Duration) Model {
 	ctx, cancel := context.WithCancel(ctx)
@@ -133,9 +203,17 @@ func NewModel(ctx context.Context, store *state.Store, refreshInterval time.Dura
 		table:         t,
 		currentView:   ViewList,
 		refreshTicker: refreshInterval,
+		spinner:       spinner.New(spinner.WithSpinner(spinner.Dot)),
 	}
 }
 
+// WithActions enables deploying, rolling back and restarting services from
+// the detail view. Without actions the dashboard is read-only, apart from logs.
+func (m Model) WithActions(actions Actions) Model {
+	m.actions = actions
+	return m
+}
+
 // Init implements tea.Model.
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
@@ -151,34 +229,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch {
-		case key.Matches(msg, keys.Quit):
-			m.cancel() // Cancel ongoing operations
-			m.quitting = true
-			return m, tea.Quit
-
-		case key.Matches(msg, keys.Refresh):
-			return m, m.loadProjects()
-
-		case key.Matches(msg, keys.Enter):
-			if m.currentView == ViewList && len(m.projects) > 0 {
-				m.selectedIndex = m.table.Cursor()
-				m.currentView = ViewDetail
-			}
-			return m, nil
-
-		case key.Matches(msg, keys.Back):
-			if m.currentView == ViewDetail {
-				m.currentView = ViewList
-			}
-			return m, nil
-		}
+		return m.handleKey(msg)
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		m.table.SetWidth(msg.Width - 4)
 		m.table.SetHeight(msg.Height - 10)
+		if m.logs != nil {
+			m.logs.resize(m.logViewportSize())
+		}
+		if m.history != nil {
+			m.history.resize(msg.Width, msg.Height)
+		}
+		if m.diff != nil {
+			m.diff.viewport.Width, m.diff.viewport.Height = m.logViewportSize()
+		}
 
 	case tickMsg:
 		return m, tea.Batch(m.loadProjects(), m.tick())
@@ -186,9 +252,64 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case refreshMsg:
 		m.projects = msg
 		m.lastRefresh = time.Now()
+		m.recordUsage(msg)
 		m.updateTable()
+		if info, ok := m.selected(); ok && m.service >= len(info.Services) {
+			m.service = max(len(info.Services)-1, 0)
+		}
+		return m, nil
+
+	case refsMsg:
+		return m.openRefPicker(msg), nil
+
+	case candidatesMsg:
+		return m.openRollbackPicker(msg), nil
+
+	case historyMsg:
+		return m.openHistory(msg), nil
+
+	case diffMsg:
+		m.notice = ""
+		if m.currentView == ViewHistory {
+			width, height := m.logViewportSize()
+			m.diff = newDiffView(msg, width, height)
+			m.currentView = ViewDiff
+		}
+		return m, nil
+
+	case streamLineMsg:
+		switch {
+		case m.task != nil && msg.src == m.task.stream:
+			m.task.addLine(msg.line)
+		case m.logs != nil && msg.src == m.logs.stream:
+			m.logs.addLine(msg.line)
+		default:
+			return m, nil // Output of a closed log pane
+		}
+		return m, msg.src.next()
+
+	case streamDoneMsg:
+		switch {
+		case m.task != nil && msg.src == m.task.stream:
+			m.task.done = true
+			m.task.err = msg.err
+			if m.history != nil {
+				return m, tea.Batch(m.loadProjects(), m.loadHistory(m.history.project))
+			}
+			return m, m.loadProjects()
+		case m.logs != nil && msg.src == m.logs.stream:
+			m.logs.ended = true
+			m.logs.err = msg.err
+		}
 		return m, nil
 
+	case spinner.TickMsg:
+		if m.task == nil || m.task.done {
+			return m, nil
+		}
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
 	case errMsg:
 		m.err = msg.err
 		return m, nil
@@ -202,6 +323,118 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// handleKey routes a key press to the open prompt or picker, then to the
+// current view.
+func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "ctrl+c" {
+		return m.quit()
+	}
+
+	if m.confirm != nil {
+		conf := m.confirm
+		m.confirm = nil
+		if key.Matches(msg, keys.Confirm) {
+			return m, m.startTask(conf)
+		}
+		m.notice = "Cancelled"
+		return m, nil
+	}
+
+	if m.picker != nil {
+		switch msg.String() {
+		case "esc":
+			m.picker = nil
+			return m, nil
+		case "enter":
+			if item, ok := m.picker.selected(); ok {
+				m.confirm = m.onPick(item)
+				m.picker = nil
+			}
+			return m, nil
+		}
+		return m, m.picker.update(msg)
+	}
+
+	if key.Matches(msg, keys.Quit) {
+		if m.task != nil && !m.task.done {
+			m.notice = "An action is running; press ctrl+c to abort it and quit"
+			return m, nil
+		}
+		return m.quit()
+	}
+
+	switch m.currentView {
+	case ViewLogs:
+		if m.logs == nil {
+			break
+		}
+		if key.Matches(msg, keys.Back) {
+			m.logs.close()
+			m.logs = nil
+			m.currentView = ViewDetail
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.logs.viewport, cmd = m.logs.viewport.Update(msg)
+		return m, cmd
+
+	case ViewHistory:
+		if m.history != nil {
+			return m.handleHistoryKey(msg)
+		}
+
+	case ViewDiff:
+		if m.diff == nil {
+			break
+		}
+		if key.Matches(msg, keys.Back) {
+			m.diff = nil
+			m.currentView = ViewHistory
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.diff.viewport, cmd = m.diff.viewport.Update(msg)
+		return m, cmd
+	}
+
+	switch {
+	case key.Matches(msg, keys.Refresh):
+		return m, m.loadProjects()
+
+	case key.Matches(msg, keys.Enter):
+		if m.currentView == ViewList && len(m.projects) > 0 {
+			m.selectedIndex = m.table.Cursor()
+			m.currentView = ViewDetail
+			m.service = 0
+			m.notice = ""
+		}
+		return m, nil
+
+	case key.Matches(msg, keys.Back):
+		if m.currentView == ViewDetail {
+			m.currentView = ViewList
+		}
+		return m, nil
+	}
+
+	if m.currentView == ViewDetail {
+		return m.handleDetailKey(msg)
+	}
+
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m Model) quit() (tea.Model, tea.Cmd) {
+	m.cancel() // Cancel ongoing operations
+	if m.logs != nil {
+		m.logs.close()
+	}
+	m.quitting = true
+	return m, tea.Quit
+}
+
 // View implements tea.Model.
 func (m Model) View() string {
 	if m.quitting {
@@ -215,6 +448,21 @@ func (m Model) View() string {
 	switch m.currentView {
 	case ViewDetail:
 		return m.detailView()
+	case ViewLogs:
+		if m.logs != nil {
+			return m.logs.view()
+		}
+		return m.detailView()
+	case ViewHistory:
+		if m.history != nil {
+			return m.historyView()
+		}
+		return m.detailView()
+	case ViewDiff:
+		if m.diff != nil {
+			return m.diff.view()
+		}
+		return m.detailView()
 	default:
 		return m.listView()
 	}
@@ -281,26 +529,44 @@ func (m *Model) detailView() string {
 	// Services
 	if len(info.Services) > 0 {
 		s += LabelStyle.Render("Services:") + "\n"
-		for _, svc := range info.Services {
+		for i, svc := range info.Services {
 			icon := GetStatusIcon(svc.Status)
 			style := GetStatusStyle(svc.Status)
 			health := ""
 			if svc.Health != "" {
 				health = fmt.Sprintf(" (%s)", svc.Health)
 			}
-			s += fmt.Sprintf("  %s %s %s%s\n",
+			cursor := " "
+			if i == m.service {
+				cursor = lipgloss.NewStyle().Foreground(ColorPrimary).Render(">")
+			}
+			s += fmt.Sprintf("%s %s %s %s%s\n",
+				cursor,
 				style.Render(icon),
 				ValueStyle.Render(svc.Name),
 				style.Render(svc.Status),
 				health,
 			)
+			s += m.usageView(info, svc.Name)
 		}
 		s += "\n"
 	}
 
+	s += m.actionView(project)
+
 	// Footer
-	footer := HelpStyle.Render("[Esc] Back  [r] Refresh  [q] Quit")
-	s += footer
+	var footer string
+	switch {
+	case m.confirm != nil:
+		footer = "[y] Confirm  [any other key] Cancel"
+	case m.picker != nil:
+		footer = "[↑↓] Choose  [Enter] Select  [Esc] Cancel"
+	case m.actions != nil:
+		footer = "[↑↓] Service  [d] Deploy  [b] Rollback  [R] Restart  [l] Logs  [h] History  [Esc] Back  [r] Refresh  [q] Quit"
+	default:
+		footer = "[↑↓] Service  [l] Logs  [h] History  [Esc] Back  [r] Refresh  [q] Quit"
+	}
+	s += HelpStyle.Render(footer)
 
 	return s
 }
@@ -370,10 +636,17 @@ func (m Model) loadProjects() tea.Cmd {
 			} else if deployment != nil {
 				info.Deployment = deployment
 
-				// Get service status
-				projectName := compose.GenerateProjectName(p.Name, git.ShortSHA(deployment.GitSHA))
-				services, _ := compose.GetProjectStatus(ctx, projectName)
-				info.Services = services
+				// Get service status from the host the deployment runs on
+				host, err := state.DeploymentTarget(ctx, m.store, deployment)
+				if err != nil {
+					info.Error = err
+				} else {
+					projectName := compose.GenerateProjectName(p.Name, git.ShortSHA(deployment.GitSHA))
+					services, _ := compose.GetProjectStatus(target.With(ctx, host), projectName)
+					info.Services = services
+					info.Stats, _ = compose.GetProjectStats(target.With(ctx, host), projectName)
+					info.Host = host
+				}
 			}
 
 			infos[i] = info
@@ -0,0 +1,127 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pickerItem is one choice in a picker.
+type pickerItem struct {
+	Label  string // Shown and matched against the filter
+	Detail string // Shown dimmed after the label
+	Value  string
+}
+
+// picker is a filterable single-choice list, used to choose a ref to deploy
+// or a deployment to roll back to.
+type picker struct {
+	title  string
+	items  []pickerItem
+	filter textinput.Model
+	cursor int
+	// custom accepts the filter text as the choice when nothing matches it,
+	// so a commit SHA can be deployed without being listed.
+	custom bool
+}
+
+// maxPickerRows is the number of choices shown at once.
+const maxPickerRows = 10
+
+func newPicker(title string, items []pickerItem, custom bool) *picker {
+	filter := textinput.New()
+	filter.Prompt = "Filter: "
+	filter.Focus()
+	return &picker{title: title, items: items, filter: filter, custom: custom}
+}
+
+// visible returns the items whose label contains the filter text.
+func (p *picker) visible() []pickerItem {
+	query := strings.ToLower(strings.TrimSpace(p.filter.Value()))
+	if query == "" {
+		return p.items
+	}
+	var matches []pickerItem
+	for _, item := range p.items {
+		if strings.Contains(strings.ToLower(item.Label), query) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+// selected returns the item under the cursor, or the filter text if custom
+// choices are allowed and nothing matches.
+func (p *picker) selected() (pickerItem, bool) {
+	items := p.visible()
+	if len(items) == 0 {
+		text := strings.TrimSpace(p.filter.Value())
+		if p.custom && text != "" {
+			return pickerItem{Label: text, Value: text}, true
+		}
+		return pickerItem{}, false
+	}
+	return items[min(p.cursor, len(items)-1)], true
+}
+
+// update moves the cursor on up and down; other keys edit the filter.
+func (p *picker) update(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "up", "ctrl+p":
+		if p.cursor > 0 {
+			p.cursor--
+		}
+		return nil
+	case "down", "ctrl+n":
+		if p.cursor < len(p.visible())-1 {
+			p.cursor++
+		}
+		return nil
+	}
+
+	var cmd tea.Cmd
+	p.filter, cmd = p.filter.Update(msg)
+	p.cursor = 0
+	return cmd
+}
+
+func (p *picker) view() string {
+	s := LabelStyle.Width(0).Render(p.title) + "\n"
+	s += p.filter.View() + "\n"
+
+	items := p.visible()
+	if len(items) == 0 {
+		if p.custom && strings.TrimSpace(p.filter.Value()) != "" {
+			s += HelpStyle.Padding(0).Render(fmt.Sprintf("  Enter to use %q", strings.TrimSpace(p.filter.Value()))) + "\n"
+		} else {
+			s += HelpStyle.Padding(0).Render("  No matches") + "\n"
+		}
+		return s
+	}
+
+	// Keep the cursor in view
+	start := 0
+	if p.cursor >= maxPickerRows {
+		start = p.cursor - maxPickerRows + 1
+	}
+	end := min(start+maxPickerRows, len(items))
+
+	for i := start; i < end; i++ {
+		item := items[i]
+		label := "  " + item.Label
+		if i == p.cursor {
+			label = lipgloss.NewStyle().Foreground(ColorPrimary).Bold(true).Render("> " + item.Label)
+		}
+		if item.Detail != "" {
+			label += "  " + HelpStyle.Padding(0).Render(item.Detail)
+		}
+		s += label + "\n"
+	}
+	if len(items) > end {
+		s += HelpStyle.Padding(0).Render(fmt.Sprintf("  … %d more", len(items)-end)) + "\n"
+	}
+	return s
+}
@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jayteealao/otterstack/internal/compose"
+)
+
+// usageWarningStyle highlights memory near its limit and restarted containers.
+var usageWarningStyle = lipgloss.NewStyle().Foreground(ColorWarning)
+
+// maxUsageSamples is the number of refreshes shown in a sparkline.
+const maxUsageSamples = 30
+
+// sparkBlocks are the bars of a sparkline, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// FormatBytes formats a byte count with binary units, e.g. "12.5MiB".
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// FormatUptime formats how long a container has run, e.g. "3d4h", "2h15m" or "45s".
+func FormatUptime(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+}
+
+// FormatMemory formats memory usage against its limit, e.g. "12.5MiB / 128.0MiB (9.8%)".
+func FormatMemory(s compose.ContainerStats) string {
+	return fmt.Sprintf("%s / %s (%.1f%%)", FormatBytes(s.MemUsage), FormatBytes(s.MemLimit), s.MemPercent)
+}
+
+// sparkline renders values as bars scaled against ceiling.
+func sparkline(values []float64, ceiling float64) string {
+	if ceiling <= 0 {
+		ceiling = 1
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := int(v / ceiling * float64(len(sparkBlocks)-1))
+		b.WriteRune(sparkBlocks[min(max(i, 0), len(sparkBlocks)-1)])
+	}
+	return b.String()
+}
+
+// usageHistory holds the recent CPU and memory samples of one container.
+type usageHistory struct {
+	cpu []float64 // Percent of one CPU
+	mem []float64 // Percent of the memory limit
+}
+
+func (u *usageHistory) add(s compose.ContainerStats) {
+	u.cpu = appendSample(u.cpu, s.CPUPercent)
+	u.mem = appendSample(u.mem, s.MemPercent)
+}
+
+func appendSample(samples []float64, v float64) []float64 {
+	samples = append(samples, v)
+	if len(samples) > maxUsageSamples {
+		samples = samples[len(samples)-maxUsageSamples:]
+	}
+	return samples
+}
+
+// cpuSparkline scales CPU samples against their peak, since the share of a
+// CPU a container may use depends on its limit and the host.
+func (u *usageHistory) cpuSparkline() string {
+	peak := 1.0
+	for _, v := range u.cpu {
+		peak = max(peak, v)
+	}
+	return sparkline(u.cpu, peak)
+}
+
+func (u *usageHistory) memSparkline() string {
+	return sparkline(u.mem, 100)
+}
+
+// recordUsage adds the stats of a refresh to the usage history, keyed by
+// container name, and forgets containers that are gone.
+func (m *Model) recordUsage(projects []ProjectInfo) {
+	if m.usage == nil {
+		m.usage = make(map[string]*usageHistory)
+	}
+	seen := make(map[string]bool)
+	for _, info := range projects {
+		for _, s := range info.Stats {
+			seen[s.Name] = true
+			u := m.usage[s.Name]
+			if u == nil {
+				u = &usageHistory{}
+				m.usage[s.Name] = u
+			}
+			u.add(s)
+		}
+	}
+	for name := range m.usage {
+		if !seen[name] {
+			delete(m.usage, name)
+		}
+	}
+}
+
+// usageView renders the resource usage line shown below a service in the
+// detail view, or "" when no stats were collected for its container.
+func (m *Model) usageView(info ProjectInfo, container string) string {
+	var stats *compose.ContainerStats
+	for i := range info.Stats {
+		if info.Stats[i].Name == container {
+			stats = &info.Stats[i]
+			break
+		}
+	}
+	if stats == nil {
+		return ""
+	}
+
+	cpu, mem := "", ""
+	if u := m.usage[container]; u != nil {
+		cpu, mem = u.cpuSparkline()+" ", u.memSparkline()+" "
+	}
+	memStyle := ValueStyle
+	if stats.MemPercent >= 90 {
+		memStyle = usageWarningStyle
+	}
+	restartStyle := ValueStyle
+	if stats.RestartCount > 0 {
+		restartStyle = usageWarningStyle
+	}
+
+	return fmt.Sprintf("      %s %s%s  %s %s%s  %s %s  %s %s\n",
+		HelpStyle.Padding(0).Render("CPU"), cpu, ValueStyle.Render(fmt.Sprintf("%.1f%%", stats.CPUPercent)),
+		HelpStyle.Padding(0).Render("MEM"), mem, memStyle.Render(FormatMemory(*stats)),
+		HelpStyle.Padding(0).Render("restarts"), restartStyle.Render(fmt.Sprint(stats.RestartCount)),
+		HelpStyle.Padding(0).Render("up"), ValueStyle.Render(FormatUptime(stats.Uptime(time.Now()))),
+	)
+}
@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512B", FormatBytes(512))
+	assert.Equal(t, "1.5KiB", FormatBytes(1536))
+	assert.Equal(t, "128.0MiB", FormatBytes(128<<20))
+	assert.Equal(t, "2.0GiB", FormatBytes(2<<30))
+}
+
+func TestFormatUptime(t *testing.T) {
+	assert.Equal(t, "-", FormatUptime(0))
+	assert.Equal(t, "45s", FormatUptime(45*time.Second))
+	assert.Equal(t, "12m", FormatUptime(12*time.Minute+30*time.Second))
+	assert.Equal(t, "2h15m", FormatUptime(2*time.Hour+15*time.Minute))
+	assert.Equal(t, "3d4h", FormatUptime(76*time.Hour))
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", sparkline([]float64{0, 50, 100}, 100))
+	assert.Equal(t, "█", sparkline([]float64{250}, 100), "values above the ceiling are clipped")
+	assert.Empty(t, sparkline(nil, 100))
+}
+
+func TestModel_Usage(t *testing.T) {
+	m := detailModel(t, nil)
+	stats := func(cpu, mem float64) []compose.ContainerStats {
+		return []compose.ContainerStats{{
+			Name: "myapp-ccccccc-web-1", Service: "web",
+			CPUPercent: cpu, MemPercent: mem, MemUsage: 120 << 20, MemLimit: 128 << 20,
+			RestartCount: 2, StartedAt: time.Now().Add(-2 * time.Hour),
+		}}
+	}
+
+	for i := range maxUsageSamples + 5 {
+		projects := append([]ProjectInfo(nil), m.projects...)
+		projects[0].Stats = stats(float64(i), 93.75)
+		updated, _ := m.Update(refreshMsg(projects))
+		m = updated.(Model)
+	}
+
+	u := m.usage["myapp-ccccccc-web-1"]
+	require.NotNil(t, u)
+	assert.Len(t, u.cpu, maxUsageSamples, "only recent samples are kept")
+	assert.Equal(t, float64(5), u.cpu[0])
+
+	view := m.View()
+	assert.Contains(t, view, "120.0MiB / 128.0MiB (93.8%)")
+	assert.Contains(t, view, "█", "sparklines are drawn")
+	assert.Contains(t, view, "2h0m")
+
+	t.Run("containers that are gone are forgotten", func(t *testing.T) {
+		projects := append([]ProjectInfo(nil), m.projects...)
+		projects[0].Stats = nil
+		updated, _ := m.Update(refreshMsg(projects))
+		assert.Empty(t, updated.(Model).usage)
+	})
+}
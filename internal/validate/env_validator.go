@@ -3,9 +3,11 @@ package validate
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/jayteealao/otterstack/internal/compose"
+	"github.com/jayteealao/otterstack/internal/envschema"
 )
 
 // ValidationResult represents the outcome of environment variable validation.
@@ -13,6 +15,13 @@ type ValidationResult struct {
 	AllPresent bool         // True if all required variables are present
 	Missing    []MissingVar // Variables that are missing and required
 	Optional   []MissingVar // Variables that are missing but have defaults
+	Invalid    []InvalidVar // Variables whose stored value violates the env schema
+}
+
+// Passed reports whether the deployment can proceed: every required variable is
+// present and every stored value satisfies the env schema.
+func (r *ValidationResult) Passed() bool {
+	return r.AllPresent && len(r.Invalid) == 0
 }
 
 // MissingVar represents an environment variable that is not set.
@@ -25,9 +34,26 @@ type MissingVar struct {
 	Services     []string // Which services need this variable
 }
 
+// InvalidVar represents a stored environment variable that violates the env schema.
+// The value itself is omitted so secrets never appear in output.
+type InvalidVar struct {
+	Name    string // Variable name
+	Message string // Which constraint the value violates
+}
+
 // ValidateEnvVars checks if all required environment variables are present.
 // It parses the compose file to find required variables and compares against stored variables.
 // Returns a ValidationResult indicating which variables are missing.
+//
+// A variable is required when it is explicitly marked (${VAR:?error}) or when any
+// service references it without a fallback; the Services of a required variable
+// are the services that would break without it. Variables provided by the .env
+// file next to the compose file are never reported.
+//
+// When the repository ships an env schema (see envschema.FileName) next to the
+// compose file, its declarations decide which variables are required, declared
+// defaults count as present, and stored values are checked against the declared
+// type, enum and pattern.
 func ValidateEnvVars(composeFilePath string, storedVars map[string]string) (*ValidationResult, error) {
 	// Parse compose file to get all variable references
 	requiredVars, err := compose.ParseEnvVars(composeFilePath)
@@ -35,6 +61,12 @@ func ValidateEnvVars(composeFilePath string, storedVars map[string]string) (*Val
 		return nil, fmt.Errorf("failed to parse compose file: %w", err)
 	}
 
+	schema, err := envschema.Load(filepath.Dir(composeFilePath))
+	if err != nil {
+		return nil, err
+	}
+	requiredVars = schema.Apply(requiredVars)
+
 	// Get missing variables
 	missingRefs := compose.GetMissingVars(requiredVars, storedVars)
 
@@ -51,24 +83,30 @@ func ValidateEnvVars(composeFilePath string, storedVars map[string]string) (*Val
 			Services:     ref.Locations,
 		}
 
-		if ref.IsRequired {
-			// Explicitly required with :? or ?
-			required = append(required, mv)
-		} else if ref.HasDefault {
-			// Has default value - optional
-			optional = append(optional, mv)
-		} else {
-			// Basic ${VAR} form without default - treat as required
-			// Docker Compose will use empty string but this is usually wrong
+		if ref.NeedsValue() {
+			// Explicitly required, or referenced somewhere without a fallback.
+			// Docker Compose would substitute an empty string, which is usually wrong.
 			mv.IsRequired = true
+			if services := ref.ServicesNeedingValue(); len(services) > 0 {
+				mv.Services = services
+			}
 			required = append(required, mv)
+		} else {
+			// Every reference has a fallback - optional
+			optional = append(optional, mv)
 		}
 	}
 
+	var invalid []InvalidVar
+	for _, v := range schema.Validate(storedVars) {
+		invalid = append(invalid, InvalidVar{Name: v.Name, Message: v.Message})
+	}
+
 	result := &ValidationResult{
 		AllPresent: len(required) == 0,
 		Missing:    required,
 		Optional:   optional,
+		Invalid:    invalid,
 	}
 
 	return result, nil
@@ -77,7 +115,7 @@ func ValidateEnvVars(composeFilePath string, storedVars map[string]string) (*Val
 // FormatValidationError creates a user-friendly error message with a checklist
 // of missing variables and clear instructions for how to fix them.
 func FormatValidationError(result *ValidationResult, projectName string) string {
-	if result.AllPresent {
+	if result.Passed() {
 		return ""
 	}
 
@@ -85,9 +123,22 @@ func FormatValidationError(result *ValidationResult, projectName string) string
 
 	// Header
 	lines = append(lines, "")
-	lines = append(lines, "Missing environment variables detected:")
+	if result.AllPresent {
+		lines = append(lines, "Invalid environment variables detected:")
+	} else {
+		lines = append(lines, "Missing environment variables detected:")
+	}
 	lines = append(lines, "")
 
+	// Schema violations
+	if len(result.Invalid) > 0 {
+		lines = append(lines, fmt.Sprintf("Invalid (violates %s):", envschema.FileName))
+		for _, v := range result.Invalid {
+			lines = append(lines, fmt.Sprintf("  ✗ %s: %s", v.Name, v.Message))
+		}
+		lines = append(lines, "")
+	}
+
 	// Required section
 	if len(result.Missing) > 0 {
 		lines = append(lines, "Required (deployment will fail):")
@@ -138,11 +189,14 @@ func FormatValidationError(result *ValidationResult, projectName string) string
 	lines = append(lines, "To fix:")
 	lines = append(lines, "")
 
-	if len(result.Missing) > 0 {
+	if len(result.Missing) > 0 || len(result.Invalid) > 0 {
 		lines = append(lines, "  Option 1: Set variables individually")
 		for _, v := range result.Missing {
 			lines = append(lines, fmt.Sprintf("    otterstack env set %s %s=<value>", projectName, v.Name))
 		}
+		for _, v := range result.Invalid {
+			lines = append(lines, fmt.Sprintf("    otterstack env set %s %s=<value>", projectName, v.Name))
+		}
 		lines = append(lines, "")
 	}
 
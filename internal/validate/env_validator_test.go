@@ -2,6 +2,7 @@ package validate
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -220,6 +221,36 @@ services:
 	assert.Contains(t, redisURL.Services, "cache")
 }
 
+// TestValidateEnvVars_PerServiceFallbacks tests that a default in one service doesn't hide a bare reference in another
+func TestValidateEnvVars_PerServiceFallbacks(t *testing.T) {
+	composeContent := `
+services:
+  web:
+    image: nginx
+    environment:
+      - API_KEY=${API_KEY}
+  worker:
+    image: worker
+    environment:
+      - API_KEY=${API_KEY:-dev-key}
+      - LOG_LEVEL=${LOG_LEVEL:-info}
+`
+
+	tmpfile := createTempComposeFile(t, composeContent)
+	defer os.Remove(tmpfile)
+
+	result, err := ValidateEnvVars(tmpfile, map[string]string{})
+	require.NoError(t, err)
+
+	assert.False(t, result.AllPresent)
+	require.Len(t, result.Missing, 1)
+	assert.Equal(t, "API_KEY", result.Missing[0].Name)
+	assert.Equal(t, []string{"web"}, result.Missing[0].Services)
+
+	require.Len(t, result.Optional, 1)
+	assert.Equal(t, "LOG_LEVEL", result.Optional[0].Name)
+}
+
 // TestValidateEnvVars_NoVariables tests compose file with no variables
 func TestValidateEnvVars_NoVariables(t *testing.T) {
 	composeContent := `
@@ -399,6 +430,74 @@ func TestFormatValidationWarning_EmptyDefault(t *testing.T) {
 	assert.Contains(t, message, "OPTIONAL_VAR will default to empty string")
 }
 
+// TestValidateEnvVars_Schema tests that the env schema next to the compose file is enforced
+func TestValidateEnvVars_Schema(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	require.NoError(t, os.WriteFile(composePath, []byte(`
+services:
+  web:
+    image: nginx
+    environment:
+      - API_URL=${API_URL:-http://localhost}
+      - PORT=${PORT}
+`), 0644))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".otterstack"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".otterstack", "env.schema.yaml"), []byte(`
+variables:
+  API_URL:
+    type: url
+    required: true
+  PORT:
+    type: port
+    default: "3000"
+  LOG_LEVEL:
+    enum: [info, debug]
+`), 0644))
+
+	t.Run("schema decides what is required", func(t *testing.T) {
+		result, err := ValidateEnvVars(composePath, map[string]string{})
+		require.NoError(t, err)
+
+		assert.False(t, result.AllPresent)
+		require.Len(t, result.Missing, 1)
+		assert.Equal(t, "API_URL", result.Missing[0].Name, "schema requirement overrides compose default")
+		assert.Equal(t, []string{"web"}, result.Missing[0].Services)
+
+		port := findMissingVar(result.Optional, "PORT")
+		require.NotNil(t, port, "schema default makes PORT optional")
+		assert.Equal(t, "3000", port.DefaultValue)
+	})
+
+	t.Run("stored values must satisfy the schema", func(t *testing.T) {
+		result, err := ValidateEnvVars(composePath, map[string]string{
+			"API_URL":   "https://api.example.com",
+			"PORT":      "99999",
+			"LOG_LEVEL": "trace",
+		})
+		require.NoError(t, err)
+
+		assert.True(t, result.AllPresent)
+		assert.False(t, result.Passed())
+		require.Len(t, result.Invalid, 2)
+		assert.Equal(t, "LOG_LEVEL", result.Invalid[0].Name)
+		assert.Equal(t, "PORT", result.Invalid[1].Name)
+
+		message := FormatValidationError(result, "myapp")
+		assert.Contains(t, message, "Invalid environment variables detected")
+		assert.Contains(t, message, "PORT: port must be between 1 and 65535")
+		assert.NotContains(t, message, "99999")
+	})
+
+	t.Run("invalid schema is an error", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".otterstack", "env.schema.yaml"), []byte("variables:\n  PORT:\n    type: float\n"), 0644))
+
+		_, err := ValidateEnvVars(composePath, map[string]string{})
+		assert.Error(t, err)
+	})
+}
+
 // Helper functions
 
 // createTempComposeFile creates a temporary compose file for testing
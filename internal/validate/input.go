@@ -69,6 +69,18 @@ func ProjectName(name string) error {
 	return nil
 }
 
+// HostName validates the name of a remote host. Host names follow the
+// project name rules; "local" is reserved for the local Docker engine.
+func HostName(name string) error {
+	if name == "local" {
+		return fmt.Errorf("%w: %q is reserved for the local engine", errors.ErrInvalidHostName, name)
+	}
+	if !projectNameRegexSingle.MatchString(name) && !projectNameRegexMulti.MatchString(name) {
+		return errors.ErrInvalidHostName
+	}
+	return nil
+}
+
 // GitRef validates a git reference (tag, branch, or SHA).
 func GitRef(ref string) error {
 	if ref == "" {
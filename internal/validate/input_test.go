@@ -52,6 +52,35 @@ func TestProjectName(t *testing.T) {
 	}
 }
 
+func TestHostName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"simple", "web1", false},
+		{"with hyphens", "eu-web-1", false},
+		{"single char", "a", false},
+
+		{"empty", "", true},
+		{"reserved local", "local", true},
+		{"uppercase", "Web1", true},
+		{"dots", "web1.example.com", true},
+		{"ends with hyphen", "web-", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := HostName(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestGitRef(t *testing.T) {
 	tests := []struct {
 		name    string
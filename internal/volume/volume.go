@@ -0,0 +1,231 @@
+// Package volume copies the contents of Docker named volumes in and out as
+// tar streams. The copy runs in a throwaway helper container, so it works the
+// same whatever volume driver or Docker root directory the host uses.
+package volume
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jayteealao/otterstack/internal/target"
+)
+
+// HelperImage is the image of the container that reads and writes volume contents.
+const HelperImage = "alpine:3"
+
+// Labels docker compose sets on the volumes it creates.
+const (
+	LabelComposeProject = "com.docker.compose.project"
+	LabelComposeVolume  = "com.docker.compose.volume"
+)
+
+var (
+	// namePattern matches Docker volume names.
+	namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+	// shortSHAPattern matches the commit suffix of deployment compose project names.
+	shortSHAPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+)
+
+// Volume is a Docker named volume created by docker compose.
+type Volume struct {
+	Name           string `json:"name"`
+	ComposeProject string `json:"compose_project,omitempty"` // e.g. "myapp-a1b2c3d"
+	ComposeVolume  string `json:"compose_volume,omitempty"`  // key under "volumes:" in the compose file
+}
+
+// ValidateName checks a volume name before it is passed to docker.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid volume name %q", name)
+	}
+	return nil
+}
+
+// ProjectVolumes returns the volumes created by the compose projects of an
+// OtterStack project, one per deployment commit unless the compose file pins
+// a volume name.
+func ProjectVolumes(ctx context.Context, projectName string) ([]Volume, error) {
+	cmd := target.Docker(ctx, "volume", "ls", "--format",
+		`{{.Name}}\t{{.Label "`+LabelComposeProject+`"}}\t{{.Label "`+LabelComposeVolume+`"}}`)
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("volume ls cancelled: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("volume ls failed: %w", err)
+	}
+
+	return parseVolumeList(string(output), projectName), nil
+}
+
+// parseVolumeList selects the volumes of projectName from "docker volume ls" output.
+func parseVolumeList(output, projectName string) []Volume {
+	var volumes []Volume
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		v := Volume{Name: fields[0], ComposeProject: fields[1]}
+		if len(fields) > 2 {
+			v.ComposeVolume = fields[2]
+		}
+		if BelongsTo(v.ComposeProject, projectName) {
+			volumes = append(volumes, v)
+		}
+	}
+
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+	return volumes
+}
+
+// BelongsTo reports whether a compose project name is one of the deployments
+// of an OtterStack project, i.e. "<project>-<short sha>", or the project itself.
+func BelongsTo(composeProject, projectName string) bool {
+	if composeProject == projectName {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(composeProject, projectName+"-")
+	return ok && shortSHAPattern.MatchString(suffix)
+}
+
+// Exists reports whether a volume exists.
+func Exists(ctx context.Context, name string) (bool, error) {
+	if err := ValidateName(name); err != nil {
+		return false, err
+	}
+
+	cmd := target.Docker(ctx, "volume", "ls", "--quiet", "--filter", "name=^"+name+"$")
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, fmt.Errorf("volume ls cancelled: %w", ctx.Err())
+		}
+		return false, fmt.Errorf("volume ls failed: %w", err)
+	}
+	return strings.TrimSpace(string(output)) == name, nil
+}
+
+// Export writes the contents of a volume to w as a tar stream.
+// The volume is mounted read-only, so it is safe to export while in use, but
+// files being written at the same time may be captured mid-write.
+func Export(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	cmd := target.Docker(ctx, "run", "--rm", "--network", "none",
+		"-v", name+":/volume:ro", HelperImage,
+		"tar", "-C", "/volume", "-cf", "-", ".")
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("export of volume %s cancelled: %w", name, ctx.Err())
+		}
+		return fmt.Errorf("export of volume %s failed: %w\n%s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Import replaces the contents of a volume with a tar stream read from r,
+// creating the volume with its compose labels if it does not exist.
+func Import(ctx context.Context, v Volume, r io.Reader) error {
+	if err := ValidateName(v.Name); err != nil {
+		return err
+	}
+
+	create := []string{"volume", "create"}
+	if v.ComposeProject != "" {
+		create = append(create, "--label", LabelComposeProject+"="+v.ComposeProject)
+	}
+	if v.ComposeVolume != "" {
+		create = append(create, "--label", LabelComposeVolume+"="+v.ComposeVolume)
+	}
+	create = append(create, v.Name)
+
+	exists, err := Exists(ctx, v.Name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if output, err := target.Docker(ctx, create...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w\n%s", v.Name, err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	cmd := target.Docker(ctx, "run", "--rm", "-i", "--network", "none",
+		"-v", v.Name+":/volume", HelperImage,
+		"sh", "-c", "find /volume -mindepth 1 -delete && tar -C /volume -xf -")
+	var stderr bytes.Buffer
+	cmd.Stdin = r
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("import of volume %s cancelled: %w", v.Name, ctx.Err())
+		}
+		return fmt.Errorf("import of volume %s failed: %w\n%s", v.Name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// InUse reports whether a running container mounts a volume.
+func InUse(ctx context.Context, name string) (bool, error) {
+	ids, err := runningContainers(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
+
+// Pause freezes the running containers that mount a volume, so an export made
+// meanwhile is a point-in-time copy. The returned function unpauses them and
+// must be called even if the export fails.
+func Pause(ctx context.Context, name string) (func(context.Context) error, error) {
+	ids, err := runningContainers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if output, err := target.Docker(ctx, append([]string{"pause"}, ids...)...).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to pause containers using volume %s: %w\n%s", name, err, strings.TrimSpace(string(output)))
+	}
+
+	return func(ctx context.Context) error {
+		if output, err := target.Docker(ctx, append([]string{"unpause"}, ids...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to unpause containers using volume %s: %w\n%s", name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}, nil
+}
+
+// runningContainers returns the IDs of the running containers that mount a volume.
+func runningContainers(ctx context.Context, name string) ([]string, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	cmd := target.Docker(ctx, "ps", "--quiet", "--filter", "volume="+name)
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("docker ps cancelled: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("docker ps failed: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
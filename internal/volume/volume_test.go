@@ -0,0 +1,54 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"myapp_data", "myapp-abc1234_db", "v1.data"} {
+		assert.NoError(t, ValidateName(name), name)
+	}
+	for _, name := range []string{"", "-flag", "../data", "a b", "a:/etc"} {
+		assert.Error(t, ValidateName(name), name)
+	}
+}
+
+func TestBelongsTo(t *testing.T) {
+	tests := []struct {
+		composeProject string
+		want           bool
+	}{
+		{"myapp", true},
+		{"myapp-abc1234", true},
+		{"myapp-abc1234def5678abc1234def5678abc1234d", true},
+		{"myapp-api-abc1234", false},
+		{"myapp-abc", false},
+		{"myapp-ABC1234", false},
+		{"other-abc1234", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.composeProject, func(t *testing.T) {
+			assert.Equal(t, tt.want, BelongsTo(tt.composeProject, "myapp"))
+		})
+	}
+}
+
+func TestParseVolumeList(t *testing.T) {
+	output := "myapp-def5678_db\tmyapp-def5678\tdb\n" +
+		"myapp-abc1234_db\tmyapp-abc1234\tdb\n" +
+		"other-abc1234_db\tother-abc1234\tdb\n" +
+		"0f3a9c\t\t\n" +
+		"shared_cache\tmyapp\tcache\n"
+
+	volumes := parseVolumeList(output, "myapp")
+
+	assert.Equal(t, []Volume{
+		{Name: "myapp-abc1234_db", ComposeProject: "myapp-abc1234", ComposeVolume: "db"},
+		{Name: "myapp-def5678_db", ComposeProject: "myapp-def5678", ComposeVolume: "db"},
+		{Name: "shared_cache", ComposeProject: "myapp", ComposeVolume: "cache"},
+	}, volumes)
+}